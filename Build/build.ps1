@@ -46,18 +46,19 @@ if (Test-Path -Path $nbloader_dll) {
 
 # 更新nbloader
 cd "${rootdir}/NetBeauty/src"
-go-bindata -o ./main/bindata.go ./nbloader/
+go-bindata -pkg beauty -o ./beauty/bindata.go ./nbloader/
 
-# 编译nbeauty
+# 编译nbeauty，版本与nupkg相同
+$version = ([xml](Get-Content "${rootdir}/Common/Common.props")).Project.PropertyGroup.Version
 cd "${rootdir}/NetBeauty"
 if ([System.Runtime.InteropServices.RuntimeInformation]::IsOSPlatform([System.Runtime.InteropServices.OSPlatform]::Windows)) {
-    pwsh "make.ps1"
+    pwsh "make.ps1" -Version $version
 } else {
-    make
+    make VERSION=$version
 }
 
 # 签名nbeauty
-pwsh "${builddir}/sign.ps1" -Certificate Auto -Algorithm SHA384 -TimeStampServer "http://timestamp.sectigo.com" "${tooldir}/win-x86/nbeauty2.exe" "${tooldir}/win-x64/nbeauty2.exe"
+pwsh "${builddir}/sign.ps1" -Certificate Auto -Algorithm SHA384 -TimeStampServer "http://timestamp.sectigo.com" "${tooldir}/win-x86/nbeauty2.exe" "${tooldir}/win-x64/nbeauty2.exe" "${tooldir}/win-arm64/nbeauty2.exe"
 
 # 编译NetBeautyNuget
 cd "${rootdir}/NetBeautyNuget"
@@ -69,7 +70,7 @@ dotnet pack -c Release /p:PackageOutputPath=${nupkgdir}
 
 # 打包nbeauty
 mkdir ${archivedir}
-"win-x86", "win-x64", "linux-x64", "osx-x64" | ForEach-Object -Process {
+"win-x86", "win-x64", "win-arm64", "linux-x64", "osx-x64", "linux-arm64", "osx-arm64" | ForEach-Object -Process {
     $rid = $_
     cd "${tooldir}/${rid}"
     Compress-Archive -Force -Path * -DestinationPath "${archivedir}/${rid}.zip"
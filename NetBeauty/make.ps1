@@ -1,9 +1,17 @@
+param(
+    # nbeauty的版本，默认取Common/Common.props中的<Version>
+    [string]$Version = ([xml](Get-Content "${PSScriptRoot}/../Common/Common.props")).Project.PropertyGroup.Version
+)
+
 $OUTPUT           = "../Build/tools"
 $BINARY_WIN_X86   = "win-x86/nbeauty2.exe"
 $BINARY_WIN_X64   = "win-x64/nbeauty2.exe"
+$BINARY_WIN_ARM64 = "win-arm64/nbeauty2.exe"
 $BINARY_LINUX_X64 = "linux-x64/nbeauty2"
 $BINARY_MAC_X64   = "osx-x64/nbeauty2"
-$BUILD_FLAGS      = '-ldflags="-s -w"'
+$BINARY_LINUX_ARM64 = "linux-arm64/nbeauty2"
+$BINARY_MAC_ARM64 = "osx-arm64/nbeauty2"
+$BUILD_FLAGS      = "-ldflags=`"-s -w -X github.com/nulastudio/NetBeauty/src/beauty.Version=${Version}`""
 $PACKAGE          = "github.com/nulastudio/NetBeauty/src/main"
 
 $Env:CGO_ENABLED  = "0"
@@ -16,6 +24,10 @@ $Env:GOOS   = "windows"
 $Env:GOARCH = "amd64"
 go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_WIN_X64} $PACKAGE
 
+$Env:GOOS   = "windows"
+$Env:GOARCH = "arm64"
+go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_WIN_ARM64} $PACKAGE
+
 $Env:GOOS   = "linux"
 $Env:GOARCH = "amd64"
 go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_LINUX_X64} $PACKAGE
@@ -23,3 +35,11 @@ go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_LINUX_X64} $PACKAGE
 $Env:GOOS   = "darwin"
 $Env:GOARCH = "amd64"
 go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_MAC_X64} $PACKAGE
+
+$Env:GOOS   = "linux"
+$Env:GOARCH = "arm64"
+go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_LINUX_ARM64} $PACKAGE
+
+$Env:GOOS   = "darwin"
+$Env:GOARCH = "arm64"
+go build ${BUILD_FLAGS} -o ./${OUTPUT}/${BINARY_MAC_ARM64} $PACKAGE
@@ -0,0 +1,220 @@
+package beauty
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// apphost中为app路径预留的空间（包含结尾的\0）
+const appHostPathMaxLength = 1024
+
+// beautyAppHost 修改apphost中嵌入的app路径，将其余文件全部移入libsDir
+// apphost会在app所在目录查找hostfxr，因此不需要替换任何微软的二进制
+func beautyAppHost(rootBefore []string) bool {
+	dependencies := manager.FindDepsJSON(beautyDir)
+	if len(dependencies) == 0 {
+		log.LogDetail(fmt.Sprintf("no deps.json found in %s", beautyDir))
+		log.LogDetail("skipping")
+		return false
+	}
+
+	appHosts := make([]string, 0)
+	for _, deps := range dependencies {
+		mainProgram := strings.Replace(filepath.Base(deps), ".deps.json", "", -1)
+
+		appHost := findAppHost(mainProgram)
+		if appHost == "" {
+			log.LogWarning(fmt.Sprintf("no apphost found for %s", mainProgram))
+			continue
+		}
+
+		appPath := path2slash(libsDir) + "/" + mainProgram + ".dll"
+
+		log.LogDetail(fmt.Sprintf("rewriting app path of %s to %s", appHost, appPath))
+
+		if err := rewriteAppHostPath(appHost, mainProgram+".dll", appPath); err != nil {
+			log.LogErrorFields(fmt.Errorf("rewrite apphost failed: %s : %s", appHost, err.Error()), log.Fields{"file": appHost})
+			continue
+		}
+
+		appHosts = append(appHosts, appHost)
+	}
+
+	if len(appHosts) == 0 {
+		log.LogDetail("no apphost can be rewritten")
+		log.LogDetail("skipping")
+		return false
+	}
+
+	moved := 0
+	stage := &StageData{}
+	if runHooks(PreMove, stage) {
+		endMove := startStage("move")
+		moved = moveRootEntries(appHosts)
+		endMove()
+
+		stage.Moved = moved
+		runHooks(PostMove, stage)
+	}
+
+	for _, appHost := range appHosts {
+		resignBinary(appHost)
+	}
+
+	finishBeauty(rootBefore, &manager.BeautyMarker{
+		Strategy:          appHostStrategy,
+		SharedRuntimeMode: sharedRuntimeMode,
+		DepsCount:         len(dependencies),
+		MovedCount:        moved,
+	}, appHosts)
+
+	return true
+}
+
+func path2slash(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+}
+
+// findAppHost 查找app对应的apphost（Windows为app.exe，其余平台为app）
+func findAppHost(mainProgram string) string {
+	for _, name := range []string{mainProgram + ".exe", mainProgram} {
+		appHost := filepath.Join(beautyDir, name)
+		if fi, err := os.Stat(appHost); err == nil && !fi.IsDir() {
+			return appHost
+		}
+	}
+	return ""
+}
+
+// isAppHostOf 检查apphost中是否嵌入了mainProgram（或已修改过）的路径
+func isAppHostOf(appHost string, mainProgram string) bool {
+	content, err := ioutil.ReadFile(appHost)
+	if err != nil {
+		return false
+	}
+	for _, appPath := range []string{mainProgram + ".dll", path2slash(libsDir) + "/" + mainProgram + ".dll"} {
+		if bytes.Contains(content, append([]byte(appPath), 0)) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteAppHostPath 将apphost中嵌入的app路径oldPath替换为newPath
+func rewriteAppHostPath(appHost string, oldPath string, newPath string) error {
+	if len(newPath)+1 > appHostPathMaxLength {
+		return fmt.Errorf("app path is too long: %s", newPath)
+	}
+
+	content, err := ioutil.ReadFile(appHost)
+	if err != nil {
+		return err
+	}
+
+	// 已经修改过（--force）
+	if bytes.Contains(content, append([]byte(newPath), 0)) {
+		return nil
+	}
+
+	search := append([]byte(oldPath), 0)
+	index := bytes.Index(content, search)
+	if index == -1 {
+		return fmt.Errorf("cannot find embedded app path %s", oldPath)
+	}
+	if bytes.Index(content[index+len(search):], search) != -1 {
+		return fmt.Errorf("embedded app path %s is ambiguous", oldPath)
+	}
+
+	end := index + len(newPath) + 1
+	if end > len(content) {
+		return fmt.Errorf("no enough space for %s", newPath)
+	}
+	for _, b := range content[index+len(search) : end] {
+		if b != 0 {
+			return fmt.Errorf("no enough space for %s", newPath)
+		}
+	}
+
+	journalBeforeWrite(appHost)
+
+	copy(content[index:], newPath)
+	content[index+len(newPath)] = 0
+
+	fi, err := os.Stat(appHost)
+	if err != nil {
+		return err
+	}
+
+	if err := util.FileSystem.WriteFile(appHost, content, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return util.Sync(appHost)
+}
+
+// moveRootEntries 将根目录下除apphost、标记文件及excludes外的所有文件/目录移入libsDir
+func moveRootEntries(appHosts []string) int {
+	keeps := map[string]bool{
+		manager.BeautyMarkerName: true,
+		JournalName:              true,
+		LockName:                 true,
+		strings.Split(path2slash(libsDir), "/")[0]: true,
+	}
+	for _, appHost := range appHosts {
+		keeps[filepath.Base(appHost)] = true
+	}
+
+	excludeFiles := strings.Split(excludes, ";")
+
+	libsPath := filepath.Join(beautyDir, libsDir)
+	if !util.EnsureDirExists(libsPath, util.DirMode) {
+		log.LogError(notWriteableError(libsPath), false)
+		return 0
+	}
+
+	fis, err := ioutil.ReadDir(beautyDir)
+	if err != nil {
+		log.LogError(fmt.Errorf("read dir failed: %s : %s", beautyDir, err.Error()), false)
+		return 0
+	}
+
+	moved := 0
+	for _, fi := range fis {
+		checkCanceled()
+
+		name := fi.Name()
+		if keeps[name] || isRunFile(name) || fileMatch(name, excludeFiles) {
+			continue
+		}
+
+		src := filepath.Join(beautyDir, name)
+		des := filepath.Join(libsPath, name)
+
+		size := fi.Size()
+		if fi.IsDir() {
+			size = dirSize(src)
+		}
+
+		start := time.Now()
+		journalMoving(src, des)
+		if err := util.MoveFile(src, des); err != nil {
+			summary.failedFiles++
+			log.LogErrorFields(fmt.Errorf("move failed: %s : %s", src, err.Error()), log.Fields{"file": src})
+			continue
+		}
+
+		moved++
+		summary.movedBytes += size
+		emitFileMoved(src, des, size, time.Since(start))
+	}
+
+	return moved
+}
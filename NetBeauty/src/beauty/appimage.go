@@ -0,0 +1,180 @@
+package beauty
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var appDir = ""
+
+// appRunScript AppImage挂载后的入口，应用位于$APPDIR/usr/lib/<app>
+const appRunScript = `#!/bin/sh
+# generated by nbeauty %s
+HERE="$(dirname "$(readlink -f "$0")")"
+exec "$HERE/usr/lib/%s/%s" "$@"
+`
+
+const desktopEntry = `[Desktop Entry]
+Type=Application
+Name=%s
+Exec=%s
+Icon=%s
+Categories=Utility;
+`
+
+// isAppDirFile AppImage要求位于AppDir根目录的文件：AppRun、.desktop及图标
+func isAppDirFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".desktop", ".png", ".svg", ".xpm":
+		return true
+	}
+	return name == "AppRun" || name == ".DirIcon"
+}
+
+// buildAppDir 将beauty后的目录复制为AppDir：应用（含libsDir）位于usr/lib/<app>，
+// 发布目录中的AppRun、.desktop及图标保留在根目录，不存在时生成
+func buildAppDir(out string) error {
+	if err := outsideBeautyDir(out); err != nil {
+		return err
+	}
+	if summary.rid != "" && !strings.HasPrefix(summary.rid, "linux") {
+		log.LogWarning(fmt.Sprintf("AppImage only runs on Linux, the app targets %s", summary.rid))
+	}
+
+	deps := manager.FindDepsJSON(beautyDir)
+	if len(deps) == 0 {
+		return fmt.Errorf("no deps.json found in %s", beautyDir)
+	}
+	app := strings.TrimSuffix(filepath.Base(deps[0]), ".deps.json")
+
+	// 只覆盖之前生成的AppDir
+	if fis, err := ioutil.ReadDir(out); err == nil && len(fis) != 0 && !util.PathExists(filepath.Join(out, "AppRun")) {
+		return fmt.Errorf("%s is not empty and is not an AppDir", out)
+	}
+	if err := util.CheckWrite("remove", out); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(out); err != nil {
+		return err
+	}
+	appRoot := filepath.Join(out, "usr", "lib", app)
+	if !util.EnsureDirExists(appRoot, util.DirMode) {
+		return notWriteableError(appRoot)
+	}
+
+	err := filepath.Walk(beautyDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(beautyDir, path)
+		if err != nil || isRunFile(rel) {
+			return err
+		}
+		if filepath.Dir(rel) == "." && isAppDirFile(rel) {
+			return copyEntry(path, fi, filepath.Join(out, rel))
+		}
+		return copyEntry(path, fi, filepath.Join(appRoot, rel))
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := completeAppDir(out, app); err != nil {
+		return err
+	}
+
+	if problems := checkAppDir(appRoot); problems != 0 {
+		return fmt.Errorf("the app does not resolve its dependencies relative to itself (%d problem(s)), it will not start from the AppImage mount point", problems)
+	}
+
+	log.LogDetail(fmt.Sprintf("AppDir written to %s, build it with: appimagetool %s", out, out))
+	return nil
+}
+
+// completeAppDir 生成缺少的AppRun、.desktop及usr/bin链接
+func completeAppDir(out string, app string) error {
+	appRun := filepath.Join(out, "AppRun")
+	if !util.PathExists(appRun) {
+		if err := util.FileSystem.WriteFile(appRun, []byte(fmt.Sprintf(appRunScript, Version, app, app)), 0755); err != nil {
+			return err
+		}
+	}
+
+	desktops, _ := filepath.Glob(filepath.Join(out, "*.desktop"))
+	if len(desktops) == 0 {
+		desktop := filepath.Join(out, app+".desktop")
+		if err := util.FileSystem.WriteFile(desktop, []byte(fmt.Sprintf(desktopEntry, app, app, app)), util.FileMode); err != nil {
+			return err
+		}
+		log.LogWarning(fmt.Sprintf("no .desktop file in %s, a minimal one has been generated: %s", beautyDir, desktop))
+	}
+
+	icons := 0
+	for _, ext := range []string{"*.png", "*.svg", "*.xpm"} {
+		matches, _ := filepath.Glob(filepath.Join(out, ext))
+		icons += len(matches)
+	}
+	if icons == 0 {
+		log.LogWarning(fmt.Sprintf("no icon in %s, appimagetool requires the icon named in the .desktop file", beautyDir))
+	}
+
+	bin := filepath.Join(out, "usr", "bin")
+	if !util.EnsureDirExists(bin, util.DirMode) {
+		return notWriteableError(bin)
+	}
+	if err := util.CheckWrite("link", filepath.Join(bin, app)); err != nil {
+		return err
+	}
+	return os.Symlink(filepath.Join("..", "lib", app, app), filepath.Join(bin, app))
+}
+
+// checkAppDir 检查复制后的应用能否按相对路径找到依赖：probing路径必须为相对路径且存在，
+// 配置文件中不能包含beautyDir的绝对路径（挂载点每次都不同）
+func checkAppDir(appRoot string) int {
+	problems := 0
+	problem := func(file string, err error) {
+		problems++
+		log.LogErrorFields(fmt.Errorf("appimage: %s", err.Error()), log.Fields{"file": file})
+	}
+
+	if filepath.IsAbs(libsDir) {
+		problem(appRoot, fmt.Errorf("libsDir %s is an absolute path", libsDir))
+	}
+
+	for _, config := range manager.FindRuntimeConfigJSON(appRoot) {
+		content, err := ioutil.ReadFile(config)
+		if err != nil {
+			problem(config, err)
+			continue
+		}
+		if bytes.Contains(content, []byte(beautyDir)) {
+			problem(config, fmt.Errorf("contains the absolute path %s", beautyDir))
+		}
+
+		json, err := simplejson.NewJson(content)
+		if err != nil {
+			problem(config, err)
+			continue
+		}
+		paths, _ := json.GetPath("runtimeOptions", "additionalProbingPaths").StringArray()
+		for _, probing := range paths {
+			if filepath.IsAbs(probing) {
+				problem(config, fmt.Errorf("probing path %s is absolute", probing))
+			} else if !util.PathExists(filepath.Join(appRoot, probing)) {
+				problem(config, errors.New("probing path "+probing+" does not exist relative to the app"))
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,261 @@
+package beauty
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// beautyArchive 解压zip到临时目录，beauty后重新打包
+// 目录项及符号链接按原样保留，符号链接不解压，重新打包时原样写回
+func beautyArchive(src string, des string) bool {
+	src, _ = filepath.Abs(src)
+	des, _ = filepath.Abs(des)
+
+	tmpDir, err := ioutil.TempDir("", "nbeauty-archive")
+	if err != nil {
+		log.LogPanic(fmt.Errorf("cannot create temp dir: %s", err.Error()), 1)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	log.LogDetail(fmt.Sprintf("extracting %s", src))
+
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		log.LogPanic(fmt.Errorf("extract archive failed: %s : %s", src, err.Error()), 1)
+	}
+	defer reader.Close()
+
+	layout, err := extractZip(reader, tmpDir)
+	if err != nil {
+		log.LogPanic(fmt.Errorf("extract archive failed: %s : %s", src, err.Error()), 1)
+	}
+
+	beautyDir = findPublishDir(tmpDir)
+
+	if !beauty() && src == des {
+		return false
+	}
+
+	log.LogDetail(fmt.Sprintf("compressing %s", des))
+
+	if err := createZip(tmpDir, des, layout); err != nil {
+		log.LogPanic(fmt.Errorf("create archive failed: %s : %s", des, err.Error()), 1)
+	}
+
+	return true
+}
+
+// zipLayout 解压时没有落到磁盘上、重新打包时需要原样保留的内容
+type zipLayout struct {
+	// 原zip是否包含目录项，包含时重新打包的所有目录都写入目录项，否则只写入空目录
+	dirEntries bool
+	// 没有解压的符号链接
+	symlinks []*zip.File
+}
+
+// findPublishDir 寻找zip中实际的发布目录（第一个包含deps.json或exe.config的目录）
+func findPublishDir(root string) string {
+	dirs := []string{root}
+	for len(dirs) != 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+
+		if len(manager.FindDepsJSON(dir)) != 0 || len(manager.FindExeConfig(dir)) != 0 {
+			return dir
+		}
+
+		subDirs, _ := util.ReadAllDir(dir)
+		for _, subDir := range subDirs {
+			dirs = append(dirs, filepath.Join(dir, subDir))
+		}
+	}
+	return root
+}
+
+func extractZip(reader *zip.ReadCloser, des string) (*zipLayout, error) {
+	layout := &zipLayout{}
+	for _, file := range reader.File {
+		target := filepath.Join(des, filepath.FromSlash(file.Name))
+		if target != des && !strings.HasPrefix(target, des+string(filepath.Separator)) {
+			return nil, fmt.Errorf("illegal file path in archive: %s", file.Name)
+		}
+
+		if file.FileInfo().IsDir() {
+			layout.dirEntries = true
+			if !util.EnsureDirExists(target, util.DirMode) {
+				return nil, fmt.Errorf("cannot create path: %s", target)
+			}
+			continue
+		}
+
+		if file.Mode()&os.ModeSymlink != 0 {
+			layout.symlinks = append(layout.symlinks, file)
+			continue
+		}
+
+		if !util.EnsureDirExists(filepath.Dir(target), util.DirMode) {
+			return nil, fmt.Errorf("cannot create path: %s", filepath.Dir(target))
+		}
+
+		if err := extractZipFile(file, target); err != nil {
+			return nil, err
+		}
+
+		os.Chtimes(target, file.Modified, file.Modified)
+	}
+
+	return layout, nil
+}
+
+func extractZipFile(file *zip.File, target string) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	perm := file.Mode().Perm()
+	if perm == 0 {
+		perm = util.FileMode
+	}
+
+	writer, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	_, err = io.Copy(writer, reader)
+	return err
+}
+
+func createZip(src string, des string, layout *zipLayout) error {
+	tmpFile := des + ".tmp"
+
+	file, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+
+	writer := zip.NewWriter(file)
+	err = writeZip(writer, src, layout)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+
+	// 直接替换原zip，替换失败时原zip保持不变
+	if err := os.Rename(tmpFile, des); err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return nil
+}
+
+func writeZip(writer *zip.Writer, root string, layout *zipLayout) error {
+	written := make(map[string]bool)
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == root {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+
+		switch {
+		case fi.IsDir():
+			if !layout.dirEntries && !emptyDir(path) {
+				return nil
+			}
+			return addZipEntry(writer, fi, name+"/", nil)
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			written[name] = true
+			return addZipEntry(writer, fi, name, strings.NewReader(filepath.ToSlash(target)))
+		default:
+			reader, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer reader.Close()
+			written[name] = true
+			return addZipEntry(writer, fi, name, reader)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, symlink := range layout.symlinks {
+		if written[symlink.Name] {
+			continue
+		}
+		if err := copyZipEntry(writer, symlink); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emptyDir dir是否为空目录
+func emptyDir(dir string) bool {
+	fis, err := ioutil.ReadDir(dir)
+	return err == nil && len(fis) == 0
+}
+
+func addZipEntry(writer *zip.Writer, fi os.FileInfo, name string, content io.Reader) error {
+	header, err := zip.FileInfoHeader(fi)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if content != nil && fi.Mode().IsRegular() {
+		header.Method = zip.Deflate
+	} else {
+		header.Method = zip.Store
+	}
+
+	w, err := writer.CreateHeader(header)
+	if err != nil || content == nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+// copyZipEntry 将原zip中的一项连同其头部原样写入writer
+func copyZipEntry(writer *zip.Writer, file *zip.File) error {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	header := file.FileHeader
+	w, err := writer.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, reader)
+	return err
+}
@@ -0,0 +1,103 @@
+package beauty
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// zipDir 将dir打包为zipFile，dir中的目录写入目录项，extra为额外写入的项
+func zipDir(t *testing.T, dir string, zipFile string, extra func(writer *zip.Writer)) {
+	file, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	writer := zip.NewWriter(file)
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		name := filepath.ToSlash(rel)
+		if fi.IsDir() {
+			_, err = writer.Create(name + "/")
+			return err
+		}
+		w, err := writer.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(readFile(t, path)))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extra(writer)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveKeepsDirsAndSymlinks(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(filepath.Dir(dir), "app.zip")
+	zipDir(t, dir, archive, func(writer *zip.Writer) {
+		header := &zip.FileHeader{Name: "current", Method: zip.Store}
+		header.SetMode(os.ModeSymlink | 0777)
+		w, err := writer.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("app.dll"))
+	})
+
+	opts := testOptions(dir, nil)
+	opts.Archive = archive
+	if result := beautify(t, opts); !result.Modified {
+		t.Fatal("the archive has not been beautified")
+	}
+
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	entries := make(map[string]*zip.File)
+	for _, file := range reader.File {
+		entries[file.Name] = file
+	}
+
+	for _, name := range []string{"empty/", "libs/", "libs/Newtonsoft.Json.dll"} {
+		if entries[name] == nil {
+			t.Errorf("%s is missing from the archive", name)
+		}
+	}
+	if entries["Newtonsoft.Json.dll"] != nil {
+		t.Error("Newtonsoft.Json.dll has not been moved")
+	}
+	link := entries["current"]
+	if link == nil || link.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("the symlink has not been kept")
+	}
+	r, err := link.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if target, _ := ioutil.ReadAll(r); string(target) != "app.dll" {
+		t.Errorf("the symlink points to %q", target)
+	}
+	if _, err := os.Stat(archive + ".tmp"); !os.IsNotExist(err) {
+		t.Error("the temporary archive is left behind")
+	}
+}
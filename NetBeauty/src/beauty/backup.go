@@ -0,0 +1,138 @@
+package beauty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// 本次备份的hostfxr，写入标记文件供审计
+var fxrBackup = ""
+var fxrOriginal = ""
+var fxrOriginalHash = ""
+
+// backupFXR 备份原始hostfxr到.bak
+// 已经是补丁版本时不备份；.bak与当前hostfxr不一致（runtime升级过）时，旧备份按hash重命名保留
+func backupFXR(fxr string, artifact string) error {
+	bak := fxr + ".bak"
+
+	if err := backupFXRTo(fxr, bak, artifact); err != nil {
+		return err
+	}
+
+	fxrBackup = bak
+
+	if keepOriginal {
+		return keepOriginalFXR(bak)
+	}
+
+	return nil
+}
+
+func backupFXRTo(fxr string, bak string, artifact string) error {
+
+	fxrHash, err := util.GetFileHash(fxr)
+	if err != nil {
+		return err
+	}
+
+	if artifactHash, err := util.GetFileHash(artifact); err == nil && artifactHash == fxrHash {
+		log.LogWarningFields(fmt.Sprintf("%s is already patched, keeping the existing backup", fxr), log.Fields{"file": fxr})
+		return nil
+	}
+
+	if util.PathExists(bak) {
+		bakHash, err := util.GetFileHash(bak)
+		if err == nil && bakHash == fxrHash {
+			log.LogInfo(fmt.Sprintf("backup %s is up to date", bak))
+			return nil
+		}
+
+		if err == nil {
+			outdated := fmt.Sprintf("%s.%s.bak", fxr, bakHash[:8])
+			log.LogInfo(fmt.Sprintf("runtime changed, keeping outdated backup as %s", outdated))
+			if err := util.MoveFile(bak, outdated); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.LogInfo(fmt.Sprintf("backuping fxr to %s", bak))
+
+	if _, err := util.CopyFile(fxr, bak); err != nil {
+		return err
+	}
+
+	pruneFXRBackups(fxr, fxrBackups)
+
+	return nil
+}
+
+// keepOriginalFXR 在libsDir/.original下保留一份原始hostfxr，方便安全审查对比
+func keepOriginalFXR(bak string) error {
+	original := filepath.Join(beautyDir, libsDir, ".original", filepath.Base(strings.TrimSuffix(bak, ".bak")))
+
+	if !util.EnsureDirExists(filepath.Dir(original), util.DirMode) {
+		return notWriteableError(filepath.Dir(original))
+	}
+
+	log.LogInfo(fmt.Sprintf("keeping original fxr in %s", original))
+
+	if _, err := util.CopyFile(bak, original); err != nil {
+		return err
+	}
+
+	hash, err := util.GetFileHash(original)
+	if err != nil {
+		return err
+	}
+
+	fxrOriginal = original
+	fxrOriginalHash = hash
+
+	return nil
+}
+
+// pruneFXRBackups 只保留最新的keep个旧备份
+func pruneFXRBackups(fxr string, keep int) {
+	backups, _ := filepath.Glob(fxr + ".*.bak")
+	if len(backups) <= keep {
+		return
+	}
+
+	modTime := func(file string) int64 {
+		if fi, err := os.Stat(file); err == nil {
+			return fi.ModTime().UnixNano()
+		}
+		return 0
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return modTime(backups[i]) > modTime(backups[j])
+	})
+
+	if keep < 0 {
+		keep = 0
+	}
+
+	for _, backup := range backups[keep:] {
+		log.LogInfo(fmt.Sprintf("removing outdated backup %s", backup))
+		util.FileSystem.Remove(backup)
+	}
+}
+
+// relPath 转换为相对beautyDir的路径
+func relPath(file string) string {
+	if file == "" {
+		return ""
+	}
+	if rel, err := filepath.Rel(beautyDir, file); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
@@ -0,0 +1,498 @@
+package beauty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	event "github.com/nulastudio/NetBeauty/src/event"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// Options beauty选项，与命令行参数一一对应
+type Options struct {
+	// 需要beauty的目录（Archive模式下忽略）
+	Dir      string
+	LibsDir  string
+	Excludes string
+	Hiddens  string
+
+	SharedRuntimeMode bool
+	EnableDebug       bool
+	// hook/patch/apphost/none，多个以","分隔按顺序尝试，为空时使用hook
+	Strategy string
+	Force    bool
+	// 目录停留在上次中断的状态时的处理：resume撤销后重新beauty，rollback只撤销，为空时报错
+	Repair string
+	// 允许beauty看起来是build输出而不是发布目录的目录
+	SkipPublishCheck bool
+	// 移动及改写文件后fsync文件及所在目录
+	Durable bool
+	// 不论大小都以流式处理deps.json，不整体读入内存
+	LowMemory bool
+	// 文件被占用（应用正在运行）时最多等待的时间
+	WaitForUnlock time.Duration
+	// 只检查上次beauty后修改过或不在移动清单中的文件
+	Incremental bool
+	// 文件操作方式：local、network（SMB/NFS等网络共享上的发布目录）
+	IOProfile string
+	// network模式下同时进行的文件操作数，0为默认值
+	IOConcurrency int
+	// 新建目录及文件的权限（八进制，如0750），为空时为0777、0666去掉umask
+	DirMode  string
+	FileMode string
+
+	// 直接beauty zip，ArchiveOut为空时覆盖原zip
+	Archive    string
+	ArchiveOut string
+
+	// 将Dir复制到OutDir后beauty副本，Dir不会被写入，用于只读的发布目录
+	OutDir string
+
+	Report     string
+	ReportFile string
+
+	// 只使用不修改host的策略，并检查结果是否符合MSIX打包的要求
+	MSIX bool
+	// 保持Squirrel更新所需的文件不变，并写入文件移动清单
+	Squirrel bool
+	// 按移动后的布局更新ClickOnce应用清单及部署清单中的文件列表与哈希
+	ClickOnce bool
+	// 将结果复制为依赖与应用两个目录，用于分层的Docker镜像
+	DockerSplit string
+	// 将结果复制为AppImage的AppDir
+	AppDir string
+
+	// 写入被移动依赖的SBOM，SBOMFormat为cyclonedx（默认）或spdx
+	SBOM       string
+	SBOMFormat string
+
+	Codesign         bool
+	CodesignIdentity string
+	Signtool         string
+	SignThumbprint   string
+	SignCert         string
+	SignTimestamp    string
+
+	FXRBackups       int
+	KeepOriginal     bool
+	RequireKnownHash bool
+	HashAlgorithm    string
+
+	// 接收与--eventfile相同的事件
+	OnEvent func(event.Event)
+	// 与OnEvent相同的事件依次发送到该channel，Beautify返回前关闭
+	// 发送时会阻塞beauty，需要在另一个goroutine中读取，数据可通过event.Event的FileMoved等方法获取
+	Events chan<- event.Event
+	// 日志输出，为nil时使用log.DefaultLogger
+	Logger log.Handler
+	// 各阶段前后执行的钩子
+	Hooks map[HookPoint][]Hook
+	// 补丁来源，RegisterProvider或LoadPlugins注册的名称，为空时从GitCDN下载
+	Provider string
+	// 依赖在libsDir中的位置，为nil时使用默认布局，不能与SharedRuntimeMode同时使用
+	Placement Placement
+}
+
+// Result beauty结果
+type Result struct {
+	Modified        bool
+	Dirs            int
+	RootFilesBefore int
+	RootFilesAfter  int
+	MovedFiles      int
+	SkippedFiles    int
+	// 跳过的文件中应被移动却留在原处的文件数（经符号链接指向beautyDir之外、placement不移动或冲突无法放置），不含<excludes>
+	LeftFiles   int
+	FailedFiles int
+	// 与共用libsDir中不同版本的同名文件冲突、被放到版本目录的依赖数
+	Conflicts      int
+	MovedBytes     int64
+	LibsDirSize    int64
+	RewrittenFiles int
+	// 已应用的补丁，如v6.0.0/linux-x64，未应用时为空
+	Patch string
+	// SCD模式下检测到的hostfxr版本及RID
+	FXRVersion string
+	RID        string
+	Elapsed    time.Duration
+}
+
+// ExitError 出错时的退出码，错误信息已输出到日志
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("nbeauty exited with code %d", e.Code)
+	}
+	return e.Message
+}
+
+// DefaultOptions 返回与命令行默认值一致的选项
+func DefaultOptions() Options {
+	return Options{
+		LibsDir:          "libraries",
+		Strategy:         hookStrategy,
+		CodesignIdentity: "-",
+		Signtool:         "signtool",
+		FXRBackups:       1,
+		HashAlgorithm:    "sha256",
+	}
+}
+
+// 内部状态均为包级变量，同一时间只能进行一次beauty
+var running sync.Mutex
+
+// runCtx 当前beauty的context，取消后在下一个文件处中断
+var runCtx = context.Background()
+
+// canceled 由checkCanceled产生的panic
+type canceled struct {
+	err error
+}
+
+// checkCanceled 检查runCtx是否已取消，取消时中断beauty
+func checkCanceled() {
+	if err := runCtx.Err(); err != nil {
+		panic(&canceled{err})
+	}
+}
+
+// Beautify 对opts.Dir（或opts.Archive）进行beauty
+// 出错时返回*ExitError，其中的退出码与命令行一致
+func Beautify(ctx context.Context, opts Options) (result Result, err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if opts.Events != nil {
+		events := opts.Events
+		defer close(events)
+		defer event.Subscribe(func(e event.Event) { events <- e })()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	if opts.OnEvent != nil {
+		defer event.Subscribe(opts.OnEvent)()
+	}
+	defer useLogger(opts.Logger)()
+
+	runCtx = ctx
+	defer func() { runCtx = context.Background() }()
+
+	// 中断时保留journal
+	defer closeJournal(false)
+	defer catchExit(&err, trapExit())
+
+	apply(opts)
+
+	modified := false
+	if archive := strings.Trim(opts.Archive, `"`); archive != "" {
+		archiveOut := strings.Trim(opts.ArchiveOut, `"`)
+		if archiveOut == "" {
+			archiveOut = archive
+		}
+		modified = beautyArchive(archive, archiveOut)
+	} else if outDir := strings.Trim(opts.OutDir, `"`); outDir != "" {
+		modified = beautyCopyOut(beautyDir, outDir)
+	} else {
+		modified = beauty()
+	}
+
+	data := event.Data{"modified": modified}
+	for key, value := range summary.fields() {
+		data[key] = value
+	}
+	event.Emit(event.RunFinished, data)
+
+	return summary.result(modified), nil
+}
+
+// RestoreFXR 从备份还原opts.Dir中的原始hostfxr，只还原hostfxr，被移动的文件、deps.json等保持不变
+func RestoreFXR(ctx context.Context, opts Options) (err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer useLogger(opts.Logger)()
+	defer catchExit(&err, trapExit())
+
+	if !restoreFXR(opts.Dir) {
+		return errors.New("restore hostfxr failed")
+	}
+	return nil
+}
+
+// Revert 撤销opts.Dir的beauty：将移入libsDir的文件移回原处，还原deps.json、runtimeconfig.json、hostfxr等，并删除标记文件
+func Revert(ctx context.Context, opts Options) (err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer useLogger(opts.Logger)()
+	defer catchExit(&err, trapExit())
+
+	dir := strings.Trim(opts.Dir, `"`)
+	defer lockDir(dir)()
+	return revert(dir)
+}
+
+// Recover 按opts.Dir中的journal撤销被中断的beauty
+func Recover(ctx context.Context, opts Options) (err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer useLogger(opts.Logger)()
+	defer catchExit(&err, trapExit())
+
+	dir := strings.Trim(opts.Dir, `"`)
+	if !util.PathExists(filepath.Join(dir, JournalName)) {
+		return fmt.Errorf("nothing to recover: %s", dir)
+	}
+	defer lockDir(dir)()
+	return replayJournal(dir)
+}
+
+// Migrate 将旧版本写入opts.Dir的空标记文件升级为记录参数及配置文件hash的标记文件，
+// opts.LibsDir为空时从runtimeconfig.json推断
+func Migrate(ctx context.Context, opts Options) (err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer useLogger(opts.Logger)()
+	defer catchExit(&err, trapExit())
+
+	dir := strings.Trim(opts.Dir, `"`)
+	defer lockDir(dir)()
+	_, err = migrateMarker(dir, opts.LibsDir)
+	return err
+}
+
+// Relayout 将已beauty的opts.Dir中的libsDir移动为opts.LibsDir，并改写探测路径及标记文件
+func Relayout(ctx context.Context, opts Options) (err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	defer useLogger(opts.Logger)()
+	defer closeJournal(false)
+	defer catchExit(&err, trapExit())
+
+	dir := strings.Trim(opts.Dir, `"`)
+	defer lockDir(dir)()
+	return relayout(dir, opts.LibsDir)
+}
+
+// Reconcile 将更新后重新出现在opts.Dir中的依赖移入libsDir并更新标记文件，返回移动的文件数
+func Reconcile(ctx context.Context, opts Options) (moved int, err error) {
+	running.Lock()
+	defer running.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	defer useLogger(opts.Logger)()
+	defer closeJournal(false)
+	defer catchExit(&err, trapExit())
+
+	dir := strings.Trim(opts.Dir, `"`)
+	defer lockDir(dir)()
+	return reconcile(dir)
+}
+
+// PatchStatus 检查目录下的hostfxr是否为补丁版，返回可直接输出的结果
+func PatchStatus(dir string) ([]string, error) {
+	status, err := inspectFXR(dir)
+	if err != nil {
+		return nil, err
+	}
+	return status.lines(), nil
+}
+
+// useLogger 在本次调用期间使用logger，返回恢复原Handler的函数
+func useLogger(logger log.Handler) func() {
+	if logger == nil {
+		return func() {}
+	}
+	old := log.SetHandler(logger)
+	return func() { log.SetHandler(old) }
+}
+
+// trapExit 将log.LogPanic等退出进程的调用转为panic，返回原来的log.Exit
+func trapExit() func(int) {
+	exit := log.Exit
+	log.Exit = func(code int) {
+		panic(&ExitError{Code: code, Message: log.LastError()})
+	}
+	return exit
+}
+
+// catchExit 恢复log.Exit，并将trapExit产生的panic转为返回*ExitError
+func catchExit(err *error, exit func(int)) {
+	log.Exit = exit
+
+	if r := recover(); r != nil {
+		if exitErr, ok := r.(*ExitError); ok {
+			*err = exitErr
+			return
+		}
+		if c, ok := r.(*canceled); ok {
+			*err = c.err
+			return
+		}
+		panic(r)
+	}
+}
+
+// apply 重置状态并应用选项，无效的选项按命令行的方式报错
+func apply(opts Options) {
+	beautyDir = strings.Trim(opts.Dir, `"`)
+	libsDir = strings.Trim(opts.LibsDir, `"`)
+	excludes = strings.Trim(opts.Excludes, `"`)
+	hiddens = strings.Trim(opts.Hiddens, `"`)
+	sharedRuntimeMode = opts.SharedRuntimeMode
+	enableDebug = opts.EnableDebug
+	force = opts.Force
+	repair = opts.Repair
+	skipPublishCheck = opts.SkipPublishCheck
+	report = opts.Report
+	reportFile = opts.ReportFile
+	sbomFile = opts.SBOM
+	sbomFormat = opts.SBOMFormat
+	codesign = opts.Codesign
+	codesignIdentity = opts.CodesignIdentity
+	signtool = opts.Signtool
+	signThumbprint = opts.SignThumbprint
+	signCert = opts.SignCert
+	signTimestamp = opts.SignTimestamp
+	fxrBackups = opts.FXRBackups
+	keepOriginal = opts.KeepOriginal
+	requireKnownHash = opts.RequireKnownHash
+	hooks = opts.Hooks
+	msix = opts.MSIX
+	squirrel = opts.Squirrel
+	incremental = opts.Incremental
+	dockerSplit = strings.Trim(opts.DockerSplit, `"`)
+	appDir = strings.Trim(opts.AppDir, `"`)
+	clickOnce = opts.ClickOnce
+	if opts.OutDir != "" && opts.Archive != "" {
+		log.LogPanic(errors.New("--outdir cannot be used with --archive, use --archiveout instead"), 1)
+	}
+	if squirrel {
+		excludes = squirrelExcludes(excludes)
+	}
+
+	provider = gitCDN
+	if opts.Provider != "" {
+		if provider = providers[opts.Provider]; provider == nil {
+			log.LogPanic(fmt.Errorf("unknown artifact provider: %s", opts.Provider), 1)
+		}
+	}
+
+	placement = opts.Placement
+	if placement != nil && sharedRuntimeMode {
+		log.LogPanic(errors.New("a custom placement cannot be used in shared runtime mode"), 1)
+	}
+
+	isNetFx = false
+	fxrBackup, fxrOriginal, fxrOriginalHash = "", "", ""
+	relocated = nil
+	claimed, conflicts = make(map[string]string), nil
+	summary = &beautySummary{started: time.Now()}
+
+	if libsDir == "" {
+		libsDir = "libraries"
+	}
+
+	if opts.Strategy == "" {
+		opts.Strategy = hookStrategy
+	}
+	strategies = parseStrategies(opts.Strategy)
+	if msix {
+		strategies = msixStrategies(strategies)
+	}
+	strategy = strategies[0]
+	usePatch = strategy == patchStrategy
+
+	if opts.HashAlgorithm == "" {
+		opts.HashAlgorithm = "sha256"
+	}
+	if opts.HashAlgorithm != "sha256" && opts.HashAlgorithm != "sha512" {
+		log.LogPanic(fmt.Errorf("invalid hash algorithm: %s", opts.HashAlgorithm), 1)
+	}
+	util.HashAlgorithm = opts.HashAlgorithm
+	util.Durable = opts.Durable
+	util.WaitForUnlock = opts.WaitForUnlock
+	util.ResetModes()
+	if opts.DirMode != "" {
+		util.DirMode = parseMode("dir-mode", opts.DirMode)
+	}
+	if opts.FileMode != "" {
+		util.FileMode = parseMode("file-mode", opts.FileMode)
+	}
+	ioProfile = parseIOProfile(opts.IOProfile)
+	ioConcurrency = defaultIOConcurrency
+	if opts.IOConcurrency > 0 {
+		ioConcurrency = opts.IOConcurrency
+	}
+	manager.ResetNetworkBudget()
+	manager.StreamDepsSize = manager.DefaultStreamDepsSize
+	if opts.LowMemory {
+		manager.StreamDepsSize = 0
+	}
+
+	if repair != "" && repair != repairResume && repair != repairRollback {
+		log.LogPanic(fmt.Errorf("invalid repair: %s", repair), 1)
+	}
+
+	if report != "" && report != treeReport {
+		log.LogPanic(fmt.Errorf("invalid report: %s", report), 1)
+	}
+
+	if sbomFormat == "" {
+		sbomFormat = CycloneDX
+	}
+	if sbomFormat != CycloneDX && sbomFormat != SPDX {
+		log.LogPanic(fmt.Errorf("invalid sbom format: %s", sbomFormat), 1)
+	}
+}
+
+// parseMode 解析八进制的权限
+func parseMode(name string, mode string) os.FileMode {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		log.LogPanic(fmt.Errorf("invalid %s: %s, expected an octal permission such as 0750", name, mode), 1)
+	}
+	return os.FileMode(perm)
+}
@@ -0,0 +1,882 @@
+package beauty
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	event "github.com/nulastudio/NetBeauty/src/event"
+	i18n "github.com/nulastudio/NetBeauty/src/i18n"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+type depsFileDetail struct {
+	deps       string
+	main       string
+	fxrVersion string
+	rid        string
+}
+
+var startupHook = "nbloader"
+
+// Version nbeauty版本，发布时由Makefile/make.ps1通过-ldflags -X注入Common/Common.props中的版本
+var Version = "0.0.0-dev"
+
+// NativeAOT发布无需beauty，使用单独的退出码方便调用方区分
+const nativeAOTExitCode = 3
+
+var beautyDir string
+var libsDir = "libraries"
+var excludes = ""
+var hiddens = ""
+var sharedRuntimeMode = false
+var enableDebug = false
+var usePatch = false
+var strategy = ""
+var strategies []string
+var isNetFx = false
+var force = false
+var report = ""
+var reportFile = ""
+var codesign = false
+var codesignIdentity = "-"
+var signtool = "signtool"
+var signThumbprint = ""
+var signCert = ""
+var signTimestamp = ""
+var fxrBackups = 1
+var keepOriginal = false
+var requireKnownHash = false
+
+// previousMarker --force重新beauty的目录上次beauty后未变化时的标记文件，移动统计在其基础上累加，
+// 使多次beauty的结果一致
+var previousMarker *manager.BeautyMarker
+
+// beauty 对beautyDir进行beauty，返回目录是否被修改
+func beauty() bool {
+	resolveBeautyDir()
+	loadIncremental()
+	preflight()
+	defer lockDir(beautyDir)()
+	unguard := guardWrites()
+	defer unguard()
+	if !repairPartial() {
+		// 只回滚
+		checkRefused(unguard())
+		return true
+	}
+	openJournal(beautyDir)
+	modified := beautyJournaled()
+	closeJournal(true)
+	checkRefused(unguard())
+	return modified
+}
+
+func beautyJournaled() bool {
+	previousMarker = nil
+	if force {
+		// 强制重新beauty未变化的目录时，已移走的依赖不会再被统计
+		if marker, err := manager.ValidateBeautyMarker(beautyDir, libsDir); err == nil {
+			previousMarker = marker
+			appendRevert = true
+		}
+	} else {
+		if marker, err := manager.ValidateBeautyMarker(beautyDir, libsDir); err == nil {
+			log.LogDetail(fmt.Sprintf("%s has already been beautified by %s %s at %s", beautyDir, marker.Tool, marker.Version, marker.Timestamp))
+			log.LogDetail("skipping")
+			return false
+		} else if marker != nil {
+			log.LogDetail(fmt.Sprintf("beauty marker is stale: %s", err.Error()))
+		} else if util.PathExists(manager.MarkerPath(beautyDir)) {
+			log.LogWarning(fmt.Sprintf("the beauty marker of %s was written by an older version, run `nbeauty migrate %s` so that it is recognized", beautyDir, beautyDir))
+		}
+	}
+
+	if previousManifest != nil && previousMarker == nil {
+		// 增量beauty只移动变化的文件，移动统计在上次的基础上累加
+		previousMarker, _ = manager.ReadBeautyMarker(beautyDir)
+	}
+
+	rootBefore := rootSnapshot(beautyDir)
+	summary.rootFilesBefore = countFiles(rootBefore)
+	summary.dirs++
+
+	subDirs := make([]string, 0)
+	srmMapping := make(map[string]string, 0)
+
+	fxrVersion, rid := "", ""
+
+	useWPF := false
+
+	patched := false
+	depsCount, movedCount := 0, 0
+	configFiles := make([]string, 0)
+
+	exeConfig := manager.FindExeConfig(beautyDir)
+
+	if len(exeConfig) != 0 {
+		isNetFx = true
+	}
+
+	if !isNetFx {
+		endScan := startStage("scan")
+		strategy = selectStrategy()
+		endScan()
+		usePatch = strategy == patchStrategy
+
+		switch strategy {
+		case noneStrategy:
+			// 与hook相同地移动依赖，hostfxr、hostpolicy、coreclr等留在原处（usePatch为false），
+			// 只在应用无法加载nbloader时不做任何修改
+			if err := checkStartupHooks(); err != nil {
+				log.LogWarning(fmt.Sprintf("leaving files as they are, the app cannot load the startup hook: %s", err.Error()))
+				return false
+			}
+			log.LogDetail("moving dependencies, hostfxr, hostpolicy and coreclr stay in place")
+		case appHostStrategy:
+			return beautyAppHost(rootBefore)
+		}
+		if custom, ok := customStrategies[strategy]; ok {
+			return beautyCustom(custom, rootBefore)
+		}
+	}
+
+	// fix deps.json
+	if !isNetFx {
+		endScan := startStage("scan")
+		checkedDependencies := []depsFileDetail{}
+		dependencies := manager.FindDepsJSON(beautyDir)
+		if len(dependencies) != 0 {
+			for _, deps := range dependencies {
+				isHidden, hidErr := misc.IsHiddenFile(deps)
+
+				if isHidden && hidErr == nil {
+					misc.ShowFile(deps)
+				}
+
+				mainProgram := strings.Replace(filepath.Base(deps), ".deps.json", "", -1)
+
+				cfxrVersion, crid := manager.FindFXRInfo(deps)
+
+				if fxrVersion == "" || rid == "" {
+					fxrVersion, rid = cfxrVersion, crid
+				} else if cfxrVersion == fxrVersion || crid == rid {
+					log.LogError(fmt.Errorf("Multiple SCD Versions Detected:\n[%s/%s]\n[%s/%s]", fxrVersion, rid, cfxrVersion, crid), true)
+				}
+
+				checkedDependencies = append(checkedDependencies, depsFileDetail{
+					deps:       deps,
+					main:       mainProgram,
+					fxrVersion: cfxrVersion,
+					rid:        crid,
+				})
+
+				if isHidden && hidErr == nil {
+					misc.HideFile(deps)
+				}
+			}
+
+			endScan()
+
+			summary.fxrVersion, summary.rid = fxrVersion, rid
+
+			// check if pre-build artifact exists
+			if fxrVersion != "" && rid != "" {
+				// 必须检查
+				manager.CheckRunConfigJSON()
+
+				if usePatch && !hasArtifact(fxrVersion, rid) {
+					log.LogError(log.NewHintError(fmt.Errorf("Artifact does not exist. %s/%s", fxrVersion, rid), "",
+						i18n.T("hint.artifact.cause"),
+						i18n.T("hint.artifact.fix")), true)
+				}
+			}
+
+			for _, deps := range checkedDependencies {
+				checkCanceled()
+
+				if !runHooks(PreFixDeps, &StageData{File: deps.deps}) {
+					continue
+				}
+
+				isHidden, hidErr := misc.IsHiddenFile(deps.deps)
+
+				if isHidden && hidErr == nil {
+					misc.ShowFile(deps.deps)
+				}
+
+				log.LogDetailFields(fmt.Sprintf("fixing %s", deps.deps), log.Fields{"file": deps.deps})
+
+				SCDMode := deps.fxrVersion != "" && deps.rid != ""
+
+				if SCDMode {
+					log.LogDetail("SCD Mode: Yes")
+					log.LogDetail(fmt.Sprintf("SCD Version: %s, %s", deps.fxrVersion, deps.rid))
+
+					if usePatch {
+						log.LogDetail("Use Patch: Yes")
+					} else {
+						log.LogDetail("Use Patch: No")
+					}
+				} else {
+					log.LogDetail("SCD Mode: No")
+					log.LogDetail("Use Patch: No")
+				}
+
+				endFixDeps := startStage("fix deps")
+
+				journalBeforeWrite(deps.deps)
+				success := manager.AddStartUpHookToDeps(deps.deps, startupHook)
+
+				usePatch = SCDMode && usePatch
+
+				stage, _useWPF := hookedFixDeps(deps.deps, deps.main)
+
+				endFixDeps()
+
+				useWPF = _useWPF
+
+				if sharedRuntimeMode {
+					log.LogDetail("Shared Runtime Mode: Yes")
+					log.LogDetail("moving deps may take some time")
+				} else {
+					log.LogDetail("Shared Runtime Mode: No")
+				}
+
+				endMove := startStage("move")
+				curDepsCount, curMovedCount, curSubDirs, _srmMapping := hookedMoveDeps(stage, deps.main, sharedRuntimeMode)
+				endMove()
+
+				depsCount += curDepsCount
+				movedCount += curMovedCount
+				configFiles = append(configFiles, deps.deps)
+				srmMapping = _srmMapping
+				subDirs = append(subDirs, curSubDirs...)
+
+				if success {
+					summary.rewrittenFiles++
+					log.LogDetail(fmt.Sprintf("%s fixed", deps.deps))
+				}
+
+				if isHidden && hidErr == nil {
+					misc.HideFile(deps.deps)
+				}
+			}
+
+			// patch
+			if usePatch && fxrVersion != "" && rid != "" {
+				checkCanceled()
+				patched = patch(fxrVersion, rid)
+			}
+		} else if manager.IsNativeAOT(beautyDir) {
+			log.LogPanic(fmt.Errorf("%s looks like a NativeAOT publish (PublishAot=true), there is no runtime to move so beauty does not apply", beautyDir), nativeAOTExitCode)
+		} else {
+			log.LogDetail(fmt.Sprintf("no deps.json found in %s", beautyDir))
+			log.LogDetail("skipping")
+			return false
+		}
+	} else {
+		for _, appConfig := range exeConfig {
+			checkCanceled()
+
+			if !runHooks(PreFixDeps, &StageData{File: appConfig}) {
+				continue
+			}
+
+			isHidden, hidErr := misc.IsHiddenFile(appConfig)
+
+			if isHidden && hidErr == nil {
+				misc.ShowFile(appConfig)
+			}
+
+			mainProgram := strings.Replace(filepath.Base(appConfig), ".exe.config", "", -1)
+
+			log.LogDetailFields(fmt.Sprintf("fixing %s", appConfig), log.Fields{"file": appConfig})
+
+			log.LogDetail(".Net Fx: Yes")
+
+			journalBeforeWrite(appConfig)
+			allDeps, success := manager.FixExeConfig(appConfig, libsDir)
+
+			// exe.config只添加probing，与依赖列表无关，钩子在改写之后修改依赖不影响结果
+			stage := &StageData{File: appConfig, Deps: allDeps}
+			runHooks(PostFixDeps, stage)
+			if !runHooks(PreMove, stage) {
+				stage = nil
+			}
+			curDepsCount, curMovedCount, _, _ := hookedMoveDeps(stage, mainProgram, false)
+
+			depsCount += curDepsCount
+			movedCount += curMovedCount
+			configFiles = append(configFiles, appConfig)
+
+			if success {
+				summary.rewrittenFiles++
+				log.LogDetail(fmt.Sprintf("%s fixed", appConfig))
+			}
+
+			if isHidden && hidErr == nil {
+				misc.HideFile(appConfig)
+			}
+		}
+	}
+
+	uniqieSubDirs := []string{}
+	if !isNetFx {
+		tmp := map[string]byte{}
+		for _, e := range subDirs {
+			l := len(tmp)
+			tmp[e] = 0
+			if len(tmp) != l {
+				uniqieSubDirs = append(uniqieSubDirs, e)
+			}
+		}
+	}
+
+	// fix runtimeconfig.json
+	if !isNetFx {
+		runtimeConfigs := manager.FindRuntimeConfigJSON(beautyDir)
+		if len(runtimeConfigs) != 0 {
+			for _, runtimeConfig := range runtimeConfigs {
+				checkCanceled()
+
+				if !runHooks(PreFixDeps, &StageData{File: runtimeConfig}) {
+					continue
+				}
+
+				isHidden, hidErr := misc.IsHiddenFile(runtimeConfig)
+
+				if isHidden && hidErr == nil {
+					misc.ShowFile(runtimeConfig)
+				}
+
+				log.LogDetailFields(fmt.Sprintf("fixing %s", runtimeConfig), log.Fields{"file": runtimeConfig})
+
+				endFixDeps := startStage("fix deps")
+				journalBeforeWrite(runtimeConfig)
+				success := manager.AddStartUpHookToRuntimeConfig(runtimeConfig, startupHook) && manager.FixRuntimeConfig(runtimeConfig, libsDir, uniqieSubDirs, srmMapping, sharedRuntimeMode, usePatch, useWPF)
+				endFixDeps()
+
+				runHooks(PostFixDeps, &StageData{File: runtimeConfig})
+
+				if success {
+					summary.rewrittenFiles++
+					log.LogDetail(fmt.Sprintf("%s fixed", runtimeConfig))
+				}
+
+				configFiles = append(configFiles, runtimeConfig)
+
+				if isHidden && hidErr == nil {
+					misc.HideFile(runtimeConfig)
+				}
+			}
+		} else {
+			log.LogDetail(fmt.Sprintf("no runtimeconfig.json found in %s", beautyDir))
+			log.LogDetail("skipping")
+			return true
+		}
+	}
+
+	// release nbloader
+	if !isNetFx {
+		var loaderDir = beautyDir
+		if usePatch {
+			loaderDir = filepath.Join(beautyDir, libsDir)
+		}
+		log.LogDetail("releasing nbloader.dll")
+		if releasePath, err := releaseNBLoader(loaderDir); err != nil {
+			log.LogError(fmt.Errorf("release nbloader.dll failed: %s : %s", releasePath, err.Error()), true)
+		}
+	}
+
+	usedStrategy := strategy
+	if isNetFx {
+		usedStrategy = "netfx"
+	}
+
+	finishBeauty(rootBefore, &manager.BeautyMarker{
+		Strategy:          usedStrategy,
+		SharedRuntimeMode: sharedRuntimeMode,
+		FXRVersion:        fxrVersion,
+		RID:               rid,
+		Patched:           patched,
+		FXRBackup:         relPath(fxrBackup),
+		FXROriginal:       relPath(fxrOriginal),
+		FXROriginalHash:   fxrOriginalHash,
+		DepsCount:         depsCount,
+		MovedCount:        movedCount,
+	}, configFiles)
+
+	return true
+}
+
+// finishBeauty 隐藏文件、写入标记文件并输出统计及报告
+func finishBeauty(rootBefore []string, marker *manager.BeautyMarker, configFiles []string) {
+	fixRelocatedLinks()
+
+	// 之后只剩标记文件及统计，不再需要journal
+	closeJournal(true)
+
+	// hide files
+	hideFiles()
+
+	// write marker
+	marker.Tool = "nbeauty2"
+	marker.Version = Version
+	marker.Timestamp = outputTimestamp()
+	marker.RunID = event.RunID
+	marker.LibsDir = libsDir
+	marker.Relocations = movedRelocations(beautyDir, libsDir, previousRelocations())
+	moved := marker.MovedCount
+	if previous := previousMarker; previous != nil && previous.Strategy == marker.Strategy {
+		if previousManifest != nil {
+			// 增量beauty时重新移动的文件不重复统计
+			marker.MovedCount = len(squirrelRelocations())
+		} else {
+			marker.MovedCount += previous.MovedCount
+		}
+		if previous.DepsCount > marker.DepsCount {
+			marker.DepsCount = previous.DepsCount
+		}
+	}
+	markerPath := manager.MarkerPath(beautyDir)
+	misc.ShowFile(markerPath)
+	if manager.WriteBeautyMarker(beautyDir, marker, configFiles) {
+		misc.HideFile(markerPath)
+	}
+
+	rootAfter := rootSnapshot(beautyDir)
+
+	summary.rootFilesAfter = countFiles(rootAfter)
+	summary.movedFiles += moved
+	if marker.Patched {
+		summary.patch = fmt.Sprintf("%s/%s", marker.FXRVersion, marker.RID)
+	}
+	summary.libsDirSize = dirSize(filepath.Join(beautyDir, libsDir))
+
+	for _, line := range summary.lines() {
+		log.LogDetail(line)
+	}
+
+	log.LogStageDurations()
+
+	if squirrel || incremental {
+		if err := writeSquirrelManifest(); err != nil {
+			log.LogError(fmt.Errorf("write relocation manifest failed: %s", err.Error()), false)
+		}
+	}
+
+	if clickOnce {
+		if err := updateClickOnce(rootBefore); err != nil {
+			log.LogError(fmt.Errorf("update ClickOnce manifests failed: %s", err.Error()), false)
+		}
+	}
+
+	if sbomFile != "" {
+		if err := writeSBOM(); err != nil {
+			log.LogError(fmt.Errorf("write sbom failed: %s : %s", sbomFile, err.Error()), false)
+		}
+	}
+
+	if dockerSplit != "" {
+		if err := splitForDocker(dockerSplit); err != nil {
+			log.LogError(fmt.Errorf("docker split failed: %s : %s", dockerSplit, err.Error()), false)
+		}
+	}
+
+	if appDir != "" {
+		if err := buildAppDir(appDir); err != nil {
+			log.LogError(fmt.Errorf("build AppDir failed: %s : %s", appDir, err.Error()), false)
+		}
+	}
+
+	if report == treeReport {
+		if err := writeReport(treeDiff(beautyDir, rootBefore, rootAfter)); err != nil {
+			log.LogError(fmt.Errorf("write report failed: %s : %s", reportFile, err.Error()), false)
+		}
+	}
+
+	if msix {
+		if violations := checkMSIX(marker); violations != 0 {
+			log.LogPanic(fmt.Errorf("the beautified output violates %d MSIX packaging rule(s)", violations), 1)
+		}
+	}
+
+	log.LogDetailFields("nbeauty done. Enjoy it!", summary.fields())
+}
+
+func patch(fxrVersion string, rid string) bool {
+	log.LogDetail("patching hostfxr...")
+
+	fxrName := manager.GetHostFXRNameByRID(rid)
+	absFxrName := filepath.Join(beautyDir, fxrName)
+	absFxrBakName := absFxrName + ".bak"
+
+	// universal包需要对每个架构分别打补丁再合并
+	rids := []string{rid}
+	if sliceRIDs := fatSliceRIDs(absFxrName); len(sliceRIDs) > 1 {
+		log.LogDetail(fmt.Sprintf("universal hostfxr detected: %s", strings.Join(sliceRIDs, ", ")))
+		rids = sliceRIDs
+	}
+
+	fetchDir, err := ioutil.TempDir("", "nbeauty-artifact")
+	if err != nil {
+		log.LogError(fmt.Errorf("create temp dir failed: %s", err.Error()), false)
+		return false
+	}
+	defer os.RemoveAll(fetchDir)
+	util.AllowWrites(fetchDir)
+
+	artifacts := make([]string, 0, len(rids))
+	for _, rid := range rids {
+		artifact, ok := fetchArtifact(fxrVersion, rid, fetchDir)
+		if !ok {
+			return false
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	artifact := artifacts[0]
+	if len(artifacts) > 1 {
+		var err error
+		if artifact, err = createUniversalArtifact(fxrVersion, rids, artifacts); err != nil {
+			log.LogError(fmt.Errorf("create universal hostfxr failed: %s", err.Error()), false)
+			return false
+		}
+	}
+
+	stage := &StageData{File: absFxrName, FXRVersion: fxrVersion, RID: rid}
+	if !runHooks(PrePatch, stage) {
+		return false
+	}
+
+	defer startStage("patch")()
+
+	isHidden1, hidErr1 := misc.IsHiddenFile(absFxrName)
+	isHidden2, hidErr2 := misc.IsHiddenFile(absFxrBakName)
+
+	if isHidden1 && hidErr1 != nil {
+		misc.ShowFile(absFxrName)
+	}
+	if isHidden2 && hidErr2 != nil {
+		misc.ShowFile(absFxrBakName)
+	}
+
+	if err := backupFXR(absFxrName, artifact); err != nil {
+		log.LogErrorFields(fmt.Errorf("backup failed: %s", err.Error()), log.Fields{"file": absFxrName})
+
+		if isHidden1 && hidErr1 != nil {
+			misc.HideFile(absFxrName)
+		}
+		if isHidden2 && hidErr2 != nil {
+			misc.HideFile(absFxrBakName)
+		}
+
+		return false
+	}
+
+	journalBeforeWrite(absFxrName)
+	_, err = util.CopyFile(artifact, absFxrName)
+	success := err == nil
+	if success {
+		log.LogInfoFields("patch succeeded", log.Fields{"file": absFxrName, "fxrVersion": fxrVersion, "rid": rid})
+		event.Emit(event.PatchApplied, event.Data{"file": absFxrName, "fxrVersion": fxrVersion, "rid": rid})
+	} else {
+		log.LogError(fmt.Errorf("Cannot copy artifact from %s to %s. %s", artifact, absFxrName, err.Error()), false)
+		log.LogErrorFields(errors.New("patch failed"), log.Fields{"file": absFxrName})
+	}
+
+	if success {
+		resignBinary(absFxrName)
+	}
+
+	stage.Patched = success
+	runHooks(PostPatch, stage)
+
+	if isHidden1 && hidErr1 != nil {
+		misc.HideFile(absFxrName)
+	}
+	if isHidden2 && hidErr2 != nil {
+		misc.HideFile(absFxrBakName)
+	}
+
+	return success
+}
+
+// notWriteableError beautyDir下无法创建目录，通常是权限问题或文件被占用
+func notWriteableError(path string) error {
+	return log.NewHintError(fmt.Errorf("%s is not writeable", path), path,
+		i18n.T("hint.writeable.cause"),
+		i18n.T("hint.writeable.fix", beautyDir))
+}
+
+func releaseNBLoader(dir string) (string, error) {
+	nbloader, err := Asset("nbloader/nbloader.dll")
+	loaderPath := filepath.Join(dir, "nbloader.dll")
+
+	if err == nil {
+		isHidden, hidErr := misc.IsHiddenFile(loaderPath)
+
+		if isHidden && hidErr == nil {
+			misc.ShowFile(loaderPath)
+		}
+
+		journalCreating(loaderPath)
+		err := util.FileSystem.WriteFile(loaderPath, nbloader, util.FileMode)
+		if err == nil {
+			err = util.Sync(loaderPath)
+		}
+		if err != nil {
+			if isHidden && hidErr == nil {
+				misc.HideFile(loaderPath)
+			}
+
+			return loaderPath, err
+		}
+
+		if isHidden && hidErr == nil {
+			misc.HideFile(loaderPath)
+		}
+
+		return loaderPath, nil
+	}
+
+	return loaderPath, err
+}
+
+func fileMatch(file string, sources []string) bool {
+	match := false
+	for _, pattern := range sources {
+		if pattern == "" {
+			continue
+		}
+		if regex, err := regexp.Compile(strings.ReplaceAll(pattern, "*", ".*")); err == nil {
+			match = regex.MatchString(file)
+			if match {
+				break
+			}
+		}
+	}
+
+	return match
+}
+
+// hookedFixDeps 分析并改写deps.json，改写之前执行PostFixDeps、PreMove钩子，
+// deps.json只改写钩子修改后的依赖，PreMove跳过时不改写任何依赖项并返回nil
+func hookedFixDeps(file string, entry string) (*StageData, bool) {
+	var stage *StageData
+	_, useWPF, _ := manager.FixDepsWith(file, entry, enableDebug, usePatch, sharedRuntimeMode, func(deps []manager.Deps) []manager.Deps {
+		stage = &StageData{File: file, Deps: deps}
+		runHooks(PostFixDeps, stage)
+		if !runHooks(PreMove, stage) {
+			stage = nil
+			return nil
+		}
+		return stage.Deps
+	})
+	return stage, useWPF
+}
+
+// hookedMoveDeps 移动已经过PostFixDeps、PreMove钩子的依赖并执行PostMove钩子，stage为nil时表示已跳过
+func hookedMoveDeps(stage *StageData, entry string, sharedRuntimeMode bool) (int, int, []string, map[string]string) {
+	if stage == nil {
+		return 0, 0, []string{}, map[string]string{}
+	}
+
+	depsCount, moved, subDirs, srmMapping := moveDeps(stage.Deps, entry, sharedRuntimeMode)
+
+	stage.Moved = moved
+	runHooks(PostMove, stage)
+
+	return depsCount, moved, subDirs, srmMapping
+}
+
+func moveDeps(deps []manager.Deps, entry string, sharedRuntimeMode bool) (int, int, []string, map[string]string) {
+	var isContains = func(arr []string, v string) bool {
+		for _, c := range arr {
+			if c == v {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	excludeFiles := strings.Split(excludes, ";")
+
+	realCount, moved, subDirs, srmMapping := 0, 0, make([]string, 0), make(map[string]string, 0)
+	pending, planned := make([]*pendingMove, 0), make(map[string]bool)
+
+	place := placement
+	if place == nil {
+		defaultPlace := newDefaultPlacement(entry, sharedRuntimeMode)
+		srmMapping = defaultPlace.srmMapping
+		place = defaultPlace
+	}
+
+	for _, dep := range deps {
+		checkCanceled()
+
+		var absDepsFile = ""
+		var usingPath = ""
+		var exist = false
+		var escaped = false
+
+		for _, filePath := range []string{dep.SecondPath, dep.Path} {
+			absDepsFile = filepath.Join(beautyDir, filePath)
+			// 损坏或恶意的deps.json可能包含../
+			if !util.PathWithin(beautyDir, absDepsFile) {
+				escaped = true
+				break
+			}
+			// network模式下还没有移动的文件视为已移走
+			if util.PathExists(absDepsFile) && !planned[absDepsFile] {
+				usingPath = filePath
+				exist = true
+				break
+			}
+
+			if dep.SecondPath == dep.Path {
+				break
+			}
+		}
+
+		if escaped {
+			summary.failedFiles++
+			log.LogErrorFields(fmt.Errorf("deps.json entry %s points out of %s, rejected", dep.Name, beautyDir), log.Fields{"file": absDepsFile})
+			continue
+		}
+
+		if !exist || unchanged[absDepsFile] {
+			continue
+		}
+
+		if !insideBeautyDir(filepath.Dir(absDepsFile)) {
+			summary.skippedFiles++
+			summary.leftFiles++
+			log.LogWarningFields(fmt.Sprintf("%s is reached through a symlink out of %s, leaving it", absDepsFile, beautyDir), log.Fields{"file": absDepsFile})
+			continue
+		}
+
+		if fileMatch(dep.Name, excludeFiles) {
+			summary.skippedFiles++
+			log.LogWarningFields(fmt.Sprintf("%s matches <excludes>, leaving it", absDepsFile), log.Fields{"file": absDepsFile})
+			continue
+		}
+
+		if !isNetFx {
+			/**
+			* !usePatch + !enableDebug = !move +  delete
+			* !usePatch +  enableDebug = !move + !delete
+			*  usePatch + !enableDebug = !move +  delete
+			*  usePatch +  enableDebug =  move + !delete
+			 */
+			if strings.Contains(dep.Name, "mscordaccore") ||
+				strings.Contains(dep.Name, "mscordbi") {
+				if !enableDebug {
+					util.FileSystem.Remove(absDepsFile)
+					continue
+				} else if !usePatch {
+					continue
+				}
+			}
+		}
+
+		usingPath2 := strings.ReplaceAll(usingPath, "\\", "/")
+		fileName := path.Base(usingPath2)
+
+		dest, probeDir := place.Place(dep, absDepsFile, usingPath2)
+		if dest == "" {
+			summary.skippedFiles++
+			summary.leftFiles++
+			log.LogWarningFields(fmt.Sprintf("placement leaves %s in place", absDepsFile), log.Fields{"file": absDepsFile})
+			continue
+		}
+		// 冲突时placeConflict已输出警告
+		if dest, probeDir = placeConflict(dep, absDepsFile, dest, probeDir); dest == "" {
+			summary.skippedFiles++
+			summary.leftFiles++
+			continue
+		}
+		newAbsDepsFile, _ := filepath.Abs(filepath.Join(beautyDir, libsDir, filepath.FromSlash(dest)))
+		if path.IsAbs(dest) || !util.PathWithin(filepath.Join(beautyDir, libsDir), newAbsDepsFile) {
+			summary.failedFiles++
+			log.LogErrorFields(fmt.Errorf("placement moves %s out of %s: %s", usingPath2, libsDir, dest), log.Fields{"file": absDepsFile})
+			continue
+		}
+
+		realCount++
+
+		if probeDir != "" && !isContains(subDirs, probeDir) {
+			subDirs = append(subDirs, probeDir)
+		}
+
+		if ioProfile == networkIOProfile {
+			pending = append(pending, &pendingMove{dep: dep, from: absDepsFile, to: newAbsDepsFile})
+			planned[absDepsFile] = true
+			continue
+		}
+		oldPath := filepath.Dir(absDepsFile)
+		newPath := filepath.Dir(newAbsDepsFile)
+
+		if !util.EnsureDirExists(newPath, util.DirMode) {
+			log.LogError(notWriteableError(newPath), false)
+		}
+
+		var size int64
+		if fi, err := util.FileSystem.Stat(absDepsFile); err == nil {
+			size = fi.Size()
+		}
+
+		start := time.Now()
+		journalMoving(absDepsFile, newAbsDepsFile)
+		if err := util.MoveFile(absDepsFile, newAbsDepsFile); err == nil {
+			recordRelocated(dep, absDepsFile, newAbsDepsFile)
+			moved++
+			summary.movedBytes += size
+			emitFileMoved(absDepsFile, newAbsDepsFile, size, time.Since(start))
+		} else {
+			summary.failedFiles++
+			log.LogErrorFields(err, log.Fields{"file": absDepsFile})
+		}
+
+		for _, extFile := range []string{".pdb", ".xml"} {
+			oldFile := filepath.Join(oldPath, fileName+extFile)
+			newFile := filepath.Join(newPath, fileName+extFile)
+			if util.PathExists(oldFile) {
+				journalMoving(oldFile, newFile)
+				util.MoveFile(oldFile, newFile)
+			}
+		}
+
+		dir, _ := util.FileSystem.ReadDir(oldPath)
+
+		if len(dir) == 0 {
+			util.FileSystem.Remove(oldPath)
+		}
+	}
+	moved += movePending(pending)
+
+	return realCount, moved, subDirs, srmMapping
+}
+
+func hideFiles() {
+	hiddensFiles := strings.Split(hiddens, ";")
+	rootFiles := util.GetAllFiles(beautyDir, false)
+	for _, rootFile := range rootFiles {
+		if fileMatch(rootFile, hiddensFiles) && !isSquirrelFile(rootFile) {
+			if err := misc.HideFile(rootFile); err != nil {
+				log.LogErrorFields(fmt.Errorf("hide file failed: %s : %s", rootFile, err.Error()), log.Fields{"file": rootFile})
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package beauty
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var clickOnce = false
+
+// ClickOnce清单中的文件项：<dependentAssembly codebase=...>及<file name=...>，引用路径以"\"分隔
+var (
+	clickOnceEntry    = regexp.MustCompile(`(?s)<((?:\w+:)?(?:dependentAssembly|file))\b([^>]*?)(/>|>.*?</(?:\w+:)?(?:dependentAssembly|file)>)`)
+	clickOncePath     = regexp.MustCompile(`\b(codebase|name)="([^"]*)"`)
+	clickOnceSize     = regexp.MustCompile(`\bsize="\d*"`)
+	clickOnceDigest   = regexp.MustCompile(`(<(?:\w+:)?DigestValue>)[^<]*(</(?:\w+:)?DigestValue>)`)
+	clickOnceMethod   = regexp.MustCompile(`<(?:\w+:)?DigestMethod\b[^>]*Algorithm="[^"]*#(\w+)"`)
+	clickOnceDepClose = regexp.MustCompile(`(?s)(.*</(?:\w+:)?dependency>)`)
+)
+
+const clickOnceFileEntry = `
+  <file name="%s" size="%d">
+    <hash>
+      <dsig:Transforms>
+        <dsig:Transform Algorithm="urn:schemas-microsoft-com:HashTransforms.Identity" />
+      </dsig:Transforms>
+      <dsig:DigestMethod Algorithm="http://www.w3.org/2000/09/xmldsig#sha256" />
+      <dsig:DigestValue>%s</dsig:DigestValue>
+    </hash>
+  </file>`
+
+// clickOnceDigestOf 按清单中的DigestMethod计算文件的大小及base64摘要
+func clickOnceDigestOf(file string, method string) (int64, string, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, "", err
+	}
+	var h hash.Hash
+	switch method {
+	case "sha1":
+		h = sha1.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return 0, "", fmt.Errorf("unsupported digest method: %s", method)
+	}
+	h.Write(content)
+	return int64(len(content)), base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// updateClickOnceManifest 将清单中被移动的文件改为新路径，并重新计算所有文件项的大小及摘要
+// renames的键为小写的旧路径，baseDir为清单中相对路径的起点
+func updateClickOnceManifest(manifest string, baseDir string, renames map[string]string, extra []string) error {
+	content, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool)
+	var updateErr error
+	updated := clickOnceEntry.ReplaceAllStringFunc(string(content), func(entry string) string {
+		match := clickOncePath.FindStringSubmatch(entry)
+		if match == nil {
+			return entry
+		}
+		ref := match[2]
+		if renamed, ok := renames[strings.ToLower(ref)]; ok {
+			ref = renamed
+			entry = strings.Replace(entry, match[0], fmt.Sprintf(`%s="%s"`, match[1], ref), 1)
+		}
+		listed[strings.ToLower(ref)] = true
+
+		file := filepath.Join(baseDir, filepath.FromSlash(strings.Replace(ref, `\`, "/", -1)))
+		if _, err := os.Stat(file); err != nil {
+			return entry
+		}
+
+		method := "sha256"
+		if m := clickOnceMethod.FindStringSubmatch(entry); m != nil {
+			method = strings.ToLower(m[1])
+		}
+		size, digest, err := clickOnceDigestOf(file, method)
+		if err != nil {
+			updateErr = err
+			return entry
+		}
+		entry = clickOnceSize.ReplaceAllString(entry, fmt.Sprintf(`size="%d"`, size))
+		return clickOnceDigest.ReplaceAllString(entry, "${1}"+digest+"${2}")
+	})
+	if updateErr != nil {
+		return updateErr
+	}
+
+	// beauty新建的文件（如nbloader.dll）需要加入应用清单才会被部署
+	added := ""
+	for _, ref := range extra {
+		if listed[strings.ToLower(ref)] {
+			continue
+		}
+		size, digest, err := clickOnceDigestOf(filepath.Join(baseDir, filepath.FromSlash(strings.Replace(ref, `\`, "/", -1))), "sha256")
+		if err != nil {
+			return err
+		}
+		added += fmt.Sprintf(clickOnceFileEntry, ref, size, digest)
+	}
+	if added != "" {
+		if !clickOnceDepClose.MatchString(updated) {
+			return fmt.Errorf("cannot add %s, no <dependency> found", strings.Join(extra, ", "))
+		}
+		updated = clickOnceDepClose.ReplaceAllStringFunc(updated, func(s string) string { return s + added })
+	}
+
+	if strings.Contains(updated, "<Signature") {
+		log.LogWarning(fmt.Sprintf("%s is signed and its signature is no longer valid, re-sign it after beauty (e.g. mage -Sign in --posthook)", manifest))
+	}
+
+	if err := util.FileSystem.WriteFile(manifest, []byte(updated), util.FileMode); err != nil {
+		return err
+	}
+	return util.Sync(manifest)
+}
+
+// updateClickOnce 更新beautyDir中的应用清单（*.manifest）及部署清单（*.application）
+// 部署清单同时在beautyDir及标准发布目录（Application Files/<version>的上两级）中查找
+func updateClickOnce(rootBefore []string) error {
+	renames := make(map[string]string, len(relocated))
+	for _, file := range relocated {
+		from, err1 := filepath.Rel(beautyDir, file.from)
+		to, err2 := filepath.Rel(beautyDir, file.path)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		renames[strings.ToLower(strings.Replace(filepath.ToSlash(from), "/", `\`, -1))] = strings.Replace(filepath.ToSlash(to), "/", `\`, -1)
+	}
+
+	before := make(map[string]bool, len(rootBefore))
+	for _, name := range rootBefore {
+		before[name] = true
+	}
+	extra := make([]string, 0)
+	for _, name := range rootSnapshot(beautyDir) {
+		if before[name] || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".bak") ||
+			name == manager.BeautyMarkerName || name == SquirrelManifestName || isRunFile(name) {
+			continue
+		}
+		extra = append(extra, name)
+	}
+
+	manifests, _ := filepath.Glob(filepath.Join(beautyDir, "*.manifest"))
+	updated := 0
+	for _, manifest := range manifests {
+		content, err := ioutil.ReadFile(manifest)
+		if err != nil || !strings.Contains(string(content), "entryPoint") {
+			continue
+		}
+		if err := updateClickOnceManifest(manifest, beautyDir, renames, extra); err != nil {
+			return fmt.Errorf("%s: %s", manifest, err.Error())
+		}
+		updated++
+	}
+	if updated == 0 {
+		return fmt.Errorf("no ClickOnce application manifest found in %s", beautyDir)
+	}
+
+	deployments, _ := filepath.Glob(filepath.Join(beautyDir, "*.application"))
+	if strings.EqualFold(filepath.Base(filepath.Dir(beautyDir)), "Application Files") {
+		publishDir := filepath.Dir(filepath.Dir(beautyDir))
+		more, _ := filepath.Glob(filepath.Join(publishDir, "*.application"))
+		deployments = append(deployments, more...)
+	}
+	for _, deployment := range deployments {
+		if err := updateClickOnceManifest(deployment, filepath.Dir(deployment), nil, nil); err != nil {
+			return fmt.Errorf("%s: %s", deployment, err.Error())
+		}
+		updated++
+	}
+
+	log.LogDetail(fmt.Sprintf("%d ClickOnce manifest(s) updated", updated))
+	return nil
+}
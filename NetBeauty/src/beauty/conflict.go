@@ -0,0 +1,102 @@
+package beauty
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// depConflict 同一位置上内容不同的两个依赖，后者被放到了Kept
+type depConflict struct {
+	Name     string
+	Version  string
+	From     string
+	Existing string
+	Kept     string
+}
+
+// claimed 本次beauty中已被占用的目标位置 => 来源文件
+var claimed map[string]string
+
+// conflicts 本次beauty中检测到的冲突
+var conflicts []depConflict
+
+// sameContent 两个文件内容是否相同，已被移动到target的来源文件与target比较
+func sameContent(a string, b string, target string) bool {
+	if !util.PathExists(a) {
+		a = target
+	}
+	fa, errA := util.FileSystem.Stat(a)
+	fb, errB := util.FileSystem.Stat(b)
+	if errA != nil || errB != nil || fa.Size() != fb.Size() {
+		return false
+	}
+	md5A, errA := util.GetFileMD5(a)
+	md5B, errB := util.GetFileMD5(b)
+	return errA == nil && errB == nil && md5A == md5B
+}
+
+// placeConflict dest已被本次beauty中的其他文件占用，或被共用libsDir的其他应用的不同版本占用时，
+// 将file放到libsDir/versions/<版本>下并返回新的dest及probeDir，没有冲突时原样返回
+// .NET Framework不支持版本目录的探测，冲突的文件保留在原处（dest为空）
+func placeConflict(dep manager.Deps, file string, dest string, probeDir string) (string, string) {
+	libsPath := filepath.Join(beautyDir, libsDir)
+	target := filepath.Join(libsPath, filepath.FromSlash(dest))
+
+	existing, ok := claimed[target]
+	if !ok {
+		// 只有beautyDir之外的libsDir会被其他应用共用，自己的libsDir中的旧版本直接替换
+		if util.PathWithin(beautyDir, libsPath) || !util.PathExists(target) {
+			claimed[target] = file
+			return dest, probeDir
+		}
+		existing = target
+	}
+	if existing == file || sameContent(existing, file, target) {
+		return dest, probeDir
+	}
+
+	conflict := depConflict{Name: dep.Name, Version: dep.Version, From: file, Existing: existing}
+	defer func() {
+		conflicts = append(conflicts, conflict)
+		log.LogWarningFields(fmt.Sprintf("%s conflicts with a different %s already placed at %s, keeping both: %s", file, path.Base(dest), target, conflict.Kept), log.Fields{"file": file})
+	}()
+
+	if isNetFx {
+		conflict.Kept = file
+		return "", ""
+	}
+
+	version := dep.Version
+	if version == "" {
+		version = "unknown"
+	}
+	for _, key := range []string{version, ""} {
+		if key == "" {
+			md5, _ := util.GetFileMD5(file)
+			if len(md5) > 8 {
+				md5 = md5[:8]
+			}
+			key = version + "-" + md5
+		}
+		versioned := path.Join(manager.VersionsDir, key, dest)
+		versionedTarget := filepath.Join(libsPath, filepath.FromSlash(versioned))
+		other, ok := claimed[versionedTarget]
+		if !ok && util.PathExists(versionedTarget) {
+			other = versionedTarget
+		}
+		if other == "" || other == file || sameContent(other, file, versionedTarget) {
+			claimed[versionedTarget] = file
+			conflict.Kept = versionedTarget
+			return versioned, path.Join(manager.VersionsDir, key, probeDir)
+		}
+	}
+
+	// 版本号及md5前缀都相同但内容不同，保留在原处
+	conflict.Kept = file
+	return "", ""
+}
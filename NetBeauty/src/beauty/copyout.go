@@ -0,0 +1,67 @@
+package beauty
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// beautyCopyOut 将src复制到out后beauty副本，src只会被读取，用于只读挂载的发布目录
+func beautyCopyOut(src string, out string) bool {
+	src, _ = filepath.Abs(src)
+	out, _ = filepath.Abs(out)
+
+	if src == out || util.PathWithin(src, out) || util.PathWithin(out, src) {
+		log.LogPanic(fmt.Errorf("--outdir %s must be outside of %s", out, src), 1)
+	}
+	if !util.PathExists(src) {
+		log.LogPanic(fmt.Errorf("%s does not exist", src), 1)
+	}
+	if entries, _ := ioutil.ReadDir(out); len(entries) != 0 {
+		log.LogPanic(fmt.Errorf("--outdir %s is not empty", out), 1)
+	}
+
+	// 复制前检查，避免复制到一半才失败
+	if !util.EnsureDirExists(out, util.DirMode) {
+		log.LogPanic(notWriteableError(out), 1)
+	}
+	if err := checkWriteable(out); err != nil {
+		log.LogPanic(notWriteableError(out), 1)
+	}
+	size := dirSize(src)
+	if free, err := misc.FreeSpace(out); err == nil && uint64(size) > free {
+		log.LogPanic(fmt.Errorf("%s needs %s of free space, only %s is available", out, formatSize(size), formatSize(int64(free))), 1)
+	}
+
+	log.LogDetail(fmt.Sprintf("copying %s to %s", src, out))
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if fi.IsDir() {
+			if !util.EnsureDirExists(filepath.Join(out, rel), util.DirMode) {
+				return notWriteableError(filepath.Join(out, rel))
+			}
+			return nil
+		}
+		return copyEntry(path, fi, filepath.Join(out, rel))
+	})
+	if err != nil {
+		log.LogPanic(fmt.Errorf("copy %s to %s failed: %s", src, out, err.Error()), 1)
+	}
+
+	beautyDir = out
+	beauty()
+
+	// 副本总是新的输出
+	return true
+}
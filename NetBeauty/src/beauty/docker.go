@@ -0,0 +1,114 @@
+package beauty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var dockerSplit = ""
+
+// docker-split输出目录下的两个层及Dockerfile片段
+const (
+	dockerLibsLayer = "libs"
+	dockerAppLayer  = "app"
+	dockerSnippet   = "Dockerfile.snippet"
+)
+
+const dockerfileSnippet = `# generated by nbeauty %s
+# the dependencies in libs/ rarely change, copy them first so their layer is reused
+# when only the app itself changes. paths are relative to %s
+WORKDIR /app
+COPY %s/ ./
+COPY %s/ ./
+`
+
+// splitForDocker 将beautyDir复制为libsDir（不常变化）及其余文件（应用本身）两个目录，并生成Dockerfile片段
+func splitForDocker(out string) error {
+	if err := outsideBeautyDir(out); err != nil {
+		return err
+	}
+
+	libsLayer := filepath.Join(out, dockerLibsLayer)
+	appLayer := filepath.Join(out, dockerAppLayer)
+	for _, dir := range []string{libsLayer, appLayer} {
+		if err := util.CheckWrite("remove", dir); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		if !util.EnsureDirExists(dir, util.DirMode) {
+			return notWriteableError(dir)
+		}
+	}
+
+	libsCount, appCount := 0, 0
+	libsRoot := filepath.Join(beautyDir, libsDir)
+	err := filepath.Walk(beautyDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(beautyDir, path)
+		if err != nil || isRunFile(rel) {
+			return err
+		}
+
+		layer := appLayer
+		if path == libsRoot || strings.HasPrefix(path, libsRoot+string(filepath.Separator)) {
+			layer = libsLayer
+			libsCount++
+		} else {
+			appCount++
+		}
+
+		return copyEntry(path, fi, filepath.Join(layer, rel))
+	})
+	if err != nil {
+		return err
+	}
+
+	snippet := fmt.Sprintf(dockerfileSnippet, Version, out, dockerLibsLayer, dockerAppLayer)
+	if err := util.FileSystem.WriteFile(filepath.Join(out, dockerSnippet), []byte(snippet), util.FileMode); err != nil {
+		return err
+	}
+
+	log.LogDetail(fmt.Sprintf("docker layers written to %s: %d dependency file(s), %d app file(s)", out, libsCount, appCount))
+	return nil
+}
+
+// outsideBeautyDir 检查输出目录不在beautyDir中，否则复制时会包含输出本身
+func outsideBeautyDir(out string) error {
+	abs, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(beautyDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%s is inside %s", out, beautyDir)
+	}
+	return nil
+}
+
+// copyEntry 复制文件，符号链接按原样重建
+func copyEntry(src string, fi os.FileInfo, dst string) error {
+	if fi.Mode()&os.ModeSymlink == 0 {
+		_, err := util.CopyFile(src, dst)
+		return err
+	}
+
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if !util.EnsureDirExists(filepath.Dir(dst), util.DirMode) {
+		return notWriteableError(filepath.Dir(dst))
+	}
+	if err := util.CheckWrite("link", dst); err != nil {
+		return err
+	}
+	return os.Symlink(target, dst)
+}
@@ -0,0 +1,18 @@
+package beauty
+
+import (
+	"time"
+
+	event "github.com/nulastudio/NetBeauty/src/event"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// startStage 开始计时并输出stage_started事件
+func startStage(name string) func() {
+	event.Emit(event.StageStarted, event.Data{"stage": name})
+	return log.StartStage(name)
+}
+
+func emitFileMoved(src string, des string, size int64, duration time.Duration) {
+	event.Emit(event.FileMoved, event.Data{"from": src, "to": des, "size": size, "duration": duration.Seconds()})
+}
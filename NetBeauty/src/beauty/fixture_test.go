@@ -0,0 +1,118 @@
+package beauty
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+func init() {
+	// 测试不访问网络
+	policy := manager.DefaultNetworkPolicy()
+	policy.NoNetwork = true
+	manager.SetNetworkPolicy(policy)
+}
+
+// publishDir 将testdata中的发布目录fixture复制到临时目录下的app，返回app的路径及删除临时目录的函数
+func publishDir(t *testing.T, fixture string) (string, func()) {
+	root, err := ioutil.TempDir("", "nbeauty-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// macOS下临时目录经由符号链接
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	cleanup := func() { os.RemoveAll(root) }
+
+	src := filepath.Join("..", "testdata", fixture)
+	dir := filepath.Join(root, "app")
+	err = filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if fi.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), 0777)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), content, 0644)
+	})
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return dir, cleanup
+}
+
+// writeFiles 在dir中写入files（"/"分隔的相对路径 => 内容）
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// replaceInFile 将file中的old替换为new
+func replaceInFile(t *testing.T, file string, old string, new string) {
+	content := readFile(t, file)
+	if !strings.Contains(content, old) {
+		t.Fatalf("%s does not contain %s", file, old)
+	}
+	writeFiles(t, filepath.Dir(file), map[string]string{filepath.Base(file): strings.Replace(content, old, new, -1)})
+}
+
+// testOptions 日志只收集Warning及以上到messages（可为nil）的选项
+func testOptions(dir string, messages *[]string) Options {
+	opts := DefaultOptions()
+	opts.Dir = dir
+	opts.LibsDir = "libs"
+	opts.Logger = log.HandlerFunc(func(message string, level log.LogLevel, fields log.Fields) {
+		if messages != nil && level <= log.Warning {
+			*messages = append(*messages, message)
+		}
+	})
+	return opts
+}
+
+// beautify 以opts执行beauty，出错时测试失败
+func beautify(t *testing.T, opts Options) Result {
+	result, err := Beautify(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("beauty %s failed: %s", opts.Dir, err.Error())
+	}
+	return result
+}
+
+// readFile 读取文件内容，不存在时返回空字符串
+func readFile(t *testing.T, file string) string {
+	content, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// containsMessage messages中是否有包含substr的日志
+func containsMessage(messages []string, substr string) bool {
+	for _, message := range messages {
+		if strings.Contains(message, substr) {
+			return true
+		}
+	}
+	return false
+}
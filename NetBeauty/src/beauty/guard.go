@@ -0,0 +1,72 @@
+package beauty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// skipPublishCheck --i-know-what-im-doing，允许beauty看起来不是发布目录的目录
+var skipPublishCheck = false
+
+// tfmPattern 目标框架目录名，如net8.0、net6.0-windows、netcoreapp3.1
+var tfmPattern = regexp.MustCompile(`^net(coreapp)?\d+\.\d+(-[\w.]+)?$`)
+
+// checkPublishDir 检查beautyDir是否为build输出而不是publish输出，是时拒绝继续，避免破坏构建目录
+func checkPublishDir() {
+	if skipPublishCheck || len(manager.FindExeConfig(beautyDir)) != 0 {
+		return
+	}
+
+	reasons := buildOutputSigns(beautyDir)
+	if len(reasons) == 0 {
+		return
+	}
+	log.LogPanic(log.NewHintError(fmt.Errorf("%s does not look like a publish directory:\n  - %s", beautyDir, strings.Join(reasons, "\n  - ")), beautyDir,
+		"beauty moves files around and would break the build output of the project",
+		"beauty the output of `dotnet publish` instead, or pass --i-know-what-im-doing if this really is the directory to beauty"), 1)
+}
+
+// buildOutputSigns 返回目录是build输出的迹象
+func buildOutputSigns(dir string) []string {
+	reasons := make([]string, 0)
+
+	// bin/<Configuration>/<tfm>[/<rid>]，默认的发布目录为其下的publish
+	absDir, _ := filepath.Abs(dir)
+	parts := strings.Split(filepath.ToSlash(absDir), "/")
+	for i := len(parts) - 1; i >= 2 && i >= len(parts)-2 && !strings.EqualFold(parts[len(parts)-1], "publish"); i-- {
+		if tfmPattern.MatchString(strings.ToLower(parts[i])) && strings.EqualFold(parts[i-2], "bin") {
+			reasons = append(reasons, fmt.Sprintf("it is the build output bin/%s/%s, not a publish folder", parts[i-1], parts[i]))
+			break
+		}
+	}
+
+	for _, name := range []string{"ref", "refint"} {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && fi.IsDir() {
+			reasons = append(reasons, fmt.Sprintf("it contains the reference assembly folder %s/", name))
+		}
+	}
+
+	for _, runtimeConfig := range manager.FindRuntimeConfigJSON(dir) {
+		name := filepath.Base(runtimeConfig)
+		if strings.HasSuffix(name, ".runtimeconfig.dev.json") {
+			reasons = append(reasons, fmt.Sprintf("it contains %s, which only exists in build outputs", name))
+			continue
+		}
+		if !strings.HasSuffix(name, ".runtimeconfig.json") {
+			continue
+		}
+		app := strings.TrimSuffix(name, ".runtimeconfig.json")
+		if !util.PathExists(filepath.Join(dir, app+".deps.json")) {
+			reasons = append(reasons, fmt.Sprintf("%s has no %s.deps.json", app, app))
+		}
+	}
+
+	return reasons
+}
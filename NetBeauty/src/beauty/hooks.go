@@ -0,0 +1,73 @@
+package beauty
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+// HookPoint 可以注册钩子的位置
+type HookPoint string
+
+const (
+	// PreFixDeps 修改deps.json/runtimeconfig.json/exe.config之前，File为该文件
+	PreFixDeps HookPoint = "PreFixDeps"
+	// PostFixDeps 分析出依赖之后，Deps为将要移动的依赖，可增删
+	// deps.json在钩子执行之后只改写最终的Deps，runtimeconfig.json的钩子在修改之后执行
+	PostFixDeps HookPoint = "PostFixDeps"
+	// PreMove 移动依赖之前（deps.json改写之前），Deps同PostFixDeps，跳过时deps.json中的依赖项保持不变，apphost策略下为nil
+	PreMove HookPoint = "PreMove"
+	// PostMove 移动依赖之后，Moved为移动的文件数
+	PostMove HookPoint = "PostMove"
+	// PrePatch 替换hostfxr之前，File为hostfxr
+	PrePatch HookPoint = "PrePatch"
+	// PostPatch 替换hostfxr之后，Patched为是否成功，可在此重新签名等
+	PostPatch HookPoint = "PostPatch"
+)
+
+// StageData 钩子收到的阶段数据
+type StageData struct {
+	Point   HookPoint
+	Dir     string
+	LibsDir string
+	// 当前处理的文件，为空时表示整个目录
+	File string
+	// 将要移动的依赖，PostFixDeps、PreMove钩子可修改
+	Deps []manager.Deps
+	// PostMove
+	Moved int
+	// PrePatch、PostPatch
+	FXRVersion string
+	RID        string
+	Patched    bool
+}
+
+// Hook 阶段钩子，Pre*钩子返回ErrSkip时跳过该阶段，返回其它错误时中止beauty
+type Hook func(stage *StageData) error
+
+// ErrSkip 由Pre*钩子返回，跳过当前阶段
+var ErrSkip = errors.New("skip this stage")
+
+var hooks map[HookPoint][]Hook
+
+// runHooks 依次执行point上的钩子，返回false表示应跳过该阶段
+func runHooks(point HookPoint, stage *StageData) bool {
+	stage.Point = point
+	stage.Dir = beautyDir
+	stage.LibsDir = libsDir
+
+	for _, hook := range hooks[point] {
+		err := hook(stage)
+		if err == nil {
+			continue
+		}
+		if err == ErrSkip {
+			log.LogDetail(fmt.Sprintf("%s skipped by hook: %s", point, stage.File))
+			return false
+		}
+		log.LogPanic(fmt.Errorf("%s hook failed: %s", point, err.Error()), 1)
+	}
+	return true
+}
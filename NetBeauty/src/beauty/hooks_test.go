@@ -0,0 +1,70 @@
+package beauty
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+const newtonsoftEntry = "lib/netstandard2.0/Newtonsoft.Json.dll"
+
+func TestDepsRemovedByHooksStayInDepsJSON(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+
+	opts := testOptions(dir, nil)
+	opts.Hooks = map[HookPoint][]Hook{
+		PostFixDeps: {func(stage *StageData) error {
+			if !strings.Contains(readFile(t, filepath.Join(dir, "app.deps.json")), newtonsoftEntry) {
+				t.Error("deps.json has been rewritten before the PostFixDeps hooks")
+			}
+			return nil
+		}},
+		PreMove: {func(stage *StageData) error {
+			kept := make([]manager.Deps, 0)
+			for _, dep := range stage.Deps {
+				if dep.Name != "Newtonsoft.Json.dll" {
+					kept = append(kept, dep)
+				}
+			}
+			stage.Deps = kept
+			return nil
+		}},
+	}
+	beautify(t, opts)
+
+	if !util.PathExists(filepath.Join(dir, "Newtonsoft.Json.dll")) {
+		t.Error("Newtonsoft.Json.dll removed by the hook has been moved")
+	}
+	deps := readFile(t, filepath.Join(dir, "app.deps.json"))
+	if !strings.Contains(deps, newtonsoftEntry) {
+		t.Error("the deps.json entry of Newtonsoft.Json.dll removed by the hook has been rewritten")
+	}
+	if strings.Contains(deps, "runtimes/linux-x64/native/libfoo.so") {
+		t.Error("the deps.json entry of libfoo.so has not been rewritten")
+	}
+}
+
+func TestSkippedMoveKeepsDepsJSON(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+
+	opts := testOptions(dir, nil)
+	opts.Hooks = map[HookPoint][]Hook{
+		PreMove: {func(stage *StageData) error { return ErrSkip }},
+	}
+	result := beautify(t, opts)
+
+	if result.MovedFiles != 0 {
+		t.Errorf("moved %d files after the move was skipped", result.MovedFiles)
+	}
+	deps := readFile(t, filepath.Join(dir, "app.deps.json"))
+	for _, entry := range []string{newtonsoftEntry, "runtimes/linux-x64/native/libfoo.so"} {
+		if !strings.Contains(deps, entry) {
+			t.Errorf("the deps.json entry %s has been rewritten after the move was skipped", entry)
+		}
+	}
+}
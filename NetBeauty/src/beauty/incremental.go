@@ -0,0 +1,96 @@
+package beauty
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+var incremental = false
+
+// unchanged --incremental时上次beauty后未变化且当时保持原样的文件，本次不再检查；nil表示检查所有文件
+var unchanged map[string]bool
+
+// previousManifest --incremental时上次写入的移动清单
+var previousManifest *squirrelManifest
+
+// loadIncremental 根据上次的标记文件及移动清单找出无需再检查的文件
+func loadIncremental() {
+	unchanged, previousManifest = nil, nil
+	if !incremental {
+		return
+	}
+	// 未变化的目录会直接跳过
+	if _, err := manager.ValidateBeautyMarker(beautyDir, libsDir); err == nil && !force {
+		return
+	}
+
+	// 标记文件的修改时间即上次beauty结束的时间
+	markerInfo, err := os.Stat(manager.MarkerPath(beautyDir))
+	if err != nil {
+		log.LogDetail("incremental: the directory has not been beautified before, examining all files")
+		return
+	}
+	marker, err := manager.ReadBeautyMarker(beautyDir)
+	if err != nil || marker.LibsDir != libsDir || marker.SharedRuntimeMode != sharedRuntimeMode {
+		log.LogDetail("incremental: the previous beauty used different options, examining all files")
+		return
+	}
+	bytes, err := ioutil.ReadFile(filepath.Join(beautyDir, SquirrelManifestName))
+	manifest := &squirrelManifest{}
+	if err != nil || json.Unmarshal(bytes, manifest) != nil || manifest.LibsDir != libsDir || manifest.Kept == nil {
+		log.LogDetail(fmt.Sprintf("incremental: no usable %s, examining all files", SquirrelManifestName))
+		return
+	}
+
+	kept := make(map[string]bool, len(manifest.Kept))
+	for _, rel := range manifest.Kept {
+		kept[rel] = true
+	}
+
+	// 修改时间早于上次写入标记文件且上次保持原样的文件
+	since := markerInfo.ModTime()
+	skipped, total := make(map[string]bool), 0
+	walkKept(func(path string, rel string, fi os.FileInfo) {
+		total++
+		if kept[rel] && fi.ModTime().Before(since) {
+			skipped[path] = true
+		}
+	})
+
+	unchanged, previousManifest = skipped, manifest
+	log.LogDetail(fmt.Sprintf("incremental: %d of %d file(s) changed since %s", total-len(skipped), total, since.Format(time.RFC3339)))
+}
+
+// walkKept 遍历libsDir之外的文件，跳过标记文件、移动清单及journal、锁文件
+func walkKept(fn func(path string, rel string, fi os.FileInfo)) {
+	libsPath := filepath.Join(beautyDir, libsDir)
+	filepath.Walk(beautyDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if fi.IsDir() {
+			if path == libsPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(beautyDir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == manager.BeautyMarkerName || rel == SquirrelManifestName || isRunFile(rel) || strings.HasPrefix(rel, "../") {
+			return nil
+		}
+		fn(path, rel, fi)
+		return nil
+	})
+}
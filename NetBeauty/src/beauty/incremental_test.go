@@ -0,0 +1,78 @@
+package beauty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+func TestIncrementalSkipsUnchangedFiles(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		touched bool
+		moved   bool
+	}{
+		{"unchanged", false, false},
+		{"modified", true, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir, cleanup := publishDir(t, "fdd")
+			defer cleanup()
+			deps := filepath.Join(dir, "app.deps.json")
+			original := readFile(t, deps)
+
+			// 第一次beauty时排除的文件保持原样，记入移动清单
+			opts := testOptions(dir, nil)
+			opts.Incremental = true
+			opts.Excludes = "Newtonsoft.*"
+			beautify(t, opts)
+			if !util.PathExists(filepath.Join(dir, "Newtonsoft.Json.dll")) {
+				t.Fatal("the excluded file has been moved")
+			}
+			if readFile(t, filepath.Join(dir, SquirrelManifestName)) == "" {
+				t.Fatalf("%s has not been written", SquirrelManifestName)
+			}
+
+			// 重新发布：deps.json被覆盖，其余文件早于上次beauty写入的标记文件
+			past := time.Now().Add(-time.Hour)
+			walkFiles(t, dir, func(path string) {
+				if filepath.Base(path) != manager.BeautyMarkerName {
+					os.Chtimes(path, past, past)
+				}
+			})
+			writeFiles(t, dir, map[string]string{"app.deps.json": original})
+			if c.touched {
+				writeFiles(t, dir, map[string]string{"Newtonsoft.Json.dll": "Newtonsoft.Json v2"})
+				future := time.Now().Add(time.Hour)
+				os.Chtimes(filepath.Join(dir, "Newtonsoft.Json.dll"), future, future)
+			}
+
+			opts.Excludes = ""
+			beautify(t, opts)
+			moved := util.PathExists(filepath.Join(dir, "libs", "Newtonsoft.Json.dll"))
+			if moved != c.moved || util.PathExists(filepath.Join(dir, "Newtonsoft.Json.dll")) == c.moved {
+				t.Errorf("Newtonsoft.Json.dll moved = %v, want %v", moved, c.moved)
+			}
+		})
+	}
+}
+
+// walkFiles 对dir下的每个文件执行fn
+func walkFiles(t *testing.T, dir string, fn func(path string)) {
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			fn(path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,256 @@
+package beauty
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+const (
+	// localIOProfile 逐个创建目录、移动文件
+	localIOProfile = "local"
+	// networkIOProfile 先一次性创建所有目录，整个目录移走时直接移动目录，并发移动文件，减少SMB/NFS上的往返
+	networkIOProfile = "network"
+)
+
+// defaultIOConcurrency network模式下默认同时进行的文件操作数
+const defaultIOConcurrency = 4
+
+var ioProfile = localIOProfile
+var ioConcurrency = defaultIOConcurrency
+
+// pendingMove network模式下等待移动的依赖
+type pendingMove struct {
+	dep  manager.Deps
+	from string
+	to   string
+
+	size     int64
+	duration time.Duration
+	err      error
+}
+
+// movePending 移动network模式下收集的依赖，返回成功移动的数量
+func movePending(pending []*pendingMove) int {
+	if len(pending) == 0 {
+		return 0
+	}
+
+	dirMoves, fileMoves := planDirMoves(pending)
+
+	// 整个目录移走时只需一次重命名，失败时退回逐个移动
+	froms := make([]string, 0, len(dirMoves))
+	for from := range dirMoves {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		checkCanceled()
+		to := dirMoves[from]
+		start := time.Now()
+		err := util.FileSystem.MkdirAll(filepath.Dir(to), util.DirMode)
+		if err == nil {
+			journalMoving(from, to)
+			err = util.MoveFile(from, to)
+		}
+		for _, move := range pending {
+			if filepath.Dir(move.from) != from {
+				continue
+			}
+			if err != nil {
+				fileMoves = append(fileMoves, move)
+			} else {
+				move.duration = time.Since(start)
+			}
+		}
+		if err != nil {
+			log.LogDetail(fmt.Sprintf("cannot move %s as a whole, moving its files one by one: %s", from, err.Error()))
+		}
+	}
+
+	// 目录一次性创建，更深的目录已包含上级目录
+	dirs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, move := range fileMoves {
+		if dir := filepath.Dir(move.to); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Strings(dirs)
+	leaves := make([]string, 0, len(dirs))
+	for i, dir := range dirs {
+		if i+1 < len(dirs) && strings.HasPrefix(dirs[i+1], dir+string(filepath.Separator)) {
+			continue
+		}
+		leaves = append(leaves, dir)
+	}
+	parallel(len(leaves), func(i int) {
+		if err := util.FileSystem.MkdirAll(leaves[i], util.DirMode); err != nil {
+			log.LogError(notWriteableError(leaves[i]), false)
+		}
+	})
+
+	// 其余文件并发移动
+	parallel(len(fileMoves), func(i int) {
+		move := fileMoves[i]
+		if fi, err := util.FileSystem.Stat(move.from); err == nil {
+			move.size = fi.Size()
+		}
+		start := time.Now()
+		journalMoving(move.from, move.to)
+		if move.err = util.MoveFile(move.from, move.to); move.err == nil {
+			move.duration = time.Since(start)
+		}
+		for _, extFile := range []string{".pdb", ".xml"} {
+			if util.PathExists(move.from + extFile) {
+				journalMoving(move.from+extFile, move.to+extFile)
+				util.MoveFile(move.from+extFile, move.to+extFile)
+			}
+		}
+	})
+
+	// 按原顺序记录结果
+	moved := 0
+	for _, move := range pending {
+		if move.err != nil {
+			summary.failedFiles++
+			log.LogErrorFields(move.err, log.Fields{"file": move.from})
+			continue
+		}
+		recordRelocated(move.dep, move.from, move.to)
+		moved++
+		summary.movedBytes += move.size
+		emitFileMoved(move.from, move.to, move.size, move.duration)
+	}
+
+	// 移空的目录最后统一删除
+	emptied := make([]string, 0)
+	seen = make(map[string]bool)
+	for _, move := range fileMoves {
+		if dir := filepath.Dir(move.from); !seen[dir] {
+			seen[dir] = true
+			emptied = append(emptied, dir)
+		}
+	}
+	for _, dir := range emptied {
+		if entries, _ := util.FileSystem.ReadDir(dir); len(entries) == 0 {
+			util.FileSystem.Remove(dir)
+		}
+	}
+
+	return moved
+}
+
+// planDirMoves 找出其中的文件（及.pdb、.xml）全部移到同一个新目录的源目录，返回源目录=>新目录及其余需要逐个移动的文件
+func planDirMoves(pending []*pendingMove) (map[string]string, []*pendingMove) {
+	absDir, _ := filepath.Abs(beautyDir)
+	libsPath := filepath.Join(absDir, libsDir)
+
+	groups := make(map[string][]*pendingMove)
+	for _, move := range pending {
+		dir := filepath.Dir(move.from)
+		groups[dir] = append(groups[dir], move)
+	}
+
+	// 新目录中还会放入其他目录的文件时不能整个移动
+	shared := func(dir string, to string) bool {
+		for _, move := range pending {
+			if filepath.Dir(move.from) != dir && strings.HasPrefix(move.to, to+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	dirMoves := make(map[string]string)
+	for dir, moves := range groups {
+		to := filepath.Dir(moves[0].to)
+		if dir == absDir || strings.HasPrefix(libsPath+string(filepath.Separator), dir+string(filepath.Separator)) || util.PathExists(to) || shared(dir, to) {
+			continue
+		}
+		names := make(map[string]bool)
+		for _, move := range moves {
+			if filepath.Dir(move.to) != to || filepath.Base(move.to) != filepath.Base(move.from) {
+				names = nil
+				break
+			}
+			names[filepath.Base(move.from)] = true
+		}
+		if names == nil {
+			continue
+		}
+		entries, err := util.FileSystem.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		sizes := make(map[string]int64)
+		whole := true
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(names[name] || names[strings.TrimSuffix(name, ".pdb")] || names[strings.TrimSuffix(name, ".xml")]) {
+				whole = false
+				break
+			}
+			sizes[name] = entry.Size()
+		}
+		if !whole {
+			continue
+		}
+		for _, move := range moves {
+			move.size = sizes[filepath.Base(move.from)]
+		}
+		dirMoves[dir] = to
+	}
+
+	fileMoves := make([]*pendingMove, 0, len(pending))
+	for _, move := range pending {
+		if _, ok := dirMoves[filepath.Dir(move.from)]; !ok {
+			fileMoves = append(fileMoves, move)
+		}
+	}
+	return dirMoves, fileMoves
+}
+
+// parallel 以最多ioConcurrency个goroutine执行fn(0)...fn(n-1)
+func parallel(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	next := make(chan int)
+	workers := ioConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// parseIOProfile 检查--io-profile
+func parseIOProfile(profile string) string {
+	switch profile {
+	case "":
+		return localIOProfile
+	case localIOProfile, networkIOProfile:
+		return profile
+	}
+	log.LogPanic(fmt.Errorf("invalid io profile: %s", profile), 1)
+	return ""
+}
@@ -0,0 +1,68 @@
+package beauty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+// treeOf dir下所有文件（"/"分隔的相对路径 => 内容），跳过标记文件、revert journal等每次写入内容不同的文件
+func treeOf(t *testing.T, dir string) map[string]string {
+	tree := make(map[string]string)
+	walkFiles(t, dir, func(path string) {
+		rel, _ := filepath.Rel(dir, path)
+		rel = filepath.ToSlash(rel)
+		if rel == manager.BeautyMarkerName || rel == SquirrelManifestName || isRunFile(rel) {
+			return
+		}
+		tree[rel] = readFile(t, path)
+	})
+	return tree
+}
+
+func TestNetworkIOProfileMatchesLocal(t *testing.T) {
+	trees := make(map[string]map[string]string)
+	for _, profile := range []string{localIOProfile, networkIOProfile} {
+		dir, cleanup := publishDir(t, "fdd")
+		defer cleanup()
+
+		var messages []string
+		opts := testOptions(dir, &messages)
+		opts.IOProfile = profile
+		opts.IOConcurrency = 2
+		result := beautify(t, opts)
+		if !result.Modified || len(messages) != 0 {
+			t.Fatalf("%s: modified = %v, messages = %v", profile, result.Modified, messages)
+		}
+		// 整个目录移走时不留下空目录
+		if _, err := os.Stat(filepath.Join(dir, "runtimes", "linux-x64", "native")); !os.IsNotExist(err) {
+			t.Errorf("%s: runtimes/linux-x64/native has not been removed", profile)
+		}
+		trees[profile] = treeOf(t, dir)
+		if _, ok := trees[profile]["libs/Newtonsoft.Json.dll"]; !ok {
+			t.Errorf("%s: Newtonsoft.Json.dll has not been moved", profile)
+		}
+	}
+
+	if !reflect.DeepEqual(trees[localIOProfile], trees[networkIOProfile]) {
+		t.Errorf("--io-profile network differs from local:\n%v\n%v", trees[localIOProfile], trees[networkIOProfile])
+	}
+}
+
+func TestInvalidIOProfile(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+
+	opts := testOptions(dir, nil)
+	opts.IOProfile = "smb"
+	if _, err := Beautify(context.Background(), opts); err == nil {
+		t.Error("an invalid io profile is accepted")
+	}
+	if readFile(t, filepath.Join(dir, "Newtonsoft.Json.dll")) == "" {
+		t.Error("files have been moved with an invalid io profile")
+	}
+}
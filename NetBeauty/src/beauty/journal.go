@@ -0,0 +1,342 @@
+package beauty
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// JournalName 记录本次beauty修改的日志文件，中断后保留用于Recover，正常结束时追加到RevertJournalName
+const JournalName = "NetCoreBeauty.journal"
+
+// RevertJournalName 已完成的beauty的journal，供Revert撤销，路径均为相对beautyDir的"/"分隔路径
+const RevertJournalName = "NetCoreBeauty.revert"
+
+const (
+	journalMove   = "move"
+	journalFile   = "file"
+	journalCreate = "create"
+	journalLink   = "link"
+)
+
+// journalEntry 一条修改记录，move记录文件移动，file记录文件修改前的内容，create记录新建的文件，
+// link记录符号链接修改前指向的路径
+// 大文件修改前的内容不写入journal，而是复制为Backup
+type journalEntry struct {
+	Op      string `json:"op"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Content []byte `json:"content,omitempty"`
+	Backup  string `json:"backup,omitempty"`
+}
+
+var journal *os.File
+
+// journalMutex network模式下并发移动的文件同时写入journal
+var journalMutex sync.Mutex
+
+// journalBackups 本次journal复制的备份数
+var journalBackups = 0
+
+// journalMoves 本次记录的移动，用于写入标记文件中的移动记录
+var journalMoves []journalEntry
+
+// appendRevert 上次beauty的结果仍在目录中，完成后追加到revert journal而不是替换
+var appendRevert = false
+
+// openJournal 打开journal，上次中断留下的journal已由repairPartial处理
+// 非本地的FileSystem中断后不会留下任何修改，不写journal
+func openJournal(dir string) {
+	journal = nil
+	journalMoves = nil
+	appendRevert = false
+	if !util.LocalDisk() {
+		return
+	}
+	path := filepath.Join(dir, JournalName)
+	if err := util.CheckWrite("write", path); err != nil {
+		log.LogPanic(err, 1)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, util.FileMode)
+	if err != nil {
+		log.LogPanic(notWriteableError(dir), 1)
+	}
+	// journal本身也要落盘，否则断电后无法恢复
+	if err := util.Sync(path); err != nil {
+		f.Close()
+		log.LogPanic(err, 1)
+	}
+	journal = f
+	journalBackups = 0
+}
+
+func writeJournal(entry journalEntry) {
+	if journal == nil {
+		return
+	}
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+	journal.Write(append(bytes, '\n'))
+	if util.Durable {
+		journal.Sync()
+	}
+}
+
+// journalMoving 在移动文件前记录，移动失败或未进行时撤销会跳过该记录
+func journalMoving(from string, to string) {
+	entry := journalEntry{Op: journalMove, From: from, To: to}
+	journalMutex.Lock()
+	journalMoves = append(journalMoves, entry)
+	journalMutex.Unlock()
+	writeJournal(entry)
+}
+
+// journalBeforeWrite 在修改文件前记录原内容，指向beautyDir之外的符号链接先替换为副本
+func journalBeforeWrite(file string) {
+	if err := detachOutsideLink(file); err != nil {
+		log.LogErrorFields(fmt.Errorf("cannot detach %s from its link target: %s", file, err.Error()), log.Fields{"file": file})
+	}
+	if journal == nil {
+		return
+	}
+	fi, err := os.Stat(file)
+	if err != nil {
+		return
+	}
+	// 与流式处理deps.json的大小相同，不整体读入内存
+	if fi.Size() >= manager.StreamDepsSize {
+		journalBackups++
+		backup := fmt.Sprintf("%s.%d", journal.Name(), journalBackups)
+		if _, err := util.CopyFile(file, backup); err != nil {
+			return
+		}
+		writeJournal(journalEntry{Op: journalFile, To: file, Backup: backup})
+		return
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return
+	}
+	writeJournal(journalEntry{Op: journalFile, To: file, Content: content})
+}
+
+// journalLinked 在修改符号链接前记录其原来指向的路径
+func journalLinked(file string, link string) {
+	writeJournal(journalEntry{Op: journalLink, From: link, To: file})
+}
+
+// journalCreating 在新建文件前记录，文件已存在时按修改处理
+func journalCreating(file string) {
+	if util.PathExists(file) {
+		journalBeforeWrite(file)
+		return
+	}
+	writeJournal(journalEntry{Op: journalCreate, To: file})
+}
+
+// closeJournal 关闭journal，completed时追加到revert journal，否则保留用于Recover
+func closeJournal(completed bool) {
+	if journal == nil {
+		return
+	}
+	path := journal.Name()
+	journal.Close()
+	journal = nil
+
+	if completed {
+		keepRevertJournal(path)
+		os.Remove(path)
+		removeJournalBackups(path)
+	} else {
+		log.LogWarning(fmt.Sprintf("beauty was interrupted, run `nbeauty recover %s` to undo the changes, or run again with --repair=resume", filepath.Dir(path)))
+	}
+}
+
+// rollbackJournal 关闭journal并立即撤销其中记录的修改
+func rollbackJournal(dir string) error {
+	if journal != nil {
+		journal.Close()
+		journal = nil
+	}
+	return replayJournal(dir)
+}
+
+// readJournal 读取journal中的记录
+func readJournal(path string) ([]journalEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := make([]journalEntry, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry journalEntry
+		// 最后一行可能因中断而不完整
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// keepRevertJournal 将完成的journal中的记录追加到revert journal，备份一起改名保留
+// 上次beauty的结果已不在目录中（重新发布过）时，旧的记录不再适用，先删除
+func keepRevertJournal(path string) {
+	entries, err := readJournal(path)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+
+	dir := filepath.Dir(path)
+	revertPath := filepath.Join(dir, RevertJournalName)
+	if !appendRevert {
+		os.Remove(revertPath)
+		removeJournalBackups(revertPath)
+	}
+	existing, _ := filepath.Glob(revertPath + ".*")
+	backups := len(existing)
+
+	f, err := os.OpenFile(revertPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, util.FileMode)
+	if err != nil {
+		log.LogWarning(fmt.Sprintf("cannot write %s, the run cannot be reverted: %s", revertPath, err.Error()))
+		return
+	}
+	defer f.Close()
+
+	// 目录整体移动后仍可撤销
+	rel := func(file string) string {
+		if file == "" || !util.PathWithin(dir, file) {
+			return file
+		}
+		if r, err := filepath.Rel(dir, file); err == nil {
+			return filepath.ToSlash(r)
+		}
+		return file
+	}
+	for _, entry := range entries {
+		if entry.Backup != "" {
+			backups++
+			backup := fmt.Sprintf("%s.%d", revertPath, backups)
+			if err := os.Rename(entry.Backup, backup); err != nil {
+				log.LogWarning(fmt.Sprintf("cannot keep %s, %s cannot be reverted: %s", entry.Backup, entry.To, err.Error()))
+				continue
+			}
+			entry.Backup = rel(backup)
+		}
+		// link记录的From为链接原来的内容
+		if entry.Op != journalLink {
+			entry.From = rel(entry.From)
+		}
+		entry.To = rel(entry.To)
+		bytes, err := json.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		f.Write(append(bytes, '\n'))
+	}
+	if util.Durable {
+		f.Sync()
+	}
+}
+
+// isRevertFile 判断rel是否为revert journal及其备份
+func isRevertFile(rel string) bool {
+	return rel == RevertJournalName || strings.HasPrefix(rel, RevertJournalName+".")
+}
+
+// replayJournal 按相反顺序撤销journal中记录的修改
+func replayJournal(dir string) error {
+	return replayJournalFile(dir, filepath.Join(dir, JournalName))
+}
+
+// replayJournalFile 按相反顺序撤销path中记录的修改，相对路径相对于dir，全部撤销后删除path
+func replayJournalFile(dir string, path string) error {
+	entries, err := readJournal(path)
+	if err != nil {
+		return err
+	}
+
+	abs := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, filepath.FromSlash(file))
+	}
+
+	failed := 0
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		entry.To, entry.Backup = abs(entry.To), abs(entry.Backup)
+		if entry.Op != journalLink {
+			entry.From = abs(entry.From)
+		}
+		switch entry.Op {
+		case journalMove:
+			// 符号链接本身被移动，不论其指向是否存在
+			if _, err := os.Lstat(entry.To); err != nil {
+				continue
+			}
+			// 记录之后移动未进行（To为被替换的原有文件）
+			if _, err := os.Lstat(entry.From); err == nil {
+				continue
+			}
+			log.LogDetail(fmt.Sprintf("moving back %s", entry.From))
+			// 移空的原目录已被删除
+			if err = util.FileSystem.MkdirAll(filepath.Dir(entry.From), util.DirMode); err == nil {
+				err = util.MoveFile(entry.To, entry.From)
+			}
+		case journalFile:
+			log.LogDetail(fmt.Sprintf("restoring %s", entry.To))
+			if entry.Backup != "" {
+				_, err = util.CopyFile(entry.Backup, entry.To)
+			} else if err = util.FileSystem.WriteFile(entry.To, entry.Content, util.FileMode); err == nil {
+				err = util.Sync(entry.To)
+			}
+		case journalLink:
+			log.LogDetail(fmt.Sprintf("relinking %s -> %s", entry.To, entry.From))
+			err = relink(entry.To, entry.From)
+		case journalCreate:
+			if !util.PathExists(entry.To) {
+				continue
+			}
+			log.LogDetail(fmt.Sprintf("removing %s", entry.To))
+			err = util.FileSystem.Remove(entry.To)
+		default:
+			continue
+		}
+		if err != nil {
+			failed++
+			log.LogErrorFields(fmt.Errorf("recover failed: %s : %s", entry.To, err.Error()), log.Fields{"file": entry.To})
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d change(s) cannot be undone, the journal is kept in %s", failed, path)
+	}
+
+	removeJournalBackups(path)
+	return os.Remove(path)
+}
+
+// removeJournalBackups 删除journal复制的备份
+func removeJournalBackups(path string) {
+	backups, _ := filepath.Glob(path + ".*")
+	for _, backup := range backups {
+		os.Remove(backup)
+	}
+}
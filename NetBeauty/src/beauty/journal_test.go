@@ -0,0 +1,38 @@
+package beauty
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayJournalSkipsMovesThatDidNotHappen(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	writeFiles(t, dir, map[string]string{
+		"libs/Newtonsoft.Json.dll": "previous",
+		"libs/app.dll":             "moved",
+	})
+	if err := os.Remove(filepath.Join(dir, "app.dll")); err != nil {
+		t.Fatal(err)
+	}
+
+	openJournal(dir)
+	// 移动已完成
+	journalMoving(filepath.Join(dir, "app.dll"), filepath.Join(dir, "libs", "app.dll"))
+	// 记录之后中断，移动未进行
+	journalMoving(filepath.Join(dir, "Newtonsoft.Json.dll"), filepath.Join(dir, "libs", "Newtonsoft.Json.dll"))
+	if err := rollbackJournal(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readFile(t, filepath.Join(dir, "app.dll")); content != "moved" {
+		t.Errorf("the completed move has not been undone: %q", content)
+	}
+	if content := readFile(t, filepath.Join(dir, "Newtonsoft.Json.dll")); content != "Newtonsoft.Json 13.0.1\n" {
+		t.Errorf("the move that did not happen has been undone over the original file: %q", content)
+	}
+	if content := readFile(t, filepath.Join(dir, "libs", "Newtonsoft.Json.dll")); content != "previous" {
+		t.Errorf("the file in the way of the move that did not happen has been moved: %q", content)
+	}
+}
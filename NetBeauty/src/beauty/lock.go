@@ -0,0 +1,61 @@
+package beauty
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// LockName beauty期间占用目录的锁文件，结束时删除
+const LockName = "NetCoreBeauty.lock"
+
+// lockOwner 锁文件的内容
+type lockOwner struct {
+	PID     int    `json:"pid"`
+	Host    string `json:"host"`
+	Started string `json:"started"`
+}
+
+// lockDir 在dir中创建锁文件并加操作系统的锁，锁已被其他进程持有时直接失败，返回释放锁的函数
+// 持有者崩溃时锁随进程释放，留下的锁文件下次直接重用
+// 内存等非本地的FileSystem不会被其他进程同时修改，不需要锁
+func lockDir(dir string) func() {
+	if !util.LocalDisk() {
+		return func() {}
+	}
+	path := filepath.Join(dir, LockName)
+
+	host, _ := os.Hostname()
+	owner, _ := json.Marshal(lockOwner{PID: os.Getpid(), Host: host, Started: time.Now().UTC().Format(time.RFC3339)})
+	lock, err := util.TryLock(path, owner, util.FileMode)
+	if err == util.ErrLocked {
+		log.LogPanic(log.NewHintError(fmt.Errorf("%s is being beautified by another process (%s)", dir, describeLock(path)), path,
+			"another nbeauty is running on the same directory",
+			"wait for it to finish"), 1)
+	} else if err != nil {
+		log.LogPanic(notWriteableError(dir), 1)
+	}
+	return lock.Unlock
+}
+
+// describeLock 锁持有者的描述，用于提示
+func describeLock(path string) string {
+	content, err := ioutil.ReadFile(path)
+	owner := lockOwner{}
+	if err != nil || json.Unmarshal(content, &owner) != nil || owner.PID == 0 {
+		return "unknown owner"
+	}
+	return fmt.Sprintf("pid %d on %s since %s", owner.PID, owner.Host, owner.Started)
+}
+
+// isRunFile 判断rel是否为位于目录根部的journal、revert journal、锁文件，复制目录时应跳过
+func isRunFile(rel string) bool {
+	return rel == JournalName || rel == LockName || strings.HasPrefix(rel, JournalName+".") || isRevertFile(rel)
+}
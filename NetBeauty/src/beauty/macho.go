@@ -0,0 +1,52 @@
+package beauty
+
+import (
+	"debug/macho"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// fatSliceRIDs 返回universal Mach-O中各架构对应的RID，非universal时返回nil
+func fatSliceRIDs(file string) []string {
+	fat, err := macho.OpenFat(file)
+	if err != nil {
+		return nil
+	}
+	defer fat.Close()
+
+	rids := make([]string, 0, len(fat.Arches))
+	for _, arch := range fat.Arches {
+		switch arch.Cpu {
+		case macho.CpuAmd64:
+			rids = append(rids, "osx-x64")
+		case macho.CpuArm64:
+			rids = append(rids, "osx-arm64")
+		default:
+			log.LogDetail(fmt.Sprintf("unsupported architecture in %s: %s", file, arch.Cpu.String()))
+			return nil
+		}
+	}
+	return rids
+}
+
+// createUniversalArtifact 使用lipo将各架构的补丁合并为universal hostfxr
+func createUniversalArtifact(fxrVersion string, rids []string, artifacts []string) (string, error) {
+	des := manager.LocalArtifactFile(fxrVersion, strings.Join(rids, "+"))
+	if !util.EnsureDirExists(filepath.Dir(des), util.CacheDirMode) {
+		return "", fmt.Errorf("cannot create path: %s", filepath.Dir(des))
+	}
+
+	args := append([]string{"-create", "-output", des}, artifacts...)
+	output, err := exec.Command("lipo", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s : %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+
+	return des, nil
+}
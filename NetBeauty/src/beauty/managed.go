@@ -0,0 +1,35 @@
+package beauty
+
+import (
+	"fmt"
+	"path/filepath"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// guardWrites beauty期间只允许修改beautyDir、libsDir、缓存目录及指定的输出，
+// 由deps.json等计算出的其他路径都是规划错误，返回的函数解除限制并返回被拒绝的修改
+func guardWrites() func() []error {
+	return util.GuardWrites(
+		beautyDir,
+		filepath.Join(beautyDir, libsDir),
+		manager.CacheDir(),
+		reportFile,
+		sbomFile,
+		dockerSplit,
+		appDir,
+	)
+}
+
+// checkRefused 有被拒绝的修改时以错误退出
+func checkRefused(refused []error) {
+	if len(refused) == 0 {
+		return
+	}
+	for _, err := range refused {
+		log.LogError(err, false)
+	}
+	log.LogPanic(fmt.Errorf("%d change(s) outside of %s and its managed paths were refused, the layout is incomplete", len(refused), beautyDir), 1)
+}
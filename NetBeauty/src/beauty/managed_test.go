@@ -0,0 +1,178 @@
+package beauty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+func TestBeautyMovesDeps(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+
+	result := beautify(t, testOptions(dir, nil))
+	if result.MovedFiles == 0 || result.FailedFiles != 0 {
+		t.Fatalf("moved %d, failed %d", result.MovedFiles, result.FailedFiles)
+	}
+	for _, file := range []string{"Newtonsoft.Json.dll", "runtimes/linux-x64/native/libfoo.so", "locales/zh-Hans/Foo.Res.resources.dll"} {
+		if !util.PathExists(filepath.Join(dir, "libs", filepath.FromSlash(file))) {
+			t.Errorf("%s has not been moved into libs", file)
+		}
+	}
+	if util.PathExists(filepath.Join(dir, "Newtonsoft.Json.dll")) {
+		t.Error("Newtonsoft.Json.dll is still in the root")
+	}
+}
+
+func TestDepsOutsideBeautyDirAreRefused(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	replaceInFile(t, filepath.Join(dir, "app.deps.json"), "runtimes/linux-x64/native/libfoo.so", "../outside/libfoo.so")
+	outside := filepath.Join(filepath.Dir(dir), "outside", "libfoo.so")
+	writeFiles(t, filepath.Dir(dir), map[string]string{"outside/libfoo.so": "outside"})
+
+	var messages []string
+	result := beautify(t, testOptions(dir, &messages))
+	if result.FailedFiles == 0 {
+		t.Error("the entry pointing out of the publish dir is not counted as failed")
+	}
+	if readFile(t, outside) != "outside" {
+		t.Errorf("%s has been changed", outside)
+	}
+	if !containsMessage(messages, "points out of") {
+		t.Errorf("the rejected entry is not reported: %v", messages)
+	}
+}
+
+// escapePlacement 将所有依赖放到libsDir之外
+type escapePlacement struct{}
+
+func (escapePlacement) Place(dep manager.Deps, file string, rel string) (string, string) {
+	return "../../escaped/" + rel, ""
+}
+
+func TestPlacementOutsideLibsDirIsRefused(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+
+	opts := testOptions(dir, nil)
+	opts.Placement = escapePlacement{}
+	result := beautify(t, opts)
+	if result.MovedFiles != 0 || result.FailedFiles == 0 {
+		t.Errorf("moved %d, failed %d", result.MovedFiles, result.FailedFiles)
+	}
+	if util.PathExists(filepath.Join(filepath.Dir(dir), "escaped")) {
+		t.Error("files have been placed out of libs")
+	}
+	if !util.PathExists(filepath.Join(dir, "Newtonsoft.Json.dll")) {
+		t.Error("Newtonsoft.Json.dll has been moved")
+	}
+}
+
+func TestWritesOutsideManagedPathsFailTheRun(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	outside := filepath.Join(filepath.Dir(dir), "outside.dll")
+
+	var moveErr error
+	opts := testOptions(dir, nil)
+	opts.Hooks = map[HookPoint][]Hook{
+		PostMove: {func(stage *StageData) error {
+			writeFiles(t, filepath.Dir(dir), map[string]string{"victim.dll": "victim"})
+			moveErr = util.MoveFile(filepath.Join(filepath.Dir(dir), "victim.dll"), outside)
+			return nil
+		}},
+	}
+	_, err := Beautify(context.Background(), opts)
+	if _, ok := moveErr.(*util.UnmanagedPathError); !ok {
+		t.Fatalf("the move out of the managed paths is not refused: %v", moveErr)
+	}
+	if util.PathExists(outside) {
+		t.Errorf("%s has been written", outside)
+	}
+	if exitErr, ok := err.(*ExitError); !ok || exitErr.Code != 1 {
+		t.Errorf("the run does not fail: %v", err)
+	}
+	if _, ok := util.FileSystem.(util.OSFS); !ok {
+		t.Error("the guard is still installed after the run")
+	}
+}
+
+// keepPlacement 将Newtonsoft.Json.dll留在原处，其他依赖保持原有的相对路径
+type keepPlacement struct{}
+
+func (keepPlacement) Place(dep manager.Deps, file string, rel string) (string, string) {
+	if dep.Name == "Newtonsoft.Json.dll" {
+		return "", ""
+	}
+	return rel, ""
+}
+
+func TestSkippedDepsAreReported(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		setup   func(t *testing.T, opts *Options)
+		message string
+		// --strict是否将其视为失败
+		left bool
+	}{
+		{"excludes", func(t *testing.T, opts *Options) { opts.Excludes = "Newtonsoft.*" }, "matches <excludes>", false},
+		{"placement", func(t *testing.T, opts *Options) { opts.Placement = keepPlacement{} }, "placement leaves", true},
+		{"symlink", linkOutside("zh-Hans"), "through a symlink", true},
+	} {
+		func() {
+			dir, cleanup := publishDir(t, "fdd")
+			defer cleanup()
+
+			var messages []string
+			opts := testOptions(dir, &messages)
+			c.setup(t, &opts)
+			result := beautify(t, opts)
+			if result.SkippedFiles == 0 {
+				t.Errorf("%s: the skipped file is not counted", c.name)
+			}
+			if left := result.LeftFiles != 0; left != c.left {
+				t.Errorf("%s: LeftFiles = %d", c.name, result.LeftFiles)
+			}
+			if !containsMessage(messages, c.message) {
+				t.Errorf("%s: the skipped file is not reported: %v", c.name, messages)
+			}
+		}()
+	}
+}
+
+// linkOutside 将发布目录中的子目录name移到发布目录之外，原处换成指向它的符号链接
+func linkOutside(name string) func(t *testing.T, opts *Options) {
+	return func(t *testing.T, opts *Options) {
+		sub := filepath.Join(opts.Dir, name)
+		outside := filepath.Join(filepath.Dir(opts.Dir), name)
+		if err := os.Rename(sub, outside); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(outside, sub); err != nil {
+			t.Skipf("symlinks are not supported: %s", err)
+		}
+	}
+}
+
+func TestMissingRuntimeConfigIsQuietNoOp(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	if err := os.Remove(filepath.Join(dir, "app.runtimeconfig.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	result := beautify(t, testOptions(dir, &messages))
+	if result.MovedFiles != 0 {
+		t.Errorf("%d file(s) moved without a runtimeconfig.json", result.MovedFiles)
+	}
+	// 与原来一样只是Detail，--warningsaserrors下也不失败
+	if len(messages) != 0 {
+		t.Errorf("warnings for a directory without runtimeconfig.json: %v", messages)
+	}
+}
@@ -0,0 +1,60 @@
+package beauty
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// memFixture 将testdata中的发布目录fixture载入MemFS中的root，不接触磁盘上的root
+func memFixture(t *testing.T, fixture string, root string) *util.MemFS {
+	src := filepath.Join("..", "testdata", fixture)
+	files := make(map[string][]byte)
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		content, err := ioutil.ReadFile(path)
+		files[filepath.Join(root, rel)] = content
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return util.NewMemFS(files)
+}
+
+func TestMoveDepsInMemory(t *testing.T) {
+	defer func(fsys util.WriteFS) { util.FileSystem = fsys }(util.FileSystem)
+
+	dir := filepath.Join(os.TempDir(), "nbeauty-memfs", "app")
+	fsys := memFixture(t, "fdd", dir)
+	util.FileSystem = fsys
+
+	apply(testOptions(dir, nil))
+	resolveBeautyDir()
+	deps, _, _ := manager.FixDeps(filepath.Join(dir, "app.deps.json"), "app", enableDebug, usePatch, sharedRuntimeMode)
+	_, moved, _, _ := moveDeps(deps, "app", sharedRuntimeMode)
+	if moved == 0 || summary.failedFiles != 0 {
+		t.Fatalf("moved %d, failed %d", moved, summary.failedFiles)
+	}
+
+	for _, file := range []string{"Newtonsoft.Json.dll", "runtimes/linux-x64/native/libfoo.so", "locales/zh-Hans/Foo.Res.resources.dll"} {
+		if _, err := fsys.Stat(filepath.Join(dir, "libs", filepath.FromSlash(file))); err != nil {
+			t.Errorf("%s has not been moved into libs: %s", file, err.Error())
+		}
+	}
+	for _, file := range []string{"Newtonsoft.Json.dll", "runtimes/linux-x64/native/libfoo.so"} {
+		if _, err := fsys.Stat(filepath.Join(dir, filepath.FromSlash(file))); err == nil {
+			t.Errorf("%s is still in the root", file)
+		}
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Errorf("moveDeps touched the disk: %s exists", dir)
+	}
+}
@@ -0,0 +1,250 @@
+package beauty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// migrateMarker 按beauty后的目录重建旧版本写入的空标记文件
+// libs为空时从runtimeconfig.json推断
+func migrateMarker(dir string, libs string) (*manager.BeautyMarker, error) {
+	markerPath := manager.MarkerPath(dir)
+	fi, err := os.Stat(markerPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s has not been beautified, there is no marker to migrate", dir)
+	}
+	if _, err := manager.ReadBeautyMarker(dir); err == nil {
+		return nil, fmt.Errorf("the marker of %s is already up to date", dir)
+	}
+
+	marker := &manager.BeautyMarker{
+		Tool:       "nbeauty2",
+		Version:    "unknown",
+		Timestamp:  fi.ModTime().UTC().Format(time.RFC3339),
+		MigratedBy: Version,
+	}
+
+	configFiles := manager.FindExeConfig(dir)
+	if len(configFiles) != 0 {
+		marker.Strategy = "netfx"
+		if libs == "" {
+			return nil, errors.New("libsDir of a .NET Framework app cannot be inferred, pass it explicitly")
+		}
+	} else {
+		configFiles = manager.FindDepsJSON(dir)
+		if len(configFiles) == 0 {
+			return nil, fmt.Errorf("no deps.json found in %s", dir)
+		}
+
+		for _, runtimeConfig := range manager.FindRuntimeConfigJSON(dir) {
+			if configLibs, srm, err := manager.ReadBeautyConfig(runtimeConfig); err == nil {
+				if libs == "" {
+					libs = configLibs
+				}
+				marker.SharedRuntimeMode = srm
+				break
+			}
+		}
+		if libs == "" {
+			return nil, errors.New("libsDir cannot be inferred from runtimeconfig.json, pass it explicitly")
+		}
+
+		for _, deps := range configFiles {
+			if marker.FXRVersion, marker.RID = manager.FindFXRInfo(deps); marker.FXRVersion != "" && marker.RID != "" {
+				break
+			}
+		}
+
+		marker.Strategy = hookStrategy
+		if marker.FXRVersion != "" && marker.RID != "" {
+			if status, err := inspectFXR(dir); err == nil && status.patched {
+				marker.Strategy = patchStrategy
+				marker.Patched = true
+				if status.backup != "" {
+					if rel, err := filepath.Rel(dir, status.backup); err == nil {
+						marker.FXRBackup = filepath.ToSlash(rel)
+					}
+				}
+			}
+		}
+	}
+
+	libsPath := filepath.Join(dir, strings.Trim(libs, `"`))
+	if !util.PathExists(libsPath) {
+		return nil, fmt.Errorf("libsDir %s does not exist", libsPath)
+	}
+	marker.LibsDir = libs
+	if marker.Strategy == "netfx" {
+		marker.Relocations = libsRelocations(dir, libs, nil, marker)
+	} else {
+		marker.Relocations = libsRelocations(dir, libs, configFiles, marker)
+	}
+	for _, relocation := range marker.Relocations {
+		if !isCompanionFile(relocation.From) {
+			marker.MovedCount++
+		}
+	}
+	marker.DepsCount = marker.MovedCount
+
+	misc.ShowFile(markerPath)
+	saved := manager.WriteBeautyMarker(dir, marker, configFiles)
+	misc.HideFile(markerPath)
+	if !saved {
+		return nil, fmt.Errorf("write marker failed: %s", markerPath)
+	}
+
+	log.LogDetail(fmt.Sprintf("%s migrated: strategy %s, libsDir %s, %d file(s) moved into libsDir", markerPath, marker.Strategy, marker.LibsDir, marker.MovedCount))
+	return marker, nil
+}
+
+// isCompanionFile 是否为随依赖一起移动的.pdb、.xml
+func isCompanionFile(file string) bool {
+	return strings.HasSuffix(file, ".pdb") || strings.HasSuffix(file, ".xml")
+}
+
+// libsRelocations 找出libsDir中的文件及其移动前的位置（相对dir的"/"分隔路径）
+// 先按deps.json（netfx时depsFiles为nil）中的依赖查找，deps.json中已被去掉的依赖及其他文件再按默认布局反推，
+// 共享运行时模式下libsDir可能由其他app共用，只记录runtimeconfig.json中本app的文件
+func libsRelocations(dir string, libs string, depsFiles []string, marker *manager.BeautyMarker) []manager.Relocation {
+	libsPath := filepath.Join(dir, strings.Trim(libs, `"`))
+	libsSlash := filepath.ToSlash(filepath.Clean(strings.Trim(libs, `"`)))
+
+	relocations := make([]manager.Relocation, 0)
+	seen := make(map[string]bool)
+	add := func(from string, to string) bool {
+		from = strings.TrimPrefix(from, "./")
+		if seen[to] || util.PathExists(filepath.Join(dir, filepath.FromSlash(from))) {
+			return false
+		}
+		fi, err := os.Stat(filepath.Join(libsPath, filepath.FromSlash(to)))
+		if err != nil || fi.IsDir() {
+			return false
+		}
+		seen[to] = true
+		relocations = append(relocations, manager.Relocation{From: from, To: libsSlash + "/" + to})
+		// 与依赖一起移动的.pdb、.xml
+		for _, ext := range []string{".pdb", ".xml"} {
+			if !seen[to+ext] && util.PathExists(filepath.Join(libsPath, filepath.FromSlash(to+ext))) {
+				seen[to+ext] = true
+				relocations = append(relocations, manager.Relocation{From: from + ext, To: libsSlash + "/" + to + ext})
+			}
+		}
+		return true
+	}
+
+	// 补丁的备份及原始hostfxr不是被移动的文件
+	for _, file := range []string{marker.FXRBackup, marker.FXROriginal} {
+		if rel := strings.TrimPrefix(file, libsSlash+"/"); rel != file {
+			seen[rel] = true
+		}
+	}
+
+	appID, mapping := "", map[string]string(nil)
+	if marker.SharedRuntimeMode {
+		for _, runtimeConfig := range manager.FindRuntimeConfigJSON(dir) {
+			if id, m, err := manager.ReadSharedRuntimeConfig(runtimeConfig); err == nil && id != "" {
+				appID, mapping = id, m
+				break
+			}
+		}
+	}
+
+	// 默认布局中的位置
+	place := func(dep manager.Deps, rel string) string {
+		if marker.SharedRuntimeMode {
+			if dep.Type == manager.Native {
+				return path.Join("srm_native", appID, rel)
+			}
+			key := dep.Name
+			if dep.Type == manager.Resource {
+				key = dep.Locale + "/" + dep.Name
+			}
+			md5, ok := mapping[key]
+			if !ok {
+				return ""
+			}
+			rel = path.Join(rel, md5, dep.Name)
+		}
+		if dep.Type == manager.Resource && depsFiles != nil {
+			rel = path.Join("locales", rel)
+		}
+		return rel
+	}
+
+	for _, deps := range depsFiles {
+		all, err := manager.ReadDeps(deps)
+		if err != nil {
+			log.LogDetail(err.Error())
+			continue
+		}
+		for _, dep := range all {
+			for _, rel := range []string{dep.SecondPath, dep.Path} {
+				rel = strings.TrimPrefix(strings.Replace(rel, "\\", "/", -1), "./")
+				if to := place(dep, rel); to != "" && add(rel, to) {
+					break
+				}
+			}
+		}
+	}
+
+	if marker.SharedRuntimeMode {
+		// 改写后的deps.json中已没有移走的依赖，按runtimeconfig.json中的映射查找
+		keys := make([]string, 0, len(mapping))
+		for key := range mapping {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			parts := strings.Split(key, "/")
+			dep := manager.Deps{Name: parts[len(parts)-1], Type: manager.Assembly}
+			if len(parts) == 2 {
+				dep.Type, dep.Locale = manager.Resource, parts[0]
+			}
+			add(key, place(dep, key))
+		}
+		if appID != "" {
+			native := filepath.Join(libsPath, "srm_native", appID)
+			for _, file := range util.GetAllFiles(native, true) {
+				if rel, err := filepath.Rel(native, file); err == nil {
+					add(filepath.ToSlash(rel), path.Join("srm_native", appID, filepath.ToSlash(rel)))
+				}
+			}
+		}
+	} else {
+		for _, file := range util.GetAllFiles(libsPath, true) {
+			rel, err := filepath.Rel(libsPath, file)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			add(originalPath(rel, depsFiles != nil), rel)
+		}
+	}
+
+	sort.Slice(relocations, func(i, j int) bool { return relocations[i].From < relocations[j].From })
+	return relocations
+}
+
+// originalPath 按默认布局反推libsDir中的文件（相对libsDir）移动前相对beautyDir的路径
+func originalPath(rel string, locales bool) string {
+	parts := strings.Split(rel, "/")
+	// 冲突的依赖位于versions/<版本>/下
+	if len(parts) > 2 && parts[0] == manager.VersionsDir {
+		parts = parts[2:]
+	}
+	if locales && len(parts) > 1 && parts[0] == "locales" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, "/")
+}
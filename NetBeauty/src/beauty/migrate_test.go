@@ -0,0 +1,69 @@
+package beauty
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+func TestMigrateRecordsRelocations(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		opts func(opts *Options)
+	}{
+		{"hook", func(opts *Options) {}},
+		{"patch", func(opts *Options) { opts.Strategy = patchStrategy }},
+		{"srm", func(opts *Options) { opts.SharedRuntimeMode = true }},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir, cleanup := publishDir(t, "fdd")
+			defer cleanup()
+
+			opts := testOptions(dir, nil)
+			c.opts(&opts)
+			beautify(t, opts)
+
+			// 旧版本写入的是空标记文件
+			if err := ioutil.WriteFile(manager.MarkerPath(dir), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			opts.LibsDir = ""
+			if err := Migrate(context.Background(), opts); err != nil {
+				t.Fatal(err)
+			}
+
+			marker, err := manager.ReadBeautyMarker(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := []manager.Relocation{
+				{From: "Foo.Res.dll", To: "libs/Foo.Res.dll"},
+				{From: "Newtonsoft.Json.dll", To: "libs/Newtonsoft.Json.dll"},
+				{From: "runtimes/linux-x64/native/libfoo.so", To: "libs/runtimes/linux-x64/native/libfoo.so"},
+				{From: "zh-Hans/Foo.Res.resources.dll", To: "libs/locales/zh-Hans/Foo.Res.resources.dll"},
+			}
+			if opts.SharedRuntimeMode {
+				// 按md5或应用分目录存放，只比较原来的位置
+				for i, relocation := range marker.Relocations {
+					if !util.PathExists(filepath.Join(dir, filepath.FromSlash(relocation.To))) {
+						t.Errorf("%s does not exist", relocation.To)
+					}
+					if i < len(expected) {
+						expected[i].To = relocation.To
+					}
+				}
+			}
+			if !reflect.DeepEqual(marker.Relocations, expected) {
+				t.Errorf("relocations = %+v, want %+v", marker.Relocations, expected)
+			}
+			if marker.MovedCount != len(expected) || marker.DepsCount != len(expected) {
+				t.Errorf("movedCount = %d, depsCount = %d, want %d", marker.MovedCount, marker.DepsCount, len(expected))
+			}
+		})
+	}
+}
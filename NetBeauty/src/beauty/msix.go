@@ -0,0 +1,79 @@
+package beauty
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var msix = false
+
+// msixMaxPath MSIX包内文件的相对路径长度上限
+const msixMaxPath = 255
+
+// msixReserved 包根目录下由MakeAppx生成的文件/目录，发布目录中不能存在
+var msixReserved = []string{
+	"AppxManifest.xml",
+	"AppxBlockMap.xml",
+	"AppxSignature.p7x",
+	"[Content_Types].xml",
+	"AppxMetadata",
+	"Microsoft.System.Package.Metadata",
+}
+
+// msixStrategies MSIX包内的host会被签名校验，去掉替换hostfxr/apphost的策略，只使用probing
+func msixStrategies(list []string) []string {
+	filtered := make([]string, 0, len(list))
+	for _, s := range list {
+		if s == patchStrategy || s == appHostStrategy {
+			log.LogWarning(fmt.Sprintf("strategy %s modifies the host binaries and is skipped in msix mode", s))
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+	if len(filtered) == 0 {
+		filtered = append(filtered, hookStrategy)
+	}
+	return filtered
+}
+
+// checkMSIX 检查beauty后的目录是否可以直接打包为MSIX，返回不符合的项数
+func checkMSIX(marker *manager.BeautyMarker) int {
+	violations := 0
+	violate := func(file string, format string, args ...interface{}) {
+		violations++
+		log.LogErrorFields(fmt.Errorf("msix: "+format, args...), log.Fields{"file": file})
+	}
+
+	if marker.Patched || marker.Strategy == appHostStrategy {
+		violate(filepath.Join(beautyDir, libsDir), "the host binaries have been modified (strategy %s), the package signature will not cover the original files", marker.Strategy)
+	}
+
+	for _, reserved := range msixReserved {
+		for _, name := range rootSnapshot(beautyDir) {
+			if strings.EqualFold(strings.TrimSuffix(name, "/"), reserved) {
+				violate(filepath.Join(beautyDir, name), "%s is reserved by the package format", reserved)
+			}
+		}
+	}
+
+	for _, file := range util.GetAllFiles(beautyDir, true) {
+		rel, err := filepath.Rel(beautyDir, file)
+		if err != nil {
+			continue
+		}
+		if len(rel) > msixMaxPath {
+			violate(file, "package relative path is longer than %d characters", msixMaxPath)
+		}
+	}
+
+	if hiddens != "" {
+		log.LogWarning("msix: the hidden attribute is not preserved in MSIX packages, hiddens have no effect after install")
+	}
+
+	return violations
+}
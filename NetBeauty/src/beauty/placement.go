@@ -0,0 +1,78 @@
+package beauty
+
+import (
+	"strings"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// Placement 决定每个依赖在libsDir中的位置，通过Options.Placement指定，为nil时使用默认的布局
+// 只决定文件放在哪里，deps.json、runtimeconfig.json等的改写及文件的移动仍由beauty完成
+type Placement interface {
+	// Place 返回dep移动后相对libsDir的路径（"/"分隔），返回空字符串时保留在原处
+	// file为dep的绝对路径，rel为dep相对beautyDir的路径（"/"分隔）
+	// probeDir为需要加入runtimeconfig.json探测路径（NetBeautyLibsDir）的libsDir子目录，为空时不添加
+	Place(dep manager.Deps, file string, rel string) (dest string, probeDir string)
+}
+
+// placement 本次beauty使用的Placement，为nil时每次moveDeps创建defaultPlacement
+var placement Placement
+
+// defaultPlacement 默认布局：保持原有的目录结构，资源文件位于locales，
+// SRM模式下非native的dll按md5分目录存放，native的dll按应用分目录存放
+type defaultPlacement struct {
+	sharedRuntimeMode bool
+	entry             string
+	// SRM模式下的文件名（资源文件带语言目录）=> md5，写入runtimeconfig.json
+	srmMapping map[string]string
+}
+
+func newDefaultPlacement(entry string, sharedRuntimeMode bool) *defaultPlacement {
+	return &defaultPlacement{
+		sharedRuntimeMode: sharedRuntimeMode,
+		entry:             entry,
+		srmMapping:        make(map[string]string, 0),
+	}
+}
+
+func (p *defaultPlacement) Place(dep manager.Deps, file string, rel string) (string, string) {
+	parts := strings.Split(rel, "/")
+	fileName := parts[len(parts)-1]
+	subDir := strings.Join(parts[0:len(parts)-1], "/")
+
+	probeDir := ""
+	if dep.Type != manager.Resource {
+		probeDir = subDir
+	}
+
+	dest := rel
+
+	// native不能使用分层结构（多层依赖会导致加载不了dll）
+	if !isNetFx && p.sharedRuntimeMode {
+		if dep.Type != manager.Native {
+			md5, _ := util.GetFileMD5(file)
+			if md5 == "" {
+				md5 = "generic"
+			}
+			parts = append(parts, md5, fileName)
+			srmKey := fileName
+			if dep.Type == manager.Resource {
+				srmKey = parts[0] + "/" + srmKey
+			}
+			p.srmMapping[srmKey] = md5
+			dest = strings.Join(parts, "/")
+		} else {
+			appID, _ := util.GetStringMD5(p.entry)
+			parts = append([]string{"srm_native", appID}, parts...)
+			dest = strings.Join(parts, "/")
+		}
+	}
+
+	if !isNetFx && dep.Type == manager.Resource {
+		parts = append([]string{"locales"}, parts...)
+		dest = strings.Join(parts, "/")
+	}
+
+	return dest, probeDir
+}
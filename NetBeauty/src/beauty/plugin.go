@@ -0,0 +1,156 @@
+package beauty
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	i18n "github.com/nulastudio/NetBeauty/src/i18n"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// Artifact 由ArtifactProvider查找到的补丁
+type Artifact = manager.Artifact
+
+// ArtifactProvider 补丁版hostfxr的来源，未指定时使用manager.GitCDNProvider
+// 获取的补丁在已知哈希列表中时视为已校验，否则按--requireknownhash拒绝或警告
+type ArtifactProvider interface {
+	Name() string
+	// Resolve 查找fxrVersion/rid的补丁，用于选择策略，不存在时返回manager.ErrNoArtifact
+	Resolve(ctx context.Context, fxrVersion string, rid string) (Artifact, error)
+	// Fetch 将Resolve找到的补丁写入dst
+	Fetch(ctx context.Context, artifact Artifact, dst string) error
+}
+
+// Strategy 自定义的布局策略，注册后可在Options.Strategy（--strategy）中使用其名称
+// 策略自行完成所有修改，这些修改不会记录在journal中
+type Strategy interface {
+	Name() string
+	// Check 检查策略是否适用于dir，不适用时返回原因
+	Check(ctx context.Context, dir string) error
+	// Apply 对dir进行beauty，返回移动的文件数
+	Apply(ctx context.Context, dir string, libsDir string) (int, error)
+}
+
+// gitCDN 默认的ArtifactProvider，也可以通过名称gitcdn指定
+var gitCDN ArtifactProvider = manager.GitCDNProvider{}
+
+var providers = map[string]ArtifactProvider{gitCDN.Name(): gitCDN}
+var customStrategies = make(map[string]Strategy)
+
+// provider 本次beauty使用的ArtifactProvider
+var provider = gitCDN
+
+// RegisterProvider 注册ArtifactProvider，名称重复时panic
+func RegisterProvider(p ArtifactProvider) {
+	if err := registerProvider(p); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterStrategy 注册Strategy，名称与内置策略或已注册的策略重复时panic
+func RegisterStrategy(s Strategy) {
+	if err := registerStrategy(s); err != nil {
+		panic(err)
+	}
+}
+
+func registerProvider(p ArtifactProvider) error {
+	if _, ok := providers[p.Name()]; ok {
+		return fmt.Errorf("artifact provider %s is already registered", p.Name())
+	}
+	providers[p.Name()] = p
+	return nil
+}
+
+func registerStrategy(s Strategy) error {
+	name := s.Name()
+	if isBuiltinStrategy(name) || name == probingStrategy {
+		return fmt.Errorf("strategy %s is built in", name)
+	}
+	if _, ok := customStrategies[name]; ok {
+		return fmt.Errorf("strategy %s is already registered", name)
+	}
+	customStrategies[name] = s
+	return nil
+}
+
+// hasArtifact 当前provider是否存在fxrVersion/rid的补丁
+func hasArtifact(fxrVersion string, rid string) bool {
+	_, err := provider.Resolve(runCtx, fxrVersion, rid)
+	if err != nil && err != manager.ErrNoArtifact && err != manager.ErrNoCompatibleRID {
+		checkCanceled()
+		log.LogDetail(fmt.Sprintf("artifact provider %s failed: %s", provider.Name(), err.Error()))
+	}
+	return err == nil
+}
+
+// fetchArtifact 从当前provider获取补丁到dir中并校验，返回补丁文件路径
+func fetchArtifact(fxrVersion string, rid string, dir string) (string, bool) {
+	artifact, err := provider.Resolve(runCtx, fxrVersion, rid)
+	if err == manager.ErrNoCompatibleRID {
+		log.LogPanic(log.NewHintError(fmt.Errorf("cannot find a compatible rid for %s", rid), "known rids: "+strings.Join(manager.KnownRIDs(), ", "),
+			i18n.T("hint.rid.cause"),
+			i18n.T("hint.rid.fix", rid)), 1)
+	}
+	if err != nil {
+		checkCanceled()
+		log.LogError(fmt.Errorf("artifact provider %s failed: %s/%s: %s", provider.Name(), fxrVersion, rid, err.Error()), false)
+		return "", false
+	}
+	if artifact.RID != rid {
+		log.LogDetail(fmt.Sprintf("using compatible rid %s for %s", artifact.RID, rid))
+	}
+
+	log.LogDetail(fmt.Sprintf("fetching patched hostfxr %s/%s from %s", fxrVersion, artifact.RID, provider.Name()))
+	file := filepath.Join(dir, artifact.RID, manager.GetHostFXRNameByRID(artifact.RID))
+	if !util.EnsureDirExists(filepath.Dir(file), util.CacheDirMode) {
+		log.LogError(notWriteableError(filepath.Dir(file)), false)
+		return "", false
+	}
+
+	endDownload := startStage("download")
+	err = provider.Fetch(runCtx, artifact, file)
+	endDownload()
+	if err != nil {
+		checkCanceled()
+		log.LogError(fmt.Errorf("artifact provider %s failed: %s", provider.Name(), err.Error()), false)
+		return "", false
+	}
+
+	if known, err := manager.IsKnownArtifactFile(fxrVersion, artifact.RID, file); !known {
+		reason := "hash is not on the known-good list"
+		if err != nil {
+			reason = err.Error()
+		}
+		if requireKnownHash {
+			log.LogError(fmt.Errorf("patched hostfxr %s/%s cannot be verified: %s", fxrVersion, artifact.RID, reason), false)
+			return "", false
+		}
+		log.LogWarning(fmt.Sprintf("patched hostfxr %s/%s cannot be verified: %s", fxrVersion, artifact.RID, reason))
+	}
+
+	return file, true
+}
+
+// beautyCustom 使用注册的Strategy进行beauty
+func beautyCustom(s Strategy, rootBefore []string) bool {
+	endMove := startStage("move")
+	moved, err := s.Apply(runCtx, beautyDir, libsDir)
+	endMove()
+	if err != nil {
+		checkCanceled()
+		log.LogPanic(fmt.Errorf("strategy %s failed: %s", s.Name(), err.Error()), 1)
+	}
+
+	finishBeauty(rootBefore, &manager.BeautyMarker{
+		Strategy:          s.Name(),
+		SharedRuntimeMode: sharedRuntimeMode,
+		MovedCount:        moved,
+	}, nil)
+
+	return true
+}
@@ -0,0 +1,208 @@
+package beauty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// PluginPrefix 外部插件可执行文件的文件名前缀
+const PluginPrefix = "nbeauty-plugin-"
+
+// pluginRequest 发送给外部插件的请求，每次调用启动一次插件，请求从stdin传入
+type pluginRequest struct {
+	Command    string `json:"command"`
+	FXRVersion string `json:"fxrVersion,omitempty"`
+	RID        string `json:"rid,omitempty"`
+	Dir        string `json:"dir,omitempty"`
+	LibsDir    string `json:"libsDir,omitempty"`
+	// has及fetch请求中插件自行联网时须遵循的网络策略
+	Network *pluginNetwork `json:"network,omitempty"`
+}
+
+// pluginNetwork 传给插件的网络策略，时间均为秒，总时间由nbeauty在超出时结束插件来保证
+type pluginNetwork struct {
+	NoNetwork      bool    `json:"noNetwork"`
+	ConnectTimeout float64 `json:"connectTimeout"`
+	ReadTimeout    float64 `json:"readTimeout"`
+	Retries        int     `json:"retries"`
+}
+
+// providerRequest provider的请求，附带当前的网络策略
+func providerRequest(command string, fxrVersion string, rid string) pluginRequest {
+	policy := manager.CurrentNetworkPolicy()
+	return pluginRequest{
+		Command:    command,
+		FXRVersion: fxrVersion,
+		RID:        rid,
+		Network: &pluginNetwork{
+			NoNetwork:      policy.NoNetwork,
+			ConnectTimeout: policy.ConnectTimeout.Seconds(),
+			ReadTimeout:    policy.ReadTimeout.Seconds(),
+			Retries:        policy.Retries,
+		},
+	}
+}
+
+// callProvider 在一次beauty中所有请求的总时间内发出provider的请求
+func (p *execPlugin) callProvider(ctx context.Context, request pluginRequest) (*pluginResponse, error) {
+	ctx, cancel, err := manager.WithNetworkDeadline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	return p.call(ctx, request)
+}
+
+// pluginResponse 外部插件输出到stdout的响应，Error不为空表示失败
+type pluginResponse struct {
+	Name     string   `json:"name,omitempty"`
+	Provides []string `json:"provides,omitempty"`
+	Found    bool     `json:"found,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Moved    int      `json:"moved,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// execPlugin 外部可执行插件
+type execPlugin struct {
+	name string
+	path string
+}
+
+func (p *execPlugin) call(ctx context.Context, request pluginRequest) (*pluginResponse, error) {
+	input, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var output bytes.Buffer
+	cmd := exec.Command(p.path)
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-done
+		return nil, ctx.Err()
+	case err = <-done:
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filepath.Base(p.path), err.Error())
+	}
+
+	var response pluginResponse
+	if err := json.Unmarshal(output.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("%s: invalid response: %s", filepath.Base(p.path), err.Error())
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	return &response, nil
+}
+
+func (p *execPlugin) Name() string {
+	return p.name
+}
+
+// Resolve 插件协议中的has请求
+func (p *execPlugin) Resolve(ctx context.Context, fxrVersion string, rid string) (Artifact, error) {
+	artifact := Artifact{FXRVersion: fxrVersion, RID: rid}
+	response, err := p.callProvider(ctx, providerRequest("has", fxrVersion, rid))
+	if err != nil {
+		return artifact, err
+	}
+	if !response.Found {
+		return artifact, manager.ErrNoArtifact
+	}
+	return artifact, nil
+}
+
+// Fetch 插件协议中的fetch请求，插件返回本地文件路径，再复制到dst
+func (p *execPlugin) Fetch(ctx context.Context, artifact Artifact, dst string) error {
+	response, err := p.callProvider(ctx, providerRequest("fetch", artifact.FXRVersion, artifact.RID))
+	if err != nil {
+		return err
+	}
+	if response.Path == "" {
+		return fmt.Errorf("no artifact for %s/%s", artifact.FXRVersion, artifact.RID)
+	}
+	_, err = util.CopyFile(response.Path, dst)
+	return err
+}
+
+func (p *execPlugin) Check(ctx context.Context, dir string) error {
+	_, err := p.call(ctx, pluginRequest{Command: "check", Dir: dir})
+	return err
+}
+
+func (p *execPlugin) Apply(ctx context.Context, dir string, libsDir string) (int, error) {
+	response, err := p.call(ctx, pluginRequest{Command: "apply", Dir: dir, LibsDir: libsDir})
+	if err != nil {
+		return 0, err
+	}
+	return response.Moved, nil
+}
+
+// LoadPlugins 注册dir下所有nbeauty-plugin-*可执行文件，dir不存在时忽略
+// 插件先收到describe请求，按响应中的provides（provider/strategy）注册
+func LoadPlugins(dir string) error {
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, fi := range fis {
+		if fi.IsDir() || !strings.HasPrefix(fi.Name(), PluginPrefix) {
+			continue
+		}
+
+		plugin := &execPlugin{path: filepath.Join(dir, fi.Name())}
+		response, err := plugin.call(context.Background(), pluginRequest{Command: "describe"})
+		if err != nil {
+			return fmt.Errorf("load plugin %s failed: %s", fi.Name(), err.Error())
+		}
+
+		plugin.name = response.Name
+		if plugin.name == "" {
+			plugin.name = strings.TrimSuffix(strings.TrimPrefix(fi.Name(), PluginPrefix), filepath.Ext(fi.Name()))
+		}
+
+		for _, kind := range response.Provides {
+			switch kind {
+			case "provider":
+				err = registerProvider(plugin)
+			case "strategy":
+				err = registerStrategy(plugin)
+			default:
+				err = fmt.Errorf("unknown kind %s", kind)
+			}
+			if err != nil {
+				return fmt.Errorf("load plugin %s failed: %s", fi.Name(), err.Error())
+			}
+		}
+		log.LogDetail(fmt.Sprintf("loaded plugin %s (%s)", plugin.name, strings.Join(response.Provides, ", ")))
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package beauty
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// preflight 在修改任何文件前检查目录是否可写、剩余空间、移动后的路径长度及hostfxr能否备份，
+// 任一项不通过时汇总输出所有问题后中断
+func preflight() {
+	// 已beauty且无需重新beauty时不会修改文件
+	if !force {
+		if _, err := manager.ValidateBeautyMarker(beautyDir, libsDir); err == nil {
+			return
+		}
+	}
+
+	checkPublishDir()
+
+	problems := make([]string, 0)
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	absDir, _ := filepath.Abs(beautyDir)
+	libsPath := filepath.Join(absDir, libsDir)
+
+	// 可写
+	if err := checkWriteable(absDir); err != nil {
+		fail("%s is not writeable: %s. if it is on a read-only mount, pass --outdir to beautify a copy of it", absDir, err.Error())
+	}
+	if parent := existingParent(libsPath); parent != absDir {
+		if err := checkWriteable(parent); err != nil {
+			fail("%s is not writeable: %s", parent, err.Error())
+		}
+	}
+
+	// libsDir与依赖所在的目录重叠时移动会嵌套自己的输出
+	for _, problem := range libsDirOverlaps(absDir, libsPath) {
+		fail("%s", problem)
+	}
+
+	// 路径长度
+	longest, longestPath := 0, ""
+	files := make([]string, 0)
+	filepath.Walk(absDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if fi.IsDir() {
+			if path == libsPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(absDir, path)
+		if unchanged[filepath.Join(beautyDir, rel)] {
+			return nil
+		}
+		files = append(files, path)
+		target := filepath.Join(libsPath, rel)
+		if strings.HasSuffix(fi.Name(), ".resources.dll") {
+			target = filepath.Join(libsPath, "locales", rel)
+		}
+		if sharedRuntimeMode {
+			// <name>/<md5>/<name>
+			target = filepath.Join(target, strings.Repeat("0", 32), fi.Name())
+		}
+		// 长路径前缀本身不计入长度限制
+		if length := len(util.StripExtendedLength(target)); length > longest {
+			longest, longestPath = length, target
+		}
+		return nil
+	})
+	if longest > misc.MaxPath {
+		fail("the relocated path is %d characters long, over the limit of %d: %s", longest, misc.MaxPath, longestPath)
+	}
+
+	// 被占用的文件（Windows下应用正在运行）无法移动
+	if holders := waitForUnlock(files); len(holders) != 0 {
+		fail("files in %s are in use by %s, close the app or pass --wait-for-unlock", absDir, strings.Join(holders, ", "))
+	}
+
+	// 剩余空间：hostfxr备份、复制到其他位置的输出、libsDir不在beautyDir内时移动即复制
+	required := make(map[string]int64)
+	require := func(path string, size int64) {
+		required[existingParent(path)] += size
+	}
+	fxr := ""
+	if hasStrategy(patchStrategy) {
+		fxr = findHostFXR(absDir)
+	}
+	if fxr != "" {
+		if fi, err := os.Stat(fxr); err == nil {
+			copies := int64(1)
+			if keepOriginal {
+				copies++
+			}
+			require(absDir, fi.Size()*copies)
+		}
+	}
+	var appBytes int64 = -1
+	appSize := func() int64 {
+		if appBytes < 0 {
+			appBytes = dirSize(absDir)
+		}
+		return appBytes
+	}
+	if rel, err := filepath.Rel(absDir, libsPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		require(libsPath, appSize())
+	}
+	for _, out := range []string{dockerSplit, appDir} {
+		if out != "" {
+			require(out, appSize())
+		}
+	}
+	for path, size := range required {
+		free, err := misc.FreeSpace(path)
+		if err != nil {
+			log.LogDetail(fmt.Sprintf("cannot determine the free space of %s: %s", path, err.Error()))
+			continue
+		}
+		if uint64(size) > free {
+			fail("%s needs %s of free space, only %s is available", path, formatSize(size), formatSize(int64(free)))
+		}
+	}
+
+	// hostfxr备份
+	if fxr != "" {
+		if f, err := os.Open(fxr); err != nil {
+			fail("%s cannot be read for the backup: %s", fxr, err.Error())
+		} else {
+			f.Close()
+		}
+		if bak := fxr + ".bak"; util.PathExists(bak) {
+			if f, err := os.OpenFile(bak, os.O_WRONLY, 0); err != nil {
+				fail("the backup %s cannot be updated: %s", bak, err.Error())
+			} else {
+				f.Close()
+			}
+		}
+	}
+
+	if len(problems) != 0 {
+		log.LogPanic(log.NewHintError(fmt.Errorf("pre-flight checks failed, nothing has been changed:\n  - %s", strings.Join(problems, "\n  - ")), beautyDir,
+			"", "fix the problems above and run again"), 1)
+	}
+}
+
+// checkWriteable 在dir中创建并删除一个临时文件
+func checkWriteable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".nbeauty-preflight")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// existingParent path自身或最近的已存在的上级目录
+func existingParent(path string) string {
+	for !util.PathExists(path) {
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+	return path
+}
+
+// hasStrategy 按顺序尝试的策略中是否包含s
+func hasStrategy(s string) bool {
+	for _, candidate := range strategies {
+		if candidate == s {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForUnlock 返回占用files的进程，--wait-for-unlock时等待其释放
+func waitForUnlock(files []string) []string {
+	deadline := time.Now().Add(util.WaitForUnlock)
+	for waited := false; ; waited = true {
+		holders, err := misc.FilesInUseBy(files)
+		if err != nil {
+			log.LogDetail(fmt.Sprintf("cannot determine whether files are in use: %s", err.Error()))
+			return nil
+		}
+		if len(holders) == 0 || !time.Now().Before(deadline) {
+			return holders
+		}
+		if !waited {
+			log.LogInfo(fmt.Sprintf("files are in use by %s, waiting up to %s for them to be released", strings.Join(holders, ", "), util.WaitForUnlock))
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// libsDirOverlaps libsDir包含依赖所在的目录，或在依赖所在的顶层目录之中（根目录除外）时返回问题，每个目录一条
+func libsDirOverlaps(absDir string, libsPath string) []string {
+	problems := make([]string, 0)
+	reported := make(map[string]bool)
+	for _, deps := range manager.FindDepsJSON(absDir) {
+		files, err := manager.DepsFiles(deps)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			path := filepath.Join(absDir, filepath.FromSlash(file))
+			dir := filepath.Dir(path)
+			if dir == absDir || reported[dir] || !util.PathWithin(absDir, path) || !util.PathExists(path) {
+				continue
+			}
+			rel, _ := filepath.Rel(absDir, dir)
+			top := filepath.Join(absDir, strings.SplitN(filepath.ToSlash(rel), "/", 2)[0])
+			switch {
+			case dir == libsPath || util.PathWithin(libsPath, dir):
+				problems = append(problems, fmt.Sprintf("libsDir %s already contains the dependency directory %s (e.g. %s), the move would nest it inside itself. choose another libsDir", libsDir, rel, file))
+			case util.PathWithin(top, libsPath):
+				problems = append(problems, fmt.Sprintf("libsDir %s is inside %s, which holds the dependency directory %s (e.g. %s), the moved files would end up among their own sources. choose another libsDir", libsDir, filepath.Base(top), rel, file))
+			default:
+				continue
+			}
+			reported[dir] = true
+		}
+	}
+	return problems
+}
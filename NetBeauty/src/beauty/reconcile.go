@@ -0,0 +1,145 @@
+package beauty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	event "github.com/nulastudio/NetBeauty/src/event"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// reconcile 将更新程序或热修复放回dir中、已被移入libsDir的依赖再次移入libsDir（替换旧文件），
+// 新出现的附属程序集同样移入libsDir，并更新标记文件，返回移动的文件数
+func reconcile(dir string) (int, error) {
+	marker, err := manager.ReadBeautyMarker(dir)
+	if err != nil {
+		return 0, fmt.Errorf("%s has no beauty marker, beauty it first (or run `nbeauty migrate %s` if it was beautified by an older release)", dir, dir)
+	}
+	// deps.json等被整体替换时无法只移动文件
+	if _, err := manager.ValidateBeautyMarker(dir, marker.LibsDir); err != nil {
+		return 0, fmt.Errorf("the layout cannot be reconciled (%s), beauty it again with --force", err.Error())
+	}
+	switch marker.Strategy {
+	case hookStrategy, probingStrategy, patchStrategy, noneStrategy, "netfx":
+	default:
+		return 0, fmt.Errorf("reconcile does not support the %s strategy", marker.Strategy)
+	}
+	if marker.SharedRuntimeMode {
+		return 0, errors.New("reconcile does not support shared runtime mode")
+	}
+
+	libsPath := filepath.Join(dir, marker.LibsDir)
+	if !util.PathExists(libsPath) {
+		return 0, fmt.Errorf("libsDir %s does not exist", libsPath)
+	}
+
+	if marker.Patched {
+		if status, err := inspectFXR(dir); err == nil && status.determined && !status.patched {
+			log.LogWarning(fmt.Sprintf("%s has been replaced by the original one, the app cannot find %s any more, beauty it again with --force", status.fxr, marker.LibsDir))
+		}
+	}
+
+	moves := serviceMoves(dir, libsPath, marker.Strategy == "netfx")
+	if len(moves) == 0 {
+		log.LogDetail(fmt.Sprintf("the layout of %s is consistent, nothing to reconcile", dir))
+		return 0, nil
+	}
+
+	// 与beauty相同，只允许在dir及libsDir中移动
+	defer util.GuardWrites(dir, libsPath)()
+	openJournal(dir)
+	appendRevert = true
+	for _, move := range moves {
+		var size int64
+		if fi, err := os.Stat(move.from); err == nil {
+			size = fi.Size()
+		}
+		start := time.Now()
+		if !util.EnsureDirExists(filepath.Dir(move.to), util.DirMode) {
+			log.LogError(notWriteableError(filepath.Dir(move.to)), false)
+		}
+		// 记录被替换的旧文件，失败时一起还原
+		journalBeforeWrite(move.to)
+		journalMoving(move.from, move.to)
+		if err := util.MoveFile(move.from, move.to); err != nil {
+			if rollbackErr := rollbackJournal(dir); rollbackErr != nil {
+				log.LogError(rollbackErr, false)
+			}
+			return 0, err
+		}
+		emitFileMoved(move.from, move.to, size, time.Since(start))
+		if move.replace {
+			log.LogDetailFields(fmt.Sprintf("%s replaces %s", move.from, move.to), log.Fields{"file": move.from})
+		} else {
+			log.LogDetailFields(fmt.Sprintf("%s moved to %s", move.from, move.to), log.Fields{"file": move.from})
+		}
+	}
+	closeJournal(true)
+	for _, move := range moves {
+		removeEmptyParents(filepath.Dir(move.from), dir)
+	}
+
+	marker.Timestamp = outputTimestamp()
+	marker.RunID = event.RunID
+	marker.Relocations = movedRelocations(dir, marker.LibsDir, marker.Relocations)
+	markerPath := manager.MarkerPath(dir)
+	misc.ShowFile(markerPath)
+	saved := manager.SaveBeautyMarker(dir, marker)
+	misc.HideFile(markerPath)
+	if !saved {
+		return len(moves), fmt.Errorf("write marker failed: %s", markerPath)
+	}
+	return len(moves), nil
+}
+
+// serviceMove 一个需要重新移入libsDir的文件
+type serviceMove struct {
+	from    string
+	to      string
+	replace bool
+}
+
+// serviceMoves 查找libsDir之外、在libsDir中按默认布局存在同名文件的文件，以及新出现的附属程序集
+func serviceMoves(dir string, libsPath string, netfx bool) []serviceMove {
+	locales := filepath.Join(libsPath, "locales")
+	if netfx {
+		locales = libsPath
+	}
+
+	moves := make([]serviceMove, 0)
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if fi.IsDir() {
+			if path == libsPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || isRunFile(rel) || rel == manager.BeautyMarkerName || strings.HasSuffix(rel, ".bak") {
+			return nil
+		}
+		// 资源文件位于libsDir/locales下
+		for _, candidate := range []string{filepath.Join(libsPath, rel), filepath.Join(locales, rel)} {
+			if target, err := os.Stat(candidate); err == nil && !target.IsDir() {
+				moves = append(moves, serviceMove{from: path, to: candidate, replace: true})
+				return nil
+			}
+		}
+		// 与beauty时相同，<culture>/*.resources.dll视为附属程序集
+		if parts := strings.Split(filepath.ToSlash(rel), "/"); len(parts) == 2 && strings.HasSuffix(parts[1], ".resources.dll") {
+			moves = append(moves, serviceMove{from: path, to: filepath.Join(locales, rel)})
+		}
+		return nil
+	})
+	return moves
+}
@@ -0,0 +1,167 @@
+package beauty
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// relayout 将已beauty的dir中的libsDir移动为newLibs并改写探测路径及标记文件，
+// 中途失败时按journal撤销已做的修改
+func relayout(dir string, newLibs string) error {
+	marker, err := manager.ReadBeautyMarker(dir)
+	if err != nil {
+		return fmt.Errorf("%s has no beauty marker, beauty it first (or run `nbeauty migrate %s` if it was beautified by an older release)", dir, dir)
+	}
+	if _, err := manager.ValidateBeautyMarker(dir, marker.LibsDir); err != nil {
+		return fmt.Errorf("the beauty marker of %s is stale (%s), beauty it again instead", dir, err.Error())
+	}
+	switch marker.Strategy {
+	case hookStrategy, probingStrategy, patchStrategy, noneStrategy, "netfx":
+	default:
+		return fmt.Errorf("relayout does not support the %s strategy", marker.Strategy)
+	}
+	if marker.SharedRuntimeMode {
+		return errors.New("relayout does not support shared runtime mode, libsDir may be shared by other apps")
+	}
+
+	oldLibs := filepath.Clean(marker.LibsDir)
+	newLibs = filepath.Clean(strings.Trim(newLibs, `"`))
+	if filepath.IsAbs(newLibs) || newLibs == "." || newLibs == ".." || strings.HasPrefix(newLibs, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("the new libsDir must be a subdirectory of %s: %s", dir, newLibs)
+	}
+	if newLibs == oldLibs {
+		return fmt.Errorf("libsDir is already %s", marker.LibsDir)
+	}
+	oldPath, newPath := filepath.Join(dir, oldLibs), filepath.Join(dir, newLibs)
+	if !util.PathExists(oldPath) {
+		return fmt.Errorf("libsDir %s does not exist", oldPath)
+	}
+	if util.PathExists(newPath) {
+		return fmt.Errorf("%s already exists", newPath)
+	}
+
+	// 移动前先改写好所有配置文件，避免移动后才发现无法改写
+	configs := manager.FindRuntimeConfigJSON(dir)
+	if marker.Strategy == "netfx" {
+		configs = manager.FindExeConfig(dir)
+	}
+	rewrites := make(map[string][]byte, len(configs))
+	for _, config := range configs {
+		var content []byte
+		if marker.Strategy == "netfx" {
+			content, err = manager.RelayoutExeConfig(config, oldLibs, newLibs)
+		} else {
+			content, err = manager.RelayoutRuntimeConfig(config, oldLibs, newLibs)
+		}
+		if err != nil {
+			return err
+		}
+		if content != nil {
+			rewrites[config] = content
+		}
+	}
+
+	openJournal(dir)
+	appendRevert = true
+	if err := relayoutJournaled(dir, oldPath, newPath, rewrites); err != nil {
+		if rollbackErr := rollbackJournal(dir); rollbackErr != nil {
+			log.LogError(rollbackErr, false)
+		}
+		return err
+	}
+	closeJournal(true)
+	removeEmptyParents(filepath.Dir(oldPath), dir)
+
+	oldSlash, newSlash := filepath.ToSlash(oldLibs), filepath.ToSlash(newLibs)
+	for i := range marker.Relocations {
+		if to := &marker.Relocations[i].To; strings.HasPrefix(*to, oldSlash+"/") {
+			*to = newSlash + (*to)[len(oldSlash):]
+		}
+	}
+	for _, file := range []*string{&marker.FXRBackup, &marker.FXROriginal} {
+		if strings.HasPrefix(*file, oldSlash+"/") {
+			*file = newSlash + (*file)[len(oldSlash):]
+		}
+	}
+	marker.LibsDir = newSlash
+
+	configFiles := make([]string, 0, len(marker.Files))
+	for name := range marker.Files {
+		configFiles = append(configFiles, filepath.Join(dir, name))
+	}
+	markerPath := manager.MarkerPath(dir)
+	misc.ShowFile(markerPath)
+	saved := manager.WriteBeautyMarker(dir, marker, configFiles)
+	misc.HideFile(markerPath)
+	if !saved {
+		return fmt.Errorf("write marker failed: %s", markerPath)
+	}
+
+	log.LogDetail(fmt.Sprintf("%s moved to %s, %d config file(s) rewritten", oldPath, newPath, len(rewrites)))
+	return nil
+}
+
+// relayoutJournaled 移动libsDir并写入改写后的配置文件，每一步都记录在journal中
+func relayoutJournaled(dir string, oldPath string, newPath string, rewrites map[string][]byte) error {
+	from := oldPath
+	// 新位置在原libsDir内时先移出
+	if strings.HasPrefix(newPath, oldPath+string(filepath.Separator)) {
+		from = filepath.Join(dir, "."+filepath.Base(oldPath)+".relayout")
+		journalMoving(oldPath, from)
+		if err := util.MoveFile(oldPath, from); err != nil {
+			return err
+		}
+	}
+
+	// 记录需要新建的上级目录，撤销时由内向外删除
+	missing := make([]string, 0)
+	for parent := filepath.Dir(newPath); parent != dir && !util.PathExists(parent); parent = filepath.Dir(parent) {
+		missing = append([]string{parent}, missing...)
+	}
+	for _, parent := range missing {
+		journalCreating(parent)
+	}
+	if !util.EnsureDirExists(filepath.Dir(newPath), util.DirMode) {
+		return notWriteableError(filepath.Dir(newPath))
+	}
+
+	journalMoving(from, newPath)
+	if err := util.MoveFile(from, newPath); err != nil {
+		return err
+	}
+
+	for config, content := range rewrites {
+		isHidden, hidErr := misc.IsHiddenFile(config)
+		if isHidden && hidErr == nil {
+			misc.ShowFile(config)
+		}
+		journalBeforeWrite(config)
+		err := util.FileSystem.WriteFile(config, content, util.FileMode)
+		if isHidden && hidErr == nil {
+			misc.HideFile(config)
+		}
+		if err != nil {
+			return fmt.Errorf("rewrite %s failed: %s", config, err.Error())
+		}
+		log.LogDetailFields(fmt.Sprintf("%s rewritten", config), log.Fields{"file": config})
+	}
+	return nil
+}
+
+// removeEmptyParents 删除dir及其上级中的空目录，直到root
+func removeEmptyParents(dir string, root string) {
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if fis, err := util.FileSystem.ReadDir(dir); err != nil || len(fis) != 0 {
+			return
+		}
+		util.FileSystem.Remove(dir)
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,120 @@
+package beauty
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// --repair的取值
+const (
+	// repairResume 撤销中断的beauty后重新beauty
+	repairResume = "resume"
+	// repairRollback 只撤销中断的beauty
+	repairRollback = "rollback"
+)
+
+var repair = ""
+
+// resumedRelocations --repair=resume继续没有标记文件的目录时，按默认布局反推的已移走的文件
+var resumedRelocations []manager.Relocation
+
+// repairPartial 检查beautyDir是否停留在上次beauty中断时的中间状态，按repair处理
+// 返回是否继续beauty
+func repairPartial() bool {
+	resumedRelocations = nil
+	journalPath := filepath.Join(beautyDir, JournalName)
+	if util.PathExists(journalPath) {
+		if repair == "" {
+			log.LogPanic(log.NewHintError(fmt.Errorf("a previous run on %s was interrupted", beautyDir), journalPath,
+				"the journal of the interrupted run has not been recovered",
+				"run again with --repair=resume to start over from the original files, or --repair=rollback to only undo the interrupted run"), 1)
+		}
+
+		log.LogDetail(fmt.Sprintf("undoing the interrupted run on %s", beautyDir))
+		if err := replayJournal(beautyDir); err != nil {
+			log.LogPanic(err, 1)
+		}
+		return repair == repairResume
+	}
+
+	if moved := movedWithoutMarker(); moved != 0 {
+		libsPath := filepath.Join(beautyDir, libsDir)
+		switch repair {
+		case repairResume:
+			// 已移走的文件留在libsDir中，其余文件继续beauty
+			log.LogWarning(fmt.Sprintf("%s is partially beautified, continuing with the %d file(s) already in %s", beautyDir, moved, libsPath))
+			resumedRelocations = libsRelocations(beautyDir, libsDir, manager.FindDepsJSON(beautyDir), &manager.BeautyMarker{})
+			return true
+		case repairRollback:
+			if err := moveBackWithoutJournal(libsPath); err != nil {
+				log.LogPanic(err, 1)
+			}
+			return false
+		}
+		log.LogPanic(log.NewHintError(fmt.Errorf("%s is partially beautified: %d file(s) have been moved into %s but there is no beauty marker", beautyDir, moved, libsPath), libsPath,
+			"a previous run stopped before it finished and left no journal to undo it",
+			"run again with --repair=resume to continue with the files already moved, or --repair=rollback to move them back"), 1)
+	}
+
+	if repair != "" {
+		log.LogDetail(fmt.Sprintf("%s is not partially beautified, nothing to repair", beautyDir))
+	}
+	return true
+}
+
+// movedWithoutMarker 没有标记文件时libsDir中的文件数
+// 共享运行时模式或libsDir不在beautyDir内时libsDir可能由其他app共用，不做检查
+func movedWithoutMarker() int {
+	if sharedRuntimeMode || util.PathExists(manager.MarkerPath(beautyDir)) {
+		return 0
+	}
+	rel := filepath.Clean(libsDir)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return 0
+	}
+	libsPath := filepath.Join(beautyDir, rel)
+	if !util.PathExists(libsPath) {
+		return 0
+	}
+	return len(util.GetAllFiles(libsPath, true))
+}
+
+// moveBackWithoutJournal 没有journal时按默认布局将libsDir中的文件移回原处，
+// 已改写的deps.json、runtimeconfig.json无法还原
+func moveBackWithoutJournal(libsPath string) error {
+	relocations := libsRelocations(beautyDir, libsDir, manager.FindDepsJSON(beautyDir), &manager.BeautyMarker{})
+	failed := 0
+	for i := len(relocations) - 1; i >= 0; i-- {
+		from := filepath.Join(beautyDir, filepath.FromSlash(relocations[i].From))
+		to := filepath.Join(beautyDir, filepath.FromSlash(relocations[i].To))
+		log.LogDetail(fmt.Sprintf("moving back %s", from))
+		if err := util.FileSystem.MkdirAll(filepath.Dir(from), util.DirMode); err != nil {
+			failed++
+			log.LogErrorFields(notWriteableError(filepath.Dir(from)), log.Fields{"file": from})
+			continue
+		}
+		if err := util.MoveFile(to, from); err != nil {
+			failed++
+			log.LogErrorFields(fmt.Errorf("move back failed: %s : %s", to, err.Error()), log.Fields{"file": to})
+			continue
+		}
+		removeEmptyParents(filepath.Dir(to), beautyDir)
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d file(s) cannot be moved back from %s", failed, libsPath)
+	}
+
+	for _, runtimeConfig := range manager.FindRuntimeConfigJSON(beautyDir) {
+		if _, _, err := manager.ReadBeautyConfig(runtimeConfig); err == nil {
+			log.LogWarning(fmt.Sprintf("%d file(s) moved back, but %s and deps.json have already been rewritten and cannot be restored without the journal, publish the app again", len(relocations), runtimeConfig))
+			return nil
+		}
+	}
+	log.LogDetail(fmt.Sprintf("%d file(s) moved back from %s", len(relocations), libsPath))
+	return nil
+}
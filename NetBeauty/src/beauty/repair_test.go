@@ -0,0 +1,67 @@
+package beauty
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// partiallyBeautified beauty后删除标记文件，模拟没有留下journal的中断
+func partiallyBeautified(t *testing.T) (string, func()) {
+	dir, cleanup := publishDir(t, "fdd")
+	beautify(t, testOptions(dir, nil))
+	if err := os.Remove(manager.MarkerPath(dir)); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return dir, cleanup
+}
+
+func TestRepairWithoutMarker(t *testing.T) {
+	dir, cleanup := partiallyBeautified(t)
+	defer cleanup()
+
+	if _, err := Beautify(context.Background(), testOptions(dir, nil)); err == nil {
+		t.Error("a partially beautified directory is beautified without --repair")
+	}
+
+	var messages []string
+	opts := testOptions(dir, &messages)
+	opts.Repair = repairResume
+	beautify(t, opts)
+	if _, err := manager.ValidateBeautyMarker(dir, "libs"); err != nil {
+		t.Errorf("--repair=resume: %s", err.Error())
+	}
+	if !util.PathExists(filepath.Join(dir, "libs", "Newtonsoft.Json.dll")) || !containsMessage(messages, "continuing with") {
+		t.Errorf("--repair=resume: the moved files are not kept: %v", messages)
+	}
+}
+
+func TestRollbackWithoutMarker(t *testing.T) {
+	dir, cleanup := partiallyBeautified(t)
+	defer cleanup()
+
+	var messages []string
+	opts := testOptions(dir, &messages)
+	opts.Repair = repairRollback
+	beautify(t, opts)
+
+	for _, file := range []string{"Newtonsoft.Json.dll", "Foo.Res.dll", "zh-Hans/Foo.Res.resources.dll", "runtimes/linux-x64/native/libfoo.so"} {
+		if !util.PathExists(filepath.Join(dir, filepath.FromSlash(file))) {
+			t.Errorf("%s has not been moved back", file)
+		}
+	}
+	if util.PathExists(filepath.Join(dir, "libs")) {
+		t.Error("libsDir is left behind")
+	}
+	if util.PathExists(manager.MarkerPath(dir)) {
+		t.Error("--repair=rollback beautified the directory")
+	}
+	if !containsMessage(messages, "cannot be restored without the journal") {
+		t.Errorf("the rewritten config files are not reported: %v", messages)
+	}
+}
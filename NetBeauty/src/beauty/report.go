@@ -0,0 +1,82 @@
+package beauty
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+const treeReport = "tree"
+
+// rootSnapshot 记录beautyDir根目录下的文件/目录
+func rootSnapshot(dir string) []string {
+	entries := make([]string, 0)
+
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return entries
+	}
+
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() {
+			name += "/"
+		}
+		entries = append(entries, name)
+	}
+
+	sort.Strings(entries)
+
+	return entries
+}
+
+// treeDiff 生成beauty前后根目录对比，"-"为已移走，"+"为新增
+func treeDiff(dir string, before []string, after []string) string {
+	buf := &bytes.Buffer{}
+
+	fmt.Fprintf(buf, "root directory of %s\n", dir)
+	fmt.Fprintf(buf, "before: %d entries, after: %d entries\n", len(before), len(after))
+
+	beforeSet := make(map[string]bool, len(before))
+	afterSet := make(map[string]bool, len(after))
+	for _, entry := range before {
+		beforeSet[entry] = true
+	}
+	for _, entry := range after {
+		afterSet[entry] = true
+	}
+
+	all := make([]string, 0, len(before)+len(after))
+	all = append(all, after...)
+	for _, entry := range before {
+		if !afterSet[entry] {
+			all = append(all, entry)
+		}
+	}
+	sort.Strings(all)
+
+	for _, entry := range all {
+		switch {
+		case beforeSet[entry] && afterSet[entry]:
+			fmt.Fprintf(buf, "  %s\n", entry)
+		case afterSet[entry]:
+			fmt.Fprintf(buf, "+ %s\n", entry)
+		default:
+			fmt.Fprintf(buf, "- %s\n", entry)
+		}
+	}
+
+	return buf.String()
+}
+
+// writeReport 输出报告到reportFile，未指定时输出到stdout
+func writeReport(content string) error {
+	if reportFile == "" {
+		fmt.Print(content)
+		return nil
+	}
+	return util.FileSystem.WriteFile(reportFile, []byte(content), util.FileMode)
+}
@@ -0,0 +1,45 @@
+package beauty
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// sourceDate 可复现构建约定的SOURCE_DATE_EPOCH，设置时标记文件及SBOM使用该时间而不是当前时间
+func sourceDate() (time.Time, bool) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		log.LogDetail(fmt.Sprintf("ignoring invalid SOURCE_DATE_EPOCH: %s", epoch))
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// outputTimestamp 写入输出文件的时间
+func outputTimestamp() string {
+	if date, ok := sourceDate(); ok {
+		return date.UTC().Format(time.RFC3339)
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// contentUUID 设置了SOURCE_DATE_EPOCH时由content得出的UUID，否则为随机UUID
+func contentUUID(content string) string {
+	if _, ok := sourceDate(); !ok {
+		return newUUID()
+	}
+	sum := sha256.Sum256([]byte(content))
+	b := sum[:16]
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
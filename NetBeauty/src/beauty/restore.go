@@ -0,0 +1,69 @@
+package beauty
+
+import (
+	"fmt"
+	"path/filepath"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var hostFXRNames = []string{"hostfxr.dll", "libhostfxr.so", "libhostfxr.dylib"}
+
+// findHostFXR 查找目录下的hostfxr
+func findHostFXR(dir string) string {
+	for _, name := range hostFXRNames {
+		fxr := filepath.Join(dir, name)
+		if util.PathExists(fxr) {
+			return fxr
+		}
+	}
+	return ""
+}
+
+// restoreFXR 从备份还原原始hostfxr，不还原其他文件
+func restoreFXR(dir string) bool {
+	fxr := findHostFXR(dir)
+	if fxr == "" {
+		log.LogError(fmt.Errorf("no hostfxr found in %s", dir), false)
+		return false
+	}
+
+	bak := fxr + ".bak"
+	if !util.PathExists(bak) {
+		log.LogError(fmt.Errorf("no hostfxr backup found: %s", bak), false)
+		return false
+	}
+
+	isHidden, hidErr := misc.IsHiddenFile(fxr)
+	if isHidden && hidErr == nil {
+		misc.ShowFile(fxr)
+	}
+
+	log.LogDetail(fmt.Sprintf("restoring %s from %s", fxr, bak))
+
+	_, err := util.CopyFile(bak, fxr)
+
+	if isHidden && hidErr == nil {
+		misc.HideFile(fxr)
+	}
+
+	if err != nil {
+		log.LogError(fmt.Errorf("restore hostfxr failed: %s", err.Error()), false)
+		return false
+	}
+
+	if marker, err := manager.ReadBeautyMarker(dir); err == nil {
+		marker.Patched = false
+
+		markerPath := manager.MarkerPath(dir)
+		misc.ShowFile(markerPath)
+		if manager.SaveBeautyMarker(dir, marker) {
+			misc.HideFile(markerPath)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,156 @@
+package beauty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// movedRelocations 本次移入libsDir的文件（整个移动的目录按其中的文件记录），加上previous中仍在libsDir中的其他文件
+func movedRelocations(dir string, libs string, previous []manager.Relocation) []manager.Relocation {
+	libsPath := filepath.Join(dir, libs)
+	relocations := make([]manager.Relocation, 0, len(journalMoves)+len(previous))
+	seen := make(map[string]bool)
+	add := func(from string, to string) {
+		relFrom, err := filepath.Rel(dir, from)
+		if err != nil {
+			return
+		}
+		relTo, err := filepath.Rel(dir, to)
+		if err != nil || seen[filepath.ToSlash(relTo)] {
+			return
+		}
+		seen[filepath.ToSlash(relTo)] = true
+		relocations = append(relocations, manager.Relocation{From: filepath.ToSlash(relFrom), To: filepath.ToSlash(relTo)})
+	}
+
+	for _, move := range journalMoves {
+		if !util.PathWithin(libsPath, move.To) || util.PathExists(move.From) {
+			continue
+		}
+		fi, err := util.FileSystem.Stat(move.To)
+		if err != nil {
+			continue
+		}
+		if !fi.IsDir() {
+			add(move.From, move.To)
+			continue
+		}
+		for _, file := range util.GetAllFiles(move.To, true) {
+			if rel, err := filepath.Rel(move.To, file); err == nil {
+				add(filepath.Join(move.From, rel), file)
+			}
+		}
+	}
+
+	for _, relocation := range previous {
+		if !seen[relocation.To] && util.PathExists(filepath.Join(dir, filepath.FromSlash(relocation.To))) {
+			seen[relocation.To] = true
+			relocations = append(relocations, relocation)
+		}
+	}
+
+	sort.Slice(relocations, func(i, j int) bool { return relocations[i].From < relocations[j].From })
+	return relocations
+}
+
+// previousRelocations 之前移入libsDir的文件
+func previousRelocations() []manager.Relocation {
+	if resumedRelocations != nil {
+		return resumedRelocations
+	}
+	if marker, err := manager.ReadBeautyMarker(beautyDir); err == nil {
+		return marker.Relocations
+	}
+	return nil
+}
+
+// revert 撤销dir的beauty：按revert journal撤销各次beauty的修改（包括deps.json、runtimeconfig.json的改写），
+// 再将标记文件中记录的其余文件移回原处，最后删除标记文件
+func revert(dir string) error {
+	marker, err := manager.ReadBeautyMarker(dir)
+	if err != nil {
+		return fmt.Errorf("%s has no beauty marker, there is nothing to revert (run `nbeauty migrate %s` if it was beautified by an older release)", dir, dir)
+	}
+
+	// 先撤销被中断的beauty
+	if util.PathExists(filepath.Join(dir, JournalName)) {
+		log.LogDetail(fmt.Sprintf("undoing the interrupted run on %s", dir))
+		if err := replayJournal(dir); err != nil {
+			return err
+		}
+	}
+
+	revertPath := filepath.Join(dir, RevertJournalName)
+	journaled := util.PathExists(revertPath)
+	if journaled {
+		if err := replayJournalFile(dir, revertPath); err != nil {
+			return err
+		}
+	}
+
+	failed, moved := 0, 0
+	for i := len(marker.Relocations) - 1; i >= 0; i-- {
+		from := filepath.Join(dir, filepath.FromSlash(marker.Relocations[i].From))
+		to := filepath.Join(dir, filepath.FromSlash(marker.Relocations[i].To))
+		// 已由journal移回，或原处已有新发布的文件
+		if !util.PathExists(to) || util.PathExists(from) {
+			continue
+		}
+		log.LogDetail(fmt.Sprintf("moving back %s", from))
+		err := util.FileSystem.MkdirAll(filepath.Dir(from), util.DirMode)
+		if err == nil {
+			err = util.MoveFile(to, from)
+		}
+		if err != nil {
+			failed++
+			log.LogErrorFields(fmt.Errorf("move back failed: %s : %s", to, err.Error()), log.Fields{"file": to})
+			continue
+		}
+		moved++
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d file(s) cannot be moved back, the beauty marker is kept", failed)
+	}
+
+	libsPath := filepath.Join(dir, filepath.FromSlash(marker.LibsDir))
+	removeEmptyDirs(libsPath)
+	if util.PathExists(libsPath) {
+		log.LogWarning(fmt.Sprintf("%s is not empty after reverting, leaving it", libsPath))
+	}
+
+	os.Remove(filepath.Join(dir, SquirrelManifestName))
+	markerPath := manager.MarkerPath(dir)
+	misc.ShowFile(markerPath)
+	if err := util.FileSystem.Remove(markerPath); err != nil {
+		return err
+	}
+
+	if !journaled {
+		log.LogWarning(fmt.Sprintf("%s has no %s, the files are moved back but deps.json, runtimeconfig.json and the hostfxr are left as they are, publish the app again", dir, RevertJournalName))
+	}
+	log.LogDetail(fmt.Sprintf("%s reverted, %d other file(s) moved back", dir, moved))
+	return nil
+}
+
+// removeEmptyDirs 由内向外删除dir中的空目录，dir为空时一起删除
+func removeEmptyDirs(dir string) {
+	entries, err := util.FileSystem.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			removeEmptyDirs(filepath.Join(dir, entry.Name()))
+		}
+	}
+	if entries, err := util.FileSystem.ReadDir(dir); err == nil && len(entries) == 0 {
+		util.FileSystem.Remove(dir)
+	}
+}
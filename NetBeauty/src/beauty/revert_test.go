@@ -0,0 +1,103 @@
+package beauty
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+func TestRevertRestoresThePublishDir(t *testing.T) {
+	original, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	published := treeOf(t, original)
+
+	for _, c := range []struct {
+		name string
+		run  func(t *testing.T, dir string)
+	}{
+		{"beauty", func(t *testing.T, dir string) {
+			beautify(t, testOptions(dir, nil))
+		}},
+		{"network io profile", func(t *testing.T, dir string) {
+			opts := testOptions(dir, nil)
+			opts.IOProfile = networkIOProfile
+			beautify(t, opts)
+		}},
+		{"beauty again with --force", func(t *testing.T, dir string) {
+			beautify(t, testOptions(dir, nil))
+			opts := testOptions(dir, nil)
+			opts.Force = true
+			beautify(t, opts)
+		}},
+		{"relayout", func(t *testing.T, dir string) {
+			beautify(t, testOptions(dir, nil))
+			opts := testOptions(dir, nil)
+			opts.LibsDir = "lib/net"
+			if err := Relayout(context.Background(), opts); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir, cleanup := publishDir(t, "fdd")
+			defer cleanup()
+			c.run(t, dir)
+
+			marker, err := manager.ReadBeautyMarker(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(marker.Relocations) != 4 {
+				t.Errorf("relocations = %+v", marker.Relocations)
+			}
+
+			var messages []string
+			if err := Revert(context.Background(), testOptions(dir, &messages)); err != nil {
+				t.Fatal(err)
+			}
+			if len(messages) != 0 {
+				t.Errorf("unexpected messages: %v", messages)
+			}
+			if util.PathExists(manager.MarkerPath(dir)) || util.PathExists(filepath.Join(dir, RevertJournalName)) {
+				t.Error("the marker or the revert journal is left behind")
+			}
+			if reverted := treeOf(t, dir); !reflect.DeepEqual(reverted, published) {
+				t.Errorf("reverted directory differs from the published one:\n%v\n%v", reverted, published)
+			}
+		})
+	}
+}
+
+func TestRevertWithoutJournalMovesFilesBack(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	beautify(t, testOptions(dir, nil))
+	if err := util.FileSystem.Remove(filepath.Join(dir, RevertJournalName)); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	if err := Revert(context.Background(), testOptions(dir, &messages)); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"Newtonsoft.Json.dll", "zh-Hans/Foo.Res.resources.dll", "runtimes/linux-x64/native/libfoo.so"} {
+		if !util.PathExists(filepath.Join(dir, filepath.FromSlash(file))) {
+			t.Errorf("%s has not been moved back", file)
+		}
+	}
+	if !containsMessage(messages, "publish the app again") {
+		t.Errorf("the config files left as they are are not reported: %v", messages)
+	}
+}
+
+func TestRevertWithoutMarker(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	if err := Revert(context.Background(), testOptions(dir, nil)); err == nil {
+		t.Error("a directory that has not been beautified is reverted")
+	}
+}
@@ -0,0 +1,276 @@
+package beauty
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// SBOM格式
+const (
+	CycloneDX = "cyclonedx"
+	SPDX      = "spdx"
+)
+
+var sbomFile = ""
+var sbomFormat = CycloneDX
+
+// relocatedFile 一个被移动到libsDir的依赖
+type relocatedFile struct {
+	dep  manager.Deps
+	from string
+	path string
+}
+
+var relocated []relocatedFile
+
+// recordRelocated 记录被移动的依赖，写入SBOM时再计算哈希
+func recordRelocated(dep manager.Deps, from string, path string) {
+	relocated = append(relocated, relocatedFile{dep: dep, from: from, path: path})
+}
+
+// sbomComponent 两种格式共用的组件信息
+type sbomComponent struct {
+	ref     string
+	name    string
+	version string
+	path    string
+	hash    string
+	library string
+	purl    string
+}
+
+// sbomComponents 按相对路径排序的被移动依赖
+func sbomComponents() ([]sbomComponent, error) {
+	components := make([]sbomComponent, 0, len(relocated))
+	for _, file := range relocated {
+		rel, err := filepath.Rel(beautyDir, file.path)
+		if err != nil {
+			rel = file.path
+		}
+		rel = filepath.ToSlash(rel)
+
+		hash, err := util.GetFileHash(file.path)
+		if err != nil {
+			return nil, err
+		}
+
+		component := sbomComponent{
+			ref:     rel,
+			name:    file.dep.Name,
+			version: file.dep.Version,
+			path:    rel,
+			hash:    hash,
+			library: file.dep.Library,
+		}
+		// 只有NuGet包才有purl，project引用没有包来源
+		if parts := strings.SplitN(file.dep.Library, "/", 2); file.dep.Package && len(parts) == 2 {
+			component.purl = fmt.Sprintf("pkg:nuget/%s@%s", parts[0], parts[1])
+			if component.version == "" {
+				component.version = parts[1]
+			}
+		}
+		components = append(components, component)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].path < components[j].path })
+	return components, nil
+}
+
+func sbomHashAlgorithm() string {
+	return strings.ToUpper(strings.Replace(util.HashAlgorithm, "sha", "sha-", 1))
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// sbomSeed 由应用名及组件得出SBOM的标识，相同的组件得到相同的标识
+func sbomSeed(components []sbomComponent) string {
+	seed := filepath.Base(beautyDir)
+	for _, c := range components {
+		seed += "\n" + c.path + ":" + c.hash
+	}
+	return seed
+}
+
+// cycloneDX CycloneDX 1.4 JSON
+func cycloneDX(components []sbomComponent) interface{} {
+	type property struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type hash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+	type component struct {
+		Type       string     `json:"type"`
+		Ref        string     `json:"bom-ref"`
+		Name       string     `json:"name"`
+		Version    string     `json:"version,omitempty"`
+		Purl       string     `json:"purl,omitempty"`
+		Hashes     []hash     `json:"hashes"`
+		Properties []property `json:"properties"`
+	}
+
+	list := make([]component, 0, len(components))
+	for _, c := range components {
+		properties := []property{{Name: "nbeauty:path", Value: c.path}}
+		if c.library != "" {
+			properties = append(properties, property{Name: "nbeauty:library", Value: c.library})
+		}
+		list = append(list, component{
+			Type:       "library",
+			Ref:        c.ref,
+			Name:       c.name,
+			Version:    c.version,
+			Purl:       c.purl,
+			Hashes:     []hash{{Alg: sbomHashAlgorithm(), Content: c.hash}},
+			Properties: properties,
+		})
+	}
+
+	return map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.4",
+		"serialNumber": "urn:uuid:" + contentUUID(sbomSeed(components)),
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": outputTimestamp(),
+			"tools":     []map[string]string{{"vendor": "nulastudio", "name": "nbeauty2", "version": Version}},
+			"component": map[string]string{"type": "application", "name": filepath.Base(beautyDir)},
+		},
+		"components": list,
+	}
+}
+
+// spdx SPDX 2.3 JSON，每个被移动的依赖为一个file
+func spdx(components []sbomComponent) interface{} {
+	type checksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+	type externalRef struct {
+		Category string `json:"referenceCategory"`
+		Type     string `json:"referenceType"`
+		Locator  string `json:"referenceLocator"`
+	}
+	type pkg struct {
+		ID               string        `json:"SPDXID"`
+		Name             string        `json:"name"`
+		Version          string        `json:"versionInfo,omitempty"`
+		DownloadLocation string        `json:"downloadLocation"`
+		FilesAnalyzed    bool          `json:"filesAnalyzed"`
+		ExternalRefs     []externalRef `json:"externalRefs,omitempty"`
+	}
+	type file struct {
+		ID        string     `json:"SPDXID"`
+		Name      string     `json:"fileName"`
+		Checksums []checksum `json:"checksums"`
+		Comment   string     `json:"comment,omitempty"`
+	}
+	type relationship struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	}
+
+	spdxID := func(prefix string, name string) string {
+		id := strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+				return r
+			}
+			return '-'
+		}, name)
+		return "SPDXRef-" + prefix + "-" + id
+	}
+
+	packages := make([]pkg, 0)
+	files := make([]file, 0, len(components))
+	relationships := []relationship{}
+	seen := make(map[string]bool)
+	for _, c := range components {
+		fileID := spdxID("File", c.path)
+		comment := ""
+		if c.version != "" {
+			comment = "version " + c.version
+		}
+		files = append(files, file{
+			ID:        fileID,
+			Name:      "./" + c.path,
+			Checksums: []checksum{{Algorithm: strings.Replace(sbomHashAlgorithm(), "-", "", 1), ChecksumValue: c.hash}},
+			Comment:   comment,
+		})
+		relationships = append(relationships, relationship{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: fileID})
+
+		if c.purl == "" {
+			continue
+		}
+		packageID := spdxID("Package", c.library)
+		if !seen[packageID] {
+			seen[packageID] = true
+			parts := strings.SplitN(c.library, "/", 2)
+			packages = append(packages, pkg{
+				ID:               packageID,
+				Name:             parts[0],
+				Version:          parts[1],
+				DownloadLocation: "NOASSERTION",
+				ExternalRefs:     []externalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: c.purl}},
+			})
+		}
+		relationships = append(relationships, relationship{Element: packageID, Type: "CONTAINS", Related: fileID})
+	}
+
+	name := filepath.Base(beautyDir)
+	return map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              name,
+		"documentNamespace": fmt.Sprintf("https://github.com/nulastudio/NetBeauty2/sbom/%s-%s", name, contentUUID(sbomSeed(components))),
+		"creationInfo": map[string]interface{}{
+			"created":  outputTimestamp(),
+			"creators": []string{"Tool: nbeauty2-" + Version},
+		},
+		"packages":      packages,
+		"files":         files,
+		"relationships": relationships,
+	}
+}
+
+// writeSBOM 将本次移动的依赖写入sbomFile
+func writeSBOM() error {
+	components, err := sbomComponents()
+	if err != nil {
+		return err
+	}
+
+	var document interface{}
+	if sbomFormat == SPDX {
+		document = spdx(components)
+	} else {
+		document = cycloneDX(components)
+	}
+
+	bytes, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := util.FileSystem.WriteFile(sbomFile, append(bytes, '\n'), util.FileMode); err != nil {
+		return err
+	}
+
+	log.LogDetail(fmt.Sprintf("sbom with %d component(s) written to %s", len(components), sbomFile))
+	return nil
+}
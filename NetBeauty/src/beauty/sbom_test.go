@@ -0,0 +1,101 @@
+package beauty
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+func TestSBOMListsMovedFiles(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+
+	cyclonedx := filepath.Join(filepath.Dir(dir), "sbom.cdx.json")
+	opts := testOptions(dir, nil)
+	opts.SBOM = cyclonedx
+	beautify(t, opts)
+
+	var bom struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Ref     string `json:"bom-ref"`
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			Purl    string `json:"purl"`
+			Hashes  []struct {
+				Content string `json:"content"`
+			} `json:"hashes"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal([]byte(readFile(t, cyclonedx)), &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.BOMFormat != "CycloneDX" {
+		t.Errorf("bomFormat = %s", bom.BOMFormat)
+	}
+
+	hash, err := util.GetFileHash(filepath.Join(dir, "libs", "Newtonsoft.Json.dll"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, c := range bom.Components {
+		if c.Ref != "libs/Newtonsoft.Json.dll" {
+			continue
+		}
+		found = true
+		if c.Purl != "pkg:nuget/Newtonsoft.Json@13.0.1" || len(c.Hashes) != 1 || c.Hashes[0].Content != hash {
+			t.Errorf("unexpected component: %+v", c)
+		}
+	}
+	if !found || len(bom.Components) < 3 {
+		t.Errorf("moved files are missing from the sbom: %+v", bom.Components)
+	}
+	// 未移动的文件不应出现在SBOM中
+	for _, c := range bom.Components {
+		if filepath.Dir(c.Ref) == "." {
+			t.Errorf("%s has not been moved but is listed", c.Ref)
+		}
+	}
+}
+
+func TestSPDXIsReproducible(t *testing.T) {
+	defer os.Setenv("SOURCE_DATE_EPOCH", os.Getenv("SOURCE_DATE_EPOCH"))
+	os.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	documents := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		dir, cleanup := publishDir(t, "fdd")
+		defer cleanup()
+
+		spdx := filepath.Join(filepath.Dir(dir), "sbom.spdx.json")
+		opts := testOptions(dir, nil)
+		opts.SBOM = spdx
+		opts.SBOMFormat = SPDX
+		beautify(t, opts)
+		documents = append(documents, readFile(t, spdx))
+	}
+
+	var document struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Files       []struct {
+			Name string `json:"fileName"`
+		} `json:"files"`
+		Packages []struct {
+			Name    string `json:"name"`
+			Version string `json:"versionInfo"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal([]byte(documents[0]), &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.SPDXVersion != "SPDX-2.3" || len(document.Files) == 0 || len(document.Packages) == 0 {
+		t.Errorf("unexpected spdx document: %s", documents[0])
+	}
+	if documents[0] != documents[1] {
+		t.Errorf("the spdx document differs between two runs:\n%s\n%s", documents[0], documents[1])
+	}
+}
@@ -0,0 +1,102 @@
+package beauty
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// findAppBundle 向上查找beautyDir所在的.app
+func findAppBundle(dir string) string {
+	for {
+		if strings.HasSuffix(dir, ".app") {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func runCodesign(args ...string) error {
+	output, err := exec.Command("codesign", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s : %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// codesignBinary 替换hostfxr或修改apphost后签名会失效，重新签名该文件及所在的.app
+func codesignBinary(binPath string) error {
+	if runtime.GOOS != "darwin" {
+		return errors.New("codesign is only available on macOS")
+	}
+
+	log.LogDetail(fmt.Sprintf("signing %s with identity %s", binPath, codesignIdentity))
+
+	if err := runCodesign("--force", "--sign", codesignIdentity, binPath); err != nil {
+		return err
+	}
+
+	bundle := findAppBundle(beautyDir)
+	if bundle == "" {
+		return nil
+	}
+
+	log.LogDetail(fmt.Sprintf("signing %s with identity %s", bundle, codesignIdentity))
+
+	return runCodesign("--force", "--deep", "--preserve-metadata=entitlements,requirements,flags,runtime", "--sign", codesignIdentity, bundle)
+}
+
+// signtoolBinary 使用signtool重新进行Authenticode签名
+func signtoolBinary(binPath string) error {
+	args := []string{"sign", "/fd", "SHA256"}
+
+	if signThumbprint != "" {
+		args = append(args, "/sha1", signThumbprint)
+	} else {
+		args = append(args, "/f", signCert)
+		// 密码只是不出现在nbeauty的命令行中，signtool没有其他传入密码的方式，
+		// 运行signtool期间密码在其命令行中对同一台机器上的其他进程可见，需要避免时使用--signthumbprint
+		if password := os.Getenv("NBEAUTY_SIGN_PASSWORD"); password != "" {
+			args = append(args, "/p", password)
+		}
+	}
+
+	if signTimestamp != "" {
+		args = append(args, "/tr", signTimestamp, "/td", "SHA256")
+	}
+
+	args = append(args, binPath)
+
+	log.LogDetail(fmt.Sprintf("signing %s with %s", binPath, signtool))
+
+	output, err := exec.Command(signtool, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s : %s", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// resignBinary 按命令行参数对修改过的二进制重新签名
+func resignBinary(binPath string) {
+	if codesign {
+		if err := codesignBinary(binPath); err != nil {
+			log.LogError(fmt.Errorf("codesign failed: %s", err.Error()), false)
+		}
+	}
+
+	if signThumbprint != "" || signCert != "" {
+		if err := signtoolBinary(binPath); err != nil {
+			log.LogError(fmt.Errorf("signtool failed: %s", err.Error()), false)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package beauty
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var squirrel = false
+
+// SquirrelManifestName squirrel及--incremental模式下写入beautyDir的文件移动清单，供应用的Squirrel事件处理及下次增量beauty读取
+const SquirrelManifestName = "NetCoreBeauty.relocations.json"
+
+// squirrelFiles 更新时由Squirrel/Clowd.Squirrel使用的文件，不移动也不隐藏
+var squirrelFiles = []string{
+	"Update.exe",
+	"Squirrel.exe",
+	"*_ExecutionStub.exe",
+	"sq.version",
+}
+
+// squirrelManifest 文件移动清单，路径均为相对beautyDir的"/"分隔路径
+type squirrelManifest struct {
+	Tool        string               `json:"tool"`
+	Version     string               `json:"version"`
+	LibsDir     string               `json:"libsDir"`
+	Relocations []squirrelRelocation `json:"relocations"`
+	Untouched   []string             `json:"untouched"`
+	// --incremental时libsDir之外保持原样的所有文件
+	Kept []string `json:"kept,omitempty"`
+}
+
+type squirrelRelocation struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// isSquirrelFile 是否为squirrel模式下需要保持原样的文件
+func isSquirrelFile(file string) bool {
+	return squirrel && fileMatch(filepath.Base(file), squirrelFiles)
+}
+
+// squirrelRelocations 按原路径排序的本次移动的文件，增量beauty时包括上次移动的其他文件
+func squirrelRelocations() []squirrelRelocation {
+	relocations := make([]squirrelRelocation, 0, len(relocated))
+	moved := make(map[string]bool, len(relocated))
+	for _, file := range relocated {
+		relocation := squirrelRelocation{From: slashRel(file.from), To: slashRel(file.path)}
+		relocations = append(relocations, relocation)
+		moved[relocation.From] = true
+	}
+	if previousManifest != nil {
+		for _, relocation := range previousManifest.Relocations {
+			if !moved[relocation.From] {
+				relocations = append(relocations, relocation)
+			}
+		}
+	}
+	sort.Slice(relocations, func(i, j int) bool { return relocations[i].From < relocations[j].From })
+	return relocations
+}
+
+// slashRel 相对beautyDir的"/"分隔路径
+func slashRel(file string) string {
+	rel, err := filepath.Rel(beautyDir, file)
+	if err != nil {
+		return filepath.ToSlash(file)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// writeSquirrelManifest 写入移动的文件及保持原样的文件
+func writeSquirrelManifest() error {
+	manifest := squirrelManifest{
+		Tool:        "nbeauty2",
+		Version:     Version,
+		LibsDir:     libsDir,
+		Relocations: squirrelRelocations(),
+		Untouched:   make([]string, 0),
+	}
+	for _, file := range util.GetAllFiles(beautyDir, false) {
+		if isSquirrelFile(file) {
+			manifest.Untouched = append(manifest.Untouched, slashRel(file))
+		}
+	}
+	if incremental {
+		manifest.Kept = make([]string, 0)
+		walkKept(func(path string, rel string, fi os.FileInfo) {
+			manifest.Kept = append(manifest.Kept, rel)
+		})
+	}
+
+	bytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(beautyDir, SquirrelManifestName)
+	if err := util.FileSystem.WriteFile(path, append(bytes, '\n'), util.FileMode); err != nil {
+		return err
+	}
+	if err := util.Sync(path); err != nil {
+		return err
+	}
+
+	log.LogDetail(fmt.Sprintf("relocation manifest with %d file(s) written to %s", len(manifest.Relocations), path))
+	return nil
+}
+
+// squirrelExcludes 将squirrelFiles追加到excludes
+func squirrelExcludes(excludes string) string {
+	patterns := strings.Join(squirrelFiles, ";")
+	if excludes == "" {
+		return patterns
+	}
+	return excludes + ";" + patterns
+}
@@ -0,0 +1,131 @@
+package beauty
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// fxrStatus hostfxr检查结果
+type fxrStatus struct {
+	fxr        string
+	hash       string
+	patched    bool
+	determined bool
+	fxrVersion string
+	rid        string
+	revision   string
+	backup     string
+}
+
+// inspectFXR 检查目录下的hostfxr是微软原版还是补丁版，以及补丁版本
+// 与补丁一致时为补丁版，与备份或标记文件中记录的原版hash一致时为原版，否则无法确定
+func inspectFXR(dir string) (*fxrStatus, error) {
+	fxr := findHostFXR(dir)
+	if fxr == "" {
+		return nil, fmt.Errorf("no hostfxr found in %s", dir)
+	}
+
+	sum, err := util.GetFileHash(fxr)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &fxrStatus{fxr: fxr, hash: sum}
+
+	if util.PathExists(fxr + ".bak") {
+		status.backup = fxr + ".bak"
+	}
+
+	libs, originalHash := libsDir, ""
+	if marker, err := manager.ReadBeautyMarker(dir); err == nil {
+		status.fxrVersion, status.rid = marker.FXRVersion, marker.RID
+		libs = marker.LibsDir
+		if marker.HashAlgorithm == util.HashAlgorithm {
+			originalHash = marker.FXROriginalHash
+		}
+	}
+	if status.fxrVersion == "" || status.rid == "" {
+		for _, deps := range manager.FindDepsJSON(dir) {
+			if status.fxrVersion, status.rid = manager.FindFXRInfo(deps); status.fxrVersion != "" && status.rid != "" {
+				break
+			}
+		}
+	}
+
+	// 与本地缓存的补丁比对
+	if status.fxrVersion != "" && status.rid != "" {
+		for _, rid := range []string{status.rid, manager.FindCompatibleRID(status.rid)} {
+			if rid == "" || !manager.IsLocalArtifactExists(status.fxrVersion, rid) {
+				continue
+			}
+			if artifactSum, err := util.GetFileHash(manager.LocalArtifactFile(status.fxrVersion, rid)); err == nil && strings.EqualFold(artifactSum, sum) {
+				status.patched = true
+				status.determined = true
+				status.rid = rid
+				status.revision = manager.GetLocalArtifactsVersion(status.fxrVersion, rid)
+				return status, nil
+			}
+		}
+	}
+
+	// 与已知补丁列表比对，不在列表中也不能说明是原版（可能是被替换的其他文件）
+	if hashes, err := manager.GetKnownHashes(); err == nil {
+		for verid, list := range hashes {
+			for _, known := range list {
+				if strings.EqualFold(known, sum) {
+					status.patched = true
+					if s := strings.Split(verid, "/"); len(s) == 2 {
+						status.fxrVersion, status.rid = s[0], s[1]
+					}
+					return status, nil
+				}
+			}
+		}
+	}
+
+	// 与beauty时记录的原版hash及备份比对
+	if originalHash != "" && strings.EqualFold(originalHash, sum) {
+		status.determined = true
+		return status, nil
+	}
+	for _, original := range []string{status.backup, filepath.Join(dir, libs, ".original", filepath.Base(fxr))} {
+		if original == "" || !util.PathExists(original) {
+			continue
+		}
+		if originalSum, err := util.GetFileHash(original); err == nil && strings.EqualFold(originalSum, sum) {
+			status.determined = true
+			return status, nil
+		}
+	}
+
+	return status, nil
+}
+
+func (s *fxrStatus) lines() []string {
+	state := "unknown"
+	if s.patched {
+		state = "patched"
+	} else if s.determined {
+		state = "original"
+	}
+
+	lines := []string{
+		fmt.Sprintf("hostfxr: %s", s.fxr),
+		fmt.Sprintf("%s: %s", util.HashAlgorithm, s.hash),
+		fmt.Sprintf("status: %s", state),
+	}
+	if s.fxrVersion != "" && s.rid != "" {
+		lines = append(lines, fmt.Sprintf("runtime: %s/%s", s.fxrVersion, s.rid))
+	}
+	if s.revision != "" {
+		lines = append(lines, fmt.Sprintf("patch revision: %s", s.revision))
+	}
+	if s.backup != "" {
+		lines = append(lines, fmt.Sprintf("backup: %s", s.backup))
+	}
+	return lines
+}
@@ -0,0 +1,49 @@
+package beauty
+
+import (
+	"path/filepath"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// fxrState inspectFXR得到的状态
+func fxrState(t *testing.T, dir string) string {
+	status, err := inspectFXR(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status.lines()[2]
+}
+
+func TestInspectFXR(t *testing.T) {
+	dir, cleanup := publishDir(t, "scd")
+	defer cleanup()
+	fxr := filepath.Join(dir, "libhostfxr.so")
+
+	if state := fxrState(t, dir); state != "status: unknown" {
+		t.Errorf("a hostfxr without backup or marker is reported as %q", state)
+	}
+
+	hash, err := util.GetFileHash(fxr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manager.SaveBeautyMarker(dir, &manager.BeautyMarker{LibsDir: "libs", FXROriginalHash: hash, HashAlgorithm: util.HashAlgorithm}) {
+		t.Fatal("cannot write the marker")
+	}
+	if state := fxrState(t, dir); state != "status: original" {
+		t.Errorf("a hostfxr matching the recorded original hash is reported as %q", state)
+	}
+
+	writeFiles(t, dir, map[string]string{"libhostfxr.so": "replaced"})
+	if state := fxrState(t, dir); state != "status: unknown" {
+		t.Errorf("a replaced hostfxr is reported as %q", state)
+	}
+
+	writeFiles(t, dir, map[string]string{"libhostfxr.so.bak": "replaced"})
+	if state := fxrState(t, dir); state != "status: original" {
+		t.Errorf("a hostfxr matching its backup is reported as %q", state)
+	}
+}
@@ -0,0 +1,140 @@
+package beauty
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+const (
+	hookStrategy    = "hook"
+	probingStrategy = "probing"
+	patchStrategy   = "patch"
+	appHostStrategy = "apphost"
+	noneStrategy    = "none"
+)
+
+// parseStrategies 解析--strategy，多个策略以","分隔，按顺序尝试
+func parseStrategies(value string) []string {
+	list := make([]string, 0)
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if s == probingStrategy {
+			s = hookStrategy
+		}
+		if _, ok := customStrategies[s]; !ok && !isBuiltinStrategy(s) {
+			log.LogPanic(fmt.Errorf("invalid strategy: %s", s), 1)
+		}
+		if s == appHostStrategy && sharedRuntimeMode {
+			log.LogPanic(errors.New("apphost strategy does not support shared runtime mode"), 1)
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+func isBuiltinStrategy(s string) bool {
+	return s == hookStrategy || s == patchStrategy || s == appHostStrategy || s == noneStrategy
+}
+
+// selectStrategy 选择第一个可用的策略，最后一个策略不做检查直接使用
+func selectStrategy() string {
+	for _, s := range strategies[:len(strategies)-1] {
+		if err := checkStrategy(s); err != nil {
+			log.LogDetail(fmt.Sprintf("strategy %s is not available: %s", s, err.Error()))
+			continue
+		}
+		log.LogDetail(fmt.Sprintf("using strategy: %s", s))
+		return s
+	}
+
+	s := strategies[len(strategies)-1]
+	log.LogDetail(fmt.Sprintf("using strategy: %s", s))
+	return s
+}
+
+func checkStrategy(s string) error {
+	switch s {
+	case hookStrategy:
+		return checkStartupHooks()
+	case patchStrategy:
+		fxrVersion, rid := "", ""
+		for _, deps := range manager.FindDepsJSON(beautyDir) {
+			if fxrVersion, rid = manager.FindFXRInfo(deps); fxrVersion != "" && rid != "" {
+				break
+			}
+		}
+		if fxrVersion == "" || rid == "" {
+			return errors.New("not a self-contained app")
+		}
+		if manager.IsLocalBuildArtifact(fxrVersion, rid) {
+			return nil
+		}
+		manager.CheckRunConfigJSON()
+		if !hasArtifact(fxrVersion, rid) {
+			return fmt.Errorf("no artifact for %s/%s", fxrVersion, rid)
+		}
+	case appHostStrategy:
+		for _, deps := range manager.FindDepsJSON(beautyDir) {
+			mainProgram := strings.Replace(filepath.Base(deps), ".deps.json", "", -1)
+			if appHost := findAppHost(mainProgram); appHost != "" && isAppHostOf(appHost, mainProgram) {
+				return nil
+			}
+		}
+		return errors.New("no apphost found")
+	}
+	if custom, ok := customStrategies[s]; ok {
+		return custom.Check(runCtx, beautyDir)
+	}
+	return nil
+}
+
+// checkStartupHooks nbloader以startup hook加载，需要.NET Core 3.0+且runtimeconfig.json没有关闭startup hook
+func checkStartupHooks() error {
+	runtimeConfigs := manager.FindRuntimeConfigJSON(beautyDir)
+	if len(runtimeConfigs) == 0 {
+		return errors.New("no runtimeconfig.json found")
+	}
+	for _, runtimeConfig := range runtimeConfigs {
+		content, err := util.FileSystem.ReadFile(runtimeConfig)
+		if err != nil {
+			return err
+		}
+		type framework struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		var config struct {
+			RuntimeOptions struct {
+				Framework          *framework             `json:"framework"`
+				Frameworks         []framework            `json:"frameworks"`
+				IncludedFrameworks []framework            `json:"includedFrameworks"`
+				ConfigProperties   map[string]interface{} `json:"configProperties"`
+			} `json:"runtimeOptions"`
+		}
+		if err := json.Unmarshal(content, &config); err != nil {
+			return fmt.Errorf("invalid runtimeconfig.json: %s : %s", runtimeConfig, err.Error())
+		}
+		options := config.RuntimeOptions
+		if supported, ok := options.ConfigProperties["System.StartupHookProvider.IsSupported"].(bool); ok && !supported {
+			return fmt.Errorf("startup hooks are disabled in %s", runtimeConfig)
+		}
+		frameworks := append(options.Frameworks, options.IncludedFrameworks...)
+		if options.Framework != nil {
+			frameworks = append(frameworks, *options.Framework)
+		}
+		for _, fw := range frameworks {
+			if major, err := strconv.Atoi(strings.SplitN(fw.Version, ".", 2)[0]); err == nil && major < 3 {
+				return fmt.Errorf("startup hooks need .NET Core 3.0+, %s targets %s %s", runtimeConfig, fw.Name, fw.Version)
+			}
+		}
+	}
+	return nil
+}
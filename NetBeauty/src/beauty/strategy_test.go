@@ -0,0 +1,70 @@
+package beauty
+
+import (
+	"path/filepath"
+	"testing"
+
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+func TestNoneStrategyKeepsHostFiles(t *testing.T) {
+	dir, cleanup := publishDir(t, "scd")
+	defer cleanup()
+
+	opts := testOptions(dir, nil)
+	opts.Strategy = noneStrategy
+	result := beautify(t, opts)
+	if result.MovedFiles == 0 {
+		t.Error("none strategy moved nothing")
+	}
+	for _, file := range []string{"libhostfxr.so", "libhostpolicy.so", "libcoreclr.so"} {
+		if !util.PathExists(filepath.Join(dir, file)) {
+			t.Errorf("%s has been moved", file)
+		}
+	}
+	if !util.PathExists(filepath.Join(dir, "libs", "Newtonsoft.Json.dll")) {
+		t.Error("Newtonsoft.Json.dll has not been moved")
+	}
+	if marker, err := manager.ReadBeautyMarker(dir); err != nil || marker.Strategy != noneStrategy {
+		t.Errorf("the marker does not record the none strategy: %v, %v", marker, err)
+	}
+}
+
+func TestHookStrategyFallsBackWhenStartupHooksAreDisabled(t *testing.T) {
+	dir, cleanup := publishDir(t, "fdd")
+	defer cleanup()
+	replaceInFile(t, filepath.Join(dir, "app.runtimeconfig.json"), `"tfm"`, `"configProperties": { "System.StartupHookProvider.IsSupported": false }, "tfm"`)
+
+	var messages []string
+	opts := testOptions(dir, &messages)
+	opts.Strategy = "hook,none"
+	result := beautify(t, opts)
+	if result.MovedFiles != 0 {
+		t.Errorf("moved %d files although the app cannot load the startup hook", result.MovedFiles)
+	}
+	if !containsMessage(messages, "cannot load the startup hook") {
+		t.Errorf("the fallback is not reported: %v", messages)
+	}
+}
+
+func TestCheckStartupHooks(t *testing.T) {
+	for runtimeConfig, ok := range map[string]bool{
+		`{ "runtimeOptions": { "framework": { "name": "Microsoft.NETCore.App", "version": "6.0.0" } } }`:                                                                  true,
+		`{ "runtimeOptions": { "frameworks": [ { "name": "Microsoft.NETCore.App", "version": "8.0.0" }, { "name": "Microsoft.AspNetCore.App", "version": "8.0.0" } ] } }`: true,
+		`{ "runtimeOptions": { "framework": { "name": "Microsoft.NETCore.App", "version": "2.1.0" } } }`:                                                                  false,
+		`{ "runtimeOptions": { "includedFrameworks": [ { "name": "Microsoft.NETCore.App", "version": "2.2.8" } ] } }`:                                                     false,
+		`{ "runtimeOptions": { "framework": { "version": "6.0.0" }, "configProperties": { "System.StartupHookProvider.IsSupported": false } } }`:                          false,
+	} {
+		func() {
+			dir, cleanup := publishDir(t, "fdd")
+			defer cleanup()
+			writeFiles(t, dir, map[string]string{"app.runtimeconfig.json": runtimeConfig})
+
+			beautyDir = dir
+			if err := checkStartupHooks(); (err == nil) != ok {
+				t.Errorf("checkStartupHooks() = %v for %s", err, runtimeConfig)
+			}
+		}()
+	}
+}
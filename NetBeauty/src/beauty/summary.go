@@ -0,0 +1,129 @@
+package beauty
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	i18n "github.com/nulastudio/NetBeauty/src/i18n"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// beautySummary 统计beauty前后的文件数、移动的文件及本次运行的结果
+type beautySummary struct {
+	started         time.Time
+	dirs            int
+	rootFilesBefore int
+	rootFilesAfter  int
+	movedFiles      int
+	skippedFiles    int
+	leftFiles       int
+	failedFiles     int
+	movedBytes      int64
+	libsDirSize     int64
+	rewrittenFiles  int
+	patch           string
+	fxrVersion      string
+	rid             string
+}
+
+var summary = &beautySummary{started: time.Now()}
+
+func countFiles(entries []string) int {
+	count := 0
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry, "/") {
+			count++
+		}
+	}
+	return count
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
+
+func (s *beautySummary) lines() []string {
+	patch := s.patch
+	if patch == "" {
+		patch = i18n.T("summary.patch.none")
+	}
+	return []string{
+		i18n.T("summary.title"),
+		i18n.T("summary.dirs", s.dirs),
+		i18n.T("summary.root", s.rootFilesBefore, s.rootFilesAfter),
+		i18n.T("summary.relocated", s.movedFiles, formatSize(s.movedBytes)),
+		i18n.T("summary.skipped", s.skippedFiles, s.failedFiles),
+		i18n.T("summary.conflicts", len(conflicts)),
+		i18n.T("summary.rewritten", s.rewrittenFiles),
+		i18n.T("summary.patch", patch),
+		i18n.T("summary.libsdirsize", formatSize(s.libsDirSize)),
+		i18n.T("summary.problems", log.Count(log.Error), log.Count(log.Warning)),
+		i18n.T("summary.elapsed", time.Since(s.started).Round(time.Millisecond)),
+		i18n.T("summary.end"),
+	}
+}
+
+func (s *beautySummary) fields() log.Fields {
+	return log.Fields{
+		"dirs":            s.dirs,
+		"rootFilesBefore": s.rootFilesBefore,
+		"rootFilesAfter":  s.rootFilesAfter,
+		"movedFiles":      s.movedFiles,
+		"skippedFiles":    s.skippedFiles,
+		"leftFiles":       s.leftFiles,
+		"failedFiles":     s.failedFiles,
+		"conflicts":       len(conflicts),
+		"movedBytes":      s.movedBytes,
+		"libsDirSize":     s.libsDirSize,
+		"rewrittenFiles":  s.rewrittenFiles,
+		"patch":           s.patch,
+		"errors":          log.Count(log.Error),
+		"warnings":        log.Count(log.Warning),
+		"elapsed":         time.Since(s.started).Seconds(),
+	}
+}
+
+func (s *beautySummary) result(modified bool) Result {
+	return Result{
+		Modified:        modified,
+		Dirs:            s.dirs,
+		RootFilesBefore: s.rootFilesBefore,
+		RootFilesAfter:  s.rootFilesAfter,
+		MovedFiles:      s.movedFiles,
+		SkippedFiles:    s.skippedFiles,
+		LeftFiles:       s.leftFiles,
+		FailedFiles:     s.failedFiles,
+		Conflicts:       len(conflicts),
+		MovedBytes:      s.movedBytes,
+		LibsDirSize:     s.libsDirSize,
+		RewrittenFiles:  s.rewrittenFiles,
+		Patch:           s.patch,
+		FXRVersion:      s.fxrVersion,
+		RID:             s.rid,
+		Elapsed:         time.Since(s.started),
+	}
+}
@@ -0,0 +1,128 @@
+package beauty
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// realBeautyDir beautyDir解析符号链接后的路径，用于判断文件是否经由符号链接指向beautyDir之外
+var realBeautyDir = ""
+
+// insideDirs 目录是否在beautyDir内的缓存
+var insideDirs map[string]bool
+
+// resolveBeautyDir beautyDir本身是符号链接时改为beauty其指向的目录
+func resolveBeautyDir() {
+	realBeautyDir, insideDirs = "", make(map[string]bool)
+	resolved, err := filepath.EvalSymlinks(beautyDir)
+	if err != nil {
+		return
+	}
+	if fi, err := os.Lstat(beautyDir); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		log.LogDetail(fmt.Sprintf("%s is a symlink to %s, beautifying the target", beautyDir, resolved))
+		beautyDir = resolved
+	}
+	realBeautyDir = resolved
+}
+
+// insideBeautyDir 解析符号链接后dir是否仍在beautyDir内
+func insideBeautyDir(dir string) bool {
+	if realBeautyDir == "" {
+		return true
+	}
+	if inside, ok := insideDirs[dir]; ok {
+		return inside
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	inside := err == nil && (resolved == realBeautyDir || strings.HasPrefix(resolved, realBeautyDir+string(filepath.Separator)))
+	insideDirs[dir] = inside
+	return inside
+}
+
+// isSymlink file本身是否为符号链接
+func isSymlink(file string) bool {
+	fi, err := os.Lstat(file)
+	return err == nil && fi.Mode()&os.ModeSymlink != 0
+}
+
+// detachOutsideLink file是指向beautyDir之外的符号链接时替换为其内容的副本，避免修改beautyDir之外的文件
+func detachOutsideLink(file string) error {
+	if !isSymlink(file) {
+		return nil
+	}
+	target, err := filepath.EvalSymlinks(file)
+	if err != nil || insideBeautyDir(filepath.Dir(target)) {
+		return err
+	}
+	link, err := os.Readlink(file)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(target)
+	if err != nil {
+		return err
+	}
+	fi, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	log.LogWarning(fmt.Sprintf("%s links to %s outside of %s, replacing the link with a copy before rewriting it", file, target, beautyDir))
+	journalLinked(file, link)
+	if err := util.FileSystem.Remove(file); err != nil {
+		return err
+	}
+	if err := util.FileSystem.WriteFile(file, content, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	return util.Sync(file)
+}
+
+// fixRelocatedLinks 被移动的相对符号链接按新位置改写，指向的文件也被移动时指向其新位置
+func fixRelocatedLinks() {
+	moved := make(map[string]string, len(relocated))
+	for _, file := range relocated {
+		moved[file.from] = file.path
+	}
+
+	for _, file := range relocated {
+		if !isSymlink(file.path) {
+			continue
+		}
+		link, err := os.Readlink(file.path)
+		if err != nil || filepath.IsAbs(link) {
+			continue
+		}
+		target := filepath.Join(filepath.Dir(file.from), link)
+		if to, ok := moved[target]; ok {
+			target = to
+		}
+		newLink, err := filepath.Rel(filepath.Dir(file.path), target)
+		if err != nil || newLink == link {
+			continue
+		}
+		journalLinked(file.path, link)
+		if err := relink(file.path, newLink); err != nil {
+			log.LogErrorFields(fmt.Errorf("relink failed: %s : %s", file.path, err.Error()), log.Fields{"file": file.path})
+			continue
+		}
+		log.LogDetail(fmt.Sprintf("relinked %s -> %s", file.path, newLink))
+	}
+}
+
+// relink 将符号链接file改为指向link
+func relink(file string, link string) error {
+	if err := util.CheckWrite("relink", file); err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(link, file)
+}
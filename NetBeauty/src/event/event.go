@@ -0,0 +1,103 @@
+package event
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	StageStarted     = "stage_started"
+	FileMoved        = "file_moved"
+	DownloadProgress = "download_progress"
+	PatchApplied     = "patch_applied"
+	RunFinished      = "run_finished"
+)
+
+// Data 事件附带的数据
+type Data map[string]interface{}
+
+// Event 每个事件输出为一行json（NDJSON）
+type Event struct {
+	Type      string `json:"type"`
+	Timestamp string `json:"timestamp"`
+	RunID     string `json:"runId,omitempty"`
+	Data      Data   `json:"data,omitempty"`
+}
+
+// RunID 本次运行的ID，附带在每个事件中
+var RunID = ""
+
+var output io.Writer
+var mutex sync.Mutex
+
+// subscribers 以库方式使用时的事件回调
+var subscribers = make(map[int]func(Event))
+var nextSubscriber = 0
+
+// SetOutput 设置事件输出，为nil时不输出
+func SetOutput(w io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	output = w
+}
+
+// Subscribe 注册事件回调，收到的事件与NDJSON输出一致，调用返回的函数取消注册
+func Subscribe(callback func(Event)) func() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	id := nextSubscriber
+	nextSubscriber++
+	subscribers[id] = callback
+
+	return func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		delete(subscribers, id)
+	}
+}
+
+// Enabled 是否需要输出事件
+func Enabled() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return output != nil || len(subscribers) != 0
+}
+
+// Emit 输出一个事件
+func Emit(eventType string, data Data) {
+	mutex.Lock()
+
+	if output == nil && len(subscribers) == 0 {
+		mutex.Unlock()
+		return
+	}
+
+	e := Event{
+		Type:      eventType,
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		RunID:     RunID,
+		Data:      data,
+	}
+
+	if output != nil {
+		if bytes, err := json.Marshal(e); err == nil {
+			output.Write(append(bytes, '\n'))
+		}
+	}
+
+	callbacks := make([]func(Event), 0, len(subscribers))
+	for _, callback := range subscribers {
+		callbacks = append(callbacks, callback)
+	}
+
+	mutex.Unlock()
+
+	// 回调中可能再次Emit，不能持有锁
+	for _, callback := range callbacks {
+		callback(e)
+	}
+}
@@ -0,0 +1,31 @@
+package event
+
+import (
+	"time"
+)
+
+// ProgressWriter 统计写入的字节数并定时输出download_progress事件
+type ProgressWriter struct {
+	URL     string
+	Total   int64
+	Written int64
+	last    time.Time
+}
+
+func (w *ProgressWriter) Write(p []byte) (int, error) {
+	w.Written += int64(len(p))
+	if time.Since(w.last) >= 200*time.Millisecond || w.Written == w.Total {
+		w.last = time.Now()
+		w.Emit()
+	}
+	return len(p), nil
+}
+
+// Emit 输出当前进度
+func (w *ProgressWriter) Emit() {
+	Emit(DownloadProgress, Data{
+		"url":     w.URL,
+		"written": w.Written,
+		"total":   w.Total,
+	})
+}
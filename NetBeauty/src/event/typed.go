@@ -0,0 +1,127 @@
+package event
+
+import (
+	"time"
+)
+
+// StageStartedData stage_started事件的数据
+type StageStartedData struct {
+	Stage string
+}
+
+// FileMovedData file_moved事件的数据
+type FileMovedData struct {
+	From     string
+	To       string
+	Size     int64
+	Duration time.Duration
+}
+
+// DownloadProgressData download_progress事件的数据，Total未知时为0
+type DownloadProgressData struct {
+	URL     string
+	Written int64
+	Total   int64
+}
+
+// PatchAppliedData patch_applied事件的数据
+type PatchAppliedData struct {
+	File       string
+	FXRVersion string
+	RID        string
+}
+
+// RunFinishedData run_finished事件的数据
+type RunFinishedData struct {
+	Modified    bool
+	MovedFiles  int64
+	FailedFiles int64
+	MovedBytes  int64
+	Errors      int64
+	Warnings    int64
+	Elapsed     time.Duration
+}
+
+func (d Data) str(key string) string {
+	s, _ := d[key].(string)
+	return s
+}
+
+// number 兼容Emit时的原始类型及从NDJSON解码后的float64
+func (d Data) number(key string) float64 {
+	switch v := d[key].(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// StageStarted 事件为stage_started时返回其数据
+func (e Event) StageStarted() (StageStartedData, bool) {
+	if e.Type != StageStarted {
+		return StageStartedData{}, false
+	}
+	return StageStartedData{Stage: e.Data.str("stage")}, true
+}
+
+// FileMoved 事件为file_moved时返回其数据
+func (e Event) FileMoved() (FileMovedData, bool) {
+	if e.Type != FileMoved {
+		return FileMovedData{}, false
+	}
+	return FileMovedData{
+		From:     e.Data.str("from"),
+		To:       e.Data.str("to"),
+		Size:     int64(e.Data.number("size")),
+		Duration: seconds(e.Data.number("duration")),
+	}, true
+}
+
+// DownloadProgress 事件为download_progress时返回其数据
+func (e Event) DownloadProgress() (DownloadProgressData, bool) {
+	if e.Type != DownloadProgress {
+		return DownloadProgressData{}, false
+	}
+	return DownloadProgressData{
+		URL:     e.Data.str("url"),
+		Written: int64(e.Data.number("written")),
+		Total:   int64(e.Data.number("total")),
+	}, true
+}
+
+// PatchApplied 事件为patch_applied时返回其数据
+func (e Event) PatchApplied() (PatchAppliedData, bool) {
+	if e.Type != PatchApplied {
+		return PatchAppliedData{}, false
+	}
+	return PatchAppliedData{
+		File:       e.Data.str("file"),
+		FXRVersion: e.Data.str("fxrVersion"),
+		RID:        e.Data.str("rid"),
+	}, true
+}
+
+// RunFinished 事件为run_finished时返回其数据
+func (e Event) RunFinished() (RunFinishedData, bool) {
+	if e.Type != RunFinished {
+		return RunFinishedData{}, false
+	}
+	modified, _ := e.Data["modified"].(bool)
+	return RunFinishedData{
+		Modified:    modified,
+		MovedFiles:  int64(e.Data.number("movedFiles")),
+		FailedFiles: int64(e.Data.number("failedFiles")),
+		MovedBytes:  int64(e.Data.number("movedBytes")),
+		Errors:      int64(e.Data.number("errors")),
+		Warnings:    int64(e.Data.number("warnings")),
+		Elapsed:     seconds(e.Data.number("elapsed")),
+	}, true
+}
@@ -0,0 +1,53 @@
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	EnUS = "en-US"
+	ZhCN = "zh-CN"
+)
+
+// Lang 当前输出语言
+var Lang = EnUS
+
+// Detect 根据--lang或LC_ALL/LC_MESSAGES/LANG环境变量确定输出语言，未知语言一律使用en-US
+func Detect(lang string) string {
+	if lang == "" {
+		for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if lang = os.Getenv(env); lang != "" {
+				break
+			}
+		}
+	}
+
+	// zh_CN.UTF-8 => zh-cn
+	lang = strings.ToLower(strings.Replace(strings.Split(lang, ".")[0], "_", "-", -1))
+	if lang == "zh" || strings.HasPrefix(lang, "zh-cn") || strings.HasPrefix(lang, "zh-hans") || lang == "zh-sg" {
+		return ZhCN
+	}
+	return EnUS
+}
+
+// Supported 是否为支持的语言
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// T 返回当前语言的消息，缺失翻译时回退到en-US
+func T(key string, args ...interface{}) string {
+	format, ok := catalogs[Lang][key]
+	if !ok {
+		if format, ok = catalogs[EnUS][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,116 @@
+package i18n
+
+var catalogs = map[string]map[string]string{
+	EnUS: {
+		// usage
+		"usage":                 "Usage:",
+		"usage.arguments":       "Arguments",
+		"usage.excludes":        "  <excludes>    dlls that no need to be moved, multi-dlls separated with \";\". Example: dll1.dll;lib*;...",
+		"usage.options":         "Options",
+		"arguments.count":       "Too few or many arguments, expected %d, got %d",
+		"cdn.set":               "set default git cdn successfully",
+		"cdn.set.failed":        "set default git cdn failed",
+		"cdn.none":              "default git cdn has not been set yet",
+		"cdn.current":           "current default git cdn: %s",
+		"cdn.deleted":           "current default git cdn has been deleted, it was: [%s] before",
+		"patch.built":           "patched hostfxr v%s/%s has been built and installed",
+		"patch.command.unknown": "unknown patch command: %s",
+		"beautydir.invalid":     "invalid beautyDir: %s",
+
+		// hint error
+		"hint.attempted": "attempted",
+		"hint.cause":     "probable cause",
+		"hint.fix":       "how to fix",
+
+		"hint.artifact.cause":    "no patched hostfxr is published for this runtime version yet, or the mirror is outdated",
+		"hint.artifact.fix":      "report the missing artifact in here: https://github.com/nulastudio/NetBeauty2/discussions/36, use --strategy=patch,hook to fall back, or build it with `nbeauty patch build`",
+		"hint.writeable.cause":   "no write permission, or the app is still running and holds the files",
+		"hint.writeable.fix":     "close the app and make sure the current user can write to %s",
+		"hint.rid.cause":         "no patched hostfxr is published for this platform",
+		"hint.rid.fix":           "use --strategy=patch,hook to fall back to the startup hook, or build the patch with `nbeauty patch build --fxr=<version> --rid=%s`",
+		"hint.network.cause":     "the network is unreachable or github is blocked",
+		"hint.network.fix":       "use --gitcdn to specify a mirror, e.g. https://gitee.com/liesauer/HostFXRPatcher, or set it permanently with `nbeauty setcdn`",
+		"hint.notfound.cause":    "the file does not exist on the mirror, the mirror may be outdated or --gittree points to an old commit",
+		"hint.notfound.fix":      "check --gitcdn and --gittree, or build the patch locally with `nbeauty patch build`",
+		"hint.refused.cause":     "the server refused the request or is rate limiting",
+		"hint.refused.fix":       "try again later or use --gitcdn to specify a mirror",
+		"hint.interrupted.cause": "the connection was reset or timed out",
+		"hint.interrupted.fix":   "try again, or use --gitcdn to specify a faster mirror",
+		"hint.tempdir.cause":     "the temp directory is read-only or owned by another user",
+		"hint.tempdir.fix":       "fix the permissions of %s, or point TMPDIR (TEMP on Windows) to a writeable directory",
+
+		// summary
+		"summary.title":       "========== summary ==========",
+		"summary.dirs":        "directories processed: %d",
+		"summary.root":        "root files: %d -> %d",
+		"summary.relocated":   "relocated: %d files, %s",
+		"summary.skipped":     "skipped: %d files, failed: %d files",
+		"summary.conflicts":   "version conflicts: %d",
+		"summary.rewritten":   "json files rewritten: %d",
+		"summary.patch":       "patch: %s",
+		"summary.patch.none":  "not applied",
+		"summary.libsdirsize": "libs dir size: %s",
+		"summary.problems":    "errors: %d, warnings: %d",
+		"summary.elapsed":     "elapsed: %s",
+		"summary.end":         "=============================",
+
+		// --stdin
+		"batch.title":  "========== batch summary ==========",
+		"batch.dirs":   "directories: %d, succeeded: %d, failed: %d",
+		"batch.failed": "failed: %s",
+	},
+	ZhCN: {
+		"usage":                 "用法：",
+		"usage.arguments":       "参数",
+		"usage.excludes":        "  <excludes>    不需要移动的dll，多个dll以\";\"分隔。例如：dll1.dll;lib*;...",
+		"usage.options":         "选项",
+		"arguments.count":       "参数数量不正确，需要%d个，实际为%d个",
+		"cdn.set":               "默认git cdn设置成功",
+		"cdn.set.failed":        "默认git cdn设置失败",
+		"cdn.none":              "尚未设置默认git cdn",
+		"cdn.current":           "当前默认git cdn：%s",
+		"cdn.deleted":           "已删除默认git cdn，原为：[%s]",
+		"patch.built":           "补丁版hostfxr v%s/%s 已编译并安装",
+		"patch.command.unknown": "未知的patch命令：%s",
+		"beautydir.invalid":     "无效的beautyDir：%s",
+
+		"hint.attempted": "尝试访问",
+		"hint.cause":     "可能原因",
+		"hint.fix":       "解决办法",
+
+		"hint.artifact.cause":    "该运行时版本尚未发布补丁版hostfxr，或镜像已过期",
+		"hint.artifact.fix":      "请在 https://github.com/nulastudio/NetBeauty2/discussions/36 反馈缺失的补丁，或使用--strategy=patch,hook回退，或使用`nbeauty patch build`自行编译",
+		"hint.writeable.cause":   "没有写入权限，或程序仍在运行并占用了文件",
+		"hint.writeable.fix":     "关闭程序并确认当前用户对 %s 有写入权限",
+		"hint.rid.cause":         "该平台没有发布补丁版hostfxr",
+		"hint.rid.fix":           "使用--strategy=patch,hook回退到startup hook，或使用`nbeauty patch build --fxr=<version> --rid=%s`自行编译补丁",
+		"hint.network.cause":     "网络不可用或无法访问github",
+		"hint.network.fix":       "使用--gitcdn指定镜像，例如 https://gitee.com/liesauer/HostFXRPatcher ，或使用`nbeauty setcdn`永久设置",
+		"hint.notfound.cause":    "镜像中不存在该文件，镜像可能已过期或--gittree指向了旧的提交",
+		"hint.notfound.fix":      "检查--gitcdn和--gittree，或使用`nbeauty patch build`在本地编译补丁",
+		"hint.refused.cause":     "服务器拒绝了请求或触发了限流",
+		"hint.refused.fix":       "稍后重试，或使用--gitcdn指定镜像",
+		"hint.interrupted.cause": "连接被重置或超时",
+		"hint.interrupted.fix":   "重试，或使用--gitcdn指定更快的镜像",
+		"hint.tempdir.cause":     "临时目录只读或属于其他用户",
+		"hint.tempdir.fix":       "修正 %s 的权限，或将TMPDIR（Windows下为TEMP）指向可写目录",
+
+		"summary.title":       "========== 汇总 ==========",
+		"summary.dirs":        "处理目录数：%d",
+		"summary.root":        "根目录文件数：%d -> %d",
+		"summary.relocated":   "已移动：%d 个文件，%s",
+		"summary.skipped":     "已跳过：%d 个文件，失败：%d 个文件",
+		"summary.conflicts":   "版本冲突：%d",
+		"summary.rewritten":   "已改写json文件：%d",
+		"summary.patch":       "补丁：%s",
+		"summary.patch.none":  "未应用",
+		"summary.libsdirsize": "libs目录大小：%s",
+		"summary.problems":    "错误：%d，警告：%d",
+		"summary.elapsed":     "耗时：%s",
+		"summary.end":         "==========================",
+
+		"batch.title":  "========== 批量汇总 ==========",
+		"batch.dirs":   "目录数：%d，成功：%d，失败：%d",
+		"batch.failed": "失败：%s",
+	},
+}
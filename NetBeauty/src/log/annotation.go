@@ -0,0 +1,108 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Annotation CI的注释格式，Error及Warning以注释的形式输出到控制台，显示在构建结果中
+type Annotation int
+
+const (
+	NoAnnotation Annotation = iota
+	GitHubAnnotation
+	AzureAnnotation
+	TeamCityAnnotation
+)
+
+// annotate 按Annotation格式化Error及Warning，其它级别返回false
+// fields中的file作为注释的文件
+func (logger *Logger) annotate(message string, level LogLevel, fields Fields) (string, bool) {
+	if logger.Annotation == NoAnnotation || level > Warning {
+		return "", false
+	}
+
+	file, _ := fields["file"].(string)
+	file = relativeFile(file)
+
+	switch logger.Annotation {
+	case GitHubAnnotation:
+		command := "warning"
+		if level == Error {
+			command = "error"
+		}
+		if file != "" {
+			command += " file=" + githubEscape(file, true)
+		}
+		return fmt.Sprintf("::%s::%s", command, githubEscape(message, false)), true
+	case AzureAnnotation:
+		properties := "type=warning"
+		if level == Error {
+			properties = "type=error"
+		}
+		if file != "" {
+			properties += ";sourcepath=" + azureEscape(file, true)
+		}
+		return fmt.Sprintf("##vso[task.logissue %s;]%s", properties, azureEscape(message, false)), true
+	case TeamCityAnnotation:
+		if file != "" {
+			message = file + ": " + message
+		}
+		if level == Error {
+			return fmt.Sprintf("##teamcity[buildProblem description='%s']", teamCityEscape(message)), true
+		}
+		return fmt.Sprintf("##teamcity[message text='%s' status='WARNING']", teamCityEscape(message)), true
+	}
+	return "", false
+}
+
+// Progress 按Annotation格式输出当前阶段，不支持时返回false
+func (logger *Logger) Progress(stage string) bool {
+	switch logger.Annotation {
+	case AzureAnnotation:
+		fmt.Println("##[section]" + azureEscape(stage, false))
+	case TeamCityAnnotation:
+		fmt.Printf("##teamcity[progressMessage '%s']\n", teamCityEscape(stage))
+	default:
+		return false
+	}
+	return true
+}
+
+// relativeFile CI中的文件路径相对于工作目录（通常为仓库根目录）
+func relativeFile(file string) string {
+	if file == "" {
+		return ""
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(file)
+}
+
+// azureEscape Azure DevOps logging command的转义，属性值还需转义;及]
+func azureEscape(s string, property bool) string {
+	s = strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(";", "%3B", "]", "%5D").Replace(s)
+	}
+	return s
+}
+
+// teamCityEscape TeamCity service message的转义
+func teamCityEscape(s string) string {
+	return strings.NewReplacer("|", "||", "'", "|'", "\n", "|n", "\r", "|r", "[", "|[", "]", "|]").Replace(s)
+}
+
+// githubEscape GitHub Actions workflow command的转义，属性值还需转义:及,
+func githubEscape(s string, property bool) string {
+	s = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+	if property {
+		s = strings.NewReplacer(":", "%3A", ",", "%2C").Replace(s)
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package log
+
+import (
+	"strings"
+
+	"github.com/nulastudio/NetBeauty/src/i18n"
+)
+
+// HintError 附带尝试的URL/路径、可能原因及解决办法的错误
+type HintError struct {
+	Err    error
+	Target string
+	Cause  string
+	Hint   string
+}
+
+// NewHintError 包装一个常见错误，target、cause、hint均可为空
+func NewHintError(err error, target string, cause string, hint string) *HintError {
+	return &HintError{Err: err, Target: target, Cause: cause, Hint: hint}
+}
+
+func (e *HintError) Error() string {
+	lines := []string{e.Err.Error()}
+	if e.Target != "" {
+		lines = append(lines, "  "+i18n.T("hint.attempted")+": "+e.Target)
+	}
+	if e.Cause != "" {
+		lines = append(lines, "  "+i18n.T("hint.cause")+": "+e.Cause)
+	}
+	if e.Hint != "" {
+		lines = append(lines, "  "+i18n.T("hint.fix")+": "+e.Hint)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e *HintError) fields() Fields {
+	fields := Fields{}
+	if e.Target != "" {
+		fields["target"] = e.Target
+	}
+	if e.Cause != "" {
+		fields["cause"] = e.Cause
+	}
+	if e.Hint != "" {
+		fields["hint"] = e.Hint
+	}
+	return fields
+}
@@ -1,60 +1,315 @@
-package log
-
-import (
-	"fmt"
-	"os"
-)
-
-type LogLevel int
-
-const (
-	Error LogLevel = iota
-	Detail
-	Info
-)
-
-type Logger struct {
-	LogLevel LogLevel
-}
-
-var DefaultLogger = &Logger{Info}
-
-func (logger *Logger) Log(message string, level LogLevel) {
-	if logger.LogLevel >= level {
-		if logger.LogLevel == Error {
-			message = "Error: " + message
-		}
-		fmt.Println(message)
-	}
-}
-
-func (logger *Logger) PanicLog(message string, level LogLevel, code int) {
-	logger.Log(message, level)
-	os.Exit(code)
-}
-
-func LogError(err error, panic bool) {
-	code := 0
-	if panic {
-		code = 1
-	}
-	LogPanic(err, code)
-}
-
-func LogPanic(err error, code int) {
-	if err != nil {
-		if code != 0 {
-			DefaultLogger.PanicLog(err.Error(), Error, code)
-		} else {
-			DefaultLogger.Log(err.Error(), Error)
-		}
-	}
-}
-
-func LogInfo(message string) {
-	DefaultLogger.Log(message, Info)
-}
-
-func LogDetail(message string) {
-	DefaultLogger.Log(message, Detail)
-}
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+type LogLevel int
+
+const (
+	Error LogLevel = iota
+	Warning
+	Detail
+	Info
+)
+
+type LogFormat int
+
+const (
+	TextFormat LogFormat = iota
+	JSONFormat
+)
+
+// Fields 日志附带的结构化字段，只在JSON格式下输出
+type Fields map[string]interface{}
+
+type Logger struct {
+	LogLevel LogLevel
+	Format   LogFormat
+	// 控制台按级别着色
+	Color bool
+	// 文本格式下在每行前输出时间
+	Timestamp bool
+	// 运行ID，附带在每行日志中
+	RunID string
+	// 按模块（包名）设置控制台的LogLevel
+	ModuleLevels map[string]LogLevel
+	// 同时输出到文件，文件可使用与控制台不同的LogLevel
+	File      io.Writer
+	FileLevel LogLevel
+	// 控制台的Error及Warning按CI的注释格式输出
+	Annotation Annotation
+	// 同时输出到系统日志（syslog/Windows事件日志）
+	System      SystemLog
+	SystemLevel LogLevel
+}
+
+// Handler 日志的输出方式，以库方式使用时可替换为自己的日志系统
+type Handler interface {
+	LogFields(message string, level LogLevel, fields Fields)
+}
+
+// HandlerFunc 将函数作为Handler
+type HandlerFunc func(message string, level LogLevel, fields Fields)
+
+func (f HandlerFunc) LogFields(message string, level LogLevel, fields Fields) {
+	f(message, level, fields)
+}
+
+// SystemLog 系统日志，只输出消息本身，不带前缀、颜色及时间
+type SystemLog interface {
+	Err(message string) error
+	Warning(message string) error
+	Info(message string) error
+}
+
+type jsonLog struct {
+	Level     string `json:"level"`
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"message"`
+	RunID     string `json:"runId,omitempty"`
+	Fields    Fields `json:"fields,omitempty"`
+}
+
+var levelNames = map[LogLevel]string{
+	Error:   "Error",
+	Warning: "Warning",
+	Detail:  "Detail",
+	Info:    "Info",
+}
+
+var levelColors = map[LogLevel]string{
+	Error:   "\x1b[31m",
+	Warning: "\x1b[33m",
+	Detail:  "\x1b[36m",
+	Info:    "\x1b[90m",
+}
+
+const colorReset = "\x1b[0m"
+
+// Exit LogPanic退出进程的方式，以库方式使用时替换为panic
+var Exit = os.Exit
+
+// lastError 最近一条Error日志
+var lastError = ""
+
+// LastError 返回最近一条Error日志
+func LastError() string {
+	return lastError
+}
+
+// counts 各级别日志的数量（无论是否输出）
+var counts = make(map[LogLevel]int)
+
+// Count 返回某级别日志的数量
+func Count(level LogLevel) int {
+	return counts[level]
+}
+
+// recent 最近的日志（无论是否输出），用于崩溃报告
+var recent = make([]string, 0, recentSize)
+
+const recentSize = 50
+
+// Recent 返回最近的日志
+func Recent() []string {
+	return append([]string{}, recent...)
+}
+
+// DefaultLogger 命令行使用的控制台日志
+var DefaultLogger = &Logger{LogLevel: Info, Format: TextFormat}
+
+var handler Handler = DefaultLogger
+
+// SetHandler 替换日志的输出方式，返回原来的Handler，为nil时恢复DefaultLogger
+func SetHandler(h Handler) Handler {
+	if h == nil {
+		h = DefaultLogger
+	}
+	old := handler
+	handler = h
+	return old
+}
+
+// logFields 统计日志后交给当前的Handler输出
+func logFields(message string, level LogLevel, fields Fields) {
+	counts[level]++
+	if level == Error {
+		lastError = message
+	}
+	if len(recent) == recentSize {
+		recent = recent[1:]
+	}
+	recent = append(recent, fmt.Sprintf("[%s] %s", levelNames[level], message))
+	handler.LogFields(message, level, fields)
+}
+
+func (logger *Logger) Log(message string, level LogLevel) {
+	logger.LogFields(message, level, nil)
+}
+
+func (logger *Logger) LogFields(message string, level LogLevel, fields Fields) {
+	if consoleLevel := logger.consoleLevel(); consoleLevel >= level {
+		line, annotated := logger.annotate(message, level, fields)
+		if !annotated {
+			line = logger.format(message, level, fields)
+			if logger.Color && logger.Format == TextFormat {
+				line = levelColors[level] + line + colorReset
+			}
+		}
+		fmt.Println(line)
+	}
+	if logger.File != nil && logger.FileLevel >= level {
+		fmt.Fprintln(logger.File, logger.format(message, level, fields))
+	}
+	if logger.System != nil && logger.SystemLevel >= level {
+		switch level {
+		case Error:
+			logger.System.Err(message)
+		case Warning:
+			logger.System.Warning(message)
+		default:
+			logger.System.Info(message)
+		}
+	}
+}
+
+func (logger *Logger) consoleLevel() LogLevel {
+	if len(logger.ModuleLevels) != 0 {
+		if level, ok := logger.ModuleLevels[callerModule()]; ok {
+			return level
+		}
+	}
+	return logger.LogLevel
+}
+
+// callerModule 返回调用日志的包名
+func callerModule() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if module := packageName(frame.Function); module != "log" {
+			return module
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// packageName github.com/nulastudio/NetBeauty/src/manager.(*T).Method => manager
+func packageName(function string) string {
+	if index := strings.LastIndex(function, "/"); index != -1 {
+		function = function[index+1:]
+	}
+	if index := strings.Index(function, "."); index != -1 {
+		function = function[:index]
+	}
+	return function
+}
+
+func (logger *Logger) format(message string, level LogLevel, fields Fields) string {
+	if logger.Format == JSONFormat {
+		return logger.formatJSON(message, level, fields)
+	}
+	if level == Error {
+		message = "Error: " + message
+	} else if level == Warning {
+		message = "Warning: " + message
+	}
+	if logger.RunID != "" {
+		message = "[" + logger.RunID + "] " + message
+	}
+	if logger.Timestamp {
+		message = time.Now().Format("[15:04:05.000] ") + message
+	}
+	return message
+}
+
+func (logger *Logger) formatJSON(message string, level LogLevel, fields Fields) string {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(jsonLog{
+		Level:     levelNames[level],
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Message:   message,
+		RunID:     logger.RunID,
+		Fields:    fields,
+	})
+	if err != nil {
+		return message
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func LogError(err error, panic bool) {
+	code := 0
+	if panic {
+		code = 1
+	}
+	LogPanic(err, code)
+}
+
+func LogPanic(err error, code int) {
+	if err == nil {
+		return
+	}
+	// 提示信息放入fields，message只保留错误本身，文本格式的Logger则输出完整的提示
+	if hint, ok := err.(*HintError); ok && !isTextLogger(handler) {
+		logFields(hint.Err.Error(), Error, hint.fields())
+	} else if ok {
+		logFields(err.Error(), Error, hint.fields())
+	} else {
+		logFields(err.Error(), Error, nil)
+	}
+	if code != 0 {
+		Exit(code)
+	}
+}
+
+// LogErrorFields 输出一条附带字段（如file）的错误，不退出
+func LogErrorFields(err error, fields Fields) {
+	if err == nil {
+		return
+	}
+	logFields(err.Error(), Error, fields)
+}
+
+func isTextLogger(h Handler) bool {
+	logger, ok := h.(*Logger)
+	return ok && logger.Format == TextFormat
+}
+
+func LogWarning(message string) {
+	logFields(message, Warning, nil)
+}
+
+func LogWarningFields(message string, fields Fields) {
+	logFields(message, Warning, fields)
+}
+
+func LogInfo(message string) {
+	logFields(message, Info, nil)
+}
+
+func LogDetail(message string) {
+	logFields(message, Detail, nil)
+}
+
+func LogInfoFields(message string, fields Fields) {
+	logFields(message, Info, fields)
+}
+
+func LogDetailFields(message string, fields Fields) {
+	logFields(message, Detail, fields)
+}
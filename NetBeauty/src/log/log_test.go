@@ -0,0 +1,17 @@
+package log
+
+import "testing"
+
+func TestFormatPrefixesByLevel(t *testing.T) {
+	logger := &Logger{}
+	for level, expected := range map[LogLevel]string{
+		Error:   "Error: moved",
+		Warning: "Warning: moved",
+		Detail:  "moved",
+		Info:    "moved",
+	} {
+		if actual := logger.format("moved", level, nil); actual != expected {
+			t.Errorf("format(%s) = %q, want %q", levelNames[level], actual, expected)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RotatingFile 按大小/时间滚动的日志文件，file.1为最近一次滚动的日志
+type RotatingFile struct {
+	Path string
+	// 单个文件的最大字节数，0为不限制
+	MaxSize int64
+	// 单个文件的最长使用时间，0为不限制
+	MaxAge time.Duration
+	// 保留的历史文件数
+	Backups int
+
+	mutex   sync.Mutex
+	file    *os.File
+	size    int64
+	created time.Time
+}
+
+// OpenRotatingFile 以追加方式打开日志文件，超出限制时滚动
+func OpenRotatingFile(path string, maxSize int64, maxAge time.Duration, backups int) (*RotatingFile, error) {
+	rf := &RotatingFile{Path: path, MaxSize: maxSize, MaxAge: maxAge, Backups: backups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = fi.Size()
+	// 无法获取创建时间，以最后修改时间近似
+	rf.created = fi.ModTime()
+	if rf.size == 0 {
+		rf.created = time.Now()
+	}
+	return nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+
+	if rf.needRotate(int64(len(p))) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) needRotate(size int64) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.MaxSize > 0 && rf.size+size > rf.MaxSize {
+		return true
+	}
+	return rf.MaxAge > 0 && time.Since(rf.created) > rf.MaxAge
+}
+
+// rotate file.N-1 => file.N ... file => file.1，超出Backups的删除
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+
+	os.Remove(backupName(rf.Path, rf.Backups))
+	for i := rf.Backups - 1; i >= 1; i-- {
+		os.Rename(backupName(rf.Path, i), backupName(rf.Path, i+1))
+	}
+	if rf.Backups > 0 {
+		if err := os.Rename(rf.Path, backupName(rf.Path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.Path); err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+func backupName(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
+
+// Close 关闭日志文件
+func (rf *RotatingFile) Close() error {
+	rf.mutex.Lock()
+	defer rf.mutex.Unlock()
+	return rf.file.Close()
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"fmt"
+	"time"
+)
+
+// StageDuration 某阶段的累计耗时
+type StageDuration struct {
+	Name     string
+	Duration time.Duration
+}
+
+var stages = make([]*StageDuration, 0)
+
+// StartStage 开始计时，调用返回的函数结束计时并累计到该阶段（同一阶段可多次计时）
+func StartStage(name string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		for _, stage := range stages {
+			if stage.Name == name {
+				stage.Duration += elapsed
+				return
+			}
+		}
+		stages = append(stages, &StageDuration{name, elapsed})
+	}
+}
+
+// StageDurations 按开始顺序返回各阶段耗时
+func StageDurations() []StageDuration {
+	list := make([]StageDuration, 0, len(stages))
+	for _, stage := range stages {
+		list = append(list, *stage)
+	}
+	return list
+}
+
+// LogStageDurations 输出各阶段耗时
+func LogStageDurations() {
+	fields := Fields{}
+	message := "stage durations:"
+	for _, stage := range StageDurations() {
+		duration := stage.Duration.Round(time.Millisecond)
+		message += fmt.Sprintf(" %s %s,", stage.Name, duration)
+		fields[stage.Name] = duration.Seconds()
+	}
+	if len(fields) == 0 {
+		return
+	}
+	LogDetailFields(message[:len(message)-1], fields)
+}
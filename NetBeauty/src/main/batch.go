@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	i18n "github.com/nulastudio/NetBeauty/src/i18n"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+var readStdin = false
+
+// runBatch 逐行读取需要beauty的目录并依次beauty，同一进程内共享补丁等缓存，最后输出汇总
+// 空行及#开头的行被忽略，任一目录失败时以1退出，被取消时停止处理剩余目录
+func runBatch(r io.Reader) {
+	started := time.Now()
+	total := beauty.Result{}
+	succeeded := 0
+	failed := make([]string, 0)
+	code := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.Trim(strings.TrimSpace(scanner.Text()), `"`)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dir, err := filepath.Abs(line)
+		if err != nil {
+			log.LogError(fmt.Errorf("%s: %s", line, err.Error()), false)
+			failed = append(failed, line)
+			continue
+		}
+
+		options.Dir = dir
+		log.LogInfo(fmt.Sprintf("running nbeauty on %s...", dir))
+		result, runCode, err := runBeauty()
+		finishRun(result, err, runCode)
+
+		total.Dirs += result.Dirs
+		total.MovedFiles += result.MovedFiles
+		total.MovedBytes += result.MovedBytes
+		total.SkippedFiles += result.SkippedFiles
+		total.LeftFiles += result.LeftFiles
+		total.FailedFiles += result.FailedFiles
+		total.Conflicts += result.Conflicts
+		total.RewrittenFiles += result.RewrittenFiles
+
+		if runCode == 0 {
+			succeeded++
+			continue
+		}
+		failed = append(failed, dir)
+		if runCode == 130 {
+			code = 130
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.LogError(fmt.Errorf("read stdin failed: %s", err.Error()), false)
+		code = 1
+	}
+
+	lines := []string{
+		i18n.T("batch.title"),
+		i18n.T("batch.dirs", succeeded+len(failed), succeeded, len(failed)),
+		i18n.T("summary.relocated", total.MovedFiles, fmt.Sprintf("%d bytes", total.MovedBytes)),
+		i18n.T("summary.skipped", total.SkippedFiles, total.FailedFiles),
+		i18n.T("summary.conflicts", total.Conflicts),
+		i18n.T("summary.rewritten", total.RewrittenFiles),
+		i18n.T("summary.problems", log.Count(log.Error), log.Count(log.Warning)),
+	}
+	for _, dir := range failed {
+		lines = append(lines, i18n.T("batch.failed", dir))
+	}
+	lines = append(lines, i18n.T("summary.elapsed", time.Since(started).Round(time.Millisecond)), i18n.T("summary.end"))
+	for _, line := range lines {
+		log.LogDetail(line)
+	}
+
+	if code != 0 {
+		os.Exit(code)
+	}
+	if len(failed) != 0 {
+		os.Exit(1)
+	}
+	if profileFile != "" {
+		writeProfile(profileFile, time.Since(started))
+	}
+	if strict && total.FailedFiles != 0 {
+		log.LogPanic(fmt.Errorf("%d file(s) failed to be moved (--strict)", total.FailedFiles), 1)
+	}
+	if strict && total.LeftFiles != 0 {
+		log.LogPanic(fmt.Errorf("%d file(s) left in place by a symlink, the placement or a conflict (--strict)", total.LeftFiles), 1)
+	}
+	if strict && total.Conflicts != 0 {
+		log.LogPanic(fmt.Errorf("%d dependency version conflict(s) in libsDir (--strict)", total.Conflicts), 1)
+	}
+	if warnings := log.Count(log.Warning); warningsAsErrors && warnings != 0 {
+		log.LogPanic(fmt.Errorf("%d warning(s) treated as errors", warnings), 1)
+	}
+}
@@ -1,16 +1,18 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
-	"path"
-	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	event "github.com/nulastudio/NetBeauty/src/event"
+	i18n "github.com/nulastudio/NetBeauty/src/i18n"
 	log "github.com/nulastudio/NetBeauty/src/log"
 	manager "github.com/nulastudio/NetBeauty/src/manager"
 	misc "github.com/nulastudio/NetBeauty/src/misc"
@@ -18,259 +20,124 @@ import (
 )
 
 const (
-	errorLevel  string = "Error"  // log errors only
-	detailLevel string = "Detail" // log useful infos
-	infoLevel   string = "Info"   // log everything
+	errorLevel   string = "Error"   // log errors only
+	warningLevel string = "Warning" // log errors and warnings
+	detailLevel  string = "Detail"  // log useful infos
+	infoLevel    string = "Info"    // log everything
 )
 
-type depsFileDetail struct {
-	deps       string
-	main       string
-	fxrVersion string
-	rid        string
-}
+const (
+	textFormat string = "text"
+	jsonFormat string = "json" // one json object per line
+)
 
-var startupHook = "nbloader"
+// outputs --output对应的注释格式
+var outputs = map[string]log.Annotation{
+	textFormat: log.NoAnnotation,
+	"github":   log.GitHubAnnotation,
+	"azdo":     log.AzureAnnotation,
+	"teamcity": log.TeamCityAnnotation,
+}
 
 var workingDir, _ = os.Getwd()
 
 var loglevel string
-var beautyDir string
-var libsDir = "libraries"
-var excludes = ""
-var hiddens = ""
-var sharedRuntimeMode = false
-var enableDebug = false
+var logFormat = textFormat
+var output = textFormat
+var logFile = ""
+var noColor = false
+var logTime = false
+var warningsAsErrors = false
+var failOnDeprecated = false
+var strict = false
+var checkIdempotent = false
+var eventFile = ""
+var runID = ""
+var profileFile = ""
+var pprofFile = ""
+var lang = ""
+var debugHTTP = false
+var logFileLevel = infoLevel
+var sysLog = false
+var sysLogLevel = detailLevel
+var logFileMaxSize = 0
+var logFileMaxAge time.Duration
+var logFileBackups = 5
+var timeoutDuration time.Duration
+var preHook = ""
+var pluginDir = ""
+var grpcAddr = ""
+var rpcAddr = ""
+var httpAddr = ""
+var packTools = ""
+var packShim = ""
+var packOut = ""
+var scriptShell = defaultScriptShell()
+var postHook = ""
+var options = beauty.DefaultOptions()
 var usePatch = false
-var isNetFx = false
+var knownHashKey = ""
+var networkPolicy = manager.DefaultNetworkPolicy()
+var buildFXR = ""
+var buildRID = ""
+var runtimeSrc = ""
+var patchFile = ""
 
 var gitcdn string
 var gittree string = ""
 
 func main() {
-	misc.Umask()
+	defer handleCrash()
+
+	util.ProcessUmask = misc.Umask()
+	util.ResetModes()
 
 	manager.EnsureLocalPath()
 
 	initCLI()
 
-	// 设置CDN
-	manager.GitCDN = gitcdn
-	if gittree != "" {
-		manager.GitTree = gittree
-	}
-
-	log.LogInfo("running nbeauty...")
-
-	subDirs := make([]string, 0)
-	srmMapping := make(map[string]string, 0)
-
-	fxrVersion, rid := "", ""
-
-	useWPF := false
+	setupManager()
 
-	exeConfig := manager.FindExeConfig(beautyDir)
-
-	if len(exeConfig) != 0 {
-		isNetFx = true
+	if readStdin {
+		runBatch(os.Stdin)
+		return
 	}
 
-	// fix deps.json
-	if !isNetFx {
-		checkedDependencies := []depsFileDetail{}
-		dependencies := manager.FindDepsJSON(beautyDir)
-		if len(dependencies) != 0 {
-			for _, deps := range dependencies {
-				isHidden, hidErr := misc.IsHiddenFile(deps)
-
-				if isHidden && hidErr == nil {
-					misc.ShowFile(deps)
-				}
-
-				deps = strings.ReplaceAll(deps, "\\", "/")
-				mainProgram := strings.Replace(filepath.Base(deps), ".deps.json", "", -1)
-
-				cfxrVersion, crid := manager.FindFXRVersion(deps)
-
-				if fxrVersion == "" || rid == "" {
-					fxrVersion, rid = cfxrVersion, crid
-				} else if cfxrVersion == fxrVersion || crid == rid {
-					log.LogError(fmt.Errorf("Multiple SCD Versions Detected:\n[%s/%s]\n[%s/%s]", fxrVersion, rid, cfxrVersion, crid), true)
-				}
-
-				checkedDependencies = append(checkedDependencies, depsFileDetail{
-					deps:       deps,
-					main:       mainProgram,
-					fxrVersion: cfxrVersion,
-					rid:        crid,
-				})
-
-				if isHidden && hidErr == nil {
-					misc.HideFile(deps)
-				}
-			}
-
-			// check if pre-build artifact exists
-			if fxrVersion != "" && rid != "" {
-				// 必须检查
-				manager.CheckRunConfigJSON()
-
-				onlineVersion := manager.GetOnlineArtifactsVersion(fxrVersion, rid)
-				if usePatch && onlineVersion == "" {
-					log.LogError(fmt.Errorf("Artifact does not exist. %s/%s\nYou can report the missing artifact in here: https://github.com/nulastudio/NetBeauty2/discussions/36", fxrVersion, rid), true)
-				}
-			}
-
-			for _, deps := range checkedDependencies {
-				isHidden, hidErr := misc.IsHiddenFile(deps.deps)
-
-				if isHidden && hidErr == nil {
-					misc.ShowFile(deps.deps)
-				}
-
-				log.LogDetail(fmt.Sprintf("fixing %s", deps.deps))
-
-				SCDMode := deps.fxrVersion != "" && deps.rid != ""
-
-				if SCDMode {
-					log.LogDetail("SCD Mode: Yes")
-					log.LogDetail(fmt.Sprintf("SCD Version: %s, %s", deps.fxrVersion, deps.rid))
-
-					if usePatch {
-						log.LogDetail("Use Patch: Yes")
-					} else {
-						log.LogDetail("Use Patch: No")
-					}
-				} else {
-					log.LogDetail("SCD Mode: No")
-					log.LogDetail("Use Patch: No")
-				}
-
-				success := manager.AddStartUpHookToDeps(deps.deps, startupHook)
-
-				usePatch = SCDMode && usePatch
-
-				allDeps, _useWPF, _ := manager.FixDeps(deps.deps, deps.main, enableDebug, usePatch, sharedRuntimeMode)
-
-				useWPF = _useWPF
-
-				if sharedRuntimeMode {
-					log.LogDetail("Shared Runtime Mode: Yes")
-					log.LogDetail("moving deps may take some time")
-				} else {
-					log.LogDetail("Shared Runtime Mode: No")
-				}
-
-				_, _, curSubDirs, _srmMapping := moveDeps(allDeps, deps.main, sharedRuntimeMode)
-
-				srmMapping = _srmMapping
-				subDirs = append(subDirs, curSubDirs...)
-
-				if success {
-					log.LogDetail(fmt.Sprintf("%s fixed", deps.deps))
-				}
-
-				if isHidden && hidErr == nil {
-					misc.HideFile(deps.deps)
-				}
-			}
-
-			// patch
-			if usePatch && fxrVersion != "" && rid != "" {
-				patch(fxrVersion, rid)
-			}
-		} else {
-			log.LogDetail(fmt.Sprintf("no deps.json found in %s", beautyDir))
-			log.LogDetail("skipping")
-			os.Exit(0)
-		}
-	} else {
-		for _, appConfig := range exeConfig {
-			isHidden, hidErr := misc.IsHiddenFile(appConfig)
-
-			if isHidden && hidErr == nil {
-				misc.ShowFile(appConfig)
-			}
-
-			appConfig = strings.ReplaceAll(appConfig, "\\", "/")
-			mainProgram := strings.Replace(filepath.Base(appConfig), ".exe.config", "", -1)
-
-			log.LogDetail(fmt.Sprintf("fixing %s", appConfig))
-
-			log.LogDetail(".Net Fx: Yes")
-
-			allDeps, success := manager.FixExeConfig(appConfig, libsDir)
-
-			moveDeps(allDeps, mainProgram, false)
-
-			if success {
-				log.LogDetail(fmt.Sprintf("%s fixed", appConfig))
-			}
+	log.LogInfo("running nbeauty...")
 
-			if isHidden && hidErr == nil {
-				misc.HideFile(appConfig)
-			}
+	if checkIdempotent {
+		if err := assertIdempotent(options); err != nil {
+			log.LogPanic(err, 1)
 		}
+		log.LogInfo("the second run produced the same layout")
+		return
 	}
 
-	uniqieSubDirs := []string{}
-	if !isNetFx {
-		tmp := map[string]byte{}
-		for _, e := range subDirs {
-			l := len(tmp)
-			tmp[e] = 0
-			if len(tmp) != l {
-				uniqieSubDirs = append(uniqieSubDirs, e)
-			}
-		}
+	result, code, err := runBeauty()
+	if code != 0 {
+		finishRun(result, err, code)
+		os.Exit(code)
 	}
 
-	// fix runtimeconfig.json
-	if !isNetFx {
-		runtimeConfigs := manager.FindRuntimeConfigJSON(beautyDir)
-		if len(runtimeConfigs) != 0 {
-			for _, runtimeConfig := range runtimeConfigs {
-				isHidden, hidErr := misc.IsHiddenFile(runtimeConfig)
-
-				if isHidden && hidErr == nil {
-					misc.ShowFile(runtimeConfig)
-				}
-
-				log.LogDetail(fmt.Sprintf("fixing %s", runtimeConfig))
-
-				success := manager.AddStartUpHookToRuntimeConfig(runtimeConfig, startupHook) && manager.FixRuntimeConfig(runtimeConfig, libsDir, uniqieSubDirs, srmMapping, sharedRuntimeMode, usePatch, useWPF)
-
-				if success {
-					log.LogDetail(fmt.Sprintf("%s fixed", runtimeConfig))
-				}
-
-				if isHidden && hidErr == nil {
-					misc.HideFile(runtimeConfig)
-				}
-			}
-		} else {
-			log.LogDetail(fmt.Sprintf("no runtimeconfig.json found in %s", beautyDir))
-			log.LogDetail("skipping")
-			os.Exit(0)
-		}
+	if profileFile != "" {
+		writeProfile(profileFile, result.Elapsed)
 	}
 
-	// release nbloader
-	if !isNetFx {
-		var loaderDir = beautyDir
-		if usePatch {
-			loaderDir = filepath.Join(beautyDir, libsDir)
-		}
-		log.LogDetail("releasing nbloader.dll")
-		if releasePath, err := releaseNBLoader(loaderDir); err != nil {
-			log.LogError(fmt.Errorf("release nbloader.dll failed: %s : %s", releasePath, err.Error()), true)
-		}
+	var strictErr error
+	if strict && result.FailedFiles != 0 {
+		strictErr = fmt.Errorf("%d file(s) failed to be moved (--strict)", result.FailedFiles)
+	} else if strict && result.LeftFiles != 0 {
+		strictErr = fmt.Errorf("%d file(s) left in place by a symlink, the placement or a conflict (--strict)", result.LeftFiles)
+	} else if strict && result.Conflicts != 0 {
+		strictErr = fmt.Errorf("%d dependency version conflict(s) in libsDir (--strict)", result.Conflicts)
+	} else if warnings := log.Count(log.Warning); warningsAsErrors && warnings != 0 {
+		strictErr = fmt.Errorf("%d warning(s) treated as errors", warnings)
 	}
-
-	// hide files
-	hideFiles()
-
-	log.LogDetail("nbeauty done. Enjoy it!")
+	if strictErr != nil {
+		finishRun(result, strictErr, 1)
+		log.LogPanic(strictErr, 1)
+	}
+	finishRun(result, nil, 0)
 }
 
 func initCLI() {
@@ -284,18 +151,122 @@ RECOMMEND https://gitee.com/liesauer/HostFXRPatcher for mainland china users.
 default is master, means that you always use the latest artifacts.
 NOTE: please provide as longer commit hash as you can, otherwise it may can not be determined as a valid unique commit hash.
 `)
-	flag.StringVar(&loglevel, "loglevel", "Error", `log level. valid values: Error/Detail/Info
+	flag.StringVar(&logFormat, "logformat", textFormat, `log format. valid values: text/json
+json: one json object per line with level, timestamp, message and fields.
+`)
+	flag.StringVar(&output, "output", textFormat, `console output for CI systems. valid values: text/github/azdo/teamcity
+github: errors and warnings are printed as GitHub Actions annotations (::error file=...::), shown on the checks page.
+azdo: errors and warnings are printed as Azure DevOps logging commands (##vso[task.logissue]), stages as sections.
+teamcity: errors are reported as build problems, warnings and stages as TeamCity service messages.
+`)
+	flag.StringVar(&lang, "lang", "", `language of usage, errors and summaries, detected from LC_ALL/LC_MESSAGES/LANG if omitted. valid values: en-US/zh-CN`)
+	flag.BoolVar(&noColor, "nocolor", false, `disable colored console output, same as setting the NO_COLOR environment variable`)
+	flag.StringVar(&runID, "runid", "", `correlation id included in json logs, events and the beauty marker, generated if omitted. when given it also prefixes text log lines`)
+	flag.StringVar(&eventFile, "eventfile", "", `write progress events (NDJSON) to this file, "-" for stderr`)
+	flag.BoolVar(&strict, "strict", false, `exit with code 1 if any deps file failed to be moved, was left in place (reached through a symlink out of the publish dir, not moved by the placement or conflicting with a file it cannot be placed beside) or conflicted with a different version in libsDir, files skipped by <excludes> are not counted`)
+	flag.StringVar(&profileFile, "profile", "", `write a json timing breakdown (stages, large files, downloads) to this file at the end of the run`)
+	flag.StringVar(&pprofFile, "pprof", "", `also write a Go pprof CPU profile to this file, requires --profile`)
+	flag.BoolVar(&failOnDeprecated, "failondeprecated", false, `treat deprecated flags and values as errors`)
+	flag.BoolVar(&warningsAsErrors, "warningsaserrors", false, `exit with code 1 if any warning is logged`)
+	flag.BoolVar(&debugHTTP, "debughttp", false, `log every outbound http request (url, status, bytes, duration, attempt) at Detail level of the manager module, credentials are redacted`)
+	flag.BoolVar(&logTime, "logtime", false, `prefix every log line with the current time`)
+	flag.StringVar(&logFile, "logfile", "", `also write the log to this file`)
+	flag.IntVar(&logFileMaxSize, "logfilemaxsize", 0, `rotate --logfile when it grows over this size in MB, the log is appended instead of truncated when rotation is enabled`)
+	flag.DurationVar(&logFileMaxAge, "logfilemaxage", 0, `rotate --logfile when it is older than this duration, e.g. 24h`)
+	flag.IntVar(&logFileBackups, "logfilebackups", 5, `how many rotated log files (<logfile>.1, <logfile>.2, ...) to keep`)
+	flag.BoolVar(&sysLog, "syslog", false, `also write the log to syslog (Linux/macOS) or the Windows Event Log (source "nbeauty")`)
+	flag.StringVar(&sysLogLevel, "sysloglevel", detailLevel, `log level of --syslog. valid values: Error/Warning/Detail/Info`)
+	flag.StringVar(&logFileLevel, "logfilelevel", infoLevel, `log level of --logfile, can be more verbose than --loglevel. valid values: Error/Warning/Detail/Info`)
+	flag.StringVar(&loglevel, "loglevel", "Error", `log level. valid values: Error/Warning/Detail/Info
 Error: Log errors only.
+Warning: Log errors and warnings.
 Detail: Log useful infos.
 Info: Log everything.
+can also be set per module(main/manager/util), e.g. Detail,manager=Info,util=Error
+`)
+	flag.BoolVar(&options.SharedRuntimeMode, "srmode", false, `[.NET Core App Only] share the runtime between apps`)
+	flag.BoolVar(&options.EnableDebug, "enabledebug", false, `[.NET Core App Only] allow 3rd debuggers(like dnSpy) debugs the app`)
+	flag.BoolVar(&usePatch, "usepatch", false, `[.NET Core App Only] DEPRECATED, use --strategy=patch instead`)
+	flag.StringVar(&options.Strategy, "strategy", "", `[.NET Core App Only] how the app finds the relocated files. valid values: hook/probing/patch/apphost/none
+hook: use the nbloader startup hook, default. probing is a deprecated alias.
+patch: use the patched hostfxr to reduce files, same as --usepatch.
+apphost: point the apphost to the app inside libsDir, no Microsoft binary is replaced.
+none: relocate like hook but keep hostfxr/hostpolicy/coreclr in place and never fail, nothing is relocated if the app cannot load the startup hook.
+multiple strategies separated with "," are tried in order, the last one is used if none of the others is available. Example: patch,probing,none
+`)
+	flag.StringVar(&options.Provider, "provider", "", `[.NET Core App Only] where to get the patched hostfxr from, the name of a provider plugin. default is --gitcdn`)
+	flag.StringVar(&pluginDir, "plugindir", defaultPluginDir(), `load artifact provider and strategy plugins (`+beauty.PluginPrefix+`* executables) from this directory`)
+	flag.StringVar(&options.Hiddens, "hiddens", "", `dlls that end users never needed, so hide them`)
+	flag.StringVar(&preHook, "prehook", "", `shell command to run before beauty, a non-zero exit aborts the beauty. NBEAUTY_DIR, NBEAUTY_LIBSDIR, NBEAUTY_STRATEGY, NBEAUTY_ARCHIVE and NBEAUTY_RUNID describe the run`)
+	flag.StringVar(&postHook, "posthook", "", `shell command to run after beauty, even if it failed. additionally gets NBEAUTY_RESULT (success/failure), NBEAUTY_EXITCODE, NBEAUTY_MODIFIED, NBEAUTY_FXRVERSION, NBEAUTY_RID, NBEAUTY_PATCH, NBEAUTY_MOVEDFILES and NBEAUTY_FAILEDFILES`)
+	flag.IntVar(&resultFD, "resultfd", -1, `write the json result (the same as --notifyurl posts) as one line to this inherited file descriptor (a handle on Windows), logs stay on stdout/stderr. one line per directory with --stdin`)
+	flag.StringVar(&resultFile, "resultfile", "", `write the json result like --resultfd into this file`)
+	flag.StringVar(&notifyURL, "notifyurl", "", `POST the json result (status, exit code, counts, warnings, marker location) to this webhook when the run finishes, following the network policy (--connect-timeout, --read-timeout, --network-deadline). cannot be used with --no-network`)
+	flag.StringVar(&scriptShell, "shell", scriptShell, `init script: generate a bash or pwsh script. valid values: bash/pwsh`)
+	flag.StringVar(&packTools, "packtools", "", `pack: directory with the nbeauty binaries of each RID (<rid>/nbeauty2[.exe]), the output of make`)
+	flag.StringVar(&packShim, "packshim", "", `pack: build output of NetBeautyGlobalTool, the launcher that selects the binary at runtime`)
+	flag.IntVar(&benchRuns, "benchruns", benchRuns, `bench: how many warm launches to measure before and after beauty, in addition to the first (cold) launch`)
+	flag.StringVar(&benchArgs, "benchargs", "", `bench: arguments to launch the app with, the app must exit by itself, e.g. "--version"`)
+	flag.StringVar(&packOut, "packout", "", `pack: where to write the dotnet tool nupkg, default is the current directory`)
+	flag.StringVar(&grpcAddr, "grpc", "127.0.0.1:7070", `address the daemon serves gRPC on (service netbeauty.NetBeauty in src/server/netbeauty.proto, Beautify streams the progress). empty to disable`)
+	flag.StringVar(&rpcAddr, "rpc", "", `address the daemon serves JSON-RPC on, methods: NetBeauty.Beautify, NetBeauty.Progress, NetBeauty.Revert, NetBeauty.RestoreFXR, NetBeauty.Verify, NetBeauty.CacheStatus`)
+	flag.StringVar(&httpAddr, "http", "", `address the daemon serves the HTTP/JSON api on: POST /beautify, GET /status/{id}, GET /cache`)
+	flag.DurationVar(&timeoutDuration, "timeout", 0, `abort the beauty when it takes longer than this duration, e.g. 5m. the changes made so far can be undone with "nbeauty recover <beautyDir>"`)
+	flag.BoolVar(&checkIdempotent, "assert-idempotent", false, `[testing] beauty a copy of <beautyDir> twice, the second time with --force, and fail if the two layouts (files, hashes and json files) differ. <beautyDir> itself is not changed`)
+	flag.BoolVar(&options.Force, "force", false, `beauty again even if the directory has already been beautified`)
+	flag.StringVar(&options.Repair, "repair", "", `what to do when a previous run on the directory was interrupted, by default the run fails. valid values: resume/rollback
+resume: undo the interrupted run and beauty again.
+rollback: only undo the interrupted run, the same as "nbeauty recover <beautyDir>".
 `)
-	flag.BoolVar(&sharedRuntimeMode, "srmode", false, `[.NET Core App Only] share the runtime between apps`)
-	flag.BoolVar(&enableDebug, "enabledebug", false, `[.NET Core App Only] allow 3rd debuggers(like dnSpy) debugs the app`)
-	flag.BoolVar(&usePatch, "usepatch", false, `[.NET Core App Only] use the patched hostfxr to reduce files`)
-	flag.StringVar(&hiddens, "hiddens", "", `dlls that end users never needed, so hide them`)
+	flag.StringVar(&options.IOProfile, "io-profile", "local", `how files are moved: local, network. network creates all directories up front, renames whole directories when they move as a whole and moves the remaining files concurrently, much faster on SMB/NFS shares`)
+	flag.StringVar(&options.DirMode, "dir-mode", "", `permission of the directories created in the publish dir, in octal, e.g. 0750. by default 0777 minus the umask`)
+	flag.StringVar(&options.FileMode, "file-mode", "", `permission of the files written in the publish dir, in octal, e.g. 0640. by default 0666 minus the umask. moved files keep their permission`)
+	flag.IntVar(&options.IOConcurrency, "io-concurrency", 0, `the number of concurrent file operations of --io-profile network (default 4)`)
+	flag.DurationVar(&options.WaitForUnlock, "wait-for-unlock", 0, `when files are in use (e.g. the app is running on Windows), wait up to this duration for them to be released instead of failing, e.g. 30s`)
+	flag.BoolVar(&options.Incremental, "incremental", false, `only examine files changed since the last beauty or missing from `+beauty.SquirrelManifestName+`, which is written in this mode. for fast publish/beauty cycles, run without it after changing other options`)
+	flag.BoolVar(&options.LowMemory, "lowmemory", false, `always process deps.json as a stream instead of loading it whole. deps.json over 16MB is streamed anyway`)
+	flag.BoolVar(&options.Durable, "durable", false, `fsync files and their directories after every move and rewrite, for network shares and disks that may lose renames on power loss. slower`)
+	flag.BoolVar(&options.SkipPublishCheck, "i-know-what-im-doing", false, `beauty the directory even if it looks like a build output (bin/<Configuration>/<tfm>, ref/, *.runtimeconfig.dev.json) instead of a publish folder`)
+	flag.BoolVar(&readStdin, "stdin", false, `read the directories to beauty from stdin, one per line, <beautyDir> must be omitted in this mode`)
+	flag.StringVar(&options.Archive, "archive", "", `beauty a zipped publish output directly, <beautyDir> must be omitted in this mode`)
+	flag.StringVar(&options.ArchiveOut, "archiveout", "", `write the beautified archive to a new zip instead of replacing the original one`)
+	flag.StringVar(&options.Report, "report", "", `print a report after beauty. valid values: tree
+tree: root directory listing before and after beauty.
+`)
+	flag.StringVar(&options.ReportFile, "reportfile", "", `write the report into a file instead of stdout`)
+	flag.BoolVar(&options.MSIX, "msix", false, `the output will be packaged as MSIX: only use strategies that keep the host binaries untouched and check the output against the MSIX packaging rules`)
+	flag.BoolVar(&options.Squirrel, "squirrel", false, `the app is updated by Squirrel/Clowd.Squirrel: keep Update.exe and the execution stubs untouched and write `+beauty.SquirrelManifestName+` listing the relocated files`)
+	flag.StringVar(&options.OutDir, "outdir", "", `beautify a copy of <beautyDir> in this empty directory, <beautyDir> itself is only read. for publish dirs on read-only mounts`)
+	flag.StringVar(&options.DockerSplit, "dockersplit", "", `also copy the beautified output into <dir>/libs (the rarely changing dependencies) and <dir>/app (the app itself) with a Dockerfile snippet copying them as separate layers`)
+	flag.StringVar(&options.AppDir, "appdir", "", `[Linux Only] also copy the beautified output into an AppImage AppDir: the app under usr/lib/<app>, AppRun, .desktop and icons at the top, generated when missing. checks that the app resolves its dependencies relative to itself`)
+	flag.BoolVar(&options.ClickOnce, "clickonce", false, `[Windows Only] update the file lists and hashes of the ClickOnce application manifest (*.manifest) and deployment manifests (*.application) to the beautified layout. signed manifests must be re-signed afterwards, e.g. with mage -Sign in --posthook`)
+	flag.StringVar(&options.SBOM, "sbom", "", `write a software bill of materials of the relocated assemblies (version, hash and NuGet package) into this file`)
+	flag.StringVar(&options.SBOMFormat, "sbomformat", beauty.CycloneDX, `sbom format. valid values: cyclonedx/spdx`)
+	flag.BoolVar(&options.Codesign, "codesign", false, `[macOS Only] re-sign the patched hostfxr and the enclosing .app bundle`)
+	flag.StringVar(&options.CodesignIdentity, "codesignidentity", "-", `[macOS Only] codesign identity, default is ad-hoc signing`)
+	flag.StringVar(&options.Signtool, "signtool", "signtool", `[Windows Only] path to signtool.exe used to re-sign the patched hostfxr`)
+	flag.StringVar(&options.SignThumbprint, "signthumbprint", "", `[Windows Only] re-sign the patched hostfxr with the certificate of this SHA1 thumbprint from the certificate store`)
+	flag.StringVar(&options.SignCert, "signcert", "", `[Windows Only] re-sign the patched hostfxr with this pfx file, the password is read from NBEAUTY_SIGN_PASSWORD and passed to signtool with /p, so it is visible in the process list while signtool runs. prefer --signthumbprint on shared machines`)
+	flag.StringVar(&options.SignTimestamp, "signtimestamp", "", `[Windows Only] RFC 3161 timestamp server url used when re-signing`)
+	flag.IntVar(&options.FXRBackups, "fxrbackups", 1, `[.NET Core App Only] how many outdated hostfxr backups to keep besides the current .bak`)
+	flag.BoolVar(&options.KeepOriginal, "keeporiginal", false, `[.NET Core App Only] also keep an untouched copy of the original hostfxr in <libsDir>/.original`)
+	flag.BoolVar(&options.RequireKnownHash, "requireknownhash", false, `[.NET Core App Only] refuse to install a patched hostfxr whose hash is not on the known-good list`)
+	flag.StringVar(&options.HashAlgorithm, "hashalgorithm", "sha256", `hash algorithm used for artifact and file verification. valid values: sha256/sha512`)
+	flag.DurationVar(&networkPolicy.ConnectTimeout, "connect-timeout", networkPolicy.ConnectTimeout, `timeout of connecting to --gitcdn, including the TLS handshake`)
+	flag.DurationVar(&networkPolicy.ReadTimeout, "read-timeout", networkPolicy.ReadTimeout, `timeout of a single request to --gitcdn, from sending it until the whole response is read`)
+	flag.DurationVar(&networkPolicy.Deadline, "network-deadline", 0, `total time all requests of a beauty may take, e.g. 2m. no limit by default`)
+	flag.IntVar(&networkPolicy.Retries, "retries", networkPolicy.Retries, `how many times the requests of a beauty may be retried in total on network errors, 5xx and 429`)
+	flag.BoolVar(&networkPolicy.NoNetwork, "no-network", false, `never access the network, only use the cached patches and version information. for air-gapped builds`)
+	flag.StringVar(&knownHashKey, "knownhashkey", "", `[.NET Core App Only] base64 ed25519 public key used to verify the signature of the known-good list`)
+	flag.StringVar(&buildFXR, "fxr", "", `[patch build/selftest] hostfxr version to build, or the runtime version the selftest sample targets, e.g. 8.0.1`)
+	flag.StringVar(&buildRID, "rid", "", `[patch build/selftest] target rid to build or publish the selftest sample for, e.g. linux-riscv64`)
+	flag.StringVar(&runtimeSrc, "runtimesrc", "", `[patch build] existing dotnet/runtime checkout, cloned automatically if omitted`)
+	flag.StringVar(&patchFile, "patchfile", "", `[patch build] HostFXRPatcher patch to apply before building`)
 
 	flag.Parse()
 
+	detectDeprecations()
+
 	args := make([]string, 0)
 
 	// 内置的坑爹flag不对空格做忽略处理
@@ -306,357 +277,510 @@ Info: Log everything.
 	}
 	argv := len(args)
 
+	// 输出语言
+	if lang != "" && !i18n.Supported(lang) {
+		log.LogPanic(fmt.Errorf("invalid lang: %s", lang), 1)
+	}
+	i18n.Lang = i18n.Detect(lang)
+
+	// logFormat检查
+	if logFormat != textFormat && logFormat != jsonFormat {
+		log.LogPanic(fmt.Errorf("invalid log format: %s", logFormat), 1)
+	}
+	if logFormat == jsonFormat {
+		log.DefaultLogger.Format = log.JSONFormat
+	}
+
+	// output检查
+	annotation, ok := outputs[output]
+	if !ok {
+		log.LogPanic(fmt.Errorf("invalid output: %s", output), 1)
+	}
+	log.DefaultLogger.Annotation = annotation
+	if annotation != log.NoAnnotation {
+		event.Subscribe(func(e event.Event) {
+			if e.Type == event.StageStarted {
+				log.DefaultLogger.Progress(fmt.Sprint(e.Data["stage"]))
+			}
+		})
+	}
+
 	// 必需参数检查
-	if argv == 0 {
+	if argv == 0 && options.Archive == "" && !readStdin {
 		usage()
 		os.Exit(0)
 	}
 
-	// logLevel检查
-	if loglevel != errorLevel && loglevel != detailLevel && loglevel != infoLevel {
-		loglevel = errorLevel
+	// strategy检查
+	// --usepatch总是优先尝试patch，--strategy中没有patch时加在最前
+	if usePatch && !strings.Contains(","+strings.Replace(options.Strategy, " ", "", -1)+",", ",patch,") {
+		if options.Strategy == "" {
+			options.Strategy = "patch"
+		} else {
+			options.Strategy = "patch," + options.Strategy
+		}
+	}
+
+	// hashAlgorithm检查
+	if options.HashAlgorithm != "sha256" && options.HashAlgorithm != "sha512" {
+		log.LogPanic(fmt.Errorf("invalid hash algorithm: %s", options.HashAlgorithm), 1)
+	}
+	util.HashAlgorithm = options.HashAlgorithm
+
+	logLevels := map[string]log.LogLevel{
+		errorLevel:   log.Error,
+		warningLevel: log.Warning,
+		detailLevel:  log.Detail,
+		infoLevel:    log.Info,
+	}
+
+	// logLevel检查，支持按模块设置：Detail,manager=Info,util=Error
+	globalLevel := errorLevel
+	moduleLevels := make(map[string]log.LogLevel)
+	for _, item := range strings.Split(loglevel, ",") {
+		item = strings.TrimSpace(item)
+		if index := strings.Index(item, "="); index != -1 {
+			module, level := item[:index], item[index+1:]
+			if _, ok := logLevels[level]; !ok {
+				log.LogPanic(fmt.Errorf("invalid log level of %s: %s", module, level), 1)
+			}
+			moduleLevels[module] = logLevels[level]
+		} else if _, ok := logLevels[item]; ok {
+			globalLevel = item
+		}
 	}
 
 	// 设置LogLevel
-	log.DefaultLogger.LogLevel = map[string]log.LogLevel{
-		errorLevel:  log.Error,
-		detailLevel: log.Detail,
-		infoLevel:   log.Info,
-	}[loglevel]
-	manager.Logger.LogLevel = log.DefaultLogger.LogLevel
-
-	switch args[0] {
+	log.DefaultLogger.LogLevel = logLevels[globalLevel]
+	// --debughttp需要manager模块至少为Detail才能看到请求记录
+	if debugHTTP {
+		level, ok := moduleLevels["manager"]
+		if !ok {
+			level = logLevels[globalLevel]
+		}
+		if level < log.Detail {
+			moduleLevels["manager"] = log.Detail
+		}
+		manager.EnableHTTPTrace()
+	}
+	log.DefaultLogger.ModuleLevels = moduleLevels
+
+	log.DefaultLogger.Timestamp = logTime
+
+	// 运行ID，便于区分并行任务汇总到一起的日志
+	explicitRunID := runID != ""
+	if !explicitRunID {
+		runID = newRunID()
+	}
+	if explicitRunID || logFormat == jsonFormat {
+		log.DefaultLogger.RunID = runID
+	}
+	event.RunID = runID
+
+	openEventFile(eventFile)
+
+	reportDeprecations()
+
+	if notifyURL != "" && networkPolicy.NoNetwork {
+		log.LogPanic(errors.New("--notifyurl cannot be used with --no-network"), 1)
+	}
+	if pprofFile != "" && profileFile == "" {
+		log.LogPanic(errors.New("--pprof requires --profile"), 1)
+	}
+	if profileFile != "" {
+		startProfile(pprofFile)
+	}
+
+	// 控制台着色，遵循NO_COLOR约定
+	if !noColor && os.Getenv("NO_COLOR") == "" && misc.IsTerminal(os.Stdout) {
+		log.DefaultLogger.Color = misc.EnableVirtualTerminal(os.Stdout)
+	}
+
+	// 日志文件
+	if logFile != "" {
+		level, ok := logLevels[logFileLevel]
+		if !ok {
+			log.LogPanic(fmt.Errorf("invalid log file level: %s", logFileLevel), 1)
+		}
+		if logFileMaxSize < 0 || logFileMaxAge < 0 || logFileBackups < 0 {
+			log.LogPanic(errors.New("log rotation limits cannot be negative"), 1)
+		}
+		var file io.Writer
+		var err error
+		if logFileMaxSize > 0 || logFileMaxAge > 0 {
+			file, err = log.OpenRotatingFile(logFile, int64(logFileMaxSize)*1024*1024, logFileMaxAge, logFileBackups)
+		} else {
+			file, err = os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		}
+		if err != nil {
+			log.LogPanic(fmt.Errorf("cannot open log file: %s : %s", logFile, err.Error()), 1)
+		}
+		log.DefaultLogger.File = file
+		log.DefaultLogger.FileLevel = level
+	}
+
+	// 系统日志
+	if sysLog {
+		level, ok := logLevels[sysLogLevel]
+		if !ok {
+			log.LogPanic(fmt.Errorf("invalid syslog level: %s", sysLogLevel), 1)
+		}
+		systemLog, err := misc.OpenSystemLog("nbeauty")
+		if err != nil {
+			log.LogPanic(fmt.Errorf("cannot open system log: %s", err.Error()), 1)
+		}
+		log.DefaultLogger.System = systemLog
+		log.DefaultLogger.SystemLevel = level
+	}
+
+	command := ""
+	if argv != 0 {
+		command = args[0]
+	}
+
+	switch command {
 	case "setcdn":
 		checkArgumentsCount(2, argv)
 		if manager.SetCDN(strings.Trim(args[1], `"`)) {
-			fmt.Println("set default git cdn successfully")
+			fmt.Println(i18n.T("cdn.set"))
 		} else {
-			fmt.Println("set default git cdn failed")
+			fmt.Println(i18n.T("cdn.set.failed"))
 		}
 		exit()
 	case "getcdn":
 		checkArgumentsCount(1, argv)
 		cdn := manager.GetCDN()
 		if cdn == "" {
-			fmt.Println("default git cdn has not been set yet")
+			fmt.Println(i18n.T("cdn.none"))
 		} else {
-			fmt.Printf("current default git cdn: %s\n", cdn)
+			fmt.Println(i18n.T("cdn.current", cdn))
 		}
 		exit()
 	case "delcdn":
 		checkArgumentsCount(1, argv)
 		cdn := manager.GetCDN()
 		if cdn == "" {
-			fmt.Println("default git cdn has not been set yet")
+			fmt.Println(i18n.T("cdn.none"))
 		} else {
 			manager.DelCDN()
-			fmt.Printf("current default git cdn has been deleted, it was: [%s] before\n", cdn)
+			fmt.Println(i18n.T("cdn.deleted", cdn))
 		}
 		exit()
-	default:
-		if gitcdn == "" {
-			cdn := manager.GetCDN()
-			if cdn == "" {
-				gitcdn = "https://github.com/nulastudio/HostFXRPatcher"
-			} else {
-				gitcdn = cdn
+	case "patch":
+		if argv < 2 {
+			checkArgumentsCount(2, argv)
+		}
+		switch args[1] {
+		case "build":
+			checkArgumentsCount(2, argv)
+			setupManager()
+			if err := buildPatch(buildFXR, buildRID); err != nil {
+				log.LogPanic(err, 1)
+			}
+			fmt.Println(i18n.T("patch.built", strings.TrimPrefix(buildFXR, "v"), buildRID))
+		case "status":
+			checkArgumentsCount(3, argv)
+			dir, err := util.AbsPath(args[2])
+			if err != nil {
+				log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+			}
+			lines, err := beauty.PatchStatus(dir)
+			if err != nil {
+				log.LogPanic(err, 1)
+			}
+			for _, line := range lines {
+				fmt.Println(line)
 			}
+		default:
+			log.LogPanic(errors.New(i18n.T("patch.command.unknown", args[1])), 1)
 		}
-
-		beautyDir = args[0]
-
-		if len(args) >= 2 {
-			libsDir = flag.Arg(1)
+		exit()
+	case "pack":
+		checkArgumentsCount(1, argv)
+		nupkg, err := packTool(packTools, packShim, packOut)
+		if err != nil {
+			log.LogPanic(err, 1)
 		}
-
-		if len(args) >= 3 {
-			excludes = flag.Arg(2)
+		fmt.Printf("%s created\n", nupkg)
+		exit()
+	case "init":
+		if argv != 2 && argv != 3 {
+			checkArgumentsCount(3, argv)
+		}
+		dir := workingDir
+		if argv == 3 {
+			dir = strings.Trim(args[2], `"`)
+		}
+		switch args[1] {
+		case "msbuild":
+			target, err := initMSBuild(dir)
+			if err != nil {
+				log.LogPanic(err, 1)
+			}
+			fmt.Printf("%s created, add <Import Project=\"%s\" /> to your *.csproj\n", target, msbuildTargetsName)
+		case "script":
+			target, err := initScript(dir, scriptShell)
+			if err != nil {
+				log.LogPanic(err, 1)
+			}
+			fmt.Printf("%s created, run it after dotnet publish\n", target)
+		default:
+			log.LogPanic(fmt.Errorf("unknown init command: %s", args[1]), 1)
 		}
-
-		beautyDir = strings.Trim(beautyDir, `"`)
-		libsDir = strings.Trim(libsDir, `"`)
-		excludes = strings.Trim(excludes, `"`)
-		hiddens = strings.Trim(hiddens, `"`)
-		absDir, err := filepath.Abs(beautyDir)
+		exit()
+	case "export":
+		if argv != 3 && argv != 4 {
+			checkArgumentsCount(3, argv)
+		}
+		dir, err := util.AbsPath(args[2])
 		if err != nil {
-			log.LogPanic(fmt.Errorf("invalid beautyDir: %s", err.Error()), 1)
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
 		}
-		beautyDir = absDir
-	}
-}
-
-func checkArgumentsCount(excepted int, got int) bool {
-	if excepted == got {
-		return true
-	}
-	log.LogPanic(fmt.Errorf("Too few or many arguments, expected %d, got %d", excepted, got), 1)
-	return false
-}
-
-func exit() {
-	os.Exit(0)
-}
-
-func usage() {
-	fmt.Println("Usage:")
-	fmt.Println("nbeauty [--loglevel=(Error|Detail|Info)] [--hiddens=hiddenFiles] <beautyDir> [<libsDir> [<excludes>]]")
-	fmt.Println("")
-	fmt.Println("Arguments")
-	fmt.Println("  <excludes>    dlls that no need to be moved, multi-dlls separated with \";\". Example: dll1.dll;lib*;...")
-	fmt.Println("")
-	fmt.Println("Options")
-	flag.PrintDefaults()
-}
-
-func patch(fxrVersion string, rid string) bool {
-	log.LogDetail("patching hostfxr...")
-
-	crid := manager.FindCompatibleRID(rid)
-	fxrName := manager.GetHostFXRNameByRID(rid)
-	if crid == "" {
-		log.LogPanic(fmt.Errorf("cannot find a compatible rid for %s", rid), 1)
-	}
-
-	log.LogDetail(fmt.Sprintf("using compatible rid %s for %s", crid, rid))
-	rid = crid
-
-	localVersion := manager.GetLocalArtifactsVersion(fxrVersion, rid)
-	onlineVersion := manager.GetOnlineArtifactsVersion(fxrVersion, rid)
-	if localVersion != onlineVersion {
-		log.LogDetail(fmt.Sprintf("downloading patched hostfxr: %s/%s", fxrVersion, rid))
-
-		if !manager.DownloadArtifact(fxrVersion, rid) || !manager.WriteLocalArtifactsVersion(fxrVersion, rid, onlineVersion) {
-			log.LogPanic(errors.New("download patch failed"), 1)
+		out := ""
+		if argv == 4 {
+			out = strings.Trim(args[3], `"`)
 		}
-	}
-
-	absFxrName := path.Join(beautyDir, fxrName)
-	absFxrBakName := absFxrName + ".bak"
-
-	isHidden1, hidErr1 := misc.IsHiddenFile(absFxrName)
-	isHidden2, hidErr2 := misc.IsHiddenFile(absFxrBakName)
-
-	if isHidden1 && hidErr1 != nil {
-		misc.ShowFile(absFxrName)
-	}
-	if isHidden2 && hidErr2 != nil {
-		misc.ShowFile(absFxrBakName)
-	}
-
-	log.LogInfo(fmt.Sprintf("backuping fxr to %s", absFxrBakName))
-
-	if _, err := util.CopyFile(absFxrName, absFxrBakName); err != nil {
-		log.LogError(fmt.Errorf("backup failed: %s", err.Error()), false)
-
-		if isHidden1 && hidErr1 != nil {
-			misc.HideFile(absFxrName)
+		if err := exportLayout(args[1], dir, out); err != nil {
+			log.LogPanic(err, 1)
 		}
-		if isHidden2 && hidErr2 != nil {
-			misc.HideFile(absFxrBakName)
+		exit()
+	case "bench":
+		if argv != 2 && argv != 3 {
+			checkArgumentsCount(2, argv)
 		}
-
-		return false
-	}
-
-	success := manager.CopyArtifactTo(fxrVersion, rid, beautyDir)
-	if success {
-		log.LogInfo("patch succeeded")
-	} else {
-		fmt.Println("patch failed")
-	}
-
-	if isHidden1 && hidErr1 != nil {
-		misc.HideFile(absFxrName)
-	}
-	if isHidden2 && hidErr2 != nil {
-		misc.HideFile(absFxrBakName)
-	}
-
-	return success
-}
-
-func releaseNBLoader(dir string) (string, error) {
-	nbloader, err := Asset("nbloader/nbloader.dll")
-	loaderPath := dir + "/nbloader.dll"
-
-	if err == nil {
-		isHidden, hidErr := misc.IsHiddenFile(loaderPath)
-
-		if isHidden && hidErr == nil {
-			misc.ShowFile(loaderPath)
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
 		}
-
-		if err := ioutil.WriteFile(loaderPath, nbloader, 0666); err != nil {
-			if isHidden && hidErr == nil {
-				misc.HideFile(loaderPath)
+		options.Dir = dir
+		if argv == 3 {
+			options.LibsDir = args[2]
+		}
+		// 与正常运行相同的CDN、插件及网络策略，否则计时不可比
+		setupManager()
+		if err := bench(options); err != nil {
+			log.LogPanic(err, 1)
+		}
+		exit()
+	case "selftest":
+		checkArgumentsCount(1, argv)
+		setupManager()
+		if err := selfTest(buildFXR, buildRID); err != nil {
+			log.LogPanic(err, 1)
+		}
+		exit()
+	case "daemon":
+		checkArgumentsCount(1, argv)
+		setupManager()
+		if err := runDaemon(); err != nil {
+			log.LogPanic(err, 1)
+		}
+	case "recover":
+		checkArgumentsCount(2, argv)
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+		}
+		if err := beauty.Recover(context.Background(), beauty.Options{Dir: dir}); err != nil {
+			if _, ok := err.(*beauty.ExitError); !ok {
+				log.LogPanic(err, 1)
 			}
-
-			return loaderPath, err
+			os.Exit(1)
 		}
-
-		if isHidden && hidErr == nil {
-			misc.HideFile(loaderPath)
+		fmt.Println("interrupted beauty has been undone")
+		exit()
+	case "migrate":
+		if argv != 2 && argv != 3 {
+			checkArgumentsCount(2, argv)
 		}
-
-		return loaderPath, nil
-	}
-
-	return loaderPath, err
-}
-
-func fileMatch(file string, sources []string) bool {
-	match := false
-	for _, pattern := range sources {
-		if pattern == "" {
-			continue
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+		}
+		libs := ""
+		if argv == 3 {
+			libs = args[2]
 		}
-		if regex, err := regexp.Compile(strings.ReplaceAll(pattern, "*", ".*")); err == nil {
-			match = regex.MatchString(file)
-			if match {
-				break
+		if err := beauty.Migrate(context.Background(), beauty.Options{Dir: dir, LibsDir: libs}); err != nil {
+			if _, ok := err.(*beauty.ExitError); !ok {
+				log.LogPanic(err, 1)
 			}
+			os.Exit(1)
 		}
-	}
-
-	return match
-}
-
-func moveDeps(deps []manager.Deps, entry string, sharedRuntimeMode bool) (int, int, []string, map[string]string) {
-	var isContains = func(arr []string, v string) bool {
-		for _, c := range arr {
-			if c == v {
-				return true
+		fmt.Println("beauty marker has been migrated")
+		exit()
+	case "relayout":
+		checkArgumentsCount(3, argv)
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+		}
+		if err := beauty.Relayout(context.Background(), beauty.Options{Dir: dir, LibsDir: args[2]}); err != nil {
+			if _, ok := err.(*beauty.ExitError); !ok {
+				log.LogPanic(err, 1)
 			}
+			os.Exit(1)
 		}
-
-		return false
-	}
-
-	excludeFiles := strings.Split(excludes, ";")
-
-	realCount, moved, subDirs, srmMapping := 0, 0, make([]string, 0), make(map[string]string, 0)
-
-	for _, dep := range deps {
-		var absDepsFile = ""
-		var usingPath = ""
-		var exist = false
-
-		for _, filePath := range []string{dep.SecondPath, dep.Path} {
-			absDepsFile = filepath.Join(beautyDir, filePath)
-			if util.PathExists(absDepsFile) {
-				usingPath = filePath
-				exist = true
-				break
+		fmt.Println("libsDir has been relocated")
+		exit()
+	case "reconcile":
+		checkArgumentsCount(2, argv)
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+		}
+		moved, err := beauty.Reconcile(context.Background(), beauty.Options{Dir: dir})
+		if err != nil {
+			if _, ok := err.(*beauty.ExitError); !ok {
+				log.LogPanic(err, 1)
 			}
-
-			if dep.SecondPath == dep.Path {
-				break
+			os.Exit(1)
+		}
+		fmt.Printf("layout reconciled, %d file(s) moved into libsDir\n", moved)
+		exit()
+	case "revert":
+		checkArgumentsCount(2, argv)
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+		}
+		if err := beauty.Revert(context.Background(), beauty.Options{Dir: dir}); err != nil {
+			if _, ok := err.(*beauty.ExitError); !ok {
+				log.LogPanic(err, 1)
 			}
+			os.Exit(1)
 		}
-
-		if !exist {
-			continue
+		fmt.Println("beauty has been reverted")
+		exit()
+	case "restorefxr":
+		checkArgumentsCount(2, argv)
+		dir, err := util.AbsPath(args[1])
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
+		}
+		if err := beauty.RestoreFXR(context.Background(), beauty.Options{Dir: dir}); err != nil {
+			os.Exit(1)
+		}
+		fmt.Println("original hostfxr has been restored")
+		exit()
+	default:
+		// archive及stdin模式下不需要<beautyDir>
+		if options.Archive != "" || readStdin {
+			args = append([]string{""}, args...)
 		}
 
-		if fileMatch(dep.Name, excludeFiles) {
-			continue
+		options.Dir = args[0]
+
+		if len(args) >= 2 {
+			options.LibsDir = args[1]
 		}
 
-		if !isNetFx {
-			/**
-			* !usePatch + !enableDebug = !move +  delete
-			* !usePatch +  enableDebug = !move + !delete
-			*  usePatch + !enableDebug = !move +  delete
-			*  usePatch +  enableDebug =  move + !delete
-			 */
-			if strings.Contains(dep.Name, "mscordaccore") ||
-				strings.Contains(dep.Name, "mscordbi") {
-				if !enableDebug {
-					os.Remove(absDepsFile)
-					continue
-				} else if !usePatch {
-					continue
-				}
-			}
+		if len(args) >= 3 {
+			options.Excludes = args[2]
 		}
 
-		realCount++
-
-		usingPath2 := strings.ReplaceAll(usingPath, "\\", "/")
-		parts := strings.Split(usingPath2, "/")
-		fileName := parts[len(parts)-1]
-		subDir := strings.Join(parts[0:len(parts)-1], "/")
-
-		if dep.Type != manager.Resource && subDir != "" && !isContains(subDirs, subDir) {
-			subDirs = append(subDirs, subDir)
-		}
-
-		// native不能使用分层结构（多层依赖会导致加载不了dll）
-		if !isNetFx && sharedRuntimeMode {
-			if dep.Type != manager.Native {
-				md5, _ := util.GetFileMD5(absDepsFile)
-				if md5 == "" {
-					md5 = "generic"
-				}
-				parts = append(parts, md5, fileName)
-				srmKey := fileName
-				if dep.Type == manager.Resource {
-					srmKey = parts[0] + "/" + srmKey
-				}
-				srmMapping[srmKey] = md5
-				usingPath = strings.Join(parts, "/")
-			} else {
-				appID, _ := util.GetStringMD5(entry)
-				parts = append([]string{"srm_native", appID}, parts...)
-				usingPath = strings.Join(parts, "/")
-			}
+		if options.Archive != "" || readStdin {
+			return
 		}
 
-		if !isNetFx && dep.Type == manager.Resource {
-			parts = append([]string{"locales"}, parts...)
-			usingPath = strings.Join(parts, "/")
+		absDir, err := util.AbsPath(options.Dir)
+		if err != nil {
+			log.LogPanic(errors.New(i18n.T("beautydir.invalid", err.Error())), 1)
 		}
+		options.Dir = absDir
+	}
+}
 
-		newAbsDepsFile, _ := filepath.Abs(beautyDir + "/" + libsDir + "/" + usingPath)
-		oldPath := filepath.Dir(absDepsFile)
-		newPath := filepath.Dir(newAbsDepsFile)
+// runBeauty 依次执行--prehook、beauty及--posthook，返回beauty的结果及退出码
+func runBeauty() (beauty.Result, int, error) {
+	if err := runCommandHook("--prehook", preHook, hookEnv()); err != nil {
+		log.LogError(err, false)
+		return beauty.Result{}, 1, err
+	}
 
-		if !util.EnsureDirExists(newPath, 0777) {
-			log.LogError(fmt.Errorf("%s is not writeable", newPath), false)
+	ctx, cancel := runContext()
+	defer cancel()
+
+	result, err := beauty.Beautify(ctx, options)
+	code := 0
+	if exitErr, ok := err.(*beauty.ExitError); ok {
+		code = exitErr.Code
+	} else if err == context.Canceled || err == context.DeadlineExceeded {
+		code = 130
+		log.LogError(fmt.Errorf("beauty aborted: %s", err.Error()), false)
+	} else if err != nil {
+		code = 1
+		log.LogError(err, false)
+	}
+
+	if hookErr := runCommandHook("--posthook", postHook, postHookEnv(result, err, code)); hookErr != nil {
+		log.LogError(hookErr, false)
+		if code == 0 {
+			code = 1
 		}
+	}
+	return result, code, err
+}
 
-		if err := os.Rename(absDepsFile, newAbsDepsFile); err == nil {
-			moved++
+// setupManager 设置CDN并加载插件
+func setupManager() {
+	if gitcdn == "" {
+		cdn := manager.GetCDN()
+		if cdn == "" {
+			gitcdn = "https://github.com/nulastudio/HostFXRPatcher"
 		} else {
-			fmt.Println(err.Error())
+			gitcdn = cdn
 		}
+	}
 
-		for _, extFile := range []string{".pdb", ".xml"} {
-			oldFile := filepath.Join(oldPath, fileName+extFile)
-			newFile := filepath.Join(newPath, fileName+extFile)
-			if util.PathExists(oldFile) {
-				os.Rename(oldFile, newFile)
-			}
-		}
+	manager.GitCDN = gitcdn
+	if gittree != "" {
+		manager.GitTree = gittree
+	}
+	manager.KnownHashPublicKey = knownHashKey
+	manager.SetNetworkPolicy(networkPolicy)
 
-		dir, _ := ioutil.ReadDir(oldPath)
+	if err := beauty.LoadPlugins(pluginDir); err != nil {
+		log.LogPanic(err, 1)
+	}
+}
 
-		if len(dir) == 0 {
-			os.Remove(oldPath)
-		}
+func checkArgumentsCount(excepted int, got int) bool {
+	if excepted == got {
+		return true
 	}
+	log.LogPanic(errors.New(i18n.T("arguments.count", excepted, got)), 1)
+	return false
+}
 
-	return realCount, moved, subDirs, srmMapping
+func exit() {
+	os.Exit(0)
 }
 
-func hideFiles() {
-	hiddensFiles := strings.Split(hiddens, ";")
-	rootFiles := util.GetAllFiles(beautyDir, false)
-	for _, rootFile := range rootFiles {
-		if fileMatch(rootFile, hiddensFiles) {
-			if err := misc.HideFile(rootFile); err != nil {
-				log.LogError(fmt.Errorf("hide file failed: %s : %s", rootFile, err.Error()), false)
-			}
-		}
-	}
+func usage() {
+	fmt.Println(i18n.T("usage"))
+	fmt.Println("nbeauty [--loglevel=(Error|Warning|Detail|Info)] [--hiddens=hiddenFiles] <beautyDir> [<libsDir> [<excludes>]]")
+	fmt.Println("nbeauty [--loglevel=(Error|Warning|Detail|Info)] [--hiddens=hiddenFiles] --archive=<zip> [--archiveout=<zip>] [<libsDir> [<excludes>]]")
+	fmt.Println("nbeauty [--loglevel=(Error|Warning|Detail|Info)] [--hiddens=hiddenFiles] --stdin [<libsDir> [<excludes>]]")
+	fmt.Println("nbeauty patch status <beautyDir>")
+	fmt.Println("nbeauty restorefxr <beautyDir>")
+	fmt.Println("nbeauty revert <beautyDir>")
+	fmt.Println("nbeauty recover <beautyDir>")
+	fmt.Println("nbeauty migrate <beautyDir> [<libsDir>]")
+	fmt.Println("nbeauty relayout <beautyDir> <newLibsDir>")
+	fmt.Println("nbeauty reconcile <beautyDir>")
+	fmt.Println("nbeauty [--grpc=<addr>] [--rpc=<addr>] [--http=<addr>] daemon")
+	fmt.Println("nbeauty init msbuild [<projectDir>]")
+	fmt.Println("nbeauty [--shell=(bash|pwsh)] init script [<projectDir>]")
+	fmt.Println("nbeauty export (wix|innosetup|msix|deb|rpm) <beautyDir> [<outFile>]")
+	fmt.Println("nbeauty [--fxr=<version>] [--rid=<rid>] selftest")
+	fmt.Println("nbeauty [--benchruns=<n>] [--benchargs=<args>] bench <beautyDir> [<libsDir>]")
+	fmt.Println("nbeauty --packtools=<dir> --packshim=<dir> [--packout=<dir>] pack")
+	fmt.Println("nbeauty --fxr=<version> --rid=<rid> --patchfile=<patch> [--runtimesrc=<dir>] patch build")
+	fmt.Println("")
+	fmt.Println(i18n.T("usage.arguments"))
+	fmt.Println(i18n.T("usage.excludes"))
+	fmt.Println("")
+	fmt.Println(i18n.T("usage.options"))
+	flag.PrintDefaults()
 }
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var benchRuns = 10
+var benchArgs = ""
+
+// benchTimeout 单次启动的超时时间
+const benchTimeout = time.Minute
+
+// benchStats 一组启动耗时的统计
+type benchStats struct {
+	cold    time.Duration
+	mean    time.Duration
+	median  time.Duration
+	min     time.Duration
+	max     time.Duration
+	stddev  time.Duration
+	samples int
+}
+
+// bench 分别复制beauty前后的目录，各启动应用1+benchRuns次并比较启动耗时，原目录不会被修改
+func bench(opts beauty.Options) error {
+	if benchRuns < 1 {
+		return errors.New("--benchruns must be at least 1")
+	}
+	dir := strings.Trim(opts.Dir, `"`)
+	if util.PathExists(manager.MarkerPath(dir)) {
+		return fmt.Errorf("%s has already been beautified, bench needs the original publish directory", dir)
+	}
+	opts.DockerSplit, opts.AppDir, opts.ReportFile, opts.SBOM, opts.Archive, opts.OutDir = "", "", "", "", "", ""
+
+	work, err := ioutil.TempDir("", "nbeauty-bench")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	before, after := filepath.Join(work, "before"), filepath.Join(work, "after")
+	if err := copyDir(dir, before); err != nil {
+		return err
+	}
+	if err := copyDir(dir, after); err != nil {
+		return err
+	}
+	opts.Dir = after
+	if _, err := beauty.Beautify(context.Background(), opts); err != nil {
+		return fmt.Errorf("beauty failed: %s", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+
+	args := strings.Fields(benchArgs)
+	beforeStats, err := benchApp(before, args)
+	if err != nil {
+		return fmt.Errorf("before beauty: %s", err.Error())
+	}
+	afterStats, err := benchApp(after, args)
+	if err != nil {
+		return fmt.Errorf("after beauty: %s", err.Error())
+	}
+
+	fmt.Printf("startup time over %d warm run(s), the cold run is the first launch of a fresh copy\n", benchRuns)
+	fmt.Printf("%-8s %10s %10s %10s %10s %10s %10s\n", "", "cold", "mean", "median", "min", "max", "stddev")
+	for _, row := range []struct {
+		name  string
+		stats benchStats
+	}{{"before", beforeStats}, {"after", afterStats}} {
+		s := row.stats
+		fmt.Printf("%-8s %10s %10s %10s %10s %10s %10s\n", row.name, formatMillis(s.cold), formatMillis(s.mean), formatMillis(s.median), formatMillis(s.min), formatMillis(s.max), formatMillis(s.stddev))
+	}
+	diff := afterStats.median - beforeStats.median
+	fmt.Printf("warm median %s%s (%+.1f%%), cold %s%s\n", sign(diff), formatMillis(diff), float64(diff)*100/float64(beforeStats.median),
+		sign(afterStats.cold-beforeStats.cold), formatMillis(afterStats.cold-beforeStats.cold))
+	return nil
+}
+
+// benchApp 启动dir中的应用1+benchRuns次，第一次为冷启动
+func benchApp(dir string, args []string) (benchStats, error) {
+	name, args, err := benchCommand(dir, args)
+	if err != nil {
+		return benchStats{}, err
+	}
+
+	durations := make([]time.Duration, 0, benchRuns)
+	stats := benchStats{}
+	for i := 0; i <= benchRuns; i++ {
+		duration, err := launchTimed(dir, name, args)
+		if err != nil {
+			return stats, err
+		}
+		if i == 0 {
+			stats.cold = duration
+			continue
+		}
+		durations = append(durations, duration)
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	stats.samples = len(durations)
+	stats.mean = sum / time.Duration(stats.samples)
+	stats.min, stats.max = durations[0], durations[stats.samples-1]
+	if stats.samples%2 == 1 {
+		stats.median = durations[stats.samples/2]
+	} else {
+		stats.median = (durations[stats.samples/2-1] + durations[stats.samples/2]) / 2
+	}
+	var variance float64
+	for _, d := range durations {
+		variance += math.Pow(float64(d-stats.mean), 2)
+	}
+	stats.stddev = time.Duration(math.Sqrt(variance / float64(stats.samples)))
+	return stats, nil
+}
+
+// benchCommand 启动应用的命令，优先使用apphost，没有时为dotnet <app>.dll
+func benchCommand(dir string, args []string) (string, []string, error) {
+	deps := manager.FindDepsJSON(dir)
+	if len(deps) == 0 {
+		return "", nil, fmt.Errorf("no deps.json found in %s", dir)
+	}
+	app := strings.TrimSuffix(filepath.Base(deps[0]), ".deps.json")
+	for _, name := range []string{app + ".exe", app} {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+			return filepath.Join(dir, name), args, nil
+		}
+	}
+	dotnet, err := exec.LookPath("dotnet")
+	if err != nil {
+		return "", nil, fmt.Errorf("%s has no apphost and dotnet is not in PATH", app)
+	}
+	return dotnet, append([]string{filepath.Join(dir, app+".dll")}, args...), nil
+}
+
+// launchTimed 启动应用并等待其退出，返回耗时
+func launchTimed(dir string, name string, args []string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), benchTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	start := time.Now()
+	err := cmd.Run()
+	duration := time.Since(start)
+	if ctx.Err() != nil {
+		return 0, fmt.Errorf("the app did not exit within %s, pass --benchargs to make it exit right after starting", benchTimeout)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("launch failed: %s: %s", err.Error(), strings.TrimSpace(output.String()))
+	}
+	return duration, nil
+}
+
+func formatMillis(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}
+
+func sign(d time.Duration) string {
+	if d < 0 {
+		return "-"
+	}
+	return "+"
+}
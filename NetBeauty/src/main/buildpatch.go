@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+const runtimeRepo = "https://github.com/dotnet/runtime"
+
+func runIn(dir string, name string, args ...string) error {
+	log.LogDetail(fmt.Sprintf("%s %s", name, strings.Join(args, " ")))
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// ridToBuildTarget 将RID转换为dotnet/runtime构建脚本的os及arch参数
+func ridToBuildTarget(rid string) (string, string, error) {
+	index := strings.LastIndex(rid, "-")
+	if index == -1 {
+		return "", "", fmt.Errorf("invalid rid: %s", rid)
+	}
+
+	targetOS, targetArch := rid[:index], rid[index+1:]
+	if targetOS == "win" {
+		targetOS = "windows"
+	}
+
+	return targetOS, targetArch, nil
+}
+
+// buildPatch 从dotnet/runtime源码编译补丁版hostfxr并安装到缓存
+func buildPatch(fxr string, rid string) error {
+	if fxr == "" || rid == "" {
+		return errors.New("--fxr and --rid are required")
+	}
+	if patchFile == "" {
+		return errors.New("--patchfile is required")
+	}
+
+	fxr = strings.TrimPrefix(fxr, "v")
+	targetOS, targetArch, err := ridToBuildTarget(rid)
+	if err != nil {
+		return err
+	}
+
+	patchFile, err = filepath.Abs(patchFile)
+	if err != nil {
+		return err
+	}
+
+	srcDir := runtimeSrc
+	if srcDir == "" {
+		srcDir = manager.LocalSourcePath("runtime-" + fxr)
+		if !util.PathExists(srcDir) {
+			if manager.CurrentNetworkPolicy().NoNetwork {
+				return fmt.Errorf("cannot clone dotnet/runtime into %s: %s", srcDir, manager.ErrNoNetwork)
+			}
+			if err := runIn("", "git", "clone", "--depth", "1", "--branch", "v"+fxr, runtimeRepo, srcDir); err != nil {
+				return fmt.Errorf("clone dotnet/runtime failed: %s", err.Error())
+			}
+		}
+	}
+
+	if err := runIn(srcDir, "git", "apply", "--check", patchFile); err == nil {
+		if err := runIn(srcDir, "git", "apply", patchFile); err != nil {
+			return fmt.Errorf("apply patch failed: %s", err.Error())
+		}
+	} else {
+		log.LogDetail("patch cannot be applied, assuming it has been applied already")
+	}
+
+	buildScript := "./build.sh"
+	if runtime.GOOS == "windows" {
+		buildScript = "build.cmd"
+	}
+
+	if err := runIn(srcDir, buildScript, "-subset", "host.native", "-c", "Release", "-os", targetOS, "-arch", targetArch); err != nil {
+		return fmt.Errorf("build hostfxr failed: %s", err.Error())
+	}
+
+	output := filepath.Join(srcDir, "artifacts", "bin", targetOS+"."+targetArch+".Release", "corehost", manager.GetHostFXRNameByRID(rid))
+	if !util.PathExists(output) {
+		return fmt.Errorf("build output not found: %s", output)
+	}
+
+	if !manager.InstallLocalArtifact("v"+fxr, rid, output) {
+		return errors.New("install patched hostfxr failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// runCommandHook 通过shell执行--prehook/--posthook，本次运行的信息以NBEAUTY_*环境变量传入
+func runCommandHook(name string, command string, env map[string]string) error {
+	if command == "" {
+		return nil
+	}
+
+	log.LogDetail(fmt.Sprintf("running %s: %s", name, command))
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = workingDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, "NBEAUTY_"+key+"="+value)
+	}
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %s", name, err.Error())
+	}
+	return nil
+}
+
+// hookEnv --prehook及--posthook共用的环境变量
+func hookEnv() map[string]string {
+	return map[string]string{
+		"DIR":      options.Dir,
+		"LIBSDIR":  options.LibsDir,
+		"STRATEGY": options.Strategy,
+		"ARCHIVE":  options.Archive,
+		"RUNID":    runID,
+	}
+}
+
+// postHookEnv 在hookEnv的基础上加入beauty的结果，err为nil时RESULT为success
+func postHookEnv(result beauty.Result, err error, code int) map[string]string {
+	env := hookEnv()
+	env["RESULT"] = "success"
+	if err != nil {
+		env["RESULT"] = "failure"
+	}
+	env["EXITCODE"] = strconv.Itoa(code)
+	env["MODIFIED"] = strconv.FormatBool(result.Modified)
+	env["FXRVERSION"] = result.FXRVersion
+	env["RID"] = result.RID
+	env["PATCH"] = result.Patch
+	env["MOVEDFILES"] = strconv.Itoa(result.MovedFiles)
+	env["FAILEDFILES"] = strconv.Itoa(result.FailedFiles)
+	return env
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// Go运行时panic的退出码
+const crashExitCode = 2
+
+// handleCrash 捕获panic，生成崩溃报告并打印位置，需在main中defer调用
+func handleCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+
+	report, err := writeCrashReport(r, stack)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "panic: %v\n\n%s\n", r, stack)
+		fmt.Fprintf(os.Stderr, "cannot write crash report: %s\n", err.Error())
+	} else {
+		fmt.Fprintf(os.Stderr, "panic: %v\n", r)
+		fmt.Fprintf(os.Stderr, "nbeauty crashed, a crash report has been written to %s\n", report)
+		fmt.Fprintln(os.Stderr, "please attach it when reporting the issue: https://github.com/nulastudio/NetBeauty2/issues")
+	}
+
+	os.Exit(crashExitCode)
+}
+
+// writeCrashReport 将堆栈、参数、版本、系统信息及最近的日志写入临时文件
+func writeCrashReport(r interface{}, stack []byte) (string, error) {
+	f, err := ioutil.TempFile("", "nbeauty-crash-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	args := make([]string, 0, len(os.Args))
+	for _, arg := range os.Args {
+		args = append(args, sanitizePath(arg))
+	}
+
+	lines := []string{
+		fmt.Sprintf("nbeauty %s crash report", beauty.Version),
+		fmt.Sprintf("time: %s", time.Now().UTC().Format(time.RFC3339)),
+		fmt.Sprintf("go: %s", runtime.Version()),
+		fmt.Sprintf("os: %s/%s", runtime.GOOS, runtime.GOARCH),
+		fmt.Sprintf("args: %s", strings.Join(args, " ")),
+		"",
+		fmt.Sprintf("panic: %v", r),
+		"",
+		sanitizePath(string(stack)),
+		"recent log:",
+	}
+	for _, line := range log.Recent() {
+		lines = append(lines, sanitizePath(line))
+	}
+
+	if _, err := f.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// sanitizePath 将工作目录、用户目录替换为占位符，避免报告中泄露用户名
+func sanitizePath(s string) string {
+	replacer := make([]string, 0, 4)
+	// 工作目录为根目录时不替换，否则所有路径分隔符都会被替换
+	if workingDir != "" && filepath.Dir(workingDir) != workingDir {
+		replacer = append(replacer, workingDir, "<cwd>")
+	}
+	for _, env := range []string{"HOME", "USERPROFILE"} {
+		if home := os.Getenv(env); home != "" && home != "/" {
+			replacer = append(replacer, home, "~")
+		}
+	}
+	return strings.NewReplacer(replacer...).Replace(s)
+}
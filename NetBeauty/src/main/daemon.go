@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+
+	server "github.com/nulastudio/NetBeauty/src/server"
+)
+
+// runDaemon 在--grpc、--rpc及--http上提供服务，任一服务停止时返回
+func runDaemon() error {
+	if grpcAddr == "" && rpcAddr == "" && httpAddr == "" {
+		return errors.New("one of --grpc, --rpc or --http is required")
+	}
+
+	errs := make(chan error, 3)
+	if grpcAddr != "" {
+		go func() { errs <- server.ServeGRPC(grpcAddr) }()
+	}
+	if rpcAddr != "" {
+		go func() { errs <- server.ServeRPC(rpcAddr) }()
+	}
+	if httpAddr != "" {
+		go func() { errs <- server.ServeHTTP(httpAddr) }()
+	}
+	return <-errs
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// deprecation 已废弃的用法及替代用法
+type deprecation struct {
+	usage       string
+	replacement string
+	used        func() bool
+}
+
+var deprecations = []deprecation{
+	{"--usepatch", "--strategy=patch", func() bool { return isFlagSet("usepatch") }},
+	{"--strategy=probing", "--strategy=hook", func() bool { return usesStrategy("probing") }},
+}
+
+// deprecatedUsages 本次调用中使用了的废弃用法，需在flag解析后、strategy等参数规范化前检测
+var deprecatedUsages []deprecation
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func usesStrategy(name string) bool {
+	for _, s := range strings.Split(options.Strategy, ",") {
+		if strings.TrimSpace(s) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func detectDeprecations() {
+	for _, d := range deprecations {
+		if d.used() {
+			deprecatedUsages = append(deprecatedUsages, d)
+		}
+	}
+}
+
+// reportDeprecations 提示废弃用法，--failondeprecated时视为错误
+func reportDeprecations() {
+	for _, d := range deprecatedUsages {
+		message := fmt.Sprintf("%s is deprecated and will be removed in a future release, use %s instead", d.usage, d.replacement)
+		if failOnDeprecated {
+			log.LogError(errors.New(message), false)
+		} else {
+			log.LogWarning(message)
+		}
+	}
+	if failOnDeprecated && len(deprecatedUsages) != 0 {
+		log.LogPanic(fmt.Errorf("%d deprecated usage(s) found (--failondeprecated)", len(deprecatedUsages)), 1)
+	}
+}
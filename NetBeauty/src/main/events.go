@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	event "github.com/nulastudio/NetBeauty/src/event"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// openEventFile 打开--eventfile，"-"为stderr（stdout用于日志）
+func openEventFile(file string) {
+	if file == "" {
+		return
+	}
+	if file == "-" {
+		event.SetOutput(os.Stderr)
+		return
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		log.LogPanic(fmt.Errorf("cannot open event file: %s : %s", file, err.Error()), 1)
+	}
+	event.SetOutput(f)
+}
+
+// newRunID 生成随机的运行ID
+func newRunID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
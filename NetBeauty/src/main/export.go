@@ -0,0 +1,335 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+// exporters export子命令支持的格式
+var exporters = map[string]func(dir string, libsDir string, w io.Writer) error{
+	"wix":       exportWix,
+	"innosetup": exportInnoSetup,
+	"msix":      exportMSIX,
+	"deb":       exportDeb,
+	"rpm":       exportRPM,
+}
+
+// exportLayout 将beauty后的目录结构导出为安装包工具的文件列表，out为空时输出到stdout
+func exportLayout(format string, dir string, out string) error {
+	exporter, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+
+	marker, err := manager.ReadBeautyMarker(dir)
+	if err != nil {
+		return fmt.Errorf("%s has not been beautified yet, run nbeauty on it first", dir)
+	}
+
+	if out == "" {
+		return exporter(dir, marker.LibsDir, os.Stdout)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	err = exporter(dir, marker.LibsDir, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+	}
+	return err
+}
+
+// layoutEntry 目录中的一个文件或子目录
+type layoutEntry struct {
+	name    string
+	rel     string
+	isDir   bool
+	mode    os.FileMode
+	entries []layoutEntry
+}
+
+// readLayout 读取dir的目录结构，不包含nbeauty自身的标记文件、journal、revert journal及空目录
+func readLayout(root string, rel string) ([]layoutEntry, error) {
+	fis, err := ioutil.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]layoutEntry, 0, len(fis))
+	for _, fi := range fis {
+		name := fi.Name()
+		if rel == "" && (name == manager.BeautyMarkerName || name == beauty.JournalName || name == beauty.LockName || strings.HasPrefix(name, beauty.RevertJournalName)) {
+			continue
+		}
+
+		entry := layoutEntry{name: name, rel: strings.TrimPrefix(rel+"/"+name, "/"), isDir: fi.IsDir(), mode: fi.Mode()}
+		if entry.isDir {
+			if entry.entries, err = readLayout(root, entry.rel); err != nil {
+				return nil, err
+			}
+			if len(entry.entries) == 0 {
+				continue
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// layoutFiles 按目录顺序展开所有文件
+func layoutFiles(entries []layoutEntry) []layoutEntry {
+	files := make([]layoutEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.isDir {
+			files = append(files, layoutFiles(entry.entries)...)
+		} else {
+			files = append(files, entry)
+		}
+	}
+	return files
+}
+
+// wixID 由相对路径生成稳定的WiX标识符，与heat的做法一致
+func wixID(prefix string, rel string) string {
+	sum := md5.Sum([]byte(strings.ToLower(rel)))
+	return prefix + strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+type wixFile struct {
+	ID      string `xml:"Id,attr"`
+	Source  string `xml:"Source,attr"`
+	KeyPath string `xml:"KeyPath,attr"`
+}
+
+type wixComponent struct {
+	ID   string  `xml:"Id,attr"`
+	GUID string  `xml:"Guid,attr"`
+	File wixFile `xml:"File"`
+}
+
+type wixDirectory struct {
+	XMLName     xml.Name
+	ID          string         `xml:"Id,attr"`
+	Name        string         `xml:"Name,attr,omitempty"`
+	Components  []wixComponent `xml:"Component"`
+	Directories []wixDirectory `xml:"Directory"`
+}
+
+type wixComponentRef struct {
+	ID string `xml:"Id,attr"`
+}
+
+type wixComponentGroup struct {
+	ID   string            `xml:"Id,attr"`
+	Refs []wixComponentRef `xml:"ComponentRef"`
+}
+
+type wixFragment struct {
+	DirectoryRef   *wixDirectory      `xml:"DirectoryRef,omitempty"`
+	ComponentGroup *wixComponentGroup `xml:"ComponentGroup,omitempty"`
+}
+
+type wixDocument struct {
+	XMLName   xml.Name      `xml:"Wix"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Fragments []wixFragment `xml:"Fragment"`
+}
+
+// wixDirectoryOf 将entries转为Directory及Component，refs收集所有Component的Id
+func wixDirectoryOf(dir *wixDirectory, entries []layoutEntry, refs *[]wixComponentRef) {
+	for _, entry := range entries {
+		if entry.isDir {
+			sub := wixDirectory{XMLName: xml.Name{Local: "Directory"}, ID: wixID("dir", entry.rel), Name: entry.name}
+			wixDirectoryOf(&sub, entry.entries, refs)
+			dir.Directories = append(dir.Directories, sub)
+			continue
+		}
+
+		component := wixComponent{
+			ID:   wixID("cmp", entry.rel),
+			GUID: "*",
+			File: wixFile{
+				ID:      wixID("fil", entry.rel),
+				Source:  `$(var.BeautyDir)\` + strings.Replace(entry.rel, "/", `\`, -1),
+				KeyPath: "yes",
+			},
+		}
+		dir.Components = append(dir.Components, component)
+		*refs = append(*refs, wixComponentRef{ID: component.ID})
+	}
+}
+
+// exportWix 输出WiX v3的Fragment：INSTALLFOLDER下的目录结构及名为BeautyComponents的ComponentGroup
+// 源文件路径为$(var.BeautyDir)\...，由安装包工程定义BeautyDir
+func exportWix(dir string, libsDir string, w io.Writer) error {
+	entries, err := readLayout(dir, "")
+	if err != nil {
+		return err
+	}
+
+	root := &wixDirectory{XMLName: xml.Name{Local: "DirectoryRef"}, ID: "INSTALLFOLDER"}
+	refs := make([]wixComponentRef, 0)
+	wixDirectoryOf(root, entries, &refs)
+
+	document := wixDocument{
+		Xmlns: "http://schemas.microsoft.com/wix/2006/wi",
+		Fragments: []wixFragment{
+			{DirectoryRef: root},
+			{ComponentGroup: &wixComponentGroup{ID: "BeautyComponents", Refs: refs}},
+		},
+	}
+
+	io.WriteString(w, xml.Header)
+	fmt.Fprintf(w, "<!-- generated by \"nbeauty export wix\" %s, define BeautyDir as the beautified publish directory -->\n", beauty.Version)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// exportInnoSetup 输出Inno Setup的[Files]段：根目录的文件逐个列出，libsDir及其他子目录整体递归
+// 源文件路径为{#BeautyDir}\...，由安装脚本#define BeautyDir
+func exportInnoSetup(dir string, libsDir string, w io.Writer) error {
+	entries, err := readLayout(dir, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "; generated by \"nbeauty export innosetup\" %s, #define BeautyDir as the beautified publish directory\n", beauty.Version)
+	fmt.Fprintln(w, "[Files]")
+	for _, entry := range entries {
+		if entry.isDir {
+			continue
+		}
+		fmt.Fprintf(w, "Source: \"{#BeautyDir}\\%s\"; DestDir: \"{app}\"; Flags: ignoreversion\n", entry.name)
+	}
+	for _, entry := range entries {
+		if !entry.isDir {
+			continue
+		}
+		if entry.name == libsDir {
+			fmt.Fprintln(w, "; dependencies moved by nbeauty")
+		}
+		fmt.Fprintf(w, "Source: \"{#BeautyDir}\\%s\\*\"; DestDir: \"{app}\\%s\"; Flags: ignoreversion recursesubdirs createallsubdirs\n", entry.name, entry.name)
+	}
+	return nil
+}
+
+// exportMSIX 输出MakeAppx的映射文件（makeappx pack /f），AppxManifest.xml需另外加入
+func exportMSIX(dir string, libsDir string, w io.Writer) error {
+	entries, err := readLayout(dir, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "[Files]")
+	for _, entry := range layoutFiles(entries) {
+		source := filepath.Join(dir, filepath.FromSlash(entry.rel))
+		if _, err := fmt.Fprintf(w, "\"%s\" \"%s\"\n", source, strings.Replace(entry.rel, "/", `\`, -1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linuxPackageLayout deb/rpm中应用的安装位置：/usr/lib/<app>，apphost存在时链接到/usr/bin/<app>
+func linuxPackageLayout(dir string) (app string, libDir string, bin string, err error) {
+	deps := manager.FindDepsJSON(dir)
+	if len(deps) == 0 {
+		return "", "", "", fmt.Errorf("no deps.json found in %s", dir)
+	}
+	app = strings.TrimSuffix(filepath.Base(deps[0]), ".deps.json")
+	libDir = "/usr/lib/" + app
+	if fi, err := os.Stat(filepath.Join(dir, app)); err == nil && !fi.IsDir() && fi.Mode()&0111 != 0 {
+		bin = "/usr/bin/" + app
+	}
+	return app, libDir, bin, nil
+}
+
+// exportDeb 输出debian/<app>.install，/usr/bin的链接需写入debian/<app>.links
+func exportDeb(dir string, libsDir string, w io.Writer) error {
+	app, libDir, bin, err := linuxPackageLayout(dir)
+	if err != nil {
+		return err
+	}
+	entries, err := readLayout(dir, "")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# debian/%s.install, generated by \"nbeauty export deb\" %s\n", app, beauty.Version)
+	if bin != "" {
+		fmt.Fprintf(w, "# add to debian/%s.links:\n#   %s %s\n", app, libDir[1:]+"/"+app, bin[1:])
+	}
+	for _, entry := range layoutFiles(entries) {
+		dest := path.Join(libDir, path.Dir(entry.rel))
+		if _, err := fmt.Fprintf(w, "%s %s/\n", filepath.Join(dir, filepath.FromSlash(entry.rel)), dest[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportRPM 输出rpm spec的%install及%files段，%files包含整个/usr/lib/<app>
+func exportRPM(dir string, libsDir string, w io.Writer) error {
+	app, libDir, bin, err := linuxPackageLayout(dir)
+	if err != nil {
+		return err
+	}
+	entries, err := readLayout(dir, "")
+	if err != nil {
+		return err
+	}
+	files := layoutFiles(entries)
+
+	fmt.Fprintf(w, "# spec sections for %s, generated by \"nbeauty export rpm\" %s\n", app, beauty.Version)
+	fmt.Fprintf(w, "# the dependencies moved by nbeauty are installed into %s\n", path.Join(libDir, libsDir))
+	io.WriteString(w, "%install\n")
+	dirs := []string{libDir}
+	created := map[string]bool{libDir: true}
+	for _, entry := range files {
+		if d := path.Join(libDir, path.Dir(entry.rel)); !created[d] {
+			created[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	for _, d := range dirs {
+		fmt.Fprintf(w, "mkdir -p \"%%{buildroot}%s\"\n", d)
+	}
+	for _, entry := range files {
+		mode := "0644"
+		if entry.mode&0111 != 0 {
+			mode = "0755"
+		}
+		fmt.Fprintf(w, "install -m %s \"%s\" \"%%{buildroot}%s\"\n", mode, filepath.Join(dir, filepath.FromSlash(entry.rel)), path.Join(libDir, entry.rel))
+	}
+	if bin != "" {
+		fmt.Fprintf(w, "mkdir -p \"%%{buildroot}/usr/bin\"\nln -s \"%s/%s\" \"%%{buildroot}%s\"\n", libDir, app, bin)
+	}
+
+	io.WriteString(w, "\n%files\n")
+	fmt.Fprintf(w, "\"%s\"\n", libDir)
+	if bin != "" {
+		fmt.Fprintf(w, "\"%s\"\n", bin)
+	}
+	return nil
+}
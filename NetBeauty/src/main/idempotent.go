@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// assertIdempotent 复制beautyDir，beauty一次后再以--force beauty一次，逐字节比较两次的结果及权限
+// 原目录不会被修改，两次结果不一致时保留副本供排查
+func assertIdempotent(opts beauty.Options) error {
+	if opts.Archive != "" {
+		return errors.New("--assert-idempotent does not support --archive")
+	}
+	libs := filepath.Clean(strings.Trim(opts.LibsDir, `"`))
+	if filepath.IsAbs(libs) || libs == ".." || strings.HasPrefix(libs, ".."+string(filepath.Separator)) {
+		return errors.New("--assert-idempotent requires libsDir to be inside <beautyDir>")
+	}
+	// 只比较目录本身，不生成其他位置的输出
+	opts.DockerSplit, opts.AppDir, opts.ReportFile, opts.SBOM = "", "", "", ""
+
+	// 两次写入标记文件的时间相同，未设置SOURCE_DATE_EPOCH时固定为现在
+	if os.Getenv("SOURCE_DATE_EPOCH") == "" {
+		os.Setenv("SOURCE_DATE_EPOCH", strconv.FormatInt(time.Now().Unix(), 10))
+		defer os.Unsetenv("SOURCE_DATE_EPOCH")
+	}
+
+	work, err := ioutil.TempDir("", "nbeauty-idempotent")
+	if err != nil {
+		return err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			os.RemoveAll(work)
+		}
+	}()
+
+	first, second := filepath.Join(work, "first"), filepath.Join(work, "second")
+	if err := copyDir(strings.Trim(opts.Dir, `"`), first); err != nil {
+		return err
+	}
+	opts.Dir = first
+	if _, err := beauty.Beautify(context.Background(), opts); err != nil {
+		return fmt.Errorf("the first run failed: %s", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	before, err := layoutSnapshot(first)
+	if err != nil {
+		return err
+	}
+
+	if err := copyDir(first, second); err != nil {
+		return err
+	}
+	opts.Dir = second
+	opts.Force = true
+	if _, err := beauty.Beautify(context.Background(), opts); err != nil {
+		return fmt.Errorf("the second run failed: %s", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	after, err := layoutSnapshot(second)
+	if err != nil {
+		return err
+	}
+
+	diffs := diffSnapshots(before, after)
+	if len(diffs) != 0 {
+		keep = true
+		return fmt.Errorf("beauty is not idempotent, %d difference(s) between %s and %s:\n  - %s", len(diffs), first, second, strings.Join(diffs, "\n  - "))
+	}
+	return nil
+}
+
+// layoutSnapshot 目录下每个文件（目录以/结尾）的权限及hash
+func layoutSnapshot(dir string) (map[string]string, error) {
+	snapshot := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// --force时revert journal会追加本次的记录
+		if strings.HasPrefix(rel, beauty.RevertJournalName) {
+			return nil
+		}
+		if fi.IsDir() {
+			snapshot[rel+"/"] = fi.Mode().String()
+			return nil
+		}
+		hash, err := util.GetFileSHA256(path)
+		snapshot[rel] = fi.Mode().String() + " " + hash
+		return err
+	})
+	return snapshot, err
+}
+
+// diffSnapshots 按路径排序列出两次结果的差异
+func diffSnapshots(before map[string]string, after map[string]string) []string {
+	diffs := make([]string, 0)
+	for rel, hash := range before {
+		if other, ok := after[rel]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s disappeared in the second run", rel))
+		} else if strings.SplitN(other, " ", 2)[0] != strings.SplitN(hash, " ", 2)[0] {
+			diffs = append(diffs, fmt.Sprintf("%s changed its mode in the second run", rel))
+		} else if other != hash {
+			diffs = append(diffs, fmt.Sprintf("%s changed in the second run", rel))
+		}
+	}
+	for rel := range after {
+		if _, ok := before[rel]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%s appeared in the second run", rel))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
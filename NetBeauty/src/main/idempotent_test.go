@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+func init() {
+	// 测试不访问网络
+	policy := manager.DefaultNetworkPolicy()
+	policy.NoNetwork = true
+	manager.SetNetworkPolicy(policy)
+}
+
+func TestAssertIdempotent(t *testing.T) {
+	cases := []struct {
+		name    string
+		fixture string
+		srm     bool
+	}{
+		{"framework-dependent", "fdd", false},
+		{"self-contained", "scd", false},
+		{"shared runtime", "fdd", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			opts := beauty.DefaultOptions()
+			opts.Dir = filepath.Join("..", "testdata", c.fixture)
+			opts.LibsDir = "libs"
+			opts.SharedRuntimeMode = c.srm
+			opts.Logger = log.HandlerFunc(func(string, log.LogLevel, log.Fields) {})
+			if err := assertIdempotent(opts); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAssertIdempotentDetectsDifferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbeauty-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"a.dll", "b.dll", "c.dll"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before, err := layoutSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "a.dll"), []byte("changed"), 0644)
+	os.Chmod(filepath.Join(dir, "b.dll"), 0755)
+	os.Remove(filepath.Join(dir, "c.dll"))
+	after, err := layoutSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"a.dll changed in the second run",
+		"b.dll changed its mode in the second run",
+		"c.dll disappeared in the second run",
+	}
+	diffs := diffSnapshots(before, after)
+	if len(diffs) != len(want) {
+		t.Fatalf("diffs = %v, want %v", diffs, want)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("diffs[%d] = %s, want %s", i, diffs[i], want[i])
+		}
+	}
+}
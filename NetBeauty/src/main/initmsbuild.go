@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// msbuildTargetsName init msbuild生成的文件名
+const msbuildTargetsName = "NetCoreBeauty.targets"
+
+// msbuildTargets 在dotnet publish之后调用nbeauty，属性与命令行参数一一对应
+const msbuildTargets = `<Project>
+  <!-- generated by "nbeauty init msbuild", import it in your *.csproj:
+       <Import Project="NetCoreBeauty.targets" /> -->
+  <PropertyGroup>
+    <BeautyBin Condition="'$(BeautyBin)' == ''">{{BIN}}</BeautyBin>
+    <!-- beauty into sub-directory, default is libraries -->
+    <BeautyLibsDir Condition="'$(BeautyLibsDir)' == ''">libraries</BeautyLibsDir>
+    <!-- dlls that you don't want to be moved or can not be moved, e.g. dll1.dll;lib* -->
+    <BeautyExcludes Condition="'$(BeautyExcludes)' == ''"></BeautyExcludes>
+    <!-- dlls that end users never needed, so hide them -->
+    <BeautyHiddens Condition="'$(BeautyHiddens)' == ''"></BeautyHiddens>
+    <!-- hook|patch|apphost|none, multiple strategies separated with "," -->
+    <BeautyStrategy Condition="'$(BeautyStrategy)' == ''">hook</BeautyStrategy>
+    <BeautySharedRuntimeMode Condition="'$(BeautySharedRuntimeMode)' == ''">False</BeautySharedRuntimeMode>
+    <BeautyEnableDebugging Condition="'$(BeautyEnableDebugging)' == ''">False</BeautyEnableDebugging>
+    <!-- Error|Warning|Detail|Info -->
+    <BeautyLogLevel Condition="'$(BeautyLogLevel)' == ''">Info</BeautyLogLevel>
+    <BeautyGitCDN Condition="'$(BeautyGitCDN)' == ''"></BeautyGitCDN>
+    <BeautyGitTree Condition="'$(BeautyGitTree)' == ''"></BeautyGitTree>
+    <!-- set to True if you want to disable -->
+    <DisableBeauty Condition="'$(DisableBeauty)' == ''">False</DisableBeauty>
+  </PropertyGroup>
+
+  <Target Name="NetCoreBeautyAfterPublish" AfterTargets="Publish" Condition="'$(DisableBeauty)' != 'True'">
+    <ItemGroup>
+      <_NetCoreBeautyPublishDir Include="$(PublishDir)" />
+    </ItemGroup>
+
+    <PropertyGroup>
+      <_NetCoreBeautyArgs>--strategy "$(BeautyStrategy)" --loglevel $(BeautyLogLevel)</_NetCoreBeautyArgs>
+      <_NetCoreBeautyArgs Condition="'$(BeautySharedRuntimeMode)' == 'True'">$(_NetCoreBeautyArgs) --srmode</_NetCoreBeautyArgs>
+      <_NetCoreBeautyArgs Condition="'$(BeautyEnableDebugging)' == 'True'">$(_NetCoreBeautyArgs) --enabledebug</_NetCoreBeautyArgs>
+      <_NetCoreBeautyArgs Condition="'$(BeautyHiddens)' != ''">$(_NetCoreBeautyArgs) --hiddens "$(BeautyHiddens)"</_NetCoreBeautyArgs>
+      <_NetCoreBeautyArgs Condition="'$(BeautyGitCDN)' != ''">$(_NetCoreBeautyArgs) --gitcdn "$(BeautyGitCDN)"</_NetCoreBeautyArgs>
+      <_NetCoreBeautyArgs Condition="'$(BeautyGitTree)' != ''">$(_NetCoreBeautyArgs) --gittree "$(BeautyGitTree)"</_NetCoreBeautyArgs>
+      <_NetCoreBeautyDir>"%(_NetCoreBeautyPublishDir.FullPath)/."</_NetCoreBeautyDir>
+      <_NetCoreBeautyExcludes Condition="'$(BeautyExcludes)' != ''">"$(BeautyExcludes)"</_NetCoreBeautyExcludes>
+    </PropertyGroup>
+
+    <Exec Command="&quot;$(BeautyBin)&quot; $(_NetCoreBeautyArgs) $(_NetCoreBeautyDir) &quot;$(BeautyLibsDir)&quot; $(_NetCoreBeautyExcludes)" />
+  </Target>
+</Project>
+`
+
+// initMSBuild 在dir下生成NetCoreBeauty.targets，已存在时不覆盖
+func initMSBuild(dir string) (string, error) {
+	target := filepath.Join(dir, msbuildTargetsName)
+	if util.PathExists(target) {
+		return "", fmt.Errorf("%s already exists, delete it first to regenerate", target)
+	}
+
+	bin, err := os.Executable()
+	if err != nil {
+		bin = "nbeauty"
+	}
+
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(bin))
+
+	content := strings.Replace(msbuildTargets, "{{BIN}}", escaped.String(), 1)
+	if err := ioutil.WriteFile(target, []byte(content), util.FileMode); err != nil {
+		return "", err
+	}
+	return target, nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// init script生成的脚本文件名
+const (
+	bashScriptName = "nbeauty-publish.sh"
+	pwshScriptName = "nbeauty-publish.ps1"
+)
+
+// bashScript dotnet publish之后运行的bash脚本，{{DIRS}}为生成时找到的发布目录
+const bashScript = `#!/usr/bin/env bash
+# generated by "nbeauty init script", run it after dotnet publish:
+#   ./` + bashScriptName + ` [<publishDir>...]
+# the variables below can be overridden from the environment
+set -euo pipefail
+shopt -s nullglob
+
+NBEAUTY="${NBEAUTY:-{{BIN}}}"
+# beauty into sub-directory, default is libraries
+BEAUTY_LIBS_DIR="${BEAUTY_LIBS_DIR:-libraries}"
+# dlls that you don't want to be moved or can not be moved, e.g. dll1.dll;lib*
+BEAUTY_EXCLUDES="${BEAUTY_EXCLUDES:-}"
+# hook|patch|apphost|none, multiple strategies separated with ","
+BEAUTY_STRATEGY="${BEAUTY_STRATEGY:-hook}"
+
+cd "$(dirname "$0")"
+
+if ! command -v "$NBEAUTY" >/dev/null 2>&1; then
+  echo "nbeauty not found: $NBEAUTY, set NBEAUTY to its path" >&2
+  exit 1
+fi
+
+if [ $# -gt 0 ]; then
+  dirs=("$@")
+else
+  dirs=({{DIRS}})
+fi
+if [ ${#dirs[@]} -eq 0 ]; then
+  echo "no publish folder found, run dotnet publish first or pass the folders as arguments" >&2
+  exit 1
+fi
+
+failed=0
+for dir in "${dirs[@]}"; do
+  if [ ! -d "$dir" ]; then
+    echo "skipping $dir: not found, run dotnet publish first" >&2
+    continue
+  fi
+  echo "beautifying $dir"
+  # --strict fails the build when a dependency cannot be moved
+  if ! "$NBEAUTY" --loglevel Detail --strategy "$BEAUTY_STRATEGY" --strict "$dir" "$BEAUTY_LIBS_DIR" ${BEAUTY_EXCLUDES:+"$BEAUTY_EXCLUDES"}; then
+    echo "nbeauty failed on $dir" >&2
+    failed=1
+  fi
+done
+exit $failed
+`
+
+// pwshScript 与bashScript相同的PowerShell脚本
+const pwshScript = `# generated by "nbeauty init script", run it after dotnet publish:
+#   ./` + pwshScriptName + ` [<publishDir>...]
+# the variables below can be overridden from the environment
+param([string[]]$PublishDirs)
+$ErrorActionPreference = 'Stop'
+Set-StrictMode -Version Latest
+
+$NBeauty = if ($env:NBEAUTY) { $env:NBEAUTY } else { '{{BIN}}' }
+# beauty into sub-directory, default is libraries
+$LibsDir = if ($env:BEAUTY_LIBS_DIR) { $env:BEAUTY_LIBS_DIR } else { 'libraries' }
+# dlls that you don't want to be moved or can not be moved, e.g. dll1.dll;lib*
+$Excludes = $env:BEAUTY_EXCLUDES
+# hook|patch|apphost|none, multiple strategies separated with ","
+$Strategy = if ($env:BEAUTY_STRATEGY) { $env:BEAUTY_STRATEGY } else { 'hook' }
+
+Set-Location $PSScriptRoot
+
+if (-not (Get-Command $NBeauty -ErrorAction SilentlyContinue)) {
+    Write-Error "nbeauty not found: $NBeauty, set NBEAUTY to its path"
+}
+
+if (-not $PublishDirs) {
+    $PublishDirs = @(Resolve-Path -Relative {{DIRS}} -ErrorAction SilentlyContinue)
+}
+if (-not $PublishDirs) {
+    Write-Error "no publish folder found, run dotnet publish first or pass the folders as arguments"
+}
+
+$failed = $false
+foreach ($dir in $PublishDirs) {
+    if (-not (Test-Path $dir -PathType Container)) {
+        Write-Warning "skipping ${dir}: not found, run dotnet publish first"
+        continue
+    }
+    Write-Host "beautifying $dir"
+    # --strict fails the build when a dependency cannot be moved
+    $nbeautyArgs = @('--loglevel', 'Detail', '--strategy', $Strategy, '--strict', $dir, $LibsDir)
+    if ($Excludes) {
+        $nbeautyArgs += $Excludes
+    }
+    & $NBeauty @nbeautyArgs
+    if ($LASTEXITCODE -ne 0) {
+        Write-Warning "nbeauty failed on $dir"
+        $failed = $true
+    }
+}
+if ($failed) {
+    exit 1
+}
+`
+
+// defaultScriptShell init script未指定--shell时的默认值
+func defaultScriptShell() string {
+	if runtime.GOOS == "windows" {
+		return "pwsh"
+	}
+	return "bash"
+}
+
+// findPublishDirs 查找dir/bin下dotnet publish的输出目录，返回"/"分隔的相对路径
+func findPublishDirs(dir string) []string {
+	dirs := make([]string, 0)
+	filepath.Walk(filepath.Join(dir, "bin"), func(path string, fi os.FileInfo, err error) error {
+		if err != nil || !fi.IsDir() || fi.Name() != "publish" {
+			return nil
+		}
+		if deps, _ := filepath.Glob(filepath.Join(path, "*.deps.json")); len(deps) != 0 {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				dirs = append(dirs, filepath.ToSlash(rel))
+			}
+		}
+		return filepath.SkipDir
+	})
+	sort.Strings(dirs)
+	return dirs
+}
+
+// initScript 在dir下生成发布后运行的bash/pwsh脚本，已存在时不覆盖
+func initScript(dir string, shell string) (string, error) {
+	var name, content, separator string
+	var quote, quoteBin func(string) string
+	switch shell {
+	case "bash":
+		name, content, separator = bashScriptName, bashScript, " "
+		quote = func(s string) string { return "'" + strings.Replace(s, "'", `'\''`, -1) + "'" }
+		// 位于双引号中
+		quoteBin = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace
+	case "pwsh":
+		name, content, separator = pwshScriptName, pwshScript, ", "
+		quote = func(s string) string { return "'" + strings.Replace(s, "'", "''", -1) + "'" }
+		// 位于单引号中
+		quoteBin = func(s string) string { return strings.Replace(s, "'", "''", -1) }
+	default:
+		return "", fmt.Errorf("invalid shell: %s, valid values: bash/pwsh", shell)
+	}
+
+	target := filepath.Join(dir, name)
+	if util.PathExists(target) {
+		return "", fmt.Errorf("%s already exists, delete it first to regenerate", target)
+	}
+
+	bin, err := os.Executable()
+	if err != nil {
+		bin = "nbeauty"
+	}
+
+	dirs := make([]string, 0)
+	for _, d := range findPublishDirs(dir) {
+		dirs = append(dirs, quote(d))
+	}
+	// 生成时还没有发布过，运行时再按默认的发布目录查找
+	if len(dirs) == 0 {
+		for _, d := range []string{"bin/Release/*/publish", "bin/Release/*/*/publish"} {
+			if shell == "pwsh" {
+				d = quote(d)
+			}
+			dirs = append(dirs, d)
+		}
+	}
+
+	content = strings.Replace(content, "{{BIN}}", quoteBin(bin), 1)
+	content = strings.Replace(content, "{{DIRS}}", strings.Join(dirs, separator), 1)
+	if err := ioutil.WriteFile(target, []byte(content), 0755); err != nil {
+		return "", err
+	}
+	return target, nil
+}
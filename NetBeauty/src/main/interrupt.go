@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// runContext 返回beauty使用的context，Ctrl-C或超过--timeout时取消
+// 取消后beauty会在下一个文件处停下，再次Ctrl-C则立即退出
+func runContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeoutDuration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeoutDuration)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupt; !ok {
+			return
+		}
+		log.LogWarning("interrupted, stopping at the next file, press Ctrl-C again to quit immediately")
+		cancel()
+		if _, ok := <-interrupt; ok {
+			os.Exit(130)
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupt)
+		close(interrupt)
+		cancel()
+	}
+}
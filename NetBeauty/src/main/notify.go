@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+var notifyURL = ""
+
+// notifyWebhook 运行结束后按网络策略将结果POST到--notifyurl，失败只输出警告，不影响退出码
+func notifyWebhook(run runResult) {
+	if notifyURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(run)
+	if err != nil {
+		log.LogWarning(fmt.Sprintf("notify %s failed: %s", notifyURL, err.Error()))
+		return
+	}
+
+	request, err := http.NewRequest("POST", notifyURL, bytes.NewReader(body))
+	if err != nil {
+		log.LogWarning(fmt.Sprintf("notify %s failed: %s", notifyURL, err.Error()))
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "nbeauty/"+beauty.Version)
+
+	response, err := manager.DoRequest(context.Background(), request)
+	if err != nil {
+		log.LogWarning(fmt.Sprintf("notify %s failed: %s", notifyURL, err.Error()))
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		log.LogWarning(fmt.Sprintf("notify %s failed: %s", notifyURL, response.Status))
+		return
+	}
+	log.LogDetail(fmt.Sprintf("result posted to %s", notifyURL))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+const (
+	toolPackageID   = "nulastudio.nbeauty"
+	toolCommandName = "nbeauty2"
+	toolFramework   = "netcoreapp3.0"
+	toolEntryPoint  = "NetBeautyGlobalTool.dll"
+)
+
+// toolRIDs 全局工具的启动程序（NetBeautyGlobalTool）支持的RID
+var toolRIDs = []string{"win-x86", "win-x64", "linux-x64", "linux-arm64", "osx-x64", "osx-arm64"}
+
+const toolNuspec = `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://schemas.microsoft.com/packaging/2013/05/nuspec.xsd">
+  <metadata>
+    <id>%s</id>
+    <version>%s</version>
+    <authors>LiesAuer</authors>
+    <license type="expression">MIT</license>
+    <projectUrl>https://github.com/nulastudio/NetBeauty2</projectUrl>
+    <description>Move a .NET Framework/.NET Core app runtime components and dependencies into a sub-directory and make it beauty.</description>
+    <packageTypes>
+      <packageType name="DotnetTool" />
+    </packageTypes>
+  </metadata>
+</package>
+`
+
+const toolSettings = `<?xml version="1.0" encoding="utf-8"?>
+<DotNetCliTool Version="1">
+  <Commands>
+    <Command Name="%s" EntryPoint="%s" Runner="dotnet" />
+  </Commands>
+</DotNetCliTool>
+`
+
+const toolContentTypes = `<?xml version="1.0" encoding="utf-8"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+  <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml" />
+  <Default Extension="nuspec" ContentType="application/octet" />
+  <Default Extension="dll" ContentType="application/octet" />
+  <Default Extension="json" ContentType="application/octet" />
+  <Default Extension="xml" ContentType="application/octet" />
+  <Default Extension="exe" ContentType="application/octet" />
+  <Default Extension="" ContentType="application/octet" />
+</Types>
+`
+
+const toolRels = `<?xml version="1.0" encoding="utf-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+  <Relationship Type="http://schemas.microsoft.com/packaging/2010/07/manifest" Target="/%s.nuspec" Id="R0" />
+</Relationships>
+`
+
+// packTool 将各RID的nbeauty及编译好的NetBeautyGlobalTool打包为dotnet tool的nupkg
+// toolsDir为make的输出目录（<rid>/nbeauty2[.exe]），shimDir为NetBeautyGlobalTool的编译输出目录
+func packTool(toolsDir string, shimDir string, outDir string) (string, error) {
+	if toolsDir == "" || shimDir == "" {
+		return "", errors.New("--packtools and --packshim are required")
+	}
+	if !util.PathExists(filepath.Join(shimDir, toolEntryPoint)) {
+		return "", fmt.Errorf("%s not found in %s, build NetBeautyGlobalTool first", toolEntryPoint, shimDir)
+	}
+
+	if outDir == "" {
+		outDir = workingDir
+	}
+	if !util.EnsureDirExists(outDir, util.DirMode) {
+		return "", fmt.Errorf("cannot create %s", outDir)
+	}
+	nupkg := filepath.Join(outDir, fmt.Sprintf("%s.%s.nupkg", toolPackageID, beauty.Version))
+
+	f, err := os.Create(nupkg)
+	if err != nil {
+		return "", err
+	}
+
+	w := zip.NewWriter(f)
+	err = writeToolPackage(w, toolsDir, shimDir)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	f.Close()
+	if err != nil {
+		os.Remove(nupkg)
+		return "", err
+	}
+	return nupkg, nil
+}
+
+// writeToolPackage 写入nupkg的内容
+func writeToolPackage(w *zip.Writer, toolsDir string, shimDir string) error {
+	add := func(name string, content []byte, mode os.FileMode) error {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		header.SetModTime(time.Now())
+		header.SetMode(mode)
+		entry, err := w.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = entry.Write(content)
+		return err
+	}
+	addFile := func(name string, file string) error {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if fi, err := os.Stat(file); err == nil && fi.Mode()&0111 != 0 {
+			mode = 0755
+		}
+		return add(name, content, mode)
+	}
+
+	entryDir := fmt.Sprintf("tools/%s/any/", toolFramework)
+	files := map[string][]byte{
+		toolPackageID + ".nuspec":           []byte(fmt.Sprintf(toolNuspec, toolPackageID, beauty.Version)),
+		entryDir + "DotnetToolSettings.xml": []byte(fmt.Sprintf(toolSettings, toolCommandName, toolEntryPoint)),
+		"[Content_Types].xml":               []byte(toolContentTypes),
+		"_rels/.rels":                       []byte(fmt.Sprintf(toolRels, toolPackageID)),
+	}
+	for name, content := range files {
+		if err := add(name, content, 0644); err != nil {
+			return err
+		}
+	}
+
+	// 启动程序的所有文件（dll、deps.json、runtimeconfig.json）
+	shimFiles, err := ioutil.ReadDir(shimDir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range shimFiles {
+		if fi.IsDir() || strings.HasSuffix(fi.Name(), ".pdb") {
+			continue
+		}
+		if err := addFile(entryDir+fi.Name(), filepath.Join(shimDir, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	// 启动程序从tools/nbeauty/<rid>/中按当前系统选择nbeauty
+	packed := 0
+	for _, rid := range toolRIDs {
+		bin := toolCommandName
+		if strings.HasPrefix(rid, "win-") {
+			bin += ".exe"
+		}
+		src := filepath.Join(toolsDir, rid, bin)
+		if !util.PathExists(src) {
+			log.LogWarning(fmt.Sprintf("%s not found, %s is not supported by the package", src, rid))
+			continue
+		}
+		if err := addFile("tools/nbeauty/"+rid+"/"+bin, src); err != nil {
+			return err
+		}
+		packed++
+	}
+	if packed == 0 {
+		return fmt.Errorf("no nbeauty binary found in %s", toolsDir)
+	}
+
+	return nil
+}
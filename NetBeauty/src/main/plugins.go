@@ -0,0 +1,15 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultPluginDir nbeauty所在目录下的plugins
+func defaultPluginDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(exe), "plugins")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	event "github.com/nulastudio/NetBeauty/src/event"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// 只记录不小于该大小的文件
+const profileLargeFile = 1024 * 1024
+
+// profile中最多记录的文件数
+const profileMaxFiles = 20
+
+type stageTiming struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration"`
+}
+
+type fileTiming struct {
+	Path     string  `json:"path"`
+	Size     int64   `json:"size"`
+	Duration float64 `json:"duration"`
+}
+
+type downloadTiming struct {
+	URL      string  `json:"url"`
+	Bytes    int64   `json:"bytes"`
+	Duration float64 `json:"duration"`
+	started  time.Time
+}
+
+// runProfile 各阶段、大文件及下载的耗时，单位均为秒
+type runProfile struct {
+	Total     float64           `json:"total"`
+	Stages    []stageTiming     `json:"stages"`
+	Files     []fileTiming      `json:"files"`
+	Downloads []*downloadTiming `json:"downloads"`
+
+	mutex sync.Mutex
+}
+
+var profile *runProfile
+
+// startProfile 开始收集耗时，cpuProfile不为空时同时输出pprof CPU profile
+func startProfile(cpuProfile string) {
+	profile = &runProfile{Files: make([]fileTiming, 0), Downloads: make([]*downloadTiming, 0)}
+	event.Subscribe(profile.record)
+
+	if cpuProfile == "" {
+		return
+	}
+	f, err := os.Create(cpuProfile)
+	if err != nil {
+		log.LogPanic(fmt.Errorf("cannot create pprof file: %s : %s", cpuProfile, err.Error()), 1)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		log.LogPanic(fmt.Errorf("cannot start cpu profile: %s", err.Error()), 1)
+	}
+}
+
+func (p *runProfile) record(e event.Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch e.Type {
+	case event.FileMoved:
+		size, _ := e.Data["size"].(int64)
+		duration, _ := e.Data["duration"].(float64)
+		if size >= profileLargeFile {
+			p.Files = append(p.Files, fileTiming{Path: fmt.Sprint(e.Data["from"]), Size: size, Duration: duration})
+		}
+	case event.DownloadProgress:
+		url := fmt.Sprint(e.Data["url"])
+		written, _ := e.Data["written"].(int64)
+		for _, download := range p.Downloads {
+			if download.URL == url {
+				download.Bytes = written
+				download.Duration = time.Since(download.started).Seconds()
+				return
+			}
+		}
+		p.Downloads = append(p.Downloads, &downloadTiming{URL: url, Bytes: written, started: time.Now()})
+	}
+}
+
+// writeProfile 停止pprof并将profile写入文件
+func writeProfile(file string, elapsed time.Duration) {
+	pprof.StopCPUProfile()
+
+	profile.mutex.Lock()
+	defer profile.mutex.Unlock()
+
+	profile.Total = elapsed.Seconds()
+	profile.Stages = make([]stageTiming, 0)
+	for _, stage := range log.StageDurations() {
+		profile.Stages = append(profile.Stages, stageTiming{Name: stage.Name, Duration: stage.Duration.Seconds()})
+	}
+
+	sort.SliceStable(profile.Files, func(i, j int) bool {
+		return profile.Files[i].Duration > profile.Files[j].Duration
+	})
+	if len(profile.Files) > profileMaxFiles {
+		profile.Files = profile.Files[:profileMaxFiles]
+	}
+
+	bytes, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		log.LogError(fmt.Errorf("cannot encode profile: %s", err.Error()), false)
+		return
+	}
+	if err := ioutil.WriteFile(file, bytes, 0666); err != nil {
+		log.LogError(fmt.Errorf("cannot write profile: %s : %s", file, err.Error()), false)
+		return
+	}
+
+	log.LogDetail(fmt.Sprintf("profile written to %s", file))
+}
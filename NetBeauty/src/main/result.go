@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var resultFD = -1
+var resultFile = ""
+
+// runResult 一次beauty的结果，--resultfd/--resultfile写入及--notifyurl POST的内容
+type runResult struct {
+	Tool     string `json:"tool"`
+	Version  string `json:"version"`
+	RunID    string `json:"runId,omitempty"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exitCode"`
+	Error    string `json:"error,omitempty"`
+	Dir      string `json:"dir,omitempty"`
+	Archive  string `json:"archive,omitempty"`
+	LibsDir  string `json:"libsDir"`
+	Strategy string `json:"strategy,omitempty"`
+	Warnings int    `json:"warnings"`
+	Errors   int    `json:"errors"`
+	// beauty后写入的标记文件，记录了本次beauty的布局
+	Manifest string `json:"manifest,omitempty"`
+	SBOM     string `json:"sbom,omitempty"`
+
+	Result beauty.Result `json:"result"`
+}
+
+// resultStatus 与--posthook的NBEAUTY_RESULT一致，被取消时为aborted
+func resultStatus(err error, code int) string {
+	switch {
+	case code == 130:
+		return "aborted"
+	case err != nil || code != 0:
+		return "failure"
+	}
+	return "success"
+}
+
+func newRunResult(result beauty.Result, err error, code int) runResult {
+	run := runResult{
+		Tool:     "nbeauty",
+		Version:  beauty.Version,
+		RunID:    runID,
+		Status:   resultStatus(err, code),
+		ExitCode: code,
+		Dir:      options.Dir,
+		Archive:  options.Archive,
+		LibsDir:  options.LibsDir,
+		Strategy: options.Strategy,
+		Warnings: log.Count(log.Warning),
+		Errors:   log.Count(log.Error),
+		SBOM:     options.SBOM,
+		Result:   result,
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if marker := filepath.Join(options.Dir, manager.BeautyMarkerName); options.Dir != "" && util.PathExists(marker) {
+		run.Manifest = marker
+	}
+	return run
+}
+
+// finishRun 运行结束后交出结果：写入--resultfd/--resultfile并通知--notifyurl
+func finishRun(result beauty.Result, err error, code int) {
+	run := newRunResult(result, err, code)
+	if resultFD >= 0 || resultFile != "" {
+		if err := writeResult(run); err != nil {
+			log.LogWarning(fmt.Sprintf("write result failed: %s", err.Error()))
+		}
+	}
+	notifyWebhook(run)
+}
+
+// resultOutputs --resultfd及--resultfile，第一次写入时打开，--resultfile会被清空
+var resultOutputs []*os.File
+
+// writeResult 以一行json写入--resultfd及--resultfile，--stdin模式下每个目录一行
+func writeResult(run runResult) error {
+	line, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if resultOutputs == nil {
+		resultOutputs = make([]*os.File, 0, 2)
+		if resultFD >= 0 {
+			f := os.NewFile(uintptr(resultFD), "resultfd")
+			if f == nil {
+				return fmt.Errorf("invalid --resultfd %d", resultFD)
+			}
+			resultOutputs = append(resultOutputs, f)
+		}
+		if resultFile != "" {
+			f, err := os.Create(resultFile)
+			if err != nil {
+				return err
+			}
+			resultOutputs = append(resultOutputs, f)
+		}
+	}
+
+	for _, f := range resultOutputs {
+		if _, err := f.Write(line); err != nil {
+			return fmt.Errorf("%s: %s", f.Name(), err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// selfTestProject 示例项目名，即发布后的可执行文件名
+const selfTestProject = "NBSelfTest"
+
+// selfTestStrategies selftest逐个验证的策略
+var selfTestStrategies = []string{"hook", "patch", "apphost"}
+
+// selfTestSample 示例项目的模板及启动后的检查方式
+type selfTestSample struct {
+	template string
+	// 控制台程序检查输出，GUI程序检查启动后没有立即崩溃
+	gui bool
+}
+
+func selfTestSamples() []selfTestSample {
+	samples := []selfTestSample{{template: "console"}}
+	if runtime.GOOS == "windows" {
+		samples = append(samples, selfTestSample{template: "wpf", gui: true})
+	}
+	return samples
+}
+
+// currentRID 当前系统对应的RID
+func currentRID() string {
+	osName := runtime.GOOS
+	switch osName {
+	case "windows":
+		osName = "win"
+	case "darwin":
+		osName = "osx"
+	}
+	arch := runtime.GOARCH
+	switch arch {
+	case "amd64":
+		arch = "x64"
+	case "386":
+		arch = "x86"
+	}
+	return osName + "-" + arch
+}
+
+// selfTest 创建示例项目并以SCD发布，按每个策略beauty后启动，输出各策略的结果
+// fxr不为空时发布为指定的运行时版本，用于验证新版本的运行时
+func selfTest(fxr string, rid string) error {
+	dotnet, err := exec.LookPath("dotnet")
+	if err != nil {
+		return errors.New("dotnet SDK not found in PATH, selftest requires it to create the sample apps")
+	}
+	if rid == "" {
+		rid = currentRID()
+	}
+	fxr = strings.TrimPrefix(fxr, "v")
+
+	work, err := ioutil.TempDir("", "nbeauty-selftest")
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, sample := range selfTestSamples() {
+		publishDir, err := publishSample(dotnet, work, sample, fxr, rid)
+		if err != nil {
+			failed += len(selfTestStrategies)
+			fmt.Printf("%s: FAIL (%s)\n", sample.template, err.Error())
+			continue
+		}
+		for _, strategy := range selfTestStrategies {
+			name := sample.template + "/" + strategy
+			if err := selfTestStrategy(work, publishDir, sample, strategy); err != nil {
+				failed++
+				fmt.Printf("%s: FAIL (%s)\n", name, err.Error())
+			} else {
+				fmt.Printf("%s: pass\n", name)
+			}
+		}
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d selftest(s) failed, the sample apps are kept in %s", failed, work)
+	}
+	os.RemoveAll(work)
+	return nil
+}
+
+// publishSample 创建示例项目并以SCD发布，返回发布目录
+func publishSample(dotnet string, work string, sample selfTestSample, fxr string, rid string) (string, error) {
+	projectDir := filepath.Join(work, sample.template)
+	newArgs := []string{"new", sample.template, "-n", selfTestProject, "-o", projectDir}
+	if fxr != "" {
+		parts := strings.SplitN(fxr, ".", 3)
+		if len(parts) < 2 {
+			return "", fmt.Errorf("invalid runtime version: %s", fxr)
+		}
+		newArgs = append(newArgs, "--framework", "net"+parts[0]+"."+parts[1])
+	}
+	if err := runIn(work, dotnet, newArgs...); err != nil {
+		return "", fmt.Errorf("dotnet new %s failed: %s", sample.template, err.Error())
+	}
+
+	publishDir := filepath.Join(projectDir, "publish")
+	publishArgs := []string{"publish", "-c", "Release", "-r", rid, "--self-contained", "true", "-o", publishDir}
+	if fxr != "" {
+		publishArgs = append(publishArgs, "-p:RuntimeFrameworkVersion="+fxr)
+	}
+	if err := runIn(projectDir, dotnet, publishArgs...); err != nil {
+		return "", fmt.Errorf("dotnet publish failed: %s", err.Error())
+	}
+	return publishDir, nil
+}
+
+// selfTestStrategy 复制发布目录，按strategy beauty后启动
+func selfTestStrategy(work string, publishDir string, sample selfTestSample, strategy string) error {
+	dir := filepath.Join(work, sample.template+"-"+strategy)
+	if err := copyDir(publishDir, dir); err != nil {
+		return err
+	}
+
+	opts := beauty.DefaultOptions()
+	opts.Dir = dir
+	opts.Strategy = strategy
+	result, err := beauty.Beautify(context.Background(), opts)
+	if err != nil {
+		// 详细信息已输出到日志
+		return fmt.Errorf("beauty failed: %s", strings.SplitN(err.Error(), "\n", 2)[0])
+	}
+	if result.FailedFiles != 0 {
+		return fmt.Errorf("%d file(s) failed to be moved", result.FailedFiles)
+	}
+
+	exe := filepath.Join(dir, selfTestProject)
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	if sample.gui {
+		return launchGUI(exe)
+	}
+	return launchConsole(exe)
+}
+
+// launchConsole 启动控制台示例，检查退出码及模板输出的Hello World
+func launchConsole(exe string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, exe)
+	cmd.Dir = filepath.Dir(exe)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("launch failed: %s: %s", err.Error(), strings.TrimSpace(output.String()))
+	}
+	if !strings.Contains(output.String(), "Hello") {
+		return fmt.Errorf("unexpected output: %s", strings.TrimSpace(output.String()))
+	}
+	return nil
+}
+
+// launchGUI 启动GUI示例，几秒后仍在运行即认为成功
+func launchGUI(exe string) error {
+	cmd := exec.Command(exe)
+	cmd.Dir = filepath.Dir(exe)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("launch failed: %s", err.Error())
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("exited early: %s", err.Error())
+		}
+		return nil
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		<-done
+		return nil
+	}
+}
+
+// copyDir 复制目录
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			if !util.EnsureDirExists(target, util.DirMode) {
+				return fmt.Errorf("cannot create %s", target)
+			}
+			return nil
+		}
+		_, err = util.CopyFile(path, target)
+		return err
+	})
+}
@@ -0,0 +1,519 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+// DefaultStreamDepsSize 默认从多大（字节）的deps.json开始流式处理
+const DefaultStreamDepsSize = 16 * 1024 * 1024
+
+// StreamDepsSize 不小于该大小的deps.json逐项读取及改写，不整体载入内存，0表示总是流式处理
+var StreamDepsSize int64 = DefaultStreamDepsSize
+
+// StreamDeps deps.json是否需要流式处理
+func StreamDeps(deps string) bool {
+	fi, err := util.FileSystem.Stat(deps)
+	return err == nil && fi.Size() >= StreamDepsSize
+}
+
+// depsWalk 流式读取deps.json，targets/<target>下的每个library及libraries下的每一项分别解码后交给回调，
+// 回调返回true时写出修改后的项，否则原样写出
+type depsWalk struct {
+	onTarget  func(target string, library string, value map[string]interface{}) bool
+	onLibrary func(library string, value map[string]interface{}) bool
+	// 追加到targets/<target>（libraries时target为""）末尾的项，已存在的项不会追加
+	extra func(target string) map[string]interface{}
+
+	// runtimeTarget.name
+	runtimeTarget string
+}
+
+// read 只读取deps.json
+func (walk *depsWalk) read(deps string) error {
+	f, err := util.FileSystem.Open(deps)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return walk.run(bufio.NewReader(f), nil)
+}
+
+// rewrite 读取deps.json并写出改写后的内容，本地磁盘上先写入临时文件再替换
+func (walk *depsWalk) rewrite(deps string) error {
+	f, err := util.FileSystem.Open(deps)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !util.LocalDisk() {
+		var buf bytes.Buffer
+		if err := walk.run(bufio.NewReader(f), &buf); err != nil {
+			return err
+		}
+		return util.FileSystem.WriteFile(deps, buf.Bytes(), util.FileMode)
+	}
+
+	perm := util.FileMode
+	if fi, err := os.Stat(deps); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	tmp := deps + ".tmp"
+	if err := util.CheckWrite("write", tmp); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	err = walk.run(bufio.NewReader(f), out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = util.Sync(tmp)
+	}
+	f.Close()
+	if err == nil {
+		err = util.FileSystem.Rename(tmp, deps)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func (walk *depsWalk) run(r io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var writer *depsWriter
+	if out != nil {
+		writer = &depsWriter{w: bufio.NewWriter(out)}
+	}
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	writer.begin("")
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "targets":
+			err = walk.walkTargets(dec, writer)
+		case "libraries":
+			err = walk.walkObject(dec, writer, key, "")
+		default:
+			var raw json.RawMessage
+			if err = dec.Decode(&raw); err != nil {
+				break
+			}
+			if key == "runtimeTarget" {
+				var runtimeTarget struct {
+					Name string `json:"name"`
+				}
+				json.Unmarshal(raw, &runtimeTarget)
+				walk.runtimeTarget = runtimeTarget.Name
+			}
+			writer.value(key, raw)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	writer.end()
+	return writer.flush()
+}
+
+func (walk *depsWalk) walkTargets(dec *json.Decoder, writer *depsWriter) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	writer.begin("targets")
+	for dec.More() {
+		target, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		if err := walk.walkObject(dec, writer, target, target); err != nil {
+			return err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	writer.end()
+	return nil
+}
+
+// walkObject 逐项处理targets/<target>或libraries（target为""）
+func (walk *depsWalk) walkObject(dec *json.Decoder, writer *depsWriter, key string, target string) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	writer.begin(key)
+
+	callback := walk.onLibrary
+	if target != "" {
+		callback = nil
+		if walk.onTarget != nil {
+			callback = func(library string, value map[string]interface{}) bool {
+				return walk.onTarget(target, library, value)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for dec.More() {
+		library, err := readKey(dec)
+		if err != nil {
+			return err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		seen[library] = true
+
+		if callback != nil {
+			var value map[string]interface{}
+			entry := json.NewDecoder(bytes.NewReader(raw))
+			entry.UseNumber()
+			if err := entry.Decode(&value); err != nil {
+				return fmt.Errorf("%s: %s", library, err.Error())
+			}
+			if callback(library, value) && writer != nil {
+				if raw, err = json.Marshal(value); err != nil {
+					return err
+				}
+			}
+		}
+		writer.value(library, raw)
+	}
+
+	if walk.extra != nil && writer != nil {
+		extra := walk.extra(target)
+		names := make([]string, 0, len(extra))
+		for name := range extra {
+			if !seen[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			raw, err := json.Marshal(extra[name])
+			if err != nil {
+				return err
+			}
+			writer.value(name, raw)
+		}
+	}
+
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	writer.end()
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %s, got %v", delim, token)
+	}
+	return nil
+}
+
+func readKey(dec *json.Decoder) (string, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", errors.New("expected an object key")
+	}
+	return key, nil
+}
+
+// depsWriter 按EncodePretty的缩进逐项写出json对象，为nil时不写出
+type depsWriter struct {
+	w *bufio.Writer
+	// 每层对象是否还没有写出任何项
+	first []bool
+	err   error
+}
+
+func (writer *depsWriter) write(s string) {
+	if writer.err == nil {
+		_, writer.err = writer.w.WriteString(s)
+	}
+}
+
+func (writer *depsWriter) indent() string {
+	return strings.Repeat("  ", len(writer.first))
+}
+
+// item 写出下一项的分隔符、缩进及键
+func (writer *depsWriter) item(key string) {
+	if !writer.first[len(writer.first)-1] {
+		writer.write(",")
+	}
+	writer.first[len(writer.first)-1] = false
+	name, _ := json.Marshal(key)
+	writer.write("\n" + writer.indent() + string(name) + ": ")
+}
+
+// begin 开始一个对象，最外层时key被忽略
+func (writer *depsWriter) begin(key string) {
+	if writer == nil {
+		return
+	}
+	if len(writer.first) != 0 {
+		writer.item(key)
+	}
+	writer.write("{")
+	writer.first = append(writer.first, true)
+}
+
+func (writer *depsWriter) end() {
+	if writer == nil {
+		return
+	}
+	empty := writer.first[len(writer.first)-1]
+	writer.first = writer.first[:len(writer.first)-1]
+	if !empty {
+		writer.write("\n" + writer.indent())
+	}
+	writer.write("}")
+}
+
+func (writer *depsWriter) value(key string, raw []byte) {
+	if writer == nil {
+		return
+	}
+	writer.item(key)
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, writer.indent(), "  "); err != nil && writer.err == nil {
+		writer.err = err
+	}
+	writer.write(buf.String())
+}
+
+func (writer *depsWriter) flush() error {
+	if writer == nil {
+		return nil
+	}
+	if writer.err != nil {
+		return writer.err
+	}
+	return writer.w.Flush()
+}
+
+// depsEdits FixDeps对deps.json的修改，整体载入及流式改写共用
+type depsEdits struct {
+	// targets/<target>/<library>/<section>中删除及添加的项
+	removed map[string]map[string]bool
+	added   map[string]map[string]interface{}
+	// libraries下所有项的path改为"./"
+	rootLibraries bool
+}
+
+func newDepsEdits() *depsEdits {
+	return &depsEdits{
+		removed: make(map[string]map[string]bool),
+		added:   make(map[string]map[string]interface{}),
+	}
+}
+
+func depsSectionKey(target string, library string, section string) string {
+	return target + "\x00" + library + "\x00" + section
+}
+
+func (edits *depsEdits) remove(analyzed analyzedDeps) {
+	key := depsSectionKey(analyzed.Target, analyzed.Library, analyzed.Section)
+	if edits.removed[key] == nil {
+		edits.removed[key] = make(map[string]bool)
+	}
+	edits.removed[key][analyzed.ItemKey] = true
+}
+
+func (edits *depsEdits) add(analyzed analyzedDeps, itemKey string, value interface{}) {
+	key := depsSectionKey(analyzed.Target, analyzed.Library, analyzed.Section)
+	if edits.added[key] == nil {
+		edits.added[key] = make(map[string]interface{})
+	}
+	edits.added[key][itemKey] = value
+}
+
+// applyTarget 修改targets/<target>/<library>，返回是否有修改
+func (edits *depsEdits) applyTarget(target string, library string, value map[string]interface{}) bool {
+	changed := false
+	for _, section := range []string{"runtime", "resources", "native"} {
+		items, ok := value[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key := depsSectionKey(target, library, section)
+		for itemKey, item := range edits.added[key] {
+			items[itemKey] = item
+			changed = true
+		}
+		for itemKey := range edits.removed[key] {
+			delete(items, itemKey)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// applyLibrary 修改libraries/<library>，返回是否有修改
+func (edits *depsEdits) applyLibrary(library string, value map[string]interface{}) bool {
+	if !edits.rootLibraries {
+		return false
+	}
+	value["path"] = "./"
+	return true
+}
+
+// scanDepsStream 流式读取deps.json中的依赖项
+// DepsFiles deps.json引用的所有文件，为相对于deps.json所在目录、以/分隔的路径，不会修改deps.json
+func DepsFiles(deps string) ([]string, error) {
+	entries, err := scanDepsStream(deps)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		for _, file := range []string{entry.Path, entry.SecondPath} {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+func scanDepsStream(deps string) ([]analyzedDeps, error) {
+	packages := make(map[string]bool)
+	entries := make([]analyzedDeps, 0)
+	walk := &depsWalk{
+		onTarget: func(target string, library string, value map[string]interface{}) bool {
+			entries = append(entries, analyzeDepsEntry(target, library, value, false)...)
+			return false
+		},
+		onLibrary: func(library string, value map[string]interface{}) bool {
+			if value["type"] == "package" {
+				packages[library] = true
+			}
+			return false
+		},
+	}
+	if err := walk.read(deps); err != nil {
+		return nil, err
+	}
+	// libraries通常位于targets之后
+	for i := range entries {
+		entries[i].Package = packages[entries[i].Library]
+	}
+	sortAnalyzedDeps(entries)
+	return entries, nil
+}
+
+// rewriteDepsStream 流式写出应用了edits的deps.json
+func rewriteDepsStream(deps string, edits *depsEdits) error {
+	walk := &depsWalk{
+		onTarget:  edits.applyTarget,
+		onLibrary: edits.applyLibrary,
+	}
+	return walk.rewrite(deps)
+}
+
+// sortAnalyzedDeps 与整体载入时的顺序一致：target、library、文件
+func sortAnalyzedDeps(entries []analyzedDeps) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Library != b.Library {
+			return a.Library < b.Library
+		}
+		return false
+	})
+}
+
+// addStartUpHookStream 流式添加nbloader启动时钩子，与AddStartUpHookToDeps相同
+func addStartUpHookStream(deps string, hook string) error {
+	// runtimeTarget可能位于targets之后，先读出
+	scan := &depsWalk{}
+	if err := scan.read(deps); err != nil {
+		return err
+	}
+	runtimeTarget := scan.runtimeTarget
+
+	library := map[string]interface{}{
+		"type":        "project",
+		"serviceable": false,
+		"sha512":      "",
+	}
+	walk := &depsWalk{
+		onTarget: func(target string, name string, value map[string]interface{}) bool {
+			if target != runtimeTarget || name != hook {
+				return false
+			}
+			runtime, ok := value["runtime"].(map[string]interface{})
+			if !ok {
+				runtime = make(map[string]interface{})
+				value["runtime"] = runtime
+			}
+			runtime[hook+".dll"] = make(map[string]interface{})
+			return true
+		},
+		onLibrary: func(name string, value map[string]interface{}) bool {
+			if name != hook {
+				return false
+			}
+			for k := range value {
+				delete(value, k)
+			}
+			for k, v := range library {
+				value[k] = v
+			}
+			return true
+		},
+		extra: func(target string) map[string]interface{} {
+			switch target {
+			case "":
+				return map[string]interface{}{hook: library}
+			case runtimeTarget:
+				return map[string]interface{}{hook: map[string]interface{}{
+					"runtime": map[string]interface{}{hook + ".dll": make(map[string]interface{})},
+				}}
+			}
+			return nil
+		},
+	}
+	return walk.rewrite(deps)
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// depsStreamFixture 较大的deps.json，包含转义的字符串、未知的节及位于targets之后的runtimeTarget
+func depsStreamFixture(libraries int) []byte {
+	var targets, libs []string
+	for i := 0; i < libraries; i++ {
+		name := fmt.Sprintf("Lib%04d/1.0.%d", i, i)
+		targets = append(targets, fmt.Sprintf(`%q: {
+        "dependencies": { "Lib%04d": "1.0.0" },
+        "compile": { "ref/net6.0/Lib%04d.dll": {} },
+        "runtime": { "lib/net6.0/Lib%04d.dll": { "assemblyVersion": "1.0.0.0", "fileVersion": "1.0.%d.0" } },
+        "native": { "runtimes/linux-x64/native/libLib%04d.so": { "fileVersion": "0.0.0.0" } },
+        "resources": { "lib/net6.0/zh-Hans/Lib%04d.resources.dll": { "locale": "zh-Hans" } }
+      }`, name, i+1, i, i, i, i, i))
+		libs = append(libs, fmt.Sprintf(`%q: { "type": "package", "serviceable": true, "sha512": "sha512-%d", "path": "lib%04d/1.0.%d", "hashPath": "lib%04d.1.0.%d.nupkg.sha512" }`, name, i, i, i, i, i))
+	}
+
+	return []byte(`{
+  "compilationOptions": { "defines": ["TRACE", "RELEASE"], "optimize": true, "warningsAsErrors": false },
+  "x-unknown": { "nested": [1, 2.50, 1e3, -0.0, null, "tab\t\"quoted\" \\ \/ é 😀"], "empty": {} },
+  "targets": {
+    ".NETCoreApp,Version=v6.0": {
+      "app/1.0.0": { "dependencies": { "Lib0000": "1.0.0" }, "runtime": { "app.dll": {} } },
+      "Escé\"Lib/2.0.0": {
+        "runtime": { "lib\/net6.0\/Escé.dll": { "assemblyVersion": "2.0.0.0" } },
+        "runtimeTargets": { "runtimes/win/lib/net6.0/Esc.dll": { "rid": "win", "assetType": "runtime" } },
+        "x-section": { "keep": "me" }
+      },
+      ` + strings.Join(targets, ",\n      ") + `
+    },
+    ".NETCoreApp,Version=v6.0/linux-x64": {}
+  },
+  "libraries": {
+    "app/1.0.0": { "type": "project", "serviceable": false, "sha512": "" },
+    "Escé\"Lib/2.0.0": { "type": "package", "serviceable": true, "sha512": "sha512-+/=", "path": "escélib/2.0.0" },
+    ` + strings.Join(libs, ",\n    ") + `
+  },
+  "runtimeTarget": { "name": ".NETCoreApp,Version=v6.0", "signature": "" }
+}`)
+}
+
+// decodeJSON 解码json以便比较内容，数字保持原样
+func decodeJSON(t *testing.T, content []byte) interface{} {
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		t.Fatalf("invalid json: %s\n%s", err, content)
+	}
+	return value
+}
+
+func TestStreamedDepsMatchLoadedDeps(t *testing.T) {
+	defer func(size int64) { StreamDepsSize = size }(StreamDepsSize)
+
+	root, err := ioutil.TempDir("", "nbeauty-depsstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	fixture := depsStreamFixture(2000)
+
+	for _, c := range []struct {
+		name string
+		run  func(deps string) interface{}
+	}{
+		{"fixdeps", func(deps string) interface{} {
+			all, _, _ := FixDeps(deps, "app", false, false, false)
+			return all
+		}},
+		{"fixdeps with patch", func(deps string) interface{} {
+			all, _, _ := FixDeps(deps, "app", true, true, false)
+			return all
+		}},
+		{"fixdeps with shared runtime", func(deps string) interface{} {
+			all, _, _ := FixDeps(deps, "app", false, true, true)
+			return all
+		}},
+		{"startup hook", func(deps string) interface{} {
+			return AddStartUpHookToDeps(deps, "nbloader")
+		}},
+	} {
+		outputs := make(map[bool][]byte)
+		results := make(map[bool]interface{})
+		for _, stream := range []bool{false, true} {
+			dir := filepath.Join(root, fmt.Sprintf("%s-%v", strings.Replace(c.name, " ", "-", -1), stream))
+			deps := filepath.Join(dir, "app.deps.json")
+			if err := os.MkdirAll(dir, 0777); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(deps, fixture, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			StreamDepsSize = DefaultStreamDepsSize
+			if stream {
+				StreamDepsSize = 0
+			}
+			results[stream] = c.run(deps)
+			if outputs[stream], err = ioutil.ReadFile(deps); err != nil {
+				t.Fatal(err)
+			}
+			if fileOnDisk(deps + ".tmp") {
+				t.Errorf("%s: the temporary deps.json is left behind", c.name)
+			}
+		}
+
+		if !reflect.DeepEqual(results[false], results[true]) {
+			t.Errorf("%s: the streamed result differs from the loaded one", c.name)
+		}
+		if !reflect.DeepEqual(decodeJSON(t, outputs[false]), decodeJSON(t, outputs[true])) {
+			t.Errorf("%s: the streamed deps.json differs from the loaded one", c.name)
+		}
+		if bytes.Equal(outputs[true], fixture) {
+			t.Errorf("%s: deps.json has not been rewritten", c.name)
+		}
+	}
+}
+
+func TestStreamedDepsKeepUnknownSections(t *testing.T) {
+	defer func(size int64) { StreamDepsSize = size }(StreamDepsSize)
+	StreamDepsSize = 0
+
+	dir, err := ioutil.TempDir("", "nbeauty-depsstream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	deps := filepath.Join(dir, "app.deps.json")
+	fixture := depsStreamFixture(3)
+	if err := ioutil.WriteFile(deps, fixture, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	FixDeps(deps, "app", false, false, false)
+	content, err := ioutil.ReadFile(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := decodeJSON(t, fixture).(map[string]interface{})
+	after := decodeJSON(t, content).(map[string]interface{})
+	for _, key := range []string{"compilationOptions", "x-unknown", "runtimeTarget"} {
+		if !reflect.DeepEqual(before[key], after[key]) {
+			t.Errorf("%s has been changed: %v", key, after[key])
+		}
+	}
+	target := func(value map[string]interface{}) map[string]interface{} {
+		return value["targets"].(map[string]interface{})[".NETCoreApp,Version=v6.0"].(map[string]interface{})["Escé\"Lib/2.0.0"].(map[string]interface{})
+	}
+	for _, key := range []string{"runtimeTargets", "x-section"} {
+		if !reflect.DeepEqual(target(before)[key], target(after)[key]) {
+			t.Errorf("targets/.../%s has been changed: %v", key, target(after)[key])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+var sensitiveQuery = regexp.MustCompile(`(?i)token|key|sig|secret|password|auth`)
+
+// tracingTransport 记录每个请求的URL、状态码、字节数、耗时及重试次数
+type tracingTransport struct {
+	base     http.RoundTripper
+	mutex    sync.Mutex
+	attempts map[string]int
+}
+
+// EnableHTTPTrace 记录之后manager发出的所有请求
+func EnableHTTPTrace() {
+	traceHTTP = true
+	SetNetworkPolicy(policy)
+}
+
+// redactURL 去掉URL中的账号密码及敏感参数
+func redactURL(u *url.URL) string {
+	redacted := *u
+	if redacted.User != nil {
+		redacted.User = url.User("***")
+	}
+	query := redacted.Query()
+	for name := range query {
+		if sensitiveQuery.MatchString(name) {
+			query.Set(name, "***")
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return strings.Replace(redacted.String(), "%2A%2A%2A", "***", -1)
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := redactURL(req.URL)
+
+	t.mutex.Lock()
+	t.attempts[target]++
+	attempt := t.attempts[target]
+	t.mutex.Unlock()
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		log.LogDetailFields(fmt.Sprintf("http %s %s failed after %s (attempt %d): %s", req.Method, target, time.Since(start).Round(time.Millisecond), attempt, err.Error()),
+			log.Fields{"method": req.Method, "url": target, "attempt": attempt, "error": err.Error()})
+		return resp, err
+	}
+
+	resp.Body = &tracingBody{
+		ReadCloser: resp.Body,
+		method:     req.Method,
+		url:        target,
+		status:     resp.StatusCode,
+		attempt:    attempt,
+		start:      start,
+	}
+
+	return resp, nil
+}
+
+type tracingBody struct {
+	io.ReadCloser
+	method  string
+	url     string
+	status  int
+	attempt int
+	start   time.Time
+	bytes   int64
+	closed  bool
+}
+
+func (b *tracingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+func (b *tracingBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		duration := time.Since(b.start).Round(time.Millisecond)
+		log.LogDetailFields(fmt.Sprintf("http %s %s -> %d, %d bytes, %s (attempt %d)", b.method, b.url, b.status, b.bytes, duration, b.attempt),
+			log.Fields{"method": b.method, "url": b.url, "status": b.status, "bytes": b.bytes, "duration": duration.Seconds(), "attempt": b.attempt})
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,175 @@
+package manager
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+// EM_LOONGARCH，低版本debug/elf中没有该常量
+const elfMachineLoongArch = elf.Machine(258)
+
+var fxrInfoLogged = make(map[string]bool)
+
+// FindFXRInfo 从deps.json中提取FXR Version及RID
+// deps.json中没有RID信息时，对于SCD（目录下存在hostfxr）从runtimeconfig.json及apphost推断
+func FindFXRInfo(deps string) (string, string) {
+	fxrVersion, rid := FindFXRVersion(deps)
+	if fxrVersion != "" && rid != "" {
+		return fxrVersion, rid
+	}
+
+	dir := filepath.Dir(deps)
+	mainProgram := strings.TrimSuffix(filepath.Base(deps), ".deps.json")
+
+	if !isSelfContained(dir) {
+		return fxrVersion, rid
+	}
+
+	if fxrVersion == "" {
+		fxrVersion = findIncludedFrameworkVersion(filepath.Join(dir, mainProgram+".runtimeconfig.json"))
+	}
+	if rid == "" {
+		for _, name := range []string{mainProgram + ".exe", mainProgram} {
+			if rid = InferRID(filepath.Join(dir, name)); rid != "" {
+				break
+			}
+		}
+	}
+
+	// 同一个deps.json会被多次检查，只提示一次
+	if fxrInfoLogged[deps] {
+		return fxrVersion, rid
+	}
+	fxrInfoLogged[deps] = true
+
+	if fxrVersion != "" && rid != "" {
+		log.LogDetail(fmt.Sprintf("no rid found in %s, inferred %s/%s from the apphost", deps, fxrVersion, rid))
+	} else {
+		log.LogWarning(fmt.Sprintf("incomplete fxr info in %s, skipping patch", deps))
+	}
+
+	return fxrVersion, rid
+}
+
+func isSelfContained(dir string) bool {
+	for _, name := range []string{"hostfxr.dll", "libhostfxr.so", "libhostfxr.dylib"} {
+		if util.PathExists(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// findIncludedFrameworkVersion SCD的runtimeconfig.json中includedFrameworks记录了运行时版本
+func findIncludedFrameworkVersion(runtimeConfig string) string {
+	jsonBytes, err := ioutil.ReadFile(runtimeConfig)
+	if err != nil {
+		return ""
+	}
+
+	json, err := simplejson.NewJson(jsonBytes)
+	if err != nil {
+		return ""
+	}
+
+	frameworks, _ := json.Get("runtimeOptions").Get("includedFrameworks").Array()
+	for index := range frameworks {
+		framework := json.Get("runtimeOptions").Get("includedFrameworks").GetIndex(index)
+		if framework.Get("name").MustString() == "Microsoft.NETCore.App" {
+			if version := framework.Get("version").MustString(); version != "" {
+				return "v" + version
+			}
+		}
+	}
+
+	return ""
+}
+
+// InferRID 根据apphost的PE/ELF/Mach-O头推断RID
+func InferRID(file string) string {
+	if f, err := pe.Open(file); err == nil {
+		defer f.Close()
+		switch f.FileHeader.Machine {
+		case pe.IMAGE_FILE_MACHINE_I386:
+			return "win-x86"
+		case pe.IMAGE_FILE_MACHINE_AMD64:
+			return "win-x64"
+		case pe.IMAGE_FILE_MACHINE_ARMNT:
+			return "win-arm"
+		case 0xaa64: // IMAGE_FILE_MACHINE_ARM64
+			return "win-arm64"
+		}
+		return ""
+	}
+
+	if f, err := elf.Open(file); err == nil {
+		defer f.Close()
+		arch := ""
+		switch f.Machine {
+		case elf.EM_386:
+			arch = "x86"
+		case elf.EM_X86_64:
+			arch = "x64"
+		case elf.EM_ARM:
+			arch = "arm"
+		case elf.EM_AARCH64:
+			arch = "arm64"
+		case elf.EM_RISCV:
+			arch = "riscv64"
+		case elfMachineLoongArch:
+			arch = "loongarch64"
+		default:
+			return ""
+		}
+		if isMuslELF(f) {
+			return "linux-musl-" + arch
+		}
+		return "linux-" + arch
+	}
+
+	if f, err := macho.Open(file); err == nil {
+		defer f.Close()
+		switch f.Cpu {
+		case macho.CpuAmd64:
+			return "osx-x64"
+		case macho.CpuArm64:
+			return "osx-arm64"
+		}
+		return ""
+	}
+
+	if f, err := macho.OpenFat(file); err == nil {
+		defer f.Close()
+		// universal apphost，hostfxr会在打补丁时按架构分别处理
+		if len(f.Arches) != 0 && f.Arches[0].Cpu == macho.CpuArm64 {
+			return "osx-arm64"
+		}
+		return "osx-x64"
+	}
+
+	return ""
+}
+
+// isMuslELF 根据动态链接器判断是否为musl
+func isMuslELF(f *elf.File) bool {
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		interp, err := ioutil.ReadAll(prog.Open())
+		if err != nil {
+			return false
+		}
+		return strings.Contains(string(interp), "ld-musl")
+	}
+	return false
+}
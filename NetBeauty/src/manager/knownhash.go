@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+// KnownHashPublicKey 校验KnownHashes.json签名所用的ed25519公钥（base64），为空时不校验签名
+var KnownHashPublicKey = ""
+
+var knownHashesJSON = "/KnownHashes.json"
+var knownHashesSig = knownHashesJSON + ".sig"
+var knownHashesPath = localArtifactsPath + knownHashesJSON
+var knownHashesSigPath = localArtifactsPath + knownHashesSig
+
+var knownHashesCache map[string][]string = nil
+
+func fetchBytes(url string) ([]byte, error) {
+	response, err := httpGet(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status %d: %s", response.StatusCode, url)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+func verifyKnownHashes(content []byte, sig []byte) error {
+	if KnownHashPublicKey == "" {
+		return nil
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(KnownHashPublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return errors.New("invalid known hash public key")
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return errors.New("invalid known hash signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), content, signature) {
+		return errors.New("known hash signature mismatch")
+	}
+	return nil
+}
+
+// GetKnownHashes 获取已知补丁的hash列表（version/rid => hashes），获取失败时使用本地缓存
+func GetKnownHashes() (map[string][]string, error) {
+	if knownHashesCache != nil {
+		return knownHashesCache, nil
+	}
+
+	online := true
+	content, err := fetchBytes(artifactsOnlinePath() + knownHashesJSON)
+	sig := []byte{}
+	if err == nil && KnownHashPublicKey != "" {
+		sig, err = fetchBytes(artifactsOnlinePath() + knownHashesSig)
+	}
+	if err != nil {
+		log.LogDetail(fmt.Sprintf("fetch known hashes failed: %s, using local cache", err.Error()))
+		online = false
+		if content, err = ioutil.ReadFile(knownHashesPath); err != nil {
+			return nil, errors.New("known hash list is not available")
+		}
+		if KnownHashPublicKey != "" {
+			if sig, err = ioutil.ReadFile(knownHashesSigPath); err != nil {
+				return nil, errors.New("known hash signature is not available")
+			}
+		}
+	}
+
+	if err := verifyKnownHashes(content, sig); err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string][]string)
+	if err := json.Unmarshal(content, &hashes); err != nil {
+		return nil, fmt.Errorf("invalid known hash list: %s", err.Error())
+	}
+
+	if online {
+		util.WriteFileAtomic(knownHashesPath, content, util.CacheFileMode)
+		if KnownHashPublicKey != "" {
+			util.WriteFileAtomic(knownHashesSigPath, sig, util.CacheFileMode)
+		}
+	}
+
+	knownHashesCache = hashes
+
+	return hashes, nil
+}
+
+// IsKnownArtifactFile file是否为已知哈希列表中version/rid的补丁
+func IsKnownArtifactFile(version string, rid string, file string) (bool, error) {
+	hashes, err := GetKnownHashes()
+	if err != nil {
+		return false, err
+	}
+
+	sum, err := util.GetFileHash(file)
+	if err != nil {
+		return false, err
+	}
+
+	// 不带前缀的为sha256，其余算法以"sha512:"形式标注
+	for _, known := range hashes[verid(version, rid)] {
+		algorithm := "sha256"
+		if i := strings.Index(known, ":"); i != -1 {
+			algorithm, known = known[:i], known[i+1:]
+		}
+		if algorithm == util.HashAlgorithm && strings.EqualFold(known, sum) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
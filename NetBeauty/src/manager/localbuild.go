@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"fmt"
+	"path"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+// LocalBuildVersion 本地编译补丁的版本号
+const LocalBuildVersion = "local"
+
+// LocalSourcePath 本地源码目录
+func LocalSourcePath(name string) string {
+	return path.Join(localPath, "src", name)
+}
+
+// IsLocalBuildArtifact 判断补丁是否为本地编译
+func IsLocalBuildArtifact(version string, rid string) bool {
+	return GetLocalArtifactsVersion(version, rid) == LocalBuildVersion && IsLocalArtifactExists(version, rid)
+}
+
+// InstallLocalArtifact 安装本地编译的补丁到缓存
+func InstallLocalArtifact(version string, rid string, file string) bool {
+	des := artifactFile(version, rid)
+	// 缓存目录不使用--dir-mode
+	util.EnsureDirExists(path.Dir(des), util.CacheDirMode)
+	if _, err := util.CopyFile(file, des); err != nil {
+		log.LogError(fmt.Errorf("Cannot copy artifact from %s to %s. %s", file, des, err.Error()), false)
+		return false
+	}
+	return WriteLocalArtifactsVersion(version, rid, LocalBuildVersion)
+}
@@ -0,0 +1,127 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bitly/go-simplejson"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// memFixture 将testdata中的发布目录fixture载入MemFS中的root，不接触磁盘上的root
+func memFixture(t *testing.T, fixture string, root string) *util.MemFS {
+	src := filepath.Join("..", "testdata", fixture)
+	files := make(map[string][]byte)
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		content, err := ioutil.ReadFile(path)
+		files[filepath.Join(root, rel)] = content
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return util.NewMemFS(files)
+}
+
+func TestFixDepsInMemory(t *testing.T) {
+	defer func(fsys util.WriteFS, size int64) {
+		util.FileSystem, StreamDepsSize = fsys, size
+	}(util.FileSystem, StreamDepsSize)
+
+	for _, size := range []int64{DefaultStreamDepsSize, 0} {
+		dir := filepath.Join(os.TempDir(), "nbeauty-memfs", "app")
+		deps := filepath.Join(dir, "app.deps.json")
+		fsys := memFixture(t, "fdd", dir)
+		util.FileSystem, StreamDepsSize = fsys, size
+
+		names := make(map[string]DepsType)
+		all, _, _ := FixDeps(deps, "app", false, false, false)
+		for _, dep := range all {
+			names[dep.Name] = dep.Type
+		}
+		if _, ok := names["app.dll"]; ok {
+			t.Errorf("stream %v: the entry assembly is not skipped", size == 0)
+		}
+		for name, typ := range map[string]DepsType{"Newtonsoft.Json.dll": Assembly, "libfoo.so": Native, "Foo.Res.resources.dll": Resource} {
+			if got, ok := names[name]; !ok || got != typ {
+				t.Errorf("stream %v: %s is missing or has a wrong type: %v", size == 0, name, names)
+			}
+		}
+
+		content, err := fsys.ReadFile(deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), "lib/netstandard2.0/Newtonsoft.Json.dll") {
+			t.Errorf("stream %v: the runtime entry has not been removed from deps.json:\n%s", size == 0, content)
+		}
+		if fileOnDisk(deps) {
+			t.Errorf("stream %v: FixDeps touched the disk", size == 0)
+		}
+	}
+}
+
+// fileOnDisk file是否存在于本地磁盘上
+func fileOnDisk(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+func TestFixDepsPlansEachEntry(t *testing.T) {
+	defer func(fsys util.WriteFS, size int64) {
+		util.FileSystem, StreamDepsSize = fsys, size
+	}(util.FileSystem, StreamDepsSize)
+
+	// 两个target中相对路径相同的native依赖
+	const depsJSON = `{
+  "targets": {
+    ".NETCoreApp,Version=v6.0": { "Foo.Native/1.0.0": { "native": { "runtimes/linux-x64/native/libfoo.so": {} } } },
+    ".NETCoreApp,Version=v6.0/linux-x64": { "Foo.Native/1.0.0": { "native": { "runtimes/linux-x64/native/libfoo.so": {} } } }
+  },
+  "libraries": { "Foo.Native/1.0.0": { "type": "package", "serviceable": true, "sha512": "", "path": "foo.native/1.0.0" } }
+}`
+	const kept = ".NETCoreApp,Version=v6.0/linux-x64"
+
+	for _, size := range []int64{DefaultStreamDepsSize, 0} {
+		dir := filepath.Join(os.TempDir(), "nbeauty-memfs", "app")
+		deps := filepath.Join(dir, "app.deps.json")
+		fsys := util.NewMemFS(map[string][]byte{deps: []byte(depsJSON)})
+		util.FileSystem, StreamDepsSize = fsys, size
+
+		FixDepsWith(deps, "app", false, false, false, func(all []Deps) []Deps {
+			planned := make([]Deps, 0)
+			for _, dep := range all {
+				if dep.Target != kept {
+					planned = append(planned, dep)
+				}
+			}
+			return planned
+		})
+
+		content, err := fsys.ReadFile(deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json, err := simplejson.NewJson(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		native := func(target string) bool {
+			_, ok := json.GetPath("targets", target, "Foo.Native/1.0.0", "native").CheckGet("runtimes/linux-x64/native/libfoo.so")
+			return ok
+		}
+		if native(".NETCoreApp,Version=v6.0") {
+			t.Errorf("stream %v: the planned entry has not been rewritten", size == 0)
+		}
+		if !native(kept) {
+			t.Errorf("stream %v: the entry left out of the plan has been rewritten", size == 0)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitly/go-simplejson"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+// BeautyMarkerName beauty完成后写入beautyDir的标记文件
+const BeautyMarkerName = "NetCoreBeauty"
+
+// BeautyMarker 标记文件内容，记录本次beauty所使用的参数及结果
+type BeautyMarker struct {
+	Tool              string            `json:"tool"`
+	Version           string            `json:"version"`
+	Timestamp         string            `json:"timestamp"`
+	RunID             string            `json:"runId,omitempty"`
+	MigratedBy        string            `json:"migratedBy,omitempty"`
+	LibsDir           string            `json:"libsDir"`
+	Strategy          string            `json:"strategy"`
+	SharedRuntimeMode bool              `json:"sharedRuntimeMode"`
+	FXRVersion        string            `json:"fxrVersion,omitempty"`
+	RID               string            `json:"rid,omitempty"`
+	Patched           bool              `json:"patched"`
+	FXRBackup         string            `json:"fxrBackup,omitempty"`
+	FXROriginal       string            `json:"fxrOriginal,omitempty"`
+	FXROriginalHash   string            `json:"fxrOriginalHash,omitempty"`
+	DepsCount         int               `json:"depsCount"`
+	MovedCount        int               `json:"movedCount"`
+	HashAlgorithm     string            `json:"hashAlgorithm"`
+	Files             map[string]string `json:"files"`
+	// 移动到libsDir的文件及其原来的位置
+	Relocations []Relocation `json:"relocations,omitempty"`
+}
+
+// Relocation 一个被移动到libsDir的文件，路径均为相对beautyDir的"/"分隔路径
+type Relocation struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// MarkerPath 标记文件路径
+func MarkerPath(dir string) string {
+	return filepath.Join(dir, BeautyMarkerName)
+}
+
+// ReadBeautyMarker 读取标记文件
+func ReadBeautyMarker(dir string) (*BeautyMarker, error) {
+	bytes, err := util.FileSystem.ReadFile(MarkerPath(dir))
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return nil, errors.New("empty marker")
+	}
+	marker := &BeautyMarker{}
+	if err := json.Unmarshal(bytes, marker); err != nil {
+		return nil, err
+	}
+	return marker, nil
+}
+
+// WriteBeautyMarker 写入标记文件，并记录配置文件的hash用于之后的校验
+func WriteBeautyMarker(dir string, marker *BeautyMarker, configFiles []string) bool {
+	marker.HashAlgorithm = util.HashAlgorithm
+	marker.Files = make(map[string]string, len(configFiles))
+	for _, file := range configFiles {
+		hash, err := util.GetFileHash(file)
+		if err != nil {
+			continue
+		}
+		marker.Files[filepath.Base(file)] = hash
+	}
+
+	return SaveBeautyMarker(dir, marker)
+}
+
+// SaveBeautyMarker 保存标记文件（不重新计算配置文件hash）
+func SaveBeautyMarker(dir string, marker *BeautyMarker) bool {
+	bytes, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		log.LogError(fmt.Errorf(encodeJSONErr, err.Error()), false)
+		return false
+	}
+	err = util.FileSystem.WriteFile(MarkerPath(dir), bytes, util.FileMode)
+	if err == nil {
+		err = util.Sync(MarkerPath(dir))
+	}
+	if err != nil {
+		log.LogError(fmt.Errorf("write marker failed: %s : %s", MarkerPath(dir), err.Error()), false)
+		return false
+	}
+	return true
+}
+
+// ValidateBeautyMarker 校验标记文件与当前目录是否一致，不一致（重新build/publish过）则需要重新beauty
+func ValidateBeautyMarker(dir string, libsDir string) (*BeautyMarker, error) {
+	marker, err := ReadBeautyMarker(dir)
+	if err != nil {
+		return nil, err
+	}
+	if marker.LibsDir != libsDir {
+		return marker, fmt.Errorf("libsDir changed: %s -> %s", marker.LibsDir, libsDir)
+	}
+	if marker.HashAlgorithm != util.HashAlgorithm {
+		return marker, fmt.Errorf("hash algorithm changed: %s -> %s", marker.HashAlgorithm, util.HashAlgorithm)
+	}
+	if len(marker.Files) == 0 {
+		return marker, errors.New("no config files recorded")
+	}
+	for name, hash := range marker.Files {
+		current, err := util.GetFileHash(filepath.Join(dir, name))
+		if err != nil {
+			return marker, fmt.Errorf("%s is missing", name)
+		}
+		if current != hash {
+			return marker, fmt.Errorf("%s has been modified", name)
+		}
+	}
+	return marker, nil
+}
+
+// ReadBeautyConfig 从beauty改写过的runtimeconfig.json读取libsDir及是否为共享运行时模式，
+// 用于没有标记文件记录时推断当时的参数
+func ReadBeautyConfig(runtimeConfig string) (string, bool, error) {
+	jsonBytes, err := util.FileSystem.ReadFile(runtimeConfig)
+	if err != nil {
+		return "", false, err
+	}
+	json, err := simplejson.NewJson(jsonBytes)
+	if err != nil {
+		return "", false, err
+	}
+
+	properties := json.GetPath("runtimeOptions", "configProperties")
+	// "."及libsDir之后为libsDir中的子目录
+	probing := strings.Split(properties.Get("NetBeautyLibsDir").MustString(), ";")
+	if len(probing) < 2 || probing[1] == "" {
+		return "", false, fmt.Errorf("%s has not been rewritten by nbeauty", runtimeConfig)
+	}
+	sharedRuntimeMode := properties.Get("NetBeautySharedRuntimeMode").MustString("no") != "no"
+	return probing[1], sharedRuntimeMode, nil
+}
+
+// ReadSharedRuntimeConfig 从共享运行时模式改写过的runtimeconfig.json读取应用的ID及文件名（资源文件带语言目录）=> md5
+func ReadSharedRuntimeConfig(runtimeConfig string) (string, map[string]string, error) {
+	jsonBytes, err := util.FileSystem.ReadFile(runtimeConfig)
+	if err != nil {
+		return "", nil, err
+	}
+	json, err := simplejson.NewJson(jsonBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	properties := json.GetPath("runtimeOptions", "configProperties")
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(properties.Get("NetBeautySharedRuntimeMapping").MustString(), "|") {
+		if i := strings.LastIndex(pair, ":"); i > 0 {
+			mapping[pair[:i]] = pair[i+1:]
+		}
+	}
+	return properties.Get("NetBeautyAppID").MustString(), mapping, nil
+}
@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+var executableMagics = [][]byte{
+	[]byte("MZ"),             // PE
+	[]byte("\x7fELF"),        // ELF
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64
+	{0xce, 0xfa, 0xed, 0xfe}, // Mach-O 32
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O fat
+}
+
+func isNativeExecutable(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".dll" || ext == ".so" || ext == ".dylib" || strings.Contains(filepath.Base(file), ".so.") {
+		return false
+	}
+
+	handle, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer handle.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(handle, header); err != nil {
+		return false
+	}
+
+	for _, magic := range executableMagics {
+		if bytes.HasPrefix(header, magic) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNativeAOT 判断目录是否为NativeAOT发布（无deps.json/runtimeconfig.json/hostfxr，只有原生可执行文件）
+func IsNativeAOT(dir string) bool {
+	if len(FindDepsJSON(dir)) != 0 || len(FindRuntimeConfigJSON(dir)) != 0 {
+		return false
+	}
+
+	for _, name := range []string{"hostfxr.dll", "libhostfxr.so", "libhostfxr.dylib", "coreclr.dll", "libcoreclr.so", "libcoreclr.dylib"} {
+		if util.PathExists(filepath.Join(dir, name)) {
+			return false
+		}
+	}
+
+	for _, file := range util.GetAllFiles(dir, false) {
+		if isNativeExecutable(file) {
+			return true
+		}
+	}
+	return false
+}
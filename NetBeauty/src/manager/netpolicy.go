@@ -0,0 +1,189 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NetworkPolicy 获取补丁、版本信息等所有请求的超时、重试及是否允许联网
+type NetworkPolicy struct {
+	// 建立连接（包括TLS握手）的超时
+	ConnectTimeout time.Duration
+	// 单次请求从发出到读完响应的超时
+	ReadTimeout time.Duration
+	// 一次beauty中所有请求的总时间，0为不限
+	Deadline time.Duration
+	// 一次beauty中所有请求共用的重试次数，网络错误、5xx及429时重试
+	Retries int
+	// 不发出任何请求，只使用本地缓存的补丁及版本信息
+	NoNetwork bool
+}
+
+// DefaultNetworkPolicy 默认的网络策略
+func DefaultNetworkPolicy() NetworkPolicy {
+	return NetworkPolicy{
+		ConnectTimeout: 10 * time.Second,
+		ReadTimeout:    60 * time.Second,
+		Retries:        3,
+	}
+}
+
+// ErrNoNetwork 禁止联网时发出的请求
+var ErrNoNetwork = errors.New("network access is disabled by --no-network")
+
+var policy = DefaultNetworkPolicy()
+var httpClient = &http.Client{Timeout: policy.ReadTimeout}
+var traceHTTP = false
+
+var budgetMutex sync.Mutex
+var retriesLeft = policy.Retries
+var networkDeadline time.Time
+
+// SetNetworkPolicy 设置之后所有请求使用的网络策略，并重置总时间及重试次数
+func SetNetworkPolicy(p NetworkPolicy) {
+	policy = p
+
+	dialer := &net.Dialer{Timeout: p.ConnectTimeout, KeepAlive: 30 * time.Second}
+	var transport http.RoundTripper = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: p.ConnectTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if traceHTTP {
+		transport = &tracingTransport{base: transport, attempts: make(map[string]int)}
+	}
+	httpClient = &http.Client{Transport: transport, Timeout: p.ReadTimeout}
+
+	ResetNetworkBudget()
+}
+
+// ResetNetworkBudget 每次beauty开始时重置请求的总时间及重试次数
+func ResetNetworkBudget() {
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	retriesLeft = policy.Retries
+	networkDeadline = time.Time{}
+	if policy.Deadline > 0 {
+		networkDeadline = time.Now().Add(policy.Deadline)
+	}
+}
+
+// takeRetry 使用一次重试，没有剩余的重试次数时返回false
+func takeRetry() bool {
+	budgetMutex.Lock()
+	defer budgetMutex.Unlock()
+
+	if retriesLeft <= 0 {
+		return false
+	}
+	retriesLeft--
+	return true
+}
+
+// retryable 请求失败或响应表示服务端暂时不可用
+func retryable(response *http.Response, err error) bool {
+	return err != nil || response.StatusCode >= 500 || response.StatusCode == http.StatusTooManyRequests
+}
+
+// CurrentNetworkPolicy 当前的网络策略，供自行联网的插件等遵循
+func CurrentNetworkPolicy() NetworkPolicy {
+	return policy
+}
+
+// WithNetworkDeadline 返回受一次beauty中所有请求总时间限制的ctx，已超出总时间时返回错误
+func WithNetworkDeadline(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	budgetMutex.Lock()
+	deadline := networkDeadline
+	budgetMutex.Unlock()
+	if deadline.IsZero() {
+		return ctx, func() {}, nil
+	}
+	if time.Now().After(deadline) {
+		return nil, nil, fmt.Errorf("the network deadline of %s has been exceeded", policy.Deadline)
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	return ctx, cancel, nil
+}
+
+// DoRequest 按网络策略（禁止联网、超时、总时间及代理）发出request，不重试，供通知等非GET请求使用
+func DoRequest(ctx context.Context, request *http.Request) (*http.Response, error) {
+	if policy.NoNetwork {
+		return nil, ErrNoNetwork
+	}
+
+	ctx, cancel, err := WithNetworkDeadline(ctx)
+	if err != nil {
+		return nil, err
+	}
+	response, err := httpClient.Do(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	response.Body = &cancelBody{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+// httpGet 按网络策略发出GET请求，可重试的失败在重试次数内重试，返回的响应状态码不一定是200
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	if policy.NoNetwork {
+		return nil, ErrNoNetwork
+	}
+
+	ctx, cancel, err := WithNetworkDeadline(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		request, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		response, err := httpClient.Do(request.WithContext(ctx))
+		if !retryable(response, err) || ctx.Err() != nil || !takeRetry() {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			response.Body = &cancelBody{ReadCloser: response.Body, cancel: cancel}
+			return response, nil
+		}
+		if response != nil {
+			response.Body.Close()
+		}
+
+		// 1s、2s、4s...，最多30s
+		backoff := time.Second << uint(attempt-1)
+		if backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			cancel()
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// cancelBody 关闭响应时释放总时间的context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
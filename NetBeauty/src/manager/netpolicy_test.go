@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDoRequestFollowsPolicy(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	previous := CurrentNetworkPolicy()
+	defer SetNetworkPolicy(previous)
+
+	post := func() error {
+		request, err := http.NewRequest("POST", server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := DoRequest(context.Background(), request)
+		if err == nil {
+			response.Body.Close()
+		}
+		return err
+	}
+
+	policy := DefaultNetworkPolicy()
+	policy.NoNetwork = true
+	SetNetworkPolicy(policy)
+	if err := post(); err != ErrNoNetwork || requests != 0 {
+		t.Errorf("--no-network: err = %v, %d request(s) sent", err, requests)
+	}
+
+	policy = DefaultNetworkPolicy()
+	policy.Deadline = time.Nanosecond
+	SetNetworkPolicy(policy)
+	time.Sleep(time.Millisecond)
+	if err := post(); err == nil || requests != 0 {
+		t.Errorf("exceeded deadline: err = %v, %d request(s) sent", err, requests)
+	}
+
+	SetNetworkPolicy(DefaultNetworkPolicy())
+	if err := post(); err != nil || requests != 1 {
+		t.Errorf("default policy: err = %v, %d request(s) sent", err, requests)
+	}
+}
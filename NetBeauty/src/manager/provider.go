@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+// Artifact 补丁版hostfxr，由ArtifactProvider的Resolve返回，Fetch时原样传回
+type Artifact struct {
+	FXRVersion string
+	// 补丁对应的RID，可能是请求的RID的兼容RID
+	RID string
+	// provider自行定义的位置，如版本号、URL或仓库中的路径
+	Location string
+}
+
+// ErrNoArtifact 不存在所请求的补丁
+var ErrNoArtifact = errors.New("artifact does not exist")
+
+// ErrNoCompatibleRID 所请求的RID没有可用的兼容RID
+var ErrNoCompatibleRID = errors.New("no compatible rid")
+
+// GitCDNProvider 从GitCDN下载补丁，本地编译的补丁优先
+type GitCDNProvider struct{}
+
+// Name provider名称
+func (GitCDNProvider) Name() string {
+	return "gitcdn"
+}
+
+// Resolve 匹配兼容RID并查找补丁，Location为在线的补丁版本号，本地编译的补丁为LocalBuildVersion
+func (GitCDNProvider) Resolve(ctx context.Context, fxrVersion string, rid string) (Artifact, error) {
+	artifact := Artifact{FXRVersion: fxrVersion, RID: rid}
+	if IsLocalBuildArtifact(fxrVersion, rid) {
+		artifact.Location = LocalBuildVersion
+		return artifact, nil
+	}
+
+	crid := FindCompatibleRID(rid)
+	if crid == "" {
+		return artifact, ErrNoCompatibleRID
+	}
+	artifact.RID = crid
+	if IsLocalBuildArtifact(fxrVersion, crid) {
+		artifact.Location = LocalBuildVersion
+		return artifact, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return artifact, err
+	}
+	if artifact.Location = GetOnlineArtifactsVersion(fxrVersion, crid); artifact.Location == "" {
+		return artifact, ErrNoArtifact
+	}
+	return artifact, nil
+}
+
+// Fetch 本地缓存的版本与Resolve时的在线版本不一致时重新下载，再复制到dst
+func (GitCDNProvider) Fetch(ctx context.Context, artifact Artifact, dst string) error {
+	version, rid := artifact.FXRVersion, artifact.RID
+	if local := GetLocalArtifactsVersion(version, rid); local != LocalBuildVersion && local != artifact.Location {
+		if err := DownloadArtifact(ctx, version, rid); err != nil {
+			return err
+		}
+		if !WriteLocalArtifactsVersion(version, rid, artifact.Location) {
+			return errors.New("download patch failed")
+		}
+	}
+
+	if _, err := util.CopyFile(LocalArtifactFile(version, rid), dst); err != nil {
+		return fmt.Errorf("copy %s/%s failed: %s", version, rid, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/bitly/go-simplejson"
+
+	"github.com/nulastudio/NetBeauty/src/util"
+)
+
+// relayoutPath 将位于oldLibsDir（含其子目录）的探测路径改为newLibsDir下的对应路径
+func relayoutPath(probing string, oldLibsDir string, newLibsDir string) (string, bool) {
+	normalized := strings.TrimPrefix(strings.Replace(probing, `\`, "/", -1), "./")
+	if normalized == oldLibsDir {
+		return newLibsDir, true
+	}
+	if strings.HasPrefix(normalized, oldLibsDir+"/") {
+		return newLibsDir + normalized[len(oldLibsDir):], true
+	}
+	return probing, false
+}
+
+func slashLibsDir(libsDir string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.Replace(libsDir, `\`, "/", -1), "./"), "/")
+}
+
+// RelayoutRuntimeConfig 将runtimeconfig.json中NetBeautyLibsDir及additionalProbingPaths里
+// 指向oldLibsDir的路径改为newLibsDir，返回改写后的内容，没有需要改写的路径时返回nil
+func RelayoutRuntimeConfig(runtimeConfig string, oldLibsDir string, newLibsDir string) ([]byte, error) {
+	jsonBytes, err := util.FileSystem.ReadFile(runtimeConfig)
+	if err != nil {
+		return nil, err
+	}
+	json, err := simplejson.NewJson(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid runtimeconfig.json: %s : %s", runtimeConfig, err.Error())
+	}
+
+	oldLibsDir, newLibsDir = slashLibsDir(oldLibsDir), slashLibsDir(newLibsDir)
+	changed := false
+
+	properties := json.GetPath("runtimeOptions", "configProperties")
+	if value, ok := properties.CheckGet("NetBeautyLibsDir"); ok {
+		paths := strings.Split(value.MustString(), ";")
+		for i, path := range paths {
+			if relayouted, ok := relayoutPath(path, oldLibsDir, newLibsDir); ok {
+				paths[i] = relayouted
+				changed = true
+			}
+		}
+		properties.Set("NetBeautyLibsDir", strings.Join(paths, ";"))
+	}
+
+	runtimeOptions := json.Get("runtimeOptions")
+	if value, ok := runtimeOptions.CheckGet("additionalProbingPaths"); ok {
+		paths, err := value.StringArray()
+		if err != nil {
+			return nil, fmt.Errorf("invalid runtimeconfig.json: %s : %s", runtimeConfig, err.Error())
+		}
+		for i, path := range paths {
+			if relayouted, ok := relayoutPath(path, oldLibsDir, newLibsDir); ok {
+				paths[i] = relayouted
+				changed = true
+			}
+		}
+		runtimeOptions.Set("additionalProbingPaths", paths)
+	}
+
+	if !changed {
+		return nil, nil
+	}
+	return json.EncodePretty()
+}
+
+// RelayoutExeConfig 将exe.config中probing的privatePath里的oldLibsDir改为newLibsDir，
+// 返回改写后的内容，没有需要改写的路径时返回nil
+func RelayoutExeConfig(exeConfig string, oldLibsDir string, newLibsDir string) ([]byte, error) {
+	content, err := util.FileSystem.ReadFile(exeConfig)
+	if err != nil {
+		return nil, err
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(content); err != nil {
+		return nil, fmt.Errorf("invalid exe.config: %s : %s", exeConfig, err.Error())
+	}
+
+	oldLibsDir, newLibsDir = slashLibsDir(oldLibsDir), slashLibsDir(newLibsDir)
+	changed := false
+
+	for _, probing := range doc.FindElements("//probing") {
+		privatePath := probing.SelectAttrValue("privatePath", "")
+		if privatePath == "" {
+			continue
+		}
+		paths := strings.Split(privatePath, ";")
+		for i, path := range paths {
+			if relayouted, ok := relayoutPath(path, oldLibsDir, newLibsDir); ok {
+				paths[i] = relayouted
+				changed = true
+			}
+		}
+		probing.CreateAttr("privatePath", strings.Join(paths, ";"))
+	}
+
+	if !changed {
+		return nil, nil
+	}
+	doc.WriteSettings.UseCRLF = true
+	doc.Indent(2)
+	return doc.WriteToBytes()
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+)
+
+// portableRIDs 兼容数据之外已知的portable RID（包括.NET 8+新增的riscv64、loongarch64）
+var portableRIDs = []string{
+	"win-x86", "win-x64", "win-arm64",
+	"linux-x64", "linux-arm", "linux-arm64", "linux-riscv64", "linux-loongarch64",
+	"linux-musl-x64", "linux-musl-arm", "linux-musl-arm64", "linux-musl-riscv64", "linux-musl-loongarch64",
+	"osx-x64", "osx-arm64",
+}
+
+func isPortableRID(rid string) bool {
+	for _, portable := range portableRIDs {
+		if portable == rid {
+			return true
+		}
+	}
+	return false
+}
+
+// KnownRIDs 所有已知的RID（兼容数据+portable RID）
+func KnownRIDs() []string {
+	known := make(map[string]bool)
+	for _, rid := range portableRIDs {
+		known[rid] = true
+	}
+	for rid := range ridCompatibility(false) {
+		known[rid] = true
+	}
+
+	list := make([]string, 0, len(known))
+	for rid := range known {
+		list = append(list, rid)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// ridArch 取RID的架构部分，如osx.12-arm64 => arm64
+func ridArch(rid string) string {
+	if index := strings.LastIndex(rid, "-"); index != -1 {
+		return rid[index+1:]
+	}
+	return ""
+}
+
+// isMuslRID musl（Alpine）与glibc的hostfxr不能混用
+func isMuslRID(rid string) bool {
+	return strings.Contains(rid, "-musl-") || strings.HasPrefix(rid, "alpine")
+}
+
+// linuxDistros 兼容数据之外已知的glibc Linux发行版RID前缀
+var linuxDistros = []string{
+	"linux", "ubuntu", "debian", "rhel", "centos", "fedora", "opensuse", "sles", "ol",
+	"linuxmint", "rocky", "almalinux", "manjaro", "arch", "gentoo", "exherbo", "tizen",
+}
+
+// isLinuxRID rid是否为Linux（glibc或musl）
+func isLinuxRID(rid string) bool {
+	for _, compatible := range ridCompatibility(false)[rid] {
+		if compatible == "linux" {
+			return true
+		}
+	}
+	targetOS := strings.SplitN(rid, "-", 2)[0]
+	for _, distro := range linuxDistros {
+		if targetOS == distro || strings.HasPrefix(targetOS, distro+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// PortableRID 去掉RID中的系统版本，如osx.12-arm64 => osx-arm64、win10-arm64 => win-arm64、alpine.3.18-x64 => linux-musl-x64
+// 未知的系统（如freebsd-x64、android-arm64）返回""
+func PortableRID(rid string) string {
+	index := strings.LastIndex(rid, "-")
+	if index == -1 {
+		return ""
+	}
+	targetOS, targetArch := rid[:index], rid[index+1:]
+	switch {
+	case strings.HasPrefix(targetOS, "win"):
+		targetOS = "win"
+	case strings.HasPrefix(targetOS, "osx"):
+		targetOS = "osx"
+	case isMuslRID(rid):
+		targetOS = "linux-musl"
+	case isLinuxRID(rid):
+		targetOS = "linux"
+	default:
+		return ""
+	}
+	return targetOS + "-" + targetArch
+}
@@ -0,0 +1,58 @@
+package manager
+
+import "testing"
+
+func TestPortableRID(t *testing.T) {
+	for rid, expected := range map[string]string{
+		"win10-arm64":        "win-arm64",
+		"win-x86":            "win-x86",
+		"osx.12-arm64":       "osx-arm64",
+		"osx.10.15-x64":      "osx-x64",
+		"ubuntu.20.04-arm64": "linux-arm64",
+		"rhel.8-x64":         "linux-x64",
+		"linux-riscv64":      "linux-riscv64",
+		"alpine.3.18-x64":    "linux-musl-x64",
+		"linux-musl-arm64":   "linux-musl-arm64",
+		"freebsd-x64":        "",
+		"android-arm64":      "",
+		"ios-arm64":          "",
+		"browser-wasm":       "",
+		"any":                "",
+	} {
+		if actual := PortableRID(rid); actual != expected {
+			t.Errorf("PortableRID(%s) = %q, want %q", rid, actual, expected)
+		}
+	}
+}
+
+func TestFindCompatibleRID(t *testing.T) {
+	defer func(cache map[string][]string) { ridCompatibilityCache = cache }(ridCompatibilityCache)
+	ridCompatibilityCache = map[string][]string{
+		"osx-arm64":      {"osx-arm64", "osx", "unix-arm64", "unix", "any", "base"},
+		"osx-x64":        {"osx-x64", "osx", "unix-x64", "unix", "any", "base"},
+		"osx.11.0-arm64": {"osx.11.0-arm64", "osx.11.0", "osx.10.16-arm64", "osx-arm64", "osx", "unix-arm64", "unix", "any", "base"},
+		"win-arm64":      {"win-arm64", "win", "any", "base"},
+		"win10-arm64":    {"win10-arm64", "win10", "win81-arm64", "win8-arm64", "win7-arm64", "win-arm64", "win", "any", "base"},
+		"linux-arm64":    {"linux-arm64", "linux", "unix-arm64", "unix", "any", "base"},
+		"linux-x64":      {"linux-x64", "linux", "unix-x64", "unix", "any", "base"},
+		"ubuntu-arm64":   {"ubuntu-arm64", "ubuntu", "debian-arm64", "debian", "linux-arm64", "linux", "unix-arm64", "unix", "any", "base"},
+		"freebsd-x64":    {"freebsd-x64", "freebsd", "unix-x64", "unix", "any", "base"},
+	}
+
+	for rid, expected := range map[string]string{
+		// Apple Silicon不能回退到x64
+		"osx-arm64":          "osx-arm64",
+		"osx.11.0-arm64":     "osx.11.0-arm64",
+		"osx.14-arm64":       "osx-arm64",
+		"win10-arm64":        "win10-arm64",
+		"win11-arm64":        "win-arm64",
+		"ubuntu.22.04-arm64": "linux-arm64",
+		"linux-musl-arm64":   "linux-musl-arm64",
+		"freebsd-arm64":      "",
+		"android-arm64":      "",
+	} {
+		if actual := FindCompatibleRID(rid); actual != expected {
+			t.Errorf("FindCompatibleRID(%s) = %q, want %q", rid, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package manager
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var runtimeCompatibilityCacheName = "runtime.compatibility.gob"
+
+// ridCompatibilityCache 解析后的RID兼容数据（rid => 兼容的RID），同一进程内只解析一次
+var ridCompatibilityCache map[string][]string = nil
+
+// ridCompatibilityFile 持久化的RID兼容数据，Source相同时直接使用，不再解析runtime.compatibility.json
+type ridCompatibilityFile struct {
+	Source        string
+	Compatibility map[string][]string
+}
+
+func runtimeCompatibilityCachePath() string {
+	return runtimeJSONPath(runtimeCompatibilityCacheName)
+}
+
+// runtimeCompatibilitySource runtime.compatibility.json的版本，本地修改过的文件大小或时间不同
+func runtimeCompatibilitySource() (string, error) {
+	fi, err := os.Stat(runtimeCompatibilityJSONPath())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d/%d", getLocalRuntimeCompatibilityVersion(), fi.Size(), fi.ModTime().UnixNano()), nil
+}
+
+// ridCompatibility 读取RID兼容数据，优先使用进程内及持久化的缓存，不存在时返回nil
+func ridCompatibility(errlog bool) map[string][]string {
+	if ridCompatibilityCache != nil {
+		return ridCompatibilityCache
+	}
+
+	source, err := runtimeCompatibilitySource()
+	if err != nil {
+		if errlog {
+			log.LogInfo(fmt.Sprintf("read json failed: %s : %s", runtimeCompatibilityJSONPath(), err.Error()))
+		}
+		return nil
+	}
+
+	if content, err := ioutil.ReadFile(runtimeCompatibilityCachePath()); err == nil {
+		var cached ridCompatibilityFile
+		if err := gob.NewDecoder(bytes.NewReader(content)).Decode(&cached); err == nil && cached.Source == source {
+			ridCompatibilityCache = cached.Compatibility
+			return ridCompatibilityCache
+		}
+	}
+
+	runtimeCompatibilityJSON := readJSON(runtimeCompatibilityJSONPath(), errlog)
+	if runtimeCompatibilityJSON == nil {
+		return nil
+	}
+	rids, err := runtimeCompatibilityJSON.Map()
+	if err != nil {
+		return nil
+	}
+	compatibility := make(map[string][]string, len(rids))
+	for rid := range rids {
+		compatibility[rid], _ = runtimeCompatibilityJSON.Get(rid).StringArray()
+	}
+	ridCompatibilityCache = compatibility
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ridCompatibilityFile{Source: source, Compatibility: compatibility}); err == nil {
+		if err := util.WriteFileAtomic(runtimeCompatibilityCachePath(), buf.Bytes(), util.CacheFileMode); err != nil {
+			log.LogDetail(fmt.Sprintf("cannot cache the rid compatibility: %s", err.Error()))
+		}
+	}
+
+	return ridCompatibilityCache
+}
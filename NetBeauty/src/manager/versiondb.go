@@ -0,0 +1,35 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	util "github.com/nulastudio/NetBeauty/src/util"
+)
+
+var artifactsVersionLockPath = artifactsVersionPath + ".lock"
+
+// versionLockWait 等待其他进程释放本地补丁版本库的最长时间
+const versionLockWait = 30 * time.Second
+
+// lockArtifactsVersion 获取本地补丁版本库的锁，并行的beauty持有时等待，返回释放锁的函数
+// 使用操作系统的锁，持有者崩溃时锁随进程释放
+func lockArtifactsVersion() (func(), error) {
+	deadline := time.Now().Add(versionLockWait)
+	owner := []byte(strconv.Itoa(os.Getpid()))
+	for {
+		lock, err := util.TryLock(artifactsVersionLockPath, owner, util.CacheFileMode)
+		if err == nil {
+			return lock.Unlock, nil
+		}
+		if err != util.ErrLocked {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for another nbeauty to release %s", artifactsVersionLockPath)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestArtifactsVersionLockIsExclusive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbeauty-versiondb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous := artifactsVersionLockPath
+	defer func() { artifactsVersionLockPath = previous }()
+	artifactsVersionLockPath = filepath.Join(dir, "version.lock")
+
+	// 崩溃的进程留下的锁文件
+	ioutil.WriteFile(artifactsVersionLockPath, []byte("1"), 0644)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := lockArtifactsVersion()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			holders++
+			if holders > maxHolders {
+				maxHolders = holders
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("%d holders at the same time", maxHolders)
+	}
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 0 {
+		t.Errorf("%d file(s) left behind", len(fis))
+	}
+}
@@ -0,0 +1,21 @@
+package misc
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// CloneFile APFS上通过clonefile(2)写时复制，文件系统不支持（ENOTSUP）或跨分区（EXDEV）时返回错误，由调用方退化为普通复制
+func CloneFile(src string, des string, perm os.FileMode) error {
+	// clonefile要求目标文件不存在
+	if _, err := os.Lstat(des); err == nil {
+		return os.ErrExist
+	}
+
+	if err := unix.Clonefile(src, des, unix.CLONE_NOFOLLOW); err != nil {
+		return &os.LinkError{Op: "clonefile", Old: src, New: des, Err: err}
+	}
+
+	return os.Chmod(des, perm)
+}
@@ -0,0 +1,30 @@
+package misc
+
+import (
+	"os"
+	"syscall"
+)
+
+// linux/fs.h: #define FICLONE _IOW(0x94, 9, int)
+const ficlone = 0x40049409
+
+func CloneFile(src string, des string, perm os.FileMode) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	desFile, err := os.OpenFile(des, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer desFile.Close()
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, desFile.Fd(), ficlone, srcFile.Fd())
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+// +build !linux,!darwin
+
+package misc
+
+import (
+	"errors"
+	"os"
+)
+
+func CloneFile(src string, des string, perm os.FileMode) error {
+	return errors.New("clone is not supported on this platform")
+}
@@ -0,0 +1,20 @@
+package misc
+
+import (
+	"os"
+	"syscall"
+)
+
+func toErrno(err error) (syscall.Errno, bool) {
+	switch e := err.(type) {
+	case *os.LinkError:
+		err = e.Err
+	case *os.PathError:
+		err = e.Err
+	case *os.SyscallError:
+		err = e.Err
+	}
+
+	errno, ok := err.(syscall.Errno)
+	return errno, ok
+}
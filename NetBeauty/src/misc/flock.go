@@ -0,0 +1,6 @@
+package misc
+
+import "errors"
+
+// ErrLocked 文件已被其他进程加锁
+var ErrLocked = errors.New("the file is locked by another process")
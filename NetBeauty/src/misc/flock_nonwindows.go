@@ -0,0 +1,24 @@
+// +build !windows
+
+package misc
+
+import (
+	"os"
+	"syscall"
+)
+
+// TryLockFile 对f加排他的咨询锁（flock），已被其他进程持有时返回ErrLocked，进程退出时锁自动释放
+func TryLockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return ErrLocked
+	}
+	return err
+}
+
+// ReleaseLockFile 删除path并释放f上的锁，先删除再解锁，等待中的进程不会锁住已删除的文件后还被当作持有者
+func ReleaseLockFile(f *os.File, path string) {
+	os.Remove(path)
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	f.Close()
+}
@@ -0,0 +1,40 @@
+package misc
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procLockFileEx   = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+	procUnlockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("UnlockFileEx")
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	// LockFileEx的锁是强制的，只锁文件内容之外的一个字节，其他进程仍可读取锁文件中的持有者信息
+	lockOffsetHigh = 0x7fffffff
+)
+
+// TryLockFile 对f加排他锁（LockFileEx），已被其他进程持有时返回ErrLocked，进程退出时锁自动释放
+func TryLockFile(f *os.File) error {
+	overlapped := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	ret, _, err := procLockFileEx.Call(f.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if ret != 0 {
+		return nil
+	}
+	if errno, ok := err.(syscall.Errno); ok && errno == errorLockViolation {
+		return ErrLocked
+	}
+	return err
+}
+
+// ReleaseLockFile 释放f上的锁后删除path，其他进程已打开path时删除失败，锁文件留给它继续使用
+func ReleaseLockFile(f *os.File, path string) {
+	overlapped := syscall.Overlapped{OffsetHigh: lockOffsetHigh}
+	procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	f.Close()
+	os.Remove(path)
+}
@@ -0,0 +1,23 @@
+// +build !windows
+
+package misc
+
+import (
+	"os"
+	"syscall"
+)
+
+// SyncDir 将目录项（新建、重命名、删除的文件）写入磁盘
+func SyncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// 部分文件系统不支持对目录fsync
+	err = f.Sync()
+	if errno, ok := toErrno(err); ok && (errno == syscall.EINVAL || errno == syscall.ENOTSUP) {
+		return nil
+	}
+	return err
+}
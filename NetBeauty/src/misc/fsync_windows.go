@@ -0,0 +1,6 @@
+package misc
+
+// SyncDir Windows下目录无法以写权限打开并刷新，NTFS的目录项由文件系统日志保证，不需要处理
+func SyncDir(dir string) error {
+	return nil
+}
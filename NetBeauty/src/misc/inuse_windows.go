@@ -0,0 +1,85 @@
+package misc
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	rstrtmgr                = syscall.NewLazyDLL("rstrtmgr.dll")
+	procRmStartSession      = rstrtmgr.NewProc("RmStartSession")
+	procRmRegisterResources = rstrtmgr.NewProc("RmRegisterResources")
+	procRmGetList           = rstrtmgr.NewProc("RmGetList")
+	procRmEndSession        = rstrtmgr.NewProc("RmEndSession")
+)
+
+const (
+	cchRmSessionKey      = 32
+	cchRmMaxAppName      = 255
+	cchRmMaxSvcName      = 63
+	errorMoreData        = 234
+	rmGetListMaxAttempts = 3
+)
+
+// rmProcessInfo RM_PROCESS_INFO
+type rmProcessInfo struct {
+	ProcessID        uint32
+	ProcessStartTime syscall.Filetime
+	AppName          [cchRmMaxAppName + 1]uint16
+	ServiceShortName [cchRmMaxSvcName + 1]uint16
+	ApplicationType  uint32
+	AppStatus        uint32
+	TSSessionID      uint32
+	Restartable      int32
+}
+
+// FilesInUseBy 通过Restart Manager查询占用files的进程，返回"名称 (pid)"
+func FilesInUseBy(files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if err := rstrtmgr.Load(); err != nil {
+		return nil, err
+	}
+
+	var session uint32
+	key := make([]uint16, cchRmSessionKey+1)
+	if ret, _, _ := procRmStartSession.Call(uintptr(unsafe.Pointer(&session)), 0, uintptr(unsafe.Pointer(&key[0]))); ret != 0 {
+		return nil, syscall.Errno(ret)
+	}
+	defer procRmEndSession.Call(uintptr(session))
+
+	names := make([]*uint16, 0, len(files))
+	for _, file := range files {
+		ptr, err := syscall.UTF16PtrFromString(file)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, ptr)
+	}
+	if ret, _, _ := procRmRegisterResources.Call(uintptr(session), uintptr(len(names)), uintptr(unsafe.Pointer(&names[0])), 0, 0, 0, 0); ret != 0 {
+		return nil, syscall.Errno(ret)
+	}
+
+	// 两次调用之间可能有新的进程占用文件
+	infos := make([]rmProcessInfo, 8)
+	for i := 0; i < rmGetListMaxAttempts; i++ {
+		var needed, count uint32 = 0, uint32(len(infos))
+		var reasons uint32
+		ret, _, _ := procRmGetList.Call(uintptr(session), uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&infos[0])), uintptr(unsafe.Pointer(&reasons)))
+		if ret == errorMoreData {
+			infos = make([]rmProcessInfo, needed+4)
+			continue
+		}
+		if ret != 0 {
+			return nil, syscall.Errno(ret)
+		}
+		holders := make([]string, 0, count)
+		for _, info := range infos[:count] {
+			holders = append(holders, fmt.Sprintf("%s (pid %d)", syscall.UTF16ToString(info.AppName[:]), info.ProcessID))
+		}
+		return holders, nil
+	}
+	return nil, syscall.Errno(errorMoreData)
+}
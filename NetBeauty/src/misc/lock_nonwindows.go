@@ -0,0 +1,17 @@
+// +build !windows
+
+package misc
+
+import (
+	"syscall"
+)
+
+func IsLockError(err error) bool {
+	return false
+}
+
+func IsCrossDeviceError(err error) bool {
+	errno, ok := toErrno(err)
+
+	return ok && errno == syscall.EXDEV
+}
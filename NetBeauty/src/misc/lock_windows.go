@@ -0,0 +1,27 @@
+package misc
+
+import (
+	"syscall"
+)
+
+const (
+	errorAccessDenied     syscall.Errno = 5
+	errorNotSameDevice    syscall.Errno = 17
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+func IsLockError(err error) bool {
+	errno, ok := toErrno(err)
+	if !ok {
+		return false
+	}
+
+	return errno == errorAccessDenied || errno == errorSharingViolation || errno == errorLockViolation
+}
+
+func IsCrossDeviceError(err error) bool {
+	errno, ok := toErrno(err)
+
+	return ok && errno == errorNotSameDevice
+}
@@ -0,0 +1,7 @@
+// +build !windows
+
+package misc
+
+func CopyFileSecurity(src string, des string) error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package misc
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32             = syscall.NewLazyDLL("advapi32.dll")
+	procGetFileSecurityW = advapi32.NewProc("GetFileSecurityW")
+	procSetFileSecurityW = advapi32.NewProc("SetFileSecurityW")
+)
+
+const (
+	ownerSecurityInformation = 0x00000001
+	groupSecurityInformation = 0x00000002
+	daclSecurityInformation  = 0x00000004
+
+	fileAttributeNotContentIndexed = 0x00002000
+
+	preservedAttributes = syscall.FILE_ATTRIBUTE_READONLY |
+		syscall.FILE_ATTRIBUTE_HIDDEN |
+		syscall.FILE_ATTRIBUTE_SYSTEM |
+		syscall.FILE_ATTRIBUTE_ARCHIVE |
+		fileAttributeNotContentIndexed
+)
+
+// CopyFileSecurity 将src的ACL及只读、隐藏等属性复制到des
+func CopyFileSecurity(src string, des string) error {
+	srcPtr, err := syscall.UTF16PtrFromString(src)
+	if err != nil {
+		return err
+	}
+	desPtr, err := syscall.UTF16PtrFromString(des)
+	if err != nil {
+		return err
+	}
+
+	// 修改owner需要特权，失败时只复制DACL
+	for _, info := range []uint32{
+		ownerSecurityInformation | groupSecurityInformation | daclSecurityInformation,
+		daclSecurityInformation,
+	} {
+		if err = copySecurityDescriptor(srcPtr, desPtr, info); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	srcAttributes, err := syscall.GetFileAttributes(srcPtr)
+	if err != nil {
+		return err
+	}
+	desAttributes, err := syscall.GetFileAttributes(desPtr)
+	if err != nil {
+		return err
+	}
+
+	desAttributes = desAttributes&^preservedAttributes | srcAttributes&preservedAttributes
+
+	return syscall.SetFileAttributes(desPtr, desAttributes)
+}
+
+func copySecurityDescriptor(src *uint16, des *uint16, info uint32) error {
+	var needed uint32
+
+	procGetFileSecurityW.Call(uintptr(unsafe.Pointer(src)), uintptr(info), 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if needed == 0 {
+		return syscall.EINVAL
+	}
+
+	descriptor := make([]byte, needed)
+	r, _, err := procGetFileSecurityW.Call(uintptr(unsafe.Pointer(src)), uintptr(info), uintptr(unsafe.Pointer(&descriptor[0])), uintptr(needed), uintptr(unsafe.Pointer(&needed)))
+	if r == 0 {
+		return err
+	}
+
+	r, _, err = procSetFileSecurityW.Call(uintptr(unsafe.Pointer(des)), uintptr(info), uintptr(unsafe.Pointer(&descriptor[0])))
+	if r == 0 {
+		return err
+	}
+
+	return nil
+}
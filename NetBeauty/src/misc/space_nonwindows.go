@@ -0,0 +1,19 @@
+// +build !windows
+
+package misc
+
+import (
+	"syscall"
+)
+
+// MaxPath 文件绝对路径的长度上限
+const MaxPath = 4096
+
+// FreeSpace path所在分区当前用户可用的剩余空间
+func FreeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,31 @@
+package misc
+
+import (
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// MaxPath 文件绝对路径的长度上限，apphost默认不支持长路径
+const MaxPath = 260
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// FreeSpace path所在分区当前用户可用的剩余空间
+func FreeSpace(path string) (uint64, error) {
+	// 网络共享路径必须以\结尾
+	if strings.HasPrefix(path, `\\`) && !strings.HasSuffix(path, `\`) {
+		path += `\`
+	}
+	ptr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var free uint64
+	ret, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(ptr)), uintptr(unsafe.Pointer(&free)), 0, 0)
+	if ret == 0 {
+		return 0, err
+	}
+	return free, nil
+}
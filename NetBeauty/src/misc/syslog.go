@@ -0,0 +1,9 @@
+package misc
+
+// SystemLog 系统日志（Linux/macOS为syslog，Windows为事件日志）
+type SystemLog interface {
+	Err(message string) error
+	Warning(message string) error
+	Info(message string) error
+	Close() error
+}
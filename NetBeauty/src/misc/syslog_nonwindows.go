@@ -0,0 +1,12 @@
+// +build !windows
+
+package misc
+
+import (
+	"log/syslog"
+)
+
+// OpenSystemLog 连接本机syslog
+func OpenSystemLog(tag string) (SystemLog, error) {
+	return syslog.New(syslog.LOG_USER|syslog.LOG_INFO, tag)
+}
@@ -0,0 +1,68 @@
+package misc
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+const (
+	eventlogErrorType       = 0x0001
+	eventlogWarningType     = 0x0002
+	eventlogInformationType = 0x0004
+)
+
+// eventLog Windows事件日志，未注册消息文件，事件查看器中会提示找不到描述但仍会显示消息内容
+type eventLog struct {
+	handle uintptr
+}
+
+// OpenSystemLog 以tag为事件源打开Windows事件日志（应用程序）
+func OpenSystemLog(tag string) (SystemLog, error) {
+	source, err := syscall.UTF16PtrFromString(tag)
+	if err != nil {
+		return nil, err
+	}
+	handle, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(source)))
+	if handle == 0 {
+		return nil, err
+	}
+	return &eventLog{handle: handle}, nil
+}
+
+func (l *eventLog) report(eventType uint16, message string) error {
+	msg, err := syscall.UTF16PtrFromString(message)
+	if err != nil {
+		return err
+	}
+	strings := []*uint16{msg}
+	ret, _, err := procReportEventW.Call(l.handle, uintptr(eventType), 0, 1, 0, 1, 0, uintptr(unsafe.Pointer(&strings[0])), 0)
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+func (l *eventLog) Err(message string) error {
+	return l.report(eventlogErrorType, message)
+}
+
+func (l *eventLog) Warning(message string) error {
+	return l.report(eventlogWarningType, message)
+}
+
+func (l *eventLog) Info(message string) error {
+	return l.report(eventlogInformationType, message)
+}
+
+func (l *eventLog) Close() error {
+	if ret, _, err := procDeregisterEventSource.Call(l.handle); ret == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package misc
+
+import (
+	"os"
+)
+
+// IsTerminal 判断是否输出到终端（非管道/重定向）
+func IsTerminal(file *os.File) bool {
+	fi, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,11 @@
+// +build !windows
+
+package misc
+
+import (
+	"os"
+)
+
+func EnableVirtualTerminal(file *os.File) bool {
+	return true
+}
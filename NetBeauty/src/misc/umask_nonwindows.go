@@ -3,9 +3,11 @@
 package misc
 
 import (
+	"os"
 	"syscall"
 )
 
-func Umask() {
-	syscall.Umask(0)
+// Umask 清零umask，返回原来的umask
+func Umask() os.FileMode {
+	return os.FileMode(syscall.Umask(0))
 }
@@ -1,3 +1,7 @@
 package misc
 
-func Umask() {}
+import "os"
+
+func Umask() os.FileMode {
+	return 0
+}
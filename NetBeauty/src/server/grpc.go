@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServiceName netbeauty.proto中的服务名
+const grpcServiceName = "netbeauty.NetBeauty"
+
+// grpcService gRPC服务，接口见netbeauty.proto
+// Beautify及Watch为服务端流，任务有新的事件或日志时立即返回，客户端断开不会中断任务，可用Watch重新连接
+type grpcService struct{}
+
+// unaryMethod 构造一元RPC，newRequest返回用于解码请求的空消息
+func unaryMethod(name string, newRequest func() protoUnmarshaler, handle func(ctx context.Context, request interface{}) (interface{}, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			request := newRequest()
+			if err := dec(request); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return handle(ctx, request)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + grpcServiceName + "/" + name}
+			return interceptor(ctx, request, info, handle)
+		},
+	}
+}
+
+// streamMethod 构造服务端流RPC
+func streamMethod(name string, newRequest func() protoUnmarshaler, handle func(request interface{}, stream grpc.ServerStream) error) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			request := newRequest()
+			if err := stream.RecvMsg(request); err != nil {
+				return err
+			}
+			return handle(request, stream)
+		},
+	}
+}
+
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: grpcServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unaryMethod("Revert", func() protoUnmarshaler { return &DirArgs{} }, func(ctx context.Context, request interface{}) (interface{}, error) {
+			return grpcRevert(ctx, request.(*DirArgs))
+		}),
+		unaryMethod("Verify", func() protoUnmarshaler { return &DirArgs{} }, func(ctx context.Context, request interface{}) (interface{}, error) {
+			reply := &VerifyReply{}
+			err := (&Service{}).Verify(request.(*DirArgs), reply)
+			return reply, err
+		}),
+		unaryMethod("CacheStatus", func() protoUnmarshaler { return &emptyMessage{} }, func(ctx context.Context, request interface{}) (interface{}, error) {
+			return &CacheReply{Artifacts: manager.LocalArtifacts()}, nil
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		streamMethod("Beautify", func() protoUnmarshaler { return &BeautifyRequest{} }, func(request interface{}, stream grpc.ServerStream) error {
+			job, err := Submit(*request.(*BeautifyRequest))
+			if err != nil && job.ID == "" {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			return streamProgress(stream, job.ID, 0, 0)
+		}),
+		streamMethod("Watch", func() protoUnmarshaler { return &watchRequest{} }, func(request interface{}, stream grpc.ServerStream) error {
+			watch := request.(*watchRequest)
+			if _, err := Lookup(watch.ID); err != nil {
+				return status.Error(codes.NotFound, err.Error())
+			}
+			return streamProgress(stream, watch.ID, watch.Offset, watch.LogOffset)
+		}),
+	},
+	Metadata: "netbeauty.proto",
+}
+
+// streamProgress 将任务的进度发送到stream，直到任务结束
+func streamProgress(stream grpc.ServerStream, id string, offset int, logOffset int) error {
+	err := Watch(stream.Context(), id, offset, logOffset, func(job Job) error {
+		offset += len(job.Events)
+		logOffset += len(job.Log)
+		return stream.SendMsg(&progress{Job: job, Offset: offset, LogOffset: logOffset})
+	})
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return status.FromContextError(err).Err()
+	}
+	return err
+}
+
+// grpcRevert 撤销目录的beauty，与beauty任务共用同一把锁，正在运行的任务结束后才开始
+func grpcRevert(ctx context.Context, args *DirArgs) (*emptyMessage, error) {
+	dir, err := filepath.Abs(args.Dir)
+	if err != nil || args.Dir == "" {
+		return nil, status.Error(codes.InvalidArgument, "dir is required")
+	}
+	if err := beauty.Revert(ctx, beauty.Options{Dir: dir}); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &emptyMessage{}, nil
+}
+
+// newGRPCServer 注册了NetBeauty服务的gRPC服务器
+func newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(grpc.ForceServerCodec(protoCodec{}))
+	server.RegisterService(&grpcServiceDesc, &grpcService{})
+	return server
+}
+
+// ServeGRPC 在addr上提供gRPC服务，阻塞直到监听失败
+func ServeGRPC(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.LogInfo("grpc listening on " + listener.Addr().String())
+
+	return newGRPCServer().Serve(listener)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// progressReply 测试中解码的netbeauty.Progress
+type progressReply struct {
+	ID        string
+	Status    string
+	Events    []string
+	Error     string
+	HasResult bool
+	Offset    int
+}
+
+func (p *progressReply) unmarshalProto(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, field protoField) {
+		switch num {
+		case 1:
+			p.ID = string(field.Bytes)
+		case 2:
+			p.Status = string(field.Bytes)
+		case 3:
+			decodeFields(field.Bytes, func(num protowire.Number, field protoField) {
+				if num == 1 {
+					p.Events = append(p.Events, string(field.Bytes))
+				}
+			})
+		case 5:
+			p.HasResult = true
+		case 6:
+			p.Error = string(field.Bytes)
+		case 7:
+			p.Offset = int(field.Varint)
+		}
+	})
+}
+
+// verifyReply 测试中解码的netbeauty.VerifyReply
+type verifyReply struct {
+	Valid  bool
+	Reason string
+}
+
+func (r *verifyReply) unmarshalProto(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, field protoField) {
+		switch num {
+		case 1:
+			r.Valid = field.Varint != 0
+		case 2:
+			r.Reason = string(field.Bytes)
+		}
+	})
+}
+
+func dialTestServer(t *testing.T) (*grpc.ClientConn, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newGRPCServer()
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(protoCodec{})))
+	if err != nil {
+		server.Stop()
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// receiveProgress 接收流中的所有进度直到服务端结束
+func receiveProgress(t *testing.T, stream grpc.ClientStream) []progressReply {
+	replies := make([]progressReply, 0)
+	for {
+		var reply progressReply
+		err := stream.RecvMsg(&reply)
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func TestGRPCBeautifyStreamsProgress(t *testing.T) {
+	conn, stop := dialTestServer(t)
+	defer stop()
+
+	tmp, err := ioutil.TempDir("", "nbeauty-grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	desc := &grpc.StreamDesc{StreamName: "Beautify", ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, "/netbeauty.NetBeauty/Beautify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&BeautifyRequest{Dir: filepath.Join(tmp, "missing")}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+
+	replies := receiveProgress(t, stream)
+	if len(replies) == 0 {
+		t.Fatal("no progress has been streamed")
+	}
+	last := replies[len(replies)-1]
+	if last.Status != Failed || last.Error == "" || !last.HasResult {
+		t.Errorf("last progress = %+v, want a failed job with its result", last)
+	}
+	for _, reply := range replies[:len(replies)-1] {
+		if reply.Status == Succeeded || reply.Status == Failed {
+			t.Errorf("progress after the job has finished: %+v", reply)
+		}
+	}
+
+	// 重新连接到已结束的任务，从offset开始只返回之后的事件
+	stream, err = conn.NewStream(ctx, &grpc.StreamDesc{StreamName: "Watch", ServerStreams: true}, "/netbeauty.NetBeauty/Watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(&watchRequest{ID: last.ID, Offset: last.Offset}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	watched := receiveProgress(t, stream)
+	if len(watched) != 1 || watched[0].Status != Failed || len(watched[0].Events) != 0 || watched[0].Offset != last.Offset {
+		t.Errorf("watching the finished job = %+v, want only its final status", watched)
+	}
+}
+
+func TestGRPCUnary(t *testing.T) {
+	conn, stop := dialTestServer(t)
+	defer stop()
+
+	tmp, err := ioutil.TempDir("", "nbeauty-grpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var verify verifyReply
+	if err := conn.Invoke(ctx, "/netbeauty.NetBeauty/Verify", &DirArgs{Dir: tmp}, &verify); err != nil {
+		t.Fatal(err)
+	}
+	if verify.Valid || verify.Reason == "" {
+		t.Errorf("Verify of a plain directory = %+v, want invalid with a reason", verify)
+	}
+
+	err = conn.Invoke(ctx, "/netbeauty.NetBeauty/Revert", &DirArgs{Dir: tmp}, &emptyMessage{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Revert of a plain directory = %v, want FailedPrecondition", err)
+	}
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{StreamName: "Watch", ServerStreams: true}, "/netbeauty.NetBeauty/Watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.SendMsg(&watchRequest{ID: "missing"})
+	stream.CloseSend()
+	if err := stream.RecvMsg(&progressReply{}); status.Code(err) != codes.NotFound {
+		t.Errorf("Watch of an unknown job = %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+type httpError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Handler HTTP/JSON接口
+//   POST /beautify    提交任务（BeautifyRequest），返回202及任务，Location为状态地址
+//   GET  /status/{id} 任务状态、结果及事件
+//   GET  /cache       本地缓存的补丁
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/beautify", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeJSON(w, http.StatusMethodNotAllowed, httpError{"POST only"})
+			return
+		}
+		var request BeautifyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSON(w, http.StatusBadRequest, httpError{err.Error()})
+			return
+		}
+		job, err := Submit(request)
+		if err != nil && job.ID == "" {
+			writeJSON(w, http.StatusBadRequest, httpError{err.Error()})
+			return
+		}
+		w.Header().Set("Location", "/status/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	})
+
+	mux.HandleFunc("/status/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writeJSON(w, http.StatusMethodNotAllowed, httpError{"GET only"})
+			return
+		}
+		job, err := Lookup(strings.TrimPrefix(r.URL.Path, "/status/"))
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, httpError{err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+
+	mux.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writeJSON(w, http.StatusMethodNotAllowed, httpError{"GET only"})
+			return
+		}
+		writeJSON(w, http.StatusOK, CacheReply{Artifacts: manager.LocalArtifacts()})
+	})
+
+	return mux
+}
+
+// ServeHTTP 在addr上提供HTTP/JSON接口，阻塞直到监听失败
+func ServeHTTP(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.LogInfo("http listening on " + listener.Addr().String())
+
+	return http.Serve(listener, Handler())
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	event "github.com/nulastudio/NetBeauty/src/event"
+	log "github.com/nulastudio/NetBeauty/src/log"
+)
+
+// 任务状态
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// BeautifyRequest 一次beauty请求，未列出的选项使用beauty.DefaultOptions
+type BeautifyRequest struct {
+	Dir               string `json:"dir"`
+	LibsDir           string `json:"libsDir,omitempty"`
+	Excludes          string `json:"excludes,omitempty"`
+	Hiddens           string `json:"hiddens,omitempty"`
+	Strategy          string `json:"strategy,omitempty"`
+	Provider          string `json:"provider,omitempty"`
+	SharedRuntimeMode bool   `json:"sharedRuntimeMode,omitempty"`
+	EnableDebug       bool   `json:"enableDebug,omitempty"`
+	Force             bool   `json:"force,omitempty"`
+	Repair            string `json:"repair,omitempty"`
+	Durable           bool   `json:"durable,omitempty"`
+	LowMemory         bool   `json:"lowMemory,omitempty"`
+	IOProfile         string `json:"ioProfile,omitempty"`
+	Incremental       bool   `json:"incremental,omitempty"`
+	DirMode           string `json:"dirMode,omitempty"`
+	OutDir            string `json:"outDir,omitempty"`
+	FileMode          string `json:"fileMode,omitempty"`
+}
+
+func (r BeautifyRequest) options() beauty.Options {
+	opts := beauty.DefaultOptions()
+	opts.Dir, _ = filepath.Abs(r.Dir)
+	opts.LibsDir = r.LibsDir
+	opts.Excludes = r.Excludes
+	opts.Hiddens = r.Hiddens
+	opts.Strategy = r.Strategy
+	opts.Provider = r.Provider
+	opts.SharedRuntimeMode = r.SharedRuntimeMode
+	opts.EnableDebug = r.EnableDebug
+	opts.Force = r.Force
+	opts.Repair = r.Repair
+	opts.Durable = r.Durable
+	opts.LowMemory = r.LowMemory
+	opts.IOProfile = r.IOProfile
+	opts.Incremental = r.Incremental
+	opts.DirMode = r.DirMode
+	opts.OutDir = r.OutDir
+	opts.FileMode = r.FileMode
+	return opts
+}
+
+// Job 一个beauty任务，Events为任务运行期间产生的事件
+type Job struct {
+	ID       string          `json:"id"`
+	Status   string          `json:"status"`
+	Request  BeautifyRequest `json:"request"`
+	Created  time.Time       `json:"created"`
+	Finished *time.Time      `json:"finished,omitempty"`
+	Result   *beauty.Result  `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Events   []event.Event   `json:"events,omitempty"`
+	Log      []string        `json:"log,omitempty"`
+
+	// updated 任务有新的状态、事件或日志时关闭并替换，用于流式返回进度
+	updated chan struct{}
+}
+
+// notify 唤醒等待该任务更新的Watch，调用时需持有jobsMutex
+func (job *Job) notify() {
+	close(job.updated)
+	job.updated = make(chan struct{})
+}
+
+const (
+	// finishedJobTTL 结束的任务保留多久，之后Progress返回no such job
+	finishedJobTTL = time.Hour
+	// maxFinishedJobs 最多保留的结束的任务数，超出时先删除最早结束的
+	maxFinishedJobs = 256
+)
+
+// jobs 所有任务，同一时间只运行一个（beauty.Beautify本身不能并发）
+var jobs = make(map[string]*Job)
+var jobsMutex sync.Mutex
+var queue = make(chan *Job, 64)
+var startWorker sync.Once
+
+func newJobID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// Submit 将请求加入队列，返回任务的快照
+func Submit(request BeautifyRequest) (Job, error) {
+	if request.Dir == "" {
+		return Job{}, errors.New("dir is required")
+	}
+
+	job := &Job{
+		ID:      newJobID(),
+		Status:  Queued,
+		Request: request,
+		Created: time.Now().UTC(),
+		updated: make(chan struct{}),
+	}
+
+	jobsMutex.Lock()
+	evictJobs(job.Created)
+	jobs[job.ID] = job
+	jobsMutex.Unlock()
+
+	startWorker.Do(func() { go worker() })
+
+	select {
+	case queue <- job:
+	default:
+		finish(job, nil, errors.New("too many queued jobs"))
+	}
+
+	return Lookup(job.ID)
+}
+
+// evictJobs 删除过期及超出数量的结束的任务，调用时需持有jobsMutex
+func evictJobs(now time.Time) {
+	finished := make([]*Job, 0)
+	for id, job := range jobs {
+		if job.Finished == nil {
+			continue
+		}
+		if now.Sub(*job.Finished) > finishedJobTTL {
+			delete(jobs, id)
+			continue
+		}
+		finished = append(finished, job)
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Finished.Before(*finished[j].Finished) })
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(jobs, job.ID)
+	}
+}
+
+// Lookup 返回任务的快照
+func Lookup(id string) (Job, error) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return Job{}, fmt.Errorf("no such job: %s", id)
+	}
+	snapshot := *job
+	snapshot.Events = append([]event.Event{}, job.Events...)
+	snapshot.Log = append([]string{}, job.Log...)
+	snapshot.updated = nil
+	return snapshot, nil
+}
+
+// Watch 从第offset个事件、第logOffset行日志开始，每当任务有更新时以新的事件及日志调用fn，
+// 任务结束、ctx取消或fn返回错误时返回
+func Watch(ctx context.Context, id string, offset int, logOffset int, fn func(Job) error) error {
+	for {
+		jobsMutex.Lock()
+		job, ok := jobs[id]
+		if !ok {
+			jobsMutex.Unlock()
+			return fmt.Errorf("no such job: %s", id)
+		}
+		snapshot := *job
+		snapshot.updated = nil
+		snapshot.Events = nil
+		snapshot.Log = nil
+		if offset < len(job.Events) {
+			snapshot.Events = append([]event.Event{}, job.Events[offset:]...)
+			offset = len(job.Events)
+		}
+		if logOffset < len(job.Log) {
+			snapshot.Log = append([]string{}, job.Log[logOffset:]...)
+			logOffset = len(job.Log)
+		}
+		updated := job.updated
+		jobsMutex.Unlock()
+
+		if err := fn(snapshot); err != nil {
+			return err
+		}
+		if snapshot.Finished != nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-updated:
+		}
+	}
+}
+
+func worker() {
+	for job := range queue {
+		run(job)
+	}
+}
+
+func run(job *Job) {
+	jobsMutex.Lock()
+	job.Status = Running
+	job.notify()
+	jobsMutex.Unlock()
+
+	opts := job.Request.options()
+	opts.OnEvent = func(e event.Event) {
+		jobsMutex.Lock()
+		job.Events = append(job.Events, e)
+		job.notify()
+		jobsMutex.Unlock()
+	}
+	opts.Logger = log.HandlerFunc(func(message string, level log.LogLevel, fields log.Fields) {
+		jobsMutex.Lock()
+		job.Log = append(job.Log, message)
+		job.notify()
+		jobsMutex.Unlock()
+		log.DefaultLogger.LogFields(message, level, fields)
+	})
+
+	result, err := beauty.Beautify(context.Background(), opts)
+	finish(job, &result, err)
+}
+
+func finish(job *Job, result *beauty.Result, err error) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+
+	finished := time.Now().UTC()
+	job.Finished = &finished
+	job.Result = result
+	if err != nil {
+		job.Status = Failed
+		job.Error = err.Error()
+	} else {
+		job.Status = Succeeded
+	}
+	job.notify()
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEvictJobs(t *testing.T) {
+	defer func(saved map[string]*Job) { jobs = saved }(jobs)
+	jobs = make(map[string]*Job)
+
+	now := time.Now().UTC()
+	expired := now.Add(-finishedJobTTL - time.Minute)
+	jobs["running"] = &Job{ID: "running", Status: Running}
+	jobs["expired"] = &Job{ID: "expired", Status: Succeeded, Finished: &expired}
+	for i := 0; i < maxFinishedJobs+2; i++ {
+		finished := now.Add(time.Duration(i-maxFinishedJobs-2) * time.Second)
+		id := fmt.Sprintf("job%d", i)
+		jobs[id] = &Job{ID: id, Status: Failed, Finished: &finished}
+	}
+
+	evictJobs(now)
+	for _, id := range []string{"expired", "job0", "job1"} {
+		if _, ok := jobs[id]; ok {
+			t.Errorf("%s has not been evicted", id)
+		}
+	}
+	for _, id := range []string{"running", "job2", fmt.Sprintf("job%d", maxFinishedJobs+1)} {
+		if _, ok := jobs[id]; !ok {
+			t.Errorf("%s has been evicted", id)
+		}
+	}
+	if len(jobs) != maxFinishedJobs+1 {
+		t.Errorf("%d jobs left, want %d", len(jobs), maxFinishedJobs+1)
+	}
+}
@@ -0,0 +1,344 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// netbeauty.proto中的消息，不使用protoc生成的代码，按字段号手工编码为protobuf wire格式
+
+// protoMarshaler 可编码为netbeauty.proto中的消息
+type protoMarshaler interface {
+	marshalProto() ([]byte, error)
+}
+
+// protoUnmarshaler 可从netbeauty.proto中的消息解码
+type protoUnmarshaler interface {
+	unmarshalProto(b []byte) error
+}
+
+// protoCodec gRPC编解码，名称为proto，与protoc生成的客户端兼容
+type protoCodec struct{}
+
+func (protoCodec) Name() string {
+	return "proto"
+}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(protoMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("cannot marshal %T", v)
+	}
+	return message.marshalProto()
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(protoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal %T", v)
+	}
+	return message.unmarshalProto(data)
+}
+
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+func appendBool(b []byte, num protowire.Number, value bool) []byte {
+	if !value {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func appendInt(b []byte, num protowire.Number, value int64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(value))
+}
+
+// appendMessage 追加子消息，子消息为空时也会追加（表示已设置）
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// marshalStruct 将可json序列化的value编码为google.protobuf.Struct
+func marshalStruct(value interface{}) ([]byte, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(bytes, &fields); err != nil {
+		return nil, err
+	}
+	message, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(message)
+}
+
+// protoField 解码出的字段值，varint类型为Varint，length-delimited类型为Bytes
+type protoField struct {
+	Varint uint64
+	Bytes  []byte
+}
+
+// decodeFields 依次以每个字段调用fn，跳过fixed32/fixed64等未使用的类型
+func decodeFields(b []byte, fn func(num protowire.Number, field protoField)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var field protoField
+		switch typ {
+		case protowire.VarintType:
+			field.Varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			field.Bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+			continue
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		fn(num, field)
+	}
+	return nil
+}
+
+// emptyMessage netbeauty.Empty
+type emptyMessage struct{}
+
+func (*emptyMessage) marshalProto() ([]byte, error) {
+	return nil, nil
+}
+
+func (*emptyMessage) unmarshalProto(b []byte) error {
+	return decodeFields(b, func(protowire.Number, protoField) {})
+}
+
+// netbeauty.BeautifyRequest
+func (r *BeautifyRequest) marshalProto() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, r.Dir)
+	b = appendString(b, 2, r.LibsDir)
+	b = appendString(b, 3, r.Excludes)
+	b = appendString(b, 4, r.Hiddens)
+	b = appendString(b, 5, r.Strategy)
+	b = appendString(b, 6, r.Provider)
+	b = appendBool(b, 7, r.SharedRuntimeMode)
+	b = appendBool(b, 8, r.EnableDebug)
+	b = appendBool(b, 9, r.Force)
+	b = appendString(b, 10, r.Repair)
+	b = appendBool(b, 11, r.Durable)
+	b = appendBool(b, 12, r.LowMemory)
+	b = appendString(b, 13, r.IOProfile)
+	b = appendBool(b, 14, r.Incremental)
+	b = appendString(b, 15, r.DirMode)
+	b = appendString(b, 16, r.OutDir)
+	b = appendString(b, 17, r.FileMode)
+	return b, nil
+}
+
+func (r *BeautifyRequest) unmarshalProto(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, field protoField) {
+		switch num {
+		case 1:
+			r.Dir = string(field.Bytes)
+		case 2:
+			r.LibsDir = string(field.Bytes)
+		case 3:
+			r.Excludes = string(field.Bytes)
+		case 4:
+			r.Hiddens = string(field.Bytes)
+		case 5:
+			r.Strategy = string(field.Bytes)
+		case 6:
+			r.Provider = string(field.Bytes)
+		case 7:
+			r.SharedRuntimeMode = field.Varint != 0
+		case 8:
+			r.EnableDebug = field.Varint != 0
+		case 9:
+			r.Force = field.Varint != 0
+		case 10:
+			r.Repair = string(field.Bytes)
+		case 11:
+			r.Durable = field.Varint != 0
+		case 12:
+			r.LowMemory = field.Varint != 0
+		case 13:
+			r.IOProfile = string(field.Bytes)
+		case 14:
+			r.Incremental = field.Varint != 0
+		case 15:
+			r.DirMode = string(field.Bytes)
+		case 16:
+			r.OutDir = string(field.Bytes)
+		case 17:
+			r.FileMode = string(field.Bytes)
+		}
+	})
+}
+
+// watchRequest netbeauty.WatchRequest
+type watchRequest struct {
+	ID        string
+	Offset    int
+	LogOffset int
+}
+
+func (r *watchRequest) marshalProto() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, r.ID)
+	b = appendInt(b, 2, int64(r.Offset))
+	b = appendInt(b, 3, int64(r.LogOffset))
+	return b, nil
+}
+
+func (r *watchRequest) unmarshalProto(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, field protoField) {
+		switch num {
+		case 1:
+			r.ID = string(field.Bytes)
+		case 2:
+			r.Offset = int(int32(field.Varint))
+		case 3:
+			r.LogOffset = int(int32(field.Varint))
+		}
+	})
+}
+
+// netbeauty.DirRequest
+func (r *DirArgs) marshalProto() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, r.Dir)
+	b = appendString(b, 2, r.LibsDir)
+	return b, nil
+}
+
+func (r *DirArgs) unmarshalProto(b []byte) error {
+	return decodeFields(b, func(num protowire.Number, field protoField) {
+		switch num {
+		case 1:
+			r.Dir = string(field.Bytes)
+		case 2:
+			r.LibsDir = string(field.Bytes)
+		}
+	})
+}
+
+// progress netbeauty.Progress，Job中只包含新的事件及日志，Offset/LogOffset为目前为止的总数
+type progress struct {
+	Job       Job
+	Offset    int
+	LogOffset int
+}
+
+func (p *progress) marshalProto() ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, p.Job.ID)
+	b = appendString(b, 2, p.Job.Status)
+	for _, e := range p.Job.Events {
+		var message []byte
+		message = appendString(message, 1, e.Type)
+		message = appendString(message, 2, e.Timestamp)
+		message = appendString(message, 3, e.RunID)
+		if e.Data != nil {
+			data, err := marshalStruct(e.Data)
+			if err != nil {
+				return nil, err
+			}
+			message = appendMessage(message, 4, data)
+		}
+		b = appendMessage(b, 3, message)
+	}
+	for _, line := range p.Job.Log {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendString(b, line)
+	}
+	if result := p.Job.Result; result != nil {
+		var message []byte
+		message = appendBool(message, 1, result.Modified)
+		message = appendInt(message, 2, int64(result.Dirs))
+		message = appendInt(message, 3, int64(result.RootFilesBefore))
+		message = appendInt(message, 4, int64(result.RootFilesAfter))
+		message = appendInt(message, 5, int64(result.MovedFiles))
+		message = appendInt(message, 6, int64(result.SkippedFiles))
+		message = appendInt(message, 7, int64(result.LeftFiles))
+		message = appendInt(message, 8, int64(result.FailedFiles))
+		message = appendInt(message, 9, int64(result.Conflicts))
+		message = appendInt(message, 10, result.MovedBytes)
+		message = appendInt(message, 11, result.LibsDirSize)
+		message = appendInt(message, 12, int64(result.RewrittenFiles))
+		message = appendString(message, 13, result.Patch)
+		message = appendString(message, 14, result.FXRVersion)
+		message = appendString(message, 15, result.RID)
+		message = appendInt(message, 16, int64(result.Elapsed/1e6))
+		b = appendMessage(b, 5, message)
+	}
+	b = appendString(b, 6, p.Job.Error)
+	b = appendInt(b, 7, int64(p.Offset))
+	b = appendInt(b, 8, int64(p.LogOffset))
+	return b, nil
+}
+
+// netbeauty.VerifyReply
+func (r *VerifyReply) marshalProto() ([]byte, error) {
+	var b []byte
+	b = appendBool(b, 1, r.Valid)
+	b = appendString(b, 2, r.Reason)
+	if r.Marker != nil {
+		marker, err := marshalStruct(r.Marker)
+		if err != nil {
+			return nil, err
+		}
+		b = appendMessage(b, 3, marker)
+	}
+	return b, nil
+}
+
+// netbeauty.CacheReply，map按key/value为字段1/2的消息编码
+func (r *CacheReply) marshalProto() ([]byte, error) {
+	var b []byte
+	for _, key := range sortedKeys(r.Artifacts) {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, r.Artifacts[key])
+		b = appendMessage(b, 1, entry)
+	}
+	return b, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
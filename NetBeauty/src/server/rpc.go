@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+
+	beauty "github.com/nulastudio/NetBeauty/src/beauty"
+	log "github.com/nulastudio/NetBeauty/src/log"
+	manager "github.com/nulastudio/NetBeauty/src/manager"
+)
+
+// Service JSON-RPC服务，方法名为NetBeauty.<Method>，供不使用gRPC的客户端
+// net/rpc不支持服务端流，进度通过Progress按Offset轮询获取
+type Service struct{}
+
+// ProgressArgs 获取任务进度，只返回第Offset个之后的事件
+type ProgressArgs struct {
+	ID     string `json:"id"`
+	Offset int    `json:"offset"`
+}
+
+// DirArgs 针对某个目录的请求
+type DirArgs struct {
+	Dir     string `json:"dir"`
+	LibsDir string `json:"libsDir,omitempty"`
+}
+
+// VerifyReply 目录的beauty状态，Valid为false时Reason为原因
+type VerifyReply struct {
+	Valid  bool                  `json:"valid"`
+	Reason string                `json:"reason,omitempty"`
+	Marker *manager.BeautyMarker `json:"marker,omitempty"`
+}
+
+// CacheReply 本地缓存的补丁，key为version/rid
+type CacheReply struct {
+	Artifacts map[string]string `json:"artifacts"`
+}
+
+// Beautify 提交beauty任务，立即返回，通过Progress轮询进度
+func (s *Service) Beautify(args *BeautifyRequest, reply *Job) error {
+	job, err := Submit(*args)
+	*reply = job
+	return err
+}
+
+// Progress 返回任务状态及Offset之后的新事件
+func (s *Service) Progress(args *ProgressArgs, reply *Job) error {
+	job, err := Lookup(args.ID)
+	if err != nil {
+		return err
+	}
+	if args.Offset > 0 && args.Offset <= len(job.Events) {
+		job.Events = job.Events[args.Offset:]
+	} else if args.Offset > len(job.Events) {
+		job.Events = nil
+	}
+	*reply = job
+	return nil
+}
+
+// RestoreFXR 还原目录中的原始hostfxr
+func (s *Service) RestoreFXR(args *DirArgs, reply *bool) error {
+	err := beauty.RestoreFXR(context.Background(), beauty.Options{Dir: args.Dir})
+	*reply = err == nil
+	return err
+}
+
+// Revert 撤销目录的beauty
+func (s *Service) Revert(args *DirArgs, reply *bool) error {
+	_, err := grpcRevert(context.Background(), args)
+	*reply = err == nil
+	return err
+}
+
+// Verify 检查目录是否已beauty且之后未被修改
+func (s *Service) Verify(args *DirArgs, reply *VerifyReply) error {
+	libsDir := args.LibsDir
+	if libsDir == "" {
+		libsDir = "libraries"
+	}
+	marker, err := manager.ValidateBeautyMarker(args.Dir, libsDir)
+	reply.Valid = err == nil
+	reply.Marker = marker
+	if err != nil {
+		reply.Reason = err.Error()
+	}
+	return nil
+}
+
+// CacheStatus 返回本地缓存的补丁
+func (s *Service) CacheStatus(args *struct{}, reply *CacheReply) error {
+	reply.Artifacts = manager.LocalArtifacts()
+	return nil
+}
+
+// ServeRPC 在addr上提供JSON-RPC（每行一个请求），阻塞直到监听失败
+func ServeRPC(addr string) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("NetBeauty", &Service{}); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.LogInfo("json-rpc listening on " + listener.Addr().String())
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
@@ -0,0 +1,223 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+)
+
+// FS 只读文件系统，方法与fs.FS、fs.ReadFileFS、fs.StatFS、fs.ReadDirFS一致
+// （go.mod为Go 1.12，没有io/fs），路径为本地路径而不是fs.FS的"/"分隔相对路径
+type FS interface {
+	Open(name string) (io.ReadCloser, error)
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (os.FileInfo, error)
+	// ReadDir 按文件名排序返回目录中的项
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// WriteFS 可写的文件系统
+type WriteFS interface {
+	FS
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Chmod(name string, perm os.FileMode) error
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+	// Rename 移动文件，目标目录必须存在
+	Rename(oldname string, newname string) error
+	Remove(name string) error
+}
+
+// FileSystem util、deps.json等文件的修改及依赖的移动所使用的文件系统，
+// 默认为本地磁盘，可替换为NewMemFS()等实现
+// 落盘（Sync）、跨分区移动、deps.json的临时文件只在本地磁盘上进行，
+// 锁文件及journal也只在本地磁盘上使用，其他实现中不会创建
+var FileSystem WriteFS = OSFS{}
+
+// OSFS 本地磁盘
+type OSFS struct{}
+
+func (OSFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (OSFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (OSFS) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (OSFS) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+func (OSFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := ioutil.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	return Sync(name)
+}
+
+func (OSFS) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSFS) Chmod(name string, perm os.FileMode) error {
+	return os.Chmod(name, perm)
+}
+
+func (OSFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Rename 文件被占用时重试（最多等待WaitForUnlock），跨分区时退化为复制+校验+删除
+func (OSFS) Rename(oldname string, newname string) error {
+	delay := lockRetryDelay
+	deadline := time.Now().Add(WaitForUnlock)
+	err := os.Rename(oldname, newname)
+	for i := 0; err != nil && misc.IsLockError(err); i++ {
+		// 之后只在确实被其他进程占用时继续等待
+		if i >= lockRetryCount {
+			if !time.Now().Before(deadline) {
+				break
+			}
+			if holders, _ := misc.FilesInUseBy([]string{oldname}); len(holders) == 0 {
+				break
+			}
+		}
+		time.Sleep(delay)
+		if i < lockRetryCount {
+			delay *= 2
+		} else {
+			delay = time.Second
+		}
+		err = os.Rename(oldname, newname)
+	}
+	if err != nil && misc.IsLockError(err) {
+		if holders, _ := misc.FilesInUseBy([]string{oldname}); len(holders) != 0 {
+			return fmt.Errorf("%s is in use by %s: %s", oldname, strings.Join(holders, ", "), err.Error())
+		}
+	}
+	// libsDir位于其他分区/挂载点时无法直接rename
+	if err != nil && misc.IsCrossDeviceError(err) {
+		err = moveAcrossDevice(oldname, newname)
+	}
+	if err != nil || !Durable {
+		return err
+	}
+	// 新旧两个目录项都要落盘
+	if err := misc.SyncDir(filepath.Dir(newname)); err != nil {
+		return err
+	}
+	if filepath.Dir(oldname) != filepath.Dir(newname) {
+		return misc.SyncDir(filepath.Dir(oldname))
+	}
+	return nil
+}
+
+// Sync Durable时将本地磁盘上的name及其所在目录写入磁盘，否则不做任何事
+func Sync(name string) error {
+	if !LocalDisk() || !Durable {
+		return nil
+	}
+	// Windows下刷新需要写权限，只读文件在其他系统上以只读打开即可
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		if f, err = os.Open(name); err != nil {
+			return err
+		}
+	}
+	err = f.Sync()
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return misc.SyncDir(filepath.Dir(name))
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// Glob 与filepath.Glob相同，但只匹配pattern中的文件名部分，在FileSystem中查找
+func Glob(pattern string) ([]string, error) {
+	if LocalDisk() {
+		return filepath.Glob(pattern)
+	}
+
+	dir, base := filepath.Split(pattern)
+	if _, err := filepath.Match(base, ""); err != nil {
+		return nil, err
+	}
+	fis, err := FileSystem.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, nil
+	}
+	matches := make([]string, 0)
+	for _, fi := range fis {
+		if ok, _ := filepath.Match(base, fi.Name()); ok {
+			matches = append(matches, filepath.Join(dir, fi.Name()))
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// copyWithin 在非本地磁盘的FileSystem中复制文件
+func copyWithin(src string, des string) (int64, error) {
+	fi, err := FileSystem.Stat(src)
+	if err != nil {
+		return 0, err
+	}
+	if fi.IsDir() {
+		return 0, errors.New(src + " is a directory")
+	}
+	content, err := FileSystem.ReadFile(src)
+	if err != nil {
+		return 0, err
+	}
+	if err := FileSystem.WriteFile(des, content, fi.Mode()); err != nil {
+		return 0, err
+	}
+	return int64(len(content)), nil
+}
+
+// WriteFileAtomic 先写入同目录下的临时文件再重命名为name，并行的进程不会读到写了一半的文件，总是写入本地磁盘
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	if err := CheckWrite("write", name); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if err == nil && Durable {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	// TempFile创建的文件权限为0600
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = OSFS{}.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
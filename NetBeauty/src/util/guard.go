@@ -0,0 +1,134 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UnmanagedPathError 修改的路径不在允许的目录之中，说明deps.json或布局的规划有误
+type UnmanagedPathError struct {
+	Op   string
+	Path string
+}
+
+func (e *UnmanagedPathError) Error() string {
+	return fmt.Sprintf("planning error: refusing to %s %s, it is outside of the directories nbeauty manages", e.Op, e.Path)
+}
+
+// guardFS 只允许修改roots之下的文件，读取及允许的修改交给内部的WriteFS
+type guardFS struct {
+	WriteFS
+	roots   []string
+	refused []error
+}
+
+// GuardWrites 之后FileSystem只允许修改roots之下（包括roots本身）的文件，
+// 返回的函数恢复原来的FileSystem并返回被拒绝的修改，可多次调用
+func GuardWrites(roots ...string) func() []error {
+	previous := FileSystem
+	guard := &guardFS{WriteFS: previous}
+	for _, root := range roots {
+		guard.allow(root)
+	}
+	FileSystem = guard
+	return func() []error {
+		if FileSystem == guard {
+			FileSystem = previous
+		}
+		return guard.refused
+	}
+}
+
+// AllowWrites 运行中创建的临时目录等也允许修改，没有GuardWrites时不做任何事
+func AllowWrites(dir string) {
+	if guard, ok := FileSystem.(*guardFS); ok {
+		guard.allow(dir)
+	}
+}
+
+// CheckWrite 不经过FileSystem直接写入本地磁盘前检查name是否允许修改
+func CheckWrite(op string, name string) error {
+	if guard, ok := FileSystem.(*guardFS); ok {
+		return guard.check(op, name)
+	}
+	return nil
+}
+
+// LocalDisk FileSystem（不计GuardWrites）是否为本地磁盘
+func LocalDisk() bool {
+	fsys := FileSystem
+	if guard, ok := fsys.(*guardFS); ok {
+		fsys = guard.WriteFS
+	}
+	_, ok := fsys.(OSFS)
+	return ok
+}
+
+func (g *guardFS) allow(root string) {
+	if root == "" {
+		return
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		g.roots = append(g.roots, abs)
+	}
+}
+
+func (g *guardFS) check(op string, name string) error {
+	if abs, err := filepath.Abs(name); err == nil {
+		for _, root := range g.roots {
+			if abs == root || PathWithin(root, abs) {
+				return nil
+			}
+		}
+	}
+	err := &UnmanagedPathError{Op: op, Path: name}
+	g.refused = append(g.refused, err)
+	return err
+}
+
+func (g *guardFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := g.check("write", name); err != nil {
+		return err
+	}
+	return g.WriteFS.WriteFile(name, data, perm)
+}
+
+func (g *guardFS) MkdirAll(name string, perm os.FileMode) error {
+	if err := g.check("create", name); err != nil {
+		return err
+	}
+	return g.WriteFS.MkdirAll(name, perm)
+}
+
+func (g *guardFS) Chmod(name string, perm os.FileMode) error {
+	if err := g.check("chmod", name); err != nil {
+		return err
+	}
+	return g.WriteFS.Chmod(name, perm)
+}
+
+func (g *guardFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if err := g.check("touch", name); err != nil {
+		return err
+	}
+	return g.WriteFS.Chtimes(name, atime, mtime)
+}
+
+func (g *guardFS) Rename(oldname string, newname string) error {
+	if err := g.check("move", oldname); err != nil {
+		return err
+	}
+	if err := g.check("move to", newname); err != nil {
+		return err
+	}
+	return g.WriteFS.Rename(oldname, newname)
+}
+
+func (g *guardFS) Remove(name string) error {
+	if err := g.check("remove", name); err != nil {
+		return err
+	}
+	return g.WriteFS.Remove(name)
+}
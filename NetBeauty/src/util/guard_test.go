@@ -0,0 +1,76 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGuardWrites(t *testing.T) {
+	root, err := ioutil.TempDir("", "nbeauty-guard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	managed := filepath.Join(root, "managed")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{managed, outside} {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inFile := filepath.Join(managed, "a.dll")
+	outFile := filepath.Join(outside, "a.dll")
+	if err := ioutil.WriteFile(inFile, []byte("a"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(outFile, []byte("outside"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	type refusal struct {
+		name string
+		err  error
+	}
+
+	release := GuardWrites(managed)
+	_, copyErr := CopyFile(inFile, filepath.Join(outside, "c.dll"))
+	refusals := []refusal{
+		{"write", FileSystem.WriteFile(outFile, []byte("x"), 0666)},
+		{"mkdir", FileSystem.MkdirAll(filepath.Join(outside, "sub"), 0777)},
+		{"remove", FileSystem.Remove(outFile)},
+		{"move out", MoveFile(inFile, filepath.Join(outside, "b.dll"))},
+		{"move in", MoveFile(outFile, filepath.Join(managed, "b.dll"))},
+		{"traversal", FileSystem.WriteFile(filepath.Join(managed, "..", "outside", "a.dll"), []byte("x"), 0666)},
+		{"atomic write", WriteFileAtomic(outFile, []byte("x"), 0666)},
+		{"copy", copyErr},
+	}
+	for _, refusal := range refusals {
+		if _, ok := refusal.err.(*UnmanagedPathError); !ok {
+			t.Errorf("%s out of the managed dir is not refused: %v", refusal.name, refusal.err)
+		}
+	}
+
+	if err := MoveFile(inFile, filepath.Join(managed, "sub", "..", "b.dll")); err != nil {
+		t.Errorf("move inside the managed dir is refused: %v", err)
+	}
+	AllowWrites(outside)
+	if err := FileSystem.WriteFile(filepath.Join(outside, "d.dll"), []byte("d"), 0666); err != nil {
+		t.Errorf("write to an allowed dir is refused: %v", err)
+	}
+
+	if refused := release(); len(refused) != len(refusals) {
+		t.Errorf("%d refusals recorded, want %d", len(refused), len(refusals))
+	}
+	if !LocalDisk() {
+		t.Error("FileSystem is not restored")
+	}
+	if content, _ := ioutil.ReadFile(outFile); string(content) != "outside" {
+		t.Errorf("%s has been changed: %s", outFile, content)
+	}
+	if err := CheckWrite("write", outFile); err != nil {
+		t.Errorf("writes are still guarded after release: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"os"
+
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+)
+
+// ErrLocked 锁已被其他进程持有
+var ErrLocked = misc.ErrLocked
+
+// FileLock 基于操作系统咨询锁（flock/LockFileEx）的锁文件，持有者退出或崩溃时锁随进程释放，不需要判断锁是否陈旧
+type FileLock struct {
+	path string
+	file *os.File
+}
+
+// TryLock 获取锁文件path并写入owner，已被其他进程持有时返回ErrLocked
+func TryLock(path string, owner []byte, perm os.FileMode) (*FileLock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, perm)
+		if err != nil {
+			return nil, err
+		}
+		if err := misc.TryLockFile(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		// 打开后上一个持有者可能已删除并释放了锁文件，锁住的不再是path时重新打开
+		locked, err := f.Stat()
+		current, statErr := os.Stat(path)
+		if err != nil || statErr != nil || !os.SameFile(locked, current) {
+			f.Close()
+			continue
+		}
+
+		f.Truncate(0)
+		f.WriteAt(owner, 0)
+		return &FileLock{path: path, file: f}, nil
+	}
+}
+
+// Unlock 删除锁文件并释放锁
+func (l *FileLock) Unlock() {
+	misc.ReleaseLockFile(l.file, l.path)
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTryLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbeauty-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.lock")
+
+	// 崩溃的持有者留下的锁文件没有被锁住，直接重用
+	if err := ioutil.WriteFile(path, []byte("crashed owner with a longer record"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := TryLock(path, []byte("owner"), 0644)
+	if err != nil {
+		t.Fatalf("the lock file left by a crashed owner is not reused: %s", err)
+	}
+	if _, err := TryLock(path, []byte("other"), 0644); err != ErrLocked {
+		t.Errorf("a held lock is taken again: %v", err)
+	}
+	if content, _ := ioutil.ReadFile(path); string(content) != "owner" {
+		t.Errorf("the owner cannot be read from a held lock: %q", content)
+	}
+	lock.Unlock()
+	if PathExists(path) {
+		t.Error("the lock file is not removed")
+	}
+}
+
+func TestTryLockIsExclusive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nbeauty-lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.lock")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				lock, err := TryLock(path, []byte("owner"), 0644)
+				if err == ErrLocked {
+					time.Sleep(time.Millisecond)
+					continue
+				} else if err != nil {
+					t.Error(err)
+					return
+				}
+
+				mu.Lock()
+				holders++
+				if holders > maxHolders {
+					maxHolders = holders
+				}
+				mu.Unlock()
+				time.Sleep(2 * time.Millisecond)
+				mu.Lock()
+				holders--
+				mu.Unlock()
+
+				lock.Unlock()
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("%d holders at the same time", maxHolders)
+	}
+	if fis, _ := ioutil.ReadDir(dir); len(fis) != 0 {
+		t.Errorf("%d file(s) left behind", len(fis))
+	}
+}
@@ -0,0 +1,227 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS 内存中的文件系统，用于不接触磁盘地测试FixDeps、moveDeps等
+type MemFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+type memFile struct {
+	name    string
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Size() int64        { return int64(len(f.data)) }
+func (f *memFile) Mode() os.FileMode  { return f.mode }
+func (f *memFile) ModTime() time.Time { return f.modTime }
+func (f *memFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}   { return nil }
+
+// NewMemFS 创建MemFS，files为需要预先写入的文件（路径 => 内容），父目录自动创建
+func NewMemFS(files map[string][]byte) *MemFS {
+	fsys := &MemFS{files: make(map[string]*memFile)}
+	for name, data := range files {
+		fsys.MkdirAll(filepath.Dir(name), DirMode)
+		fsys.WriteFile(name, data, FileMode)
+	}
+	return fsys
+}
+
+func memKey(name string) string {
+	return filepath.Clean(name)
+}
+
+func memError(op string, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// parentExists 调用时需持有mu
+func (fsys *MemFS) parentExists(name string) bool {
+	dir := filepath.Dir(memKey(name))
+	if dir == memKey(name) {
+		return true
+	}
+	parent, ok := fsys.files[dir]
+	return ok && parent.IsDir()
+}
+
+func (fsys *MemFS) Open(name string) (io.ReadCloser, error) {
+	content, err := fsys.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (fsys *MemFS) ReadFile(name string) ([]byte, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	f, ok := fsys.files[memKey(name)]
+	if !ok {
+		return nil, memError("open", name, os.ErrNotExist)
+	}
+	if f.IsDir() {
+		return nil, memError("read", name, os.ErrInvalid)
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (fsys *MemFS) Stat(name string) (os.FileInfo, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	f, ok := fsys.files[memKey(name)]
+	if !ok {
+		return nil, memError("stat", name, os.ErrNotExist)
+	}
+	copied := *f
+	return &copied, nil
+}
+
+func (fsys *MemFS) ReadDir(name string) ([]os.FileInfo, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	dir := memKey(name)
+	if f, ok := fsys.files[dir]; !ok || !f.IsDir() {
+		return nil, memError("readdir", name, os.ErrNotExist)
+	}
+	fis := make([]os.FileInfo, 0)
+	for key, f := range fsys.files {
+		if key != dir && filepath.Dir(key) == dir {
+			copied := *f
+			fis = append(fis, &copied)
+		}
+	}
+	sort.Slice(fis, func(i, j int) bool { return fis[i].Name() < fis[j].Name() })
+	return fis, nil
+}
+
+func (fsys *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	key := memKey(name)
+	if f, ok := fsys.files[key]; ok && f.IsDir() {
+		return memError("open", name, os.ErrInvalid)
+	}
+	if !fsys.parentExists(key) {
+		return memError("open", name, os.ErrNotExist)
+	}
+	fsys.files[key] = &memFile{name: filepath.Base(key), data: append([]byte(nil), data...), mode: perm, modTime: time.Now()}
+	return nil
+}
+
+func (fsys *MemFS) MkdirAll(name string, perm os.FileMode) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	key := memKey(name)
+	for {
+		if f, ok := fsys.files[key]; ok {
+			if !f.IsDir() {
+				return memError("mkdir", name, os.ErrExist)
+			}
+		} else {
+			fsys.files[key] = &memFile{name: filepath.Base(key), mode: os.ModeDir | perm, modTime: time.Now()}
+		}
+		parent := filepath.Dir(key)
+		if parent == key {
+			return nil
+		}
+		key = parent
+	}
+}
+
+func (fsys *MemFS) Chmod(name string, perm os.FileMode) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	f, ok := fsys.files[memKey(name)]
+	if !ok {
+		return memError("chmod", name, os.ErrNotExist)
+	}
+	f.mode = f.mode&os.ModeType | perm.Perm()
+	return nil
+}
+
+func (fsys *MemFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	f, ok := fsys.files[memKey(name)]
+	if !ok {
+		return memError("chtimes", name, os.ErrNotExist)
+	}
+	f.modTime = mtime
+	return nil
+}
+
+func (fsys *MemFS) Rename(oldname string, newname string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	from, to := memKey(oldname), memKey(newname)
+	f, ok := fsys.files[from]
+	if !ok {
+		return memError("rename", oldname, os.ErrNotExist)
+	}
+	if !fsys.parentExists(to) {
+		return memError("rename", newname, os.ErrNotExist)
+	}
+	if f.IsDir() && strings.HasPrefix(to, from+string(filepath.Separator)) {
+		return memError("rename", newname, os.ErrInvalid)
+	}
+
+	prefix := from + string(filepath.Separator)
+	children := make([]string, 0)
+	for key := range fsys.files {
+		if strings.HasPrefix(key, prefix) {
+			children = append(children, key)
+		}
+	}
+	for _, key := range children {
+		fsys.files[to+key[len(from):]] = fsys.files[key]
+		delete(fsys.files, key)
+	}
+	delete(fsys.files, from)
+	f.name = filepath.Base(to)
+	fsys.files[to] = f
+	return nil
+}
+
+func (fsys *MemFS) Remove(name string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+
+	key := memKey(name)
+	f, ok := fsys.files[key]
+	if !ok {
+		return memError("remove", name, os.ErrNotExist)
+	}
+	if f.IsDir() {
+		for other := range fsys.files {
+			if other != key && filepath.Dir(other) == key {
+				return memError("remove", name, os.ErrExist)
+			}
+		}
+	}
+	delete(fsys.files, key)
+	return nil
+}
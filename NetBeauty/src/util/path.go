@@ -0,0 +1,48 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extendedLengthPrefix Windows长路径前缀，之后的路径不会被系统规范化，只能使用\
+const extendedLengthPrefix = `\\?\`
+
+// AbsPath 去掉命令行参数两端的引号并转为绝对路径
+// \\?\开头的长路径已是绝对路径且不能被规范化（..是合法的文件名），原样返回
+func AbsPath(path string) (string, error) {
+	path = strings.Trim(path, `"`)
+	if IsExtendedLength(path) {
+		return path, nil
+	}
+	return filepath.Abs(path)
+}
+
+// IsExtendedLength path是否为\\?\开头的Windows长路径
+func IsExtendedLength(path string) bool {
+	return filepath.VolumeName(path) != "" && strings.HasPrefix(path, extendedLengthPrefix)
+}
+
+// isUNC path是否为Windows网络共享路径，包括\\server\share及\\?\UNC\server\share
+func isUNC(path string) bool {
+	volume := filepath.VolumeName(path)
+	if len(volume) < 2 || !os.IsPathSeparator(volume[0]) || !os.IsPathSeparator(volume[1]) {
+		return false
+	}
+	if IsExtendedLength(path) {
+		return strings.HasPrefix(strings.ToUpper(path[len(extendedLengthPrefix):]), `UNC\`)
+	}
+	return true
+}
+
+// StripExtendedLength 去掉长路径前缀，\\?\UNC\server\share => \\server\share，\\?\C:\ => C:\
+func StripExtendedLength(path string) string {
+	if !IsExtendedLength(path) {
+		return path
+	}
+	if isUNC(path) {
+		return `\\` + path[len(extendedLengthPrefix)+len(`UNC\`):]
+	}
+	return path[len(extendedLengthPrefix):]
+}
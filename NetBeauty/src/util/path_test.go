@@ -0,0 +1,73 @@
+package util
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// pathCase Windows及其他系统下分别期望的结果
+type pathCase struct {
+	path    string
+	windows interface{}
+	other   interface{}
+}
+
+func (c pathCase) expected() interface{} {
+	if runtime.GOOS == "windows" {
+		return c.windows
+	}
+	return c.other
+}
+
+func TestIsUNC(t *testing.T) {
+	for _, c := range []pathCase{
+		{`\\server\share\app`, true, false},
+		{`//server/share/app`, true, false},
+		{`\\?\UNC\server\share\app`, true, false},
+		{`\\?\unc\server\share\app`, true, false},
+		{`\\?\C:\app`, false, false},
+		{`C:\app`, false, false},
+		{`/usr/share/app`, false, false},
+		{`app`, false, false},
+	} {
+		if got := isUNC(c.path); got != c.expected() {
+			t.Errorf("isUNC(%s) = %v, want %v", c.path, got, c.expected())
+		}
+	}
+}
+
+func TestStripExtendedLength(t *testing.T) {
+	for _, c := range []pathCase{
+		{`\\?\C:\app\a.dll`, `C:\app\a.dll`, `\\?\C:\app\a.dll`},
+		{`\\?\UNC\server\share\a.dll`, `\\server\share\a.dll`, `\\?\UNC\server\share\a.dll`},
+		{`\\server\share\a.dll`, `\\server\share\a.dll`, `\\server\share\a.dll`},
+		{`C:\app\a.dll`, `C:\app\a.dll`, `C:\app\a.dll`},
+		{`/usr/share/a.dll`, `/usr/share/a.dll`, `/usr/share/a.dll`},
+	} {
+		if got := StripExtendedLength(c.path); got != c.expected() {
+			t.Errorf("StripExtendedLength(%s) = %s, want %s", c.path, got, c.expected())
+		}
+	}
+}
+
+func TestAbsPath(t *testing.T) {
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []pathCase{
+		{`"app"`, filepath.Join(cwd, "app"), filepath.Join(cwd, "app")},
+		{`app/../lib`, filepath.Join(cwd, "lib"), filepath.Join(cwd, "lib")},
+		{`/usr/share/app/`, filepath.VolumeName(cwd) + `\usr\share\app`, `/usr/share/app`},
+		{`"//server/share/app/"`, `\\server\share\app`, `/server/share/app`},
+		{`\\server\share\app\..\lib`, `\\server\share\lib`, filepath.Join(cwd, `\\server\share\app\..\lib`)},
+		{`\\?\C:\app\..\lib`, `\\?\C:\app\..\lib`, filepath.Join(cwd, `\\?\C:\app\..\lib`)},
+		{`\\?\UNC\server\share\app`, `\\?\UNC\server\share\app`, filepath.Join(cwd, `\\?\UNC\server\share\app`)},
+	} {
+		got, err := AbsPath(c.path)
+		if err != nil || got != c.expected() {
+			t.Errorf("AbsPath(%s) = %s, %v, want %s", c.path, got, err, c.expected())
+		}
+	}
+}
@@ -1,126 +1,291 @@
-package util
-
-import (
-	"crypto/md5"
-	"encoding/hex"
-	"errors"
-	"io"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-)
-
-func PathExists(path string) bool {
-	_, err := os.Stat(path)
-	return err == nil || os.IsExist(err)
-}
-
-func EnsureDirExists(dir string, perm os.FileMode) bool {
-	if !PathExists(dir) {
-		return os.MkdirAll(dir, perm) == nil
-	} else {
-		return os.Chmod(dir, perm) == nil
-	}
-}
-
-func CopyFile(src string, des string) (written int64, err error) {
-	srcFile, err := os.Open(src)
-	if err != nil {
-		return 0, err
-	}
-	defer srcFile.Close()
-
-	fi, _ := srcFile.Stat()
-	perm := fi.Mode()
-
-	dir := filepath.Dir(des)
-	if !EnsureDirExists(dir, 0777) {
-		return 0, errors.New("cannot create path: " + dir)
-	}
-
-	desFile, err := os.OpenFile(des, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
-	if err != nil {
-		return 0, err
-	}
-	defer desFile.Close()
-
-	return io.Copy(desFile, srcFile)
-}
-
-func ReadAllDir(dir string) (paths []string, err error) {
-	fd, err := ioutil.ReadDir(dir)
-	paths = make([]string, 0)
-	if err != nil {
-		return paths, err
-	}
-	for _, fi := range fd {
-		if fi.IsDir() {
-			paths = append(paths, fi.Name())
-		}
-	}
-	return paths, nil
-}
-
-func ReadAllFile(dir string) (paths []string, err error) {
-	fd, err := ioutil.ReadDir(dir)
-	paths = make([]string, 0)
-	if err != nil {
-		return paths, err
-	}
-	for _, fi := range fd {
-		if !fi.IsDir() {
-			paths = append(paths, fi.Name())
-		}
-	}
-	return paths, nil
-}
-
-func GetAllFiles(dir string, recursive bool) []string {
-	dir = filepath.Clean(dir)
-	rd, _ := ioutil.ReadDir(dir)
-	files := make([]string, 0)
-	for _, fi := range rd {
-		absName := dir + "/" + fi.Name()
-		if fi.IsDir() {
-			if recursive {
-				files = append(files, GetAllFiles(absName, recursive)...)
-			}
-		} else {
-			files = append(files, absName)
-		}
-	}
-	return files
-}
-
-func GetFileMD5(file string) (string, error) {
-	hash := md5.New()
-
-	handle, error := os.Open(file)
-
-	defer handle.Close()
-
-	if error != nil {
-		return "", error
-	}
-
-	_, error = io.Copy(hash, handle)
-
-	if error != nil {
-		return "", error
-	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
-
-func GetStringMD5(str string) (string, error) {
-	bytes := []byte(str)
-	hash := md5.New()
-
-	_, error := hash.Write(bytes)
-
-	if error != nil {
-		return "", error
-	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
-}
+package util
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	misc "github.com/nulastudio/NetBeauty/src/misc"
+)
+
+// HashAlgorithm 文件校验所使用的hash算法（sha256/sha512）
+var HashAlgorithm = "sha256"
+
+// Durable 为true时移动及写入文件后将文件及所在目录写入磁盘（fsync），用于断电后可能丢失重命名的网络共享等
+var Durable = false
+
+// ProcessUmask 启动时进程的umask，启动后umask被清零，由DirMode、FileMode决定新建目录及文件的权限
+var ProcessUmask os.FileMode
+
+// DirMode 发布目录中新建目录的权限
+var DirMode os.FileMode = 0777
+
+// FileMode 发布目录中新建文件的权限
+var FileMode os.FileMode = 0666
+
+// CacheDirMode、CacheFileMode 缓存目录中新建目录及文件的权限，不受--dir-mode、--file-mode影响
+var CacheDirMode, CacheFileMode os.FileMode = 0777, 0666
+
+// ResetModes 恢复新建目录及文件的默认权限，即0777、0666去掉启动时的umask
+func ResetModes() {
+	DirMode, FileMode = 0777&^ProcessUmask, 0666&^ProcessUmask
+	CacheDirMode, CacheFileMode = DirMode, FileMode
+}
+
+// WaitForUnlock 文件被占用（如应用正在运行）时最多等待的时间，为0时只短暂重试
+var WaitForUnlock time.Duration
+
+// 杀毒软件、索引服务可能会短暂占用刚发布的文件，重试时间依次翻倍
+var lockRetryCount = 6
+var lockRetryDelay = 50 * time.Millisecond
+
+// PathWithin file是否在dir之内（不含dir本身），只按路径比较，不解析符号链接
+func PathWithin(dir string, file string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absFile)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func PathExists(path string) bool {
+	_, err := FileSystem.Stat(path)
+	return err == nil || os.IsExist(err)
+}
+
+// EnsureDirExists 目录不存在时以perm创建，已存在的目录保持原有的权限
+func EnsureDirExists(dir string, perm os.FileMode) bool {
+	if PathExists(dir) {
+		return true
+	}
+	return FileSystem.MkdirAll(dir, perm) == nil
+}
+
+func CopyFile(src string, des string) (written int64, err error) {
+	if err := CheckWrite("copy to", des); err != nil {
+		return 0, err
+	}
+	if !LocalDisk() {
+		dir := filepath.Dir(des)
+		if !EnsureDirExists(dir, DirMode) {
+			return 0, errors.New("cannot create path: " + dir)
+		}
+		return copyWithin(src, des)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer srcFile.Close()
+
+	fi, _ := srcFile.Stat()
+	perm := fi.Mode()
+
+	dir := filepath.Dir(des)
+	if !EnsureDirExists(dir, DirMode) {
+		return 0, errors.New("cannot create path: " + dir)
+	}
+
+	// btrfs/XFS/APFS等支持写时复制的文件系统直接克隆，失败则退化为普通复制
+	if err := misc.CloneFile(src, des, perm); err == nil {
+		misc.CopyFileSecurity(src, des)
+		return fi.Size(), Sync(des)
+	}
+
+	desFile, err := os.OpenFile(des, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+	defer desFile.Close()
+
+	written, err = io.Copy(desFile, srcFile)
+	if err != nil {
+		return written, err
+	}
+	if Durable {
+		if err := desFile.Sync(); err != nil {
+			return written, err
+		}
+	}
+
+	// Windows下保留ACL及只读、隐藏等属性
+	misc.CopyFileSecurity(src, des)
+
+	if Durable {
+		return written, misc.SyncDir(dir)
+	}
+	return written, nil
+}
+
+func MoveFile(src string, des string) error {
+	return FileSystem.Rename(src, des)
+}
+
+// moveAcrossDevice OSFS.Rename跨分区时以复制+校验+删除代替，只用于本地磁盘
+func moveAcrossDevice(src string, des string) error {
+	// 符号链接只移动链接本身
+	if lfi, err := os.Lstat(src); err == nil && lfi.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Symlink(link, des); err != nil {
+			return err
+		}
+		return os.Remove(src)
+	}
+
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if _, err := CopyFile(src, des); err != nil {
+		os.Remove(des)
+		return err
+	}
+
+	srcHash, err := GetFileHash(src)
+	if err != nil {
+		os.Remove(des)
+		return err
+	}
+	desHash, err := GetFileHash(des)
+	if err != nil || srcHash != desHash {
+		os.Remove(des)
+		return errors.New("checksum mismatch after copying " + src + " to " + des)
+	}
+
+	os.Chtimes(des, fi.ModTime(), fi.ModTime())
+
+	return os.Remove(src)
+}
+
+func ReadAllDir(dir string) (paths []string, err error) {
+	fd, err := FileSystem.ReadDir(dir)
+	paths = make([]string, 0)
+	if err != nil {
+		return paths, err
+	}
+	for _, fi := range fd {
+		if fi.IsDir() {
+			paths = append(paths, fi.Name())
+		}
+	}
+	return paths, nil
+}
+
+func ReadAllFile(dir string) (paths []string, err error) {
+	fd, err := FileSystem.ReadDir(dir)
+	paths = make([]string, 0)
+	if err != nil {
+		return paths, err
+	}
+	for _, fi := range fd {
+		if !fi.IsDir() {
+			paths = append(paths, fi.Name())
+		}
+	}
+	return paths, nil
+}
+
+func GetAllFiles(dir string, recursive bool) []string {
+	dir = filepath.Clean(dir)
+	rd, _ := FileSystem.ReadDir(dir)
+	files := make([]string, 0)
+	for _, fi := range rd {
+		absName := filepath.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if recursive {
+				files = append(files, GetAllFiles(absName, recursive)...)
+			}
+		} else {
+			files = append(files, absName)
+		}
+	}
+	return files
+}
+
+func GetFileMD5(file string) (string, error) {
+	hash := md5.New()
+
+	handle, error := FileSystem.Open(file)
+
+	if error != nil {
+		return "", error
+	}
+
+	defer handle.Close()
+
+	_, error = io.Copy(hash, handle)
+
+	if error != nil {
+		return "", error
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func GetFileSHA256(file string) (string, error) {
+	return getFileHash(file, sha256.New())
+}
+
+func GetFileSHA512(file string) (string, error) {
+	return getFileHash(file, sha512.New())
+}
+
+func GetFileHash(file string) (string, error) {
+	return GetFileHashWith(file, HashAlgorithm)
+}
+
+func GetFileHashWith(file string, algorithm string) (string, error) {
+	switch algorithm {
+	case "sha256":
+		return GetFileSHA256(file)
+	case "sha512":
+		return GetFileSHA512(file)
+	}
+	return "", fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+}
+
+func getFileHash(file string, hash hash.Hash) (string, error) {
+	handle, err := FileSystem.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer handle.Close()
+
+	if _, err := io.Copy(hash, handle); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func GetStringMD5(str string) (string, error) {
+	bytes := []byte(str)
+	hash := md5.New()
+
+	_, error := hash.Write(bytes)
+
+	if error != nil {
+		return "", error
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -14,8 +14,11 @@ namespace NetCoreBeautyGlobalTool
         private static readonly Dictionary<string, string> platform = new Dictionary<string, string> {
             ["win-x86"]   = "/nbeauty/win-x86/nbeauty2.exe",
             ["win-x64"]   = "/nbeauty/win-x64/nbeauty2.exe",
+            ["win-arm64"] = "/nbeauty/win-arm64/nbeauty2.exe",
             ["linux-x64"] = "/nbeauty/linux-x64/nbeauty2",
             ["osx-x64"]   = "/nbeauty/osx-x64/nbeauty2",
+            ["linux-arm64"] = "/nbeauty/linux-arm64/nbeauty2",
+            ["osx-arm64"] = "/nbeauty/osx-arm64/nbeauty2",
         };
 
         static void Main(string[] args)
@@ -24,15 +27,15 @@ namespace NetCoreBeautyGlobalTool
             var nbeautyBin = "";
             if (RuntimeInformation.IsOSPlatform(OSPlatform.Windows))
             {
-                nbeautyBin = platform["win-x86"];
+                nbeautyBin = RuntimeInformation.OSArchitecture == Architecture.Arm64 ? platform["win-arm64"] : platform["win-x86"];
             }
             else if (RuntimeInformation.IsOSPlatform(OSPlatform.Linux))
             {
-                nbeautyBin = platform["linux-x64"];
+                nbeautyBin = RuntimeInformation.OSArchitecture == Architecture.Arm64 ? platform["linux-arm64"] : platform["linux-x64"];
             }
             else if (RuntimeInformation.IsOSPlatform(OSPlatform.OSX))
             {
-                nbeautyBin = platform["osx-x64"];
+                nbeautyBin = RuntimeInformation.OSArchitecture == Architecture.Arm64 ? platform["osx-arm64"] : platform["osx-x64"];
             }
             var psi = new ProcessStartInfo(rootDir + nbeautyBin)
             {